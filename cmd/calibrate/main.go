@@ -0,0 +1,113 @@
+// Command calibrate fits the estimator parameters against real clears and writes a candidate
+// parameter file for review. A reviewed file is adopted by pointing server.estimator_params_file
+// at it; compare the estimator versions first, as it changes the scores of stored records.
+//
+//	go run ./cmd/calibrate -input records.json -out params.candidate.json
+//	go run ./cmd/calibrate -spreadsheet <id> -sheet 轨道 -out params.candidate.json
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"os"
+	"sort"
+	"strings"
+
+	"github.com/sirupsen/logrus"
+
+	"lysk-battle-record/internal/estimator/calibration"
+	"lysk-battle-record/internal/models"
+	"lysk-battle-record/internal/registry"
+	"lysk-battle-record/internal/sheet_clients"
+)
+
+func main() {
+	opts := calibration.DefaultOptions()
+
+	input := flag.String("input", "", "JSON file with an array of records")
+	spreadsheet := flag.String("spreadsheet", "", "spreadsheet to read the records from instead of -input")
+	sheets := flag.String("sheet", "轨道,锦标赛", "comma separated sheets to read from the spreadsheet")
+	start := flag.String("params", "", "parameter file to start from instead of the defaults")
+	out := flag.String("out", "params.candidate.json", "where to write the candidate parameter file")
+	flag.IntVar(&opts.MinLevelRecords, "min-level-records", opts.MinLevelRecords, "levels with fewer records are ignored")
+	flag.IntVar(&opts.MinSupport, "min-support", opts.MinSupport, "parameters affecting fewer records are not fitted")
+	flag.IntVar(&opts.Steps, "steps", opts.Steps, "grid points per parameter")
+	flag.IntVar(&opts.Rounds, "rounds", opts.Rounds, "coordinate descent rounds for the global parameters")
+	flag.Float64Var(&opts.MinSensitivity, "min-sensitivity", opts.MinSensitivity, "relative dispersion change below which a parameter is not fitted")
+	flag.Parse()
+
+	records, err := loadRecords(*input, *spreadsheet, *sheets)
+	if err != nil {
+		logrus.Fatalf("[Calibration] Failed to load records: %v", err)
+	}
+
+	if *start != "" {
+		params, err := registry.LoadParams(*start)
+		if err != nil {
+			logrus.Fatalf("[Calibration] Failed to load %s: %v", *start, err)
+		}
+		registry.SetParams(params)
+	}
+
+	result := calibration.Calibrate(records, opts)
+	printReport(result)
+
+	if err := registry.WriteParams(*out, result.Candidate()); err != nil {
+		logrus.Fatalf("[Calibration] Failed to write %s: %v", *out, err)
+	}
+	fmt.Printf("\ncandidate parameters written to %s (set server.estimator_params_file to adopt it)\n", *out)
+}
+
+func loadRecords(input, spreadsheet, sheets string) ([]models.Record, error) {
+	if input != "" {
+		data, err := os.ReadFile(input)
+		if err != nil {
+			return nil, err
+		}
+		var records []models.Record
+		err = json.Unmarshal(data, &records)
+		return records, err
+	}
+
+	if spreadsheet == "" {
+		return nil, fmt.Errorf("either -input or -spreadsheet is required")
+	}
+
+	var records []models.Record
+	for _, sheet := range strings.Split(sheets, ",") {
+		sheetRecords, err := sheet_clients.NewRecordSheetClient(spreadsheet, sheet).FetchAllSheetData(context.Background())
+		if err != nil {
+			return nil, fmt.Errorf("sheet %s: %w", sheet, err)
+		}
+		records = append(records, sheetRecords...)
+	}
+	return records, nil
+}
+
+func printReport(result calibration.Result) {
+	fmt.Printf("%d records in %d levels\n", result.Records, result.Levels)
+	fmt.Printf("dispersion of ln(CP) within a level: %.4f -> %.4f\n\n", result.DispersionBefore, result.DispersionAfter)
+
+	fmt.Println("global:")
+	printParams(result.Global)
+
+	sort.Slice(result.Companions, func(i, j int) bool {
+		return result.Companions[i].Records > result.Companions[j].Records
+	})
+	for _, companion := range result.Companions {
+		fmt.Printf("\n%s (%d records):\n", companion.Companion, companion.Records)
+		printParams(companion.Params)
+	}
+}
+
+func printParams(params []calibration.ParamReport) {
+	for _, p := range params {
+		if p.Status == calibration.StatusNotSupported {
+			continue
+		}
+		fmt.Printf("  %-22s %8.3f -> %8.3f  support %5d  sensitivity %6.2f%%  %s\n",
+			p.Name, p.Default, p.Fitted, p.Support, p.Sensitivity*100, p.Status)
+	}
+}
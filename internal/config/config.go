@@ -0,0 +1,190 @@
+package config
+
+import (
+	"errors"
+	"fmt"
+	"net"
+	"time"
+
+	"lysk-battle-record/internal/pkg"
+)
+
+// Config is every setting of the server. See Load for where the values come from.
+type Config struct {
+	Profile string `json:"-"`
+
+	Server        Server        `json:"server"`
+	Sheets        Sheets        `json:"sheets"`
+	Championships Championships `json:"championships"`
+	Auth          Auth          `json:"auth"`
+	RateLimits    RateLimits    `json:"rate_limits"`
+}
+
+type Server struct {
+	HTTPPort                int           `json:"http_port" env:"PORT" flag:"http-port"`
+	GRPCPort                int           `json:"grpc_port" env:"GRPC_PORT" flag:"grpc-port"`
+	RequestTimeout          time.Duration `json:"request_timeout" env:"REQUEST_TIMEOUT" flag:"request-timeout"`
+	AllowOrigins            []string      `json:"allow_origins" env:"CORS_ALLOW_ORIGINS"`
+	TrustedPlatform         string        `json:"trusted_platform" env:"TRUSTED_PLATFORM"` // appengine / cloudflare / flyio，其请求头给出客户端 IP
+	TrustedProxies          []string      `json:"trusted_proxies" env:"TRUSTED_PROXIES"`   // 可信代理的 IP 或 CIDR，为空时只用连接的 IP
+	ResponseCacheMaxAge     time.Duration `json:"response_cache_max_age" env:"RESPONSE_CACHE_MAX_AGE"`
+	ReadyMaxMissedRefreshes int           `json:"ready_max_missed_refreshes" env:"READY_MAX_MISSED_REFRESHES"`
+	TracesExporter          string        `json:"traces_exporter" env:"OTEL_TRACES_EXPORTER"` // none / stdout / otlp
+	GameContentFile         string        `json:"game_content_file" env:"GAME_CONTENT_FILE"`
+	EstimatorVersion        string        `json:"estimator_version" env:"CP_ESTIMATOR_VERSION"`         // 新记录使用的估算版本
+	EstimatorParamsFile     string        `json:"estimator_params_file" env:"CP_ESTIMATOR_PARAMS_FILE"` // 校准后的估算参数文件
+}
+
+type Sheets struct {
+	SpreadsheetID   string        `json:"spreadsheet_id" env:"SPREADSHEET_ID" flag:"spreadsheet-id"`
+	Orbit           string        `json:"orbit" env:"SHEET_ORBIT"`
+	Championships   string        `json:"championships" env:"SHEET_CHAMPIONSHIPS"`
+	Users           string        `json:"users" env:"SHEET_USERS"`
+	Deletions       string        `json:"deletions" env:"SHEET_DELETIONS"`
+	Identities      string        `json:"identities" env:"SHEET_IDENTITIES"`
+	Revocations     string        `json:"revocations" env:"SHEET_REVOCATIONS"`
+	RefreshInterval time.Duration `json:"refresh_interval" env:"SHEET_REFRESH_INTERVAL" flag:"refresh-interval"`
+}
+
+type Championships struct {
+	Epoch       time.Time     `json:"epoch" env:"CHAMPIONSHIPS_EPOCH"` // 第一轮开始时间，RFC 3339
+	RoundLength time.Duration `json:"round_length" env:"CHAMPIONSHIPS_ROUND_LENGTH"`
+}
+
+type Auth struct {
+	JWTSecret          string   `json:"jwt_secret" env:"JWT_SECRET"`
+	PreviousJWTSecrets []string `json:"previous_jwt_secrets" env:"JWT_PREVIOUS_SECRETS"`
+	PseudonymSecret    string   `json:"pseudonym_secret" env:"PSEUDONYM_SECRET"` // 生产环境必填，其他配置档为空时使用 JWTSecret
+	AdminUserIDs       []string `json:"admin_user_ids" env:"ADMIN_USER_IDS"`
+	WeChatAppID        string   `json:"wechat_appid" env:"WECHAT_APPID"`
+	WeChatAppSecret    string   `json:"wechat_appsecret" env:"WECHAT_APPSECRET"`
+	OIDCClientID       string   `json:"oidc_client_id" env:"OIDC_CLIENT_ID"`
+	OIDCClientSecret   string   `json:"oidc_client_secret" env:"OIDC_CLIENT_SECRET"`
+	OIDCTokenURL       string   `json:"oidc_token_url" env:"OIDC_TOKEN_URL"` // 为空时不启用 OIDC 登录
+	OIDCUserinfoURL    string   `json:"oidc_userinfo_url" env:"OIDC_USERINFO_URL"`
+	MagicLinkURL       string   `json:"magic_link_url" env:"MAGIC_LINK_URL"`
+	Mailer             string   `json:"mailer" env:"MAILER"`       // smtp / log，为空时不启用邮箱登录
+	SMTPAddr           string   `json:"smtp_addr" env:"SMTP_ADDR"` // host:port
+	SMTPUsername       string   `json:"smtp_username" env:"SMTP_USERNAME"`
+	SMTPPassword       string   `json:"smtp_password" env:"SMTP_PASSWORD"`
+	MailFrom           string   `json:"mail_from" env:"MAIL_FROM"`
+}
+
+type RateLimits struct {
+	Read    pkg.RateLimit `json:"read" env:"RATE_LIMIT_READ"`
+	Analyze pkg.RateLimit `json:"analyze" env:"RATE_LIMIT_ANALYZE"`
+	Write   pkg.RateLimit `json:"write" env:"RATE_LIMIT_WRITE"`
+}
+
+// Default is the configuration before any profile, file, environment variable or flag.
+func Default() Config {
+	shanghai := time.FixedZone("CST", 8*60*60)
+	return Config{
+		Server: Server{
+			HTTPPort:                8080,
+			GRPCPort:                9090,
+			RequestTimeout:          5 * time.Second,
+			AllowOrigins:            []string{"*"},
+			ResponseCacheMaxAge:     time.Minute,
+			ReadyMaxMissedRefreshes: 2,
+			TracesExporter:          "none",
+		},
+		Sheets: Sheets{
+			SpreadsheetID:   "1-ORnXBnav4JVtP673Oio5sNdVpk0taUSzG3kWZqhIuY",
+			Orbit:           "轨道",
+			Championships:   "锦标赛",
+			Users:           "用户",
+			Deletions:       "注销请求",
+			Identities:      "身份",
+			Revocations:     "吊销",
+			RefreshInterval: 5 * time.Minute,
+		},
+		Championships: Championships{
+			Epoch:       time.Date(2025, time.June, 2, 0, 0, 0, 0, shanghai),
+			RoundLength: 14 * 24 * time.Hour,
+		},
+		RateLimits: RateLimits{
+			Read:    pkg.RateLimit{Burst: 120, Rate: 2},
+			Analyze: pkg.RateLimit{Burst: 10, Rate: 10.0 / 60},
+			Write:   pkg.RateLimit{Burst: 20, Rate: 20.0 / 60},
+		},
+	}
+}
+
+// Validate reports every invalid setting at once, so a bad deploy fails at startup with the
+// whole list.
+func (c Config) Validate() error {
+	var errs []error
+	check := func(ok bool, format string, args ...interface{}) {
+		if !ok {
+			errs = append(errs, fmt.Errorf(format, args...))
+		}
+	}
+
+	validPort := func(port int) bool { return port > 0 && port < 65536 }
+	check(validPort(c.Server.HTTPPort), "server.http_port %d is not a port", c.Server.HTTPPort)
+	check(validPort(c.Server.GRPCPort), "server.grpc_port %d is not a port", c.Server.GRPCPort)
+	check(c.Server.HTTPPort != c.Server.GRPCPort, "server.http_port and server.grpc_port are both %d", c.Server.HTTPPort)
+	check(c.Server.RequestTimeout > 0, "server.request_timeout must be positive")
+	check(len(c.Server.AllowOrigins) > 0, "server.allow_origins is empty")
+	check(c.Server.ResponseCacheMaxAge >= 0, "server.response_cache_max_age is negative")
+	check(c.Server.ReadyMaxMissedRefreshes >= 0, "server.ready_max_missed_refreshes is negative")
+	check(pkg.IsTrustedPlatform(c.Server.TrustedPlatform), "server.trusted_platform %q must be appengine, cloudflare, flyio or empty", c.Server.TrustedPlatform)
+	for _, proxy := range c.Server.TrustedProxies {
+		_, _, cidrErr := net.ParseCIDR(proxy)
+		check(cidrErr == nil || net.ParseIP(proxy) != nil, "server.trusted_proxies %q is not an IP or CIDR", proxy)
+	}
+	switch c.Server.TracesExporter {
+	case "none", "stdout", "otlp":
+	default:
+		check(false, "server.traces_exporter %q must be none, stdout or otlp", c.Server.TracesExporter)
+	}
+
+	check(c.Sheets.SpreadsheetID != "", "sheets.spreadsheet_id is empty")
+	sheetNames := map[string]string{}
+	for key, name := range map[string]string{
+		"orbit":         c.Sheets.Orbit,
+		"championships": c.Sheets.Championships,
+		"users":         c.Sheets.Users,
+		"deletions":     c.Sheets.Deletions,
+		"identities":    c.Sheets.Identities,
+		"revocations":   c.Sheets.Revocations,
+	} {
+		check(name != "", "sheets.%s is empty", key)
+		if other, ok := sheetNames[name]; ok && name != "" {
+			check(false, "sheets.%s and sheets.%s are both %q", other, key, name)
+		}
+		sheetNames[name] = key
+	}
+	check(c.Sheets.RefreshInterval >= 10*time.Second, "sheets.refresh_interval must be at least 10s")
+
+	check(!c.Championships.Epoch.IsZero(), "championships.epoch is not set")
+	check(c.Championships.RoundLength > 0, "championships.round_length must be positive")
+
+	// 生产环境不能用空密钥签发令牌
+	if c.Profile == "production" {
+		check(c.Auth.JWTSecret != "", "auth.jwt_secret is required in production")
+		// 公开 ID 不能随 JWT 密钥轮换而变化，也不能由它推出
+		check(c.Auth.PseudonymSecret != "", "auth.pseudonym_secret is required in production")
+		check(c.Auth.PseudonymSecret == "" || c.Auth.PseudonymSecret != c.Auth.JWTSecret, "auth.pseudonym_secret must differ from auth.jwt_secret")
+	}
+	switch c.Auth.Mailer {
+	case "":
+	case "smtp":
+		check(c.Auth.SMTPAddr != "", "auth.smtp_addr is required with the smtp mailer")
+		check(c.Auth.MailFrom != "", "auth.mail_from is required with the smtp mailer")
+		check(c.Auth.MagicLinkURL != "", "auth.magic_link_url is required with the smtp mailer")
+	case "log":
+		// 日志里的链接可以直接登录
+		check(c.Profile == "development", "auth.mailer log is only allowed in the development profile")
+	default:
+		check(false, "auth.mailer %q must be smtp, log or empty", c.Auth.Mailer)
+	}
+	check(c.Auth.OIDCTokenURL == "" || c.Auth.OIDCClientID != "", "auth.oidc_client_id is required with auth.oidc_token_url")
+
+	for key, limit := range map[string]pkg.RateLimit{"read": c.RateLimits.Read, "analyze": c.RateLimits.Analyze, "write": c.RateLimits.Write} {
+		check(limit.Burst > 0 && limit.Rate > 0, "rate_limits.%s must allow some requests", key)
+	}
+
+	return errors.Join(errs...)
+}
@@ -0,0 +1,93 @@
+package config
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestLoadLayers(t *testing.T) {
+	file := filepath.Join(t.TempDir(), "config.json")
+	err := os.WriteFile(file, []byte(`{
+		"server": {"http_port": 8000, "grpc_port": 9000, "allow_origins": ["https://a.example", "https://b.example"]},
+		"rate_limits": {"write": "5/1m"}
+	}`), 0o600)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	env := map[string]string{
+		"APP_ENV":     "development",
+		"CONFIG_FILE": file,
+		"GRPC_PORT":   "9001",
+		"PORT":        "8001",
+	}
+	c, err := Load([]string{"-http-port", "8002"}, func(key string) string { return env[key] })
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// 默认值 < 配置档 < 文件 < 环境变量 < 命令行
+	if c.Sheets.SpreadsheetID != Default().Sheets.SpreadsheetID {
+		t.Errorf("default not kept: %q", c.Sheets.SpreadsheetID)
+	}
+	if c.Server.TracesExporter != "stdout" || c.Auth.PseudonymSecret != c.Auth.JWTSecret {
+		t.Errorf("profile not applied: %+v", c.Server)
+	}
+	if len(c.Server.AllowOrigins) != 2 || c.RateLimits.Write.Burst != 5 {
+		t.Errorf("file not applied: %v %+v", c.Server.AllowOrigins, c.RateLimits.Write)
+	}
+	if c.Server.GRPCPort != 9001 {
+		t.Errorf("env not applied: %d", c.Server.GRPCPort)
+	}
+	if c.Server.HTTPPort != 8002 {
+		t.Errorf("flag not applied: %d", c.Server.HTTPPort)
+	}
+}
+
+func TestLoadErrors(t *testing.T) {
+	tests := []struct {
+		name string
+		args []string
+		env  map[string]string
+		want string
+	}{
+		{"production without secret", nil, nil, "auth.jwt_secret"},
+		{"production without pseudonym secret", nil, map[string]string{"JWT_SECRET": "s"}, "auth.pseudonym_secret"},
+		{"production reusing the jwt secret", nil, map[string]string{"JWT_SECRET": "s", "PSEUDONYM_SECRET": "s"}, "auth.pseudonym_secret must differ"},
+		{"unknown profile", []string{"-profile", "staging"}, nil, "unknown profile"},
+		{"invalid env", nil, map[string]string{"JWT_SECRET": "s", "REQUEST_TIMEOUT": "soon"}, "REQUEST_TIMEOUT"},
+		{"same ports", []string{"-grpc-port", "8080"}, map[string]string{"JWT_SECRET": "s"}, "both 8080"},
+		{"short refresh", []string{"-refresh-interval", "1s"}, map[string]string{"JWT_SECRET": "s"}, "refresh_interval"},
+		{"log mailer in production", nil, map[string]string{"JWT_SECRET": "s", "MAILER": "log"}, "auth.mailer"},
+		{"smtp mailer without server", nil, map[string]string{"JWT_SECRET": "s", "MAILER": "smtp"}, "auth.smtp_addr"},
+		{"bad proxy", nil, map[string]string{"JWT_SECRET": "s", "TRUSTED_PROXIES": "10.0.0.0/8, lb"}, "server.trusted_proxies"},
+		{"unknown flag", []string{"-port", "1"}, nil, "port"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := Load(tt.args, func(key string) string { return tt.env[key] })
+			if err == nil || !strings.Contains(err.Error(), tt.want) {
+				t.Errorf("expected an error about %s, got %v", tt.want, err)
+			}
+		})
+	}
+
+	file := filepath.Join(t.TempDir(), "config.json")
+	if err := os.WriteFile(file, []byte(`{"server": {"http_prot": 1}}`), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	_, err := Load([]string{"-config", file, "-profile", "development"}, func(string) string { return "" })
+	if err == nil || !strings.Contains(err.Error(), "server.http_prot") {
+		t.Errorf("expected the unknown setting, got %v", err)
+	}
+}
+
+func TestDefaultEpoch(t *testing.T) {
+	c := Default()
+	if want := time.Date(2025, time.June, 1, 16, 0, 0, 0, time.UTC); !c.Championships.Epoch.Equal(want) {
+		t.Errorf("unexpected epoch %v", c.Championships.Epoch)
+	}
+}
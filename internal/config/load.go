@@ -0,0 +1,237 @@
+package config
+
+import (
+	"bytes"
+	"embed"
+	"encoding"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"os"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+//go:embed profiles/*.json
+var profiles embed.FS
+
+// Load builds the configuration in layers, each overriding the one before:
+//
+//  1. Default
+//  2. the profile, chosen by -profile or APP_ENV, production by default
+//  3. the JSON file named by -config or CONFIG_FILE, if any
+//  4. environment variables, named by the env tags
+//  5. command line flags, named by the flag tags
+//
+// and then validates it.
+func Load(args []string, getenv func(string) string) (*Config, error) {
+	c := Default()
+	settings := settingsOf(&c)
+
+	flags := flag.NewFlagSet("lysk-battle-record", flag.ContinueOnError)
+	profile := flags.String("profile", "", "configuration profile, development or production (APP_ENV)")
+	file := flags.String("config", "", "JSON configuration file (CONFIG_FILE)")
+	flagValues := map[string]string{}
+	for _, s := range settings {
+		if s.flag == "" {
+			continue
+		}
+		s := s
+		flags.Func(s.flag, fmt.Sprintf("%s (%s)", s.path, s.env), func(value string) error {
+			flagValues[s.path] = value
+			return nil
+		})
+	}
+	if err := flags.Parse(args); err != nil {
+		return nil, err
+	}
+
+	c.Profile = firstNonEmpty(*profile, getenv("APP_ENV"), "production")
+	data, err := profiles.ReadFile("profiles/" + c.Profile + ".json")
+	if err != nil {
+		return nil, fmt.Errorf("unknown profile %q", c.Profile)
+	}
+	if err := applyJSON(settings, data); err != nil {
+		return nil, fmt.Errorf("profile %s: %w", c.Profile, err)
+	}
+
+	if path := firstNonEmpty(*file, getenv("CONFIG_FILE")); path != "" {
+		data, err := os.ReadFile(path)
+		if err != nil {
+			return nil, err
+		}
+		if err := applyJSON(settings, data); err != nil {
+			return nil, fmt.Errorf("%s: %w", path, err)
+		}
+	}
+
+	for _, s := range settings {
+		if value := getenv(s.env); value != "" {
+			if err := s.set(value); err != nil {
+				return nil, fmt.Errorf("%s: %w", s.env, err)
+			}
+		}
+	}
+
+	for _, s := range settings {
+		if value, ok := flagValues[s.path]; ok {
+			if err := s.set(value); err != nil {
+				return nil, fmt.Errorf("-%s: %w", s.flag, err)
+			}
+		}
+	}
+
+	if c.Auth.PseudonymSecret == "" && c.Profile != "production" {
+		c.Auth.PseudonymSecret = c.Auth.JWTSecret
+	}
+
+	if err := c.Validate(); err != nil {
+		return nil, err
+	}
+	return &c, nil
+}
+
+// setting is a leaf field of the Config.
+type setting struct {
+	path  string // JSON 路径，如 server.http_port
+	env   string
+	flag  string
+	value reflect.Value
+}
+
+func settingsOf(c *Config) []setting {
+	var settings []setting
+	var walk func(prefix string, v reflect.Value)
+	walk = func(prefix string, v reflect.Value) {
+		t := v.Type()
+		for i := 0; i < t.NumField(); i++ {
+			field := t.Field(i)
+			name := field.Tag.Get("json")
+			if name == "-" {
+				continue
+			}
+			if field.Tag.Get("env") == "" {
+				walk(prefix+name+".", v.Field(i))
+				continue
+			}
+			settings = append(settings, setting{
+				path:  prefix + name,
+				env:   field.Tag.Get("env"),
+				flag:  field.Tag.Get("flag"),
+				value: v.Field(i),
+			})
+		}
+	}
+	walk("", reflect.ValueOf(c).Elem())
+	return settings
+}
+
+var durationType = reflect.TypeOf(time.Duration(0))
+
+// set parses the value the same way whether it comes from a file, the environment or a flag.
+// Lists are comma separated.
+func (s setting) set(value string) error {
+	if unmarshaler, ok := s.value.Addr().Interface().(encoding.TextUnmarshaler); ok {
+		return unmarshaler.UnmarshalText([]byte(value))
+	}
+
+	switch {
+	case s.value.Type() == durationType:
+		d, err := time.ParseDuration(value)
+		if err != nil {
+			return err
+		}
+		s.value.SetInt(int64(d))
+	case s.value.Kind() == reflect.String:
+		s.value.SetString(value)
+	case s.value.Kind() == reflect.Int:
+		n, err := strconv.Atoi(value)
+		if err != nil {
+			return err
+		}
+		s.value.SetInt(int64(n))
+	case s.value.Kind() == reflect.Slice && s.value.Type().Elem().Kind() == reflect.String:
+		var items []string
+		for _, item := range strings.Split(value, ",") {
+			if item = strings.TrimSpace(item); item != "" {
+				items = append(items, item)
+			}
+		}
+		s.value.Set(reflect.ValueOf(items))
+	default:
+		return fmt.Errorf("unsupported setting type %v", s.value.Type())
+	}
+	return nil
+}
+
+// applyJSON sets the settings found in a JSON document. Unknown keys are errors, so a typo does
+// not silently keep the default.
+func applyJSON(settings []setting, data []byte) error {
+	decoder := json.NewDecoder(bytes.NewReader(data))
+	decoder.UseNumber()
+	var document map[string]interface{}
+	if err := decoder.Decode(&document); err != nil {
+		return err
+	}
+
+	values := map[string]string{}
+	if err := flatten("", document, values); err != nil {
+		return err
+	}
+
+	byPath := map[string]setting{}
+	for _, s := range settings {
+		byPath[s.path] = s
+	}
+	var unknown []string
+	for path, value := range values {
+		s, ok := byPath[path]
+		if !ok {
+			unknown = append(unknown, path)
+			continue
+		}
+		if err := s.set(value); err != nil {
+			return fmt.Errorf("%s: %w", path, err)
+		}
+	}
+	if len(unknown) > 0 {
+		sort.Strings(unknown)
+		return fmt.Errorf("unknown settings %s", strings.Join(unknown, ", "))
+	}
+	return nil
+}
+
+func flatten(prefix string, document map[string]interface{}, values map[string]string) error {
+	for key, value := range document {
+		switch value := value.(type) {
+		case map[string]interface{}:
+			if err := flatten(prefix+key+".", value, values); err != nil {
+				return err
+			}
+		case []interface{}:
+			items := make([]string, len(value))
+			for i, item := range value {
+				items[i] = fmt.Sprint(item)
+			}
+			values[prefix+key] = strings.Join(items, ",")
+		case nil:
+			return errors.New(prefix + key + " is null")
+		default:
+			values[prefix+key] = fmt.Sprint(value)
+		}
+	}
+	return nil
+}
+
+func firstNonEmpty(values ...string) string {
+	for _, value := range values {
+		if value != "" {
+			return value
+		}
+	}
+	return ""
+}
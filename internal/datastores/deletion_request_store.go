@@ -0,0 +1,104 @@
+package datastores
+
+import (
+	"errors"
+	"sync"
+	"time"
+
+	"github.com/sirupsen/logrus"
+
+	"lysk-battle-record/internal/models"
+	"lysk-battle-record/internal/sheet_clients"
+)
+
+type DeletionRequestStore interface {
+	GetAll() []models.DeletionRequest
+	GetPending() []models.DeletionRequest
+	GetLatestByPublicID(publicId string) (models.DeletionRequest, bool)
+	Insert(request models.DeletionRequest)
+	Update(request models.DeletionRequest) error
+}
+
+type InMemoryDeletionRequestStore struct {
+	refreshState
+
+	mu          sync.RWMutex
+	requests    []models.DeletionRequest
+	sheetClient sheet_clients.DeletionSheetClient
+}
+
+func NewInMemoryDeletionRequestStore(sheetClient sheet_clients.DeletionSheetClient, refreshInterval time.Duration) *InMemoryDeletionRequestStore {
+	store := &InMemoryDeletionRequestStore{
+		refreshState: refreshState{interval: refreshInterval},
+		sheetClient:  sheetClient,
+	}
+	go store.every(store.refresh)
+	return store
+}
+
+func (s *InMemoryDeletionRequestStore) refresh() {
+	ctx, done := s.startRefresh(s.sheetClient.GetType())
+	data, err := s.sheetClient.FetchAllSheetData(ctx)
+	defer func() { done(err) }()
+	if err != nil {
+		logrus.Errorf("failed to refresh cache for sheet: %s with error %v", s.sheetClient.GetType(), err)
+		return
+	}
+
+	s.mu.Lock()
+	s.requests = data
+	s.mu.Unlock()
+
+	logrus.Infof("sheet %s refreshed %d deletion requests", s.sheetClient.GetType(), len(data))
+}
+
+func (s *InMemoryDeletionRequestStore) GetAll() []models.DeletionRequest {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return append([]models.DeletionRequest(nil), s.requests...)
+}
+
+func (s *InMemoryDeletionRequestStore) GetPending() []models.DeletionRequest {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	pending := []models.DeletionRequest{}
+	for _, r := range s.requests {
+		if r.Status == models.DeletionStatusPending {
+			pending = append(pending, r)
+		}
+	}
+	return pending
+}
+
+// GetLatestByPublicID looks requests up by public ID, since the OpenID is erased once a
+// request is completed.
+func (s *InMemoryDeletionRequestStore) GetLatestByPublicID(publicId string) (models.DeletionRequest, bool) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	for i := len(s.requests) - 1; i >= 0; i-- {
+		if s.requests[i].PublicID == publicId {
+			return s.requests[i], true
+		}
+	}
+	return models.DeletionRequest{}, false
+}
+
+func (s *InMemoryDeletionRequestStore) Insert(request models.DeletionRequest) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.requests = append(s.requests, request)
+}
+
+func (s *InMemoryDeletionRequestStore) Update(request models.DeletionRequest) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for i, r := range s.requests {
+		if r.ID == request.ID {
+			s.requests[i] = request
+			return nil
+		}
+	}
+	return errors.New("deletion request not found")
+}
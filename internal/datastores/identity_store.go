@@ -0,0 +1,119 @@
+package datastores
+
+import (
+	"errors"
+	"sync"
+	"time"
+
+	"github.com/sirupsen/logrus"
+
+	"lysk-battle-record/internal/models"
+	"lysk-battle-record/internal/pkg"
+	"lysk-battle-record/internal/sheet_clients"
+)
+
+type IdentityStore interface {
+	Get(provider, subject string) (models.Identity, bool)
+	GetByUser(userId string) []models.Identity
+	GetPasswordHash(email string) (string, bool)
+	Insert(identity models.Identity)
+	Update(identity models.Identity) error
+	Delete(identity models.Identity) error
+}
+
+type InMemoryIdentityStore struct {
+	refreshState
+
+	mu          sync.RWMutex
+	identities  []models.Identity
+	sheetClient sheet_clients.IdentitySheetClient
+}
+
+func NewInMemoryIdentityStore(sheetClient sheet_clients.IdentitySheetClient, refreshInterval time.Duration) *InMemoryIdentityStore {
+	store := &InMemoryIdentityStore{
+		refreshState: refreshState{interval: refreshInterval},
+		sheetClient:  sheetClient,
+	}
+	go store.every(store.refresh)
+	return store
+}
+
+func (s *InMemoryIdentityStore) refresh() {
+	ctx, done := s.startRefresh(s.sheetClient.GetType())
+	data, err := s.sheetClient.FetchAllSheetData(ctx)
+	defer func() { done(err) }()
+	if err != nil {
+		logrus.Errorf("failed to refresh cache for sheet: %s with error %v", s.sheetClient.GetType(), err)
+		return
+	}
+
+	s.mu.Lock()
+	s.identities = data
+	s.mu.Unlock()
+
+	logrus.Infof("sheet %s refreshed %d identities", s.sheetClient.GetType(), len(data))
+}
+
+func (s *InMemoryIdentityStore) Get(provider, subject string) (models.Identity, bool) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	for _, identity := range s.identities {
+		if identity.Provider == provider && identity.Subject == subject {
+			return identity, true
+		}
+	}
+	return models.Identity{}, false
+}
+
+func (s *InMemoryIdentityStore) GetByUser(userId string) []models.Identity {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	identities := []models.Identity{}
+	for _, identity := range s.identities {
+		if identity.UserID == userId {
+			identities = append(identities, identity)
+		}
+	}
+	return identities
+}
+
+// GetPasswordHash returns the password hash of a verified email identity.
+func (s *InMemoryIdentityStore) GetPasswordHash(email string) (string, bool) {
+	identity, ok := s.Get(pkg.ProviderEmail, email)
+	if !ok || identity.PasswordHash == "" || identity.Unverified {
+		return "", false
+	}
+	return identity.PasswordHash, true
+}
+
+func (s *InMemoryIdentityStore) Insert(identity models.Identity) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.identities = append(s.identities, identity)
+}
+
+func (s *InMemoryIdentityStore) Update(identity models.Identity) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for i, existing := range s.identities {
+		if existing.Provider == identity.Provider && existing.Subject == identity.Subject {
+			s.identities[i] = identity
+			return nil
+		}
+	}
+	return errors.New("identity not found")
+}
+
+func (s *InMemoryIdentityStore) Delete(identity models.Identity) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for i, existing := range s.identities {
+		if existing.Provider == identity.Provider && existing.Subject == identity.Subject {
+			s.identities = append(s.identities[:i], s.identities[i+1:]...)
+			return nil
+		}
+	}
+	return errors.New("identity not found")
+}
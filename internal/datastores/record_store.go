@@ -11,6 +11,8 @@ import (
 
 	"lysk-battle-record/internal/estimator"
 	"lysk-battle-record/internal/models"
+	"lysk-battle-record/internal/pkg"
+	"lysk-battle-record/internal/registry"
 	"lysk-battle-record/internal/sheet_clients"
 	"lysk-battle-record/internal/utils"
 )
@@ -24,25 +26,31 @@ type RecordStore interface {
 	Delete(record models.Record) error
 	PrepareInsert(record models.Record) error
 	IsDuplicate(record models.Record) bool
-	GetRanking(userId string) []models.RankingItem
+	GetContributionRanking() models.Ranking
 	EvaluateRecord(record models.Record) string
 
 	GetAllLevelRecords() map[string][]models.Record
 	GetLevelRecords(record models.Record) []models.Record
 	GetCompanionCounts() map[string]int
 	GetPartnerLevelCounts() map[string]int
+
+	// Subscribe registers a function called after every insert, update, delete and refresh.
+	Subscribe(listener func())
 }
 
 type InMemoryRecordStore struct {
+	refreshState
+
 	mu              sync.RWMutex
 	records         []models.Record
 	recordsHash     map[string]bool
 	ingestPoolHash  map[string]bool
 	sheetClient     sheet_clients.RecordSheetClient
 	cpEstimator     estimator.CombatPowerEstimator
-	ranking         []models.RankingItem
+	ranking         models.Ranking
 	levelRecords    map[string][]models.Record // New field to store records by level key
 	companionCounts map[string]int             // Cache companion counts
+	listeners       []func()                   // 记录变化时调用
 }
 
 type QueryOptions struct {
@@ -60,27 +68,23 @@ type QueryResult struct {
 	Records []models.Record `json:"records"`
 }
 
-func NewInMemoryRecordStore(sheetClient sheet_clients.RecordSheetClient, cpEstimator estimator.CombatPowerEstimator) *InMemoryRecordStore {
+func NewInMemoryRecordStore(sheetClient sheet_clients.RecordSheetClient, cpEstimator estimator.CombatPowerEstimator, refreshInterval time.Duration) *InMemoryRecordStore {
 	store := &InMemoryRecordStore{
+		refreshState:    refreshState{interval: refreshInterval},
 		sheetClient:     sheetClient,
 		cpEstimator:     cpEstimator,
 		ingestPoolHash:  make(map[string]bool),
 		levelRecords:    make(map[string][]models.Record), // Initialize the levelRecords map
 		companionCounts: make(map[string]int),             // Initialize the companionCounts map
 	}
-	go store.autoRefresh()
+	go store.every(store.refresh)
 	return store
 }
 
-func (s *InMemoryRecordStore) autoRefresh() {
-	for {
-		s.refresh()
-		time.Sleep(5 * time.Minute)
-	}
-}
-
 func (s *InMemoryRecordStore) refresh() {
-	data, err := s.sheetClient.FetchAllSheetData()
+	ctx, done := s.startRefresh(s.sheetClient.GetType())
+	data, err := s.sheetClient.FetchAllSheetData(ctx)
+	defer func() { done(err) }()
 	if err != nil {
 		logrus.Errorf("failed to refresh cache for sheet: %s with error %v", s.sheetClient.GetType(), err)
 		return
@@ -89,12 +93,12 @@ func (s *InMemoryRecordStore) refresh() {
 	contribution := map[string]int32{}
 	for i, record := range data {
 		data[i].CombatPower = s.cpEstimator.EstimateCombatPower(record)
-		if len(record.UserID) > 0 && record.UserID != "<nil>" {
+		if len(record.UserID) > 0 && record.UserID != "<nil>" && !record.Anonymous {
 			contribution[record.UserID] += 1
 		}
 	}
 
-	ranking := []models.RankingItem{}
+	ranking := models.Ranking{}
 
 	for userId, count := range contribution {
 		ranking = append(ranking, models.RankingItem{
@@ -128,6 +132,7 @@ func (s *InMemoryRecordStore) refresh() {
 	s.ranking = ranking
 	s.levelRecords = levelRecords
 	s.companionCounts = companionCounts
+	s.publishSize()
 	s.mu.Unlock()
 
 	s.recordsHash = map[string]bool{}
@@ -135,6 +140,7 @@ func (s *InMemoryRecordStore) refresh() {
 		s.ingestHash(record)
 	}
 	logrus.Infof("sheet %s refreshed %d records", s.sheetClient.GetType(), len(s.records))
+	s.notify()
 }
 
 func (s *InMemoryRecordStore) ingestHash(record models.Record) {
@@ -191,11 +197,13 @@ func (s *InMemoryRecordStore) Query(opt QueryOptions) QueryResult {
 func (s *InMemoryRecordStore) Insert(record models.Record) {
 	s.ingestHash(record)
 	record.CombatPower = s.cpEstimator.EstimateCombatPower(record)
+	defer s.notify()
 
 	s.mu.Lock()
 	defer s.mu.Unlock()
 	s.records = append(s.records, record)
 	delete(s.ingestPoolHash, record.GetHash())
+	s.publishSize()
 
 	// Update the levelRecords map and companion counts
 	if !record.Deleted {
@@ -218,9 +226,15 @@ func (s *InMemoryRecordStore) PrepareInsert(record models.Record) error {
 		return errors.New("记录已在上传准备中")
 	}
 	s.ingestPoolHash[key] = true
+	s.publishSize()
 	return nil
 }
 
+// publishSize updates the record and ingest pool gauges. The caller holds the lock.
+func (s *InMemoryRecordStore) publishSize() {
+	pkg.SetStoreSize(s.sheetClient.GetType(), len(s.records), len(s.ingestPoolHash))
+}
+
 func (s *InMemoryRecordStore) IsDuplicate(record models.Record) bool {
 	s.mu.RLock()
 	defer s.mu.RUnlock()
@@ -245,6 +259,7 @@ func (s *InMemoryRecordStore) Get(id string) (models.Record, bool) {
 func (s *InMemoryRecordStore) Update(record models.Record) error {
 	record.CombatPower = s.cpEstimator.EstimateCombatPower(record)
 	levelKey := record.GenerateLevelKey()
+	defer s.notify()
 
 	s.mu.Lock()
 	defer s.mu.Unlock()
@@ -284,6 +299,8 @@ func (s *InMemoryRecordStore) Update(record models.Record) error {
 }
 
 func (s *InMemoryRecordStore) Delete(record models.Record) error {
+	defer s.notify()
+
 	s.mu.Lock()
 	defer s.mu.Unlock()
 
@@ -318,46 +335,28 @@ func (s *InMemoryRecordStore) Delete(record models.Record) error {
 	return nil
 }
 
-func (s *InMemoryRecordStore) GetRanking(userId string) []models.RankingItem {
-	s.mu.RLock()
-	defer s.mu.RUnlock()
-
-	limit := 10
-
-	processedRanking := []models.RankingItem{}
-	rank := 1
-	for i, item := range s.ranking {
-		if i > 0 && item.Contribution < s.ranking[i-1].Contribution {
-			rank = i + 1
-		}
-		processedRanking = append(processedRanking, models.RankingItem{
-			OpenID:       item.OpenID,
-			Contribution: item.Contribution,
-			Rank:         rank,
-		})
-	}
+func (s *InMemoryRecordStore) Subscribe(listener func()) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.listeners = append(s.listeners, listener)
+}
 
-	result := []models.RankingItem{}
-	userInResult := false
-	var userRank *models.RankingItem
+// notify calls the listeners. It must be called without holding the lock.
+func (s *InMemoryRecordStore) notify() {
+	s.mu.RLock()
+	listeners := s.listeners
+	s.mu.RUnlock()
 
-	for _, item := range processedRanking {
-		if item.Rank <= limit {
-			result = append(result, item)
-			if item.OpenID == userId {
-				userInResult = true
-			}
-		}
-		if item.OpenID == userId {
-			userRank = &item
-		}
+	for _, listener := range listeners {
+		listener()
 	}
+}
 
-	if !userInResult && userRank != nil {
-		result = append(result, *userRank)
-	}
+func (s *InMemoryRecordStore) GetContributionRanking() models.Ranking {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
 
-	return result
+	return append(models.Ranking(nil), s.ranking...)
 }
 
 func (s *InMemoryRecordStore) GetAllLevelRecords() map[string][]models.Record {
@@ -573,7 +572,8 @@ func getFilters(key, value string) func(models.Record) bool {
 		case "阶数":
 			return r.Stage == value
 		case "武器":
-			return r.Weapon == value
+			// 按武器类型筛选时包含该类型的具体武器
+			return r.Weapon == value || registry.WeaponType(r.Weapon) == value
 		case "用户ID":
 			return r.UserID == value
 		default:
@@ -0,0 +1,64 @@
+package datastores
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"go.opentelemetry.io/otel/attribute"
+
+	"lysk-battle-record/internal/pkg"
+)
+
+// RefreshStatus is how the refreshes of a store from its sheet went.
+type RefreshStatus struct {
+	Interval    time.Duration // 刷新间隔
+	LastSuccess time.Time     // 最后一次成功刷新，未加载过为零值
+	LastError   error         // 最后一次刷新的错误，成功后清空
+}
+
+// Refreshable is a store loaded from its sheet in the background.
+type Refreshable interface {
+	RefreshStatus() RefreshStatus
+}
+
+// refreshState is embedded in the stores to make them Refreshable.
+type refreshState struct {
+	interval  time.Duration // 刷新间隔，创建后不变
+	refreshMu sync.Mutex
+	status    RefreshStatus
+}
+
+// every calls refresh now and then once every interval, forever.
+func (r *refreshState) every(refresh func()) {
+	for {
+		refresh()
+		time.Sleep(r.interval)
+	}
+}
+
+func (r *refreshState) RefreshStatus() RefreshStatus {
+	r.refreshMu.Lock()
+	defer r.refreshMu.Unlock()
+	status := r.status
+	status.Interval = r.interval
+	return status
+}
+
+// startRefresh starts the trace of a refresh from the sheet. The returned function ends it,
+// records its duration and updates the store's refresh status.
+func (r *refreshState) startRefresh(sheetName string) (context.Context, func(error)) {
+	start := time.Now()
+	ctx, span := pkg.StartSpan(context.Background(), "store.refresh", attribute.String("sheet", sheetName))
+	return ctx, func(err error) {
+		pkg.ObserveRefresh(sheetName, start)
+		pkg.EndSpan(span, err)
+
+		r.refreshMu.Lock()
+		defer r.refreshMu.Unlock()
+		r.status.LastError = err
+		if err == nil {
+			r.status.LastSuccess = time.Now()
+		}
+	}
+}
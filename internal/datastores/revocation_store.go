@@ -0,0 +1,204 @@
+package datastores
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/sirupsen/logrus"
+
+	"lysk-battle-record/internal/models"
+	"lysk-battle-record/internal/sheet_clients"
+)
+
+// InMemoryRevocationStore is a pkg.RevocationList backed by a sheet, so logouts and bans
+// survive restarts and reach every instance on the next refresh.
+type InMemoryRevocationStore struct {
+	refreshState
+
+	mu          sync.RWMutex
+	revocations map[revocationKey]models.Revocation
+	changes     map[revocationKey]uint64 // 本实例最近一次增删的序号，刷新时用来保留读取之后的变化
+	sequence    uint64
+	sheetClient sheet_clients.RevocationSheetClient
+}
+
+type revocationKey struct {
+	kind, subject string
+}
+
+func keyOf(revocation models.Revocation) revocationKey {
+	return revocationKey{kind: revocation.Kind, subject: revocation.Subject}
+}
+
+func NewInMemoryRevocationStore(sheetClient sheet_clients.RevocationSheetClient, refreshInterval time.Duration) *InMemoryRevocationStore {
+	store := &InMemoryRevocationStore{
+		refreshState: refreshState{interval: refreshInterval},
+		revocations:  map[revocationKey]models.Revocation{},
+		changes:      map[revocationKey]uint64{},
+		sheetClient:  sheetClient,
+	}
+	go store.every(store.refresh)
+	return store
+}
+
+func (s *InMemoryRevocationStore) refresh() {
+	s.mu.RLock()
+	since := s.sequence
+	s.mu.RUnlock()
+
+	ctx, done := s.startRefresh(s.sheetClient.GetType())
+	data, err := s.sheetClient.FetchAllSheetData(ctx)
+	defer func() { done(err) }()
+	if err != nil {
+		logrus.Errorf("failed to refresh cache for sheet: %s with error %v", s.sheetClient.GetType(), err)
+		return
+	}
+
+	// revocations of expired tokens are no longer needed
+	revocations := make(map[revocationKey]models.Revocation, len(data))
+	for _, revocation := range data {
+		if isExpiredRevocation(revocation) {
+			if err := s.sheetClient.DeleteRevocation(ctx, revocation); err != nil {
+				logrus.Errorf("sheet %s failed to clean up revocation: %v", s.sheetClient.GetType(), err)
+			}
+			continue
+		}
+		revocations[keyOf(revocation)] = revocation
+	}
+
+	s.mu.Lock()
+	// logouts, bans and unbans made after the sheet was read are not in the data yet
+	for key, sequence := range s.changes {
+		if sequence <= since {
+			delete(s.changes, key)
+			continue
+		}
+		if current, ok := s.revocations[key]; ok {
+			revocations[key] = current
+		} else {
+			delete(revocations, key)
+		}
+	}
+	s.revocations = revocations
+	count := len(revocations)
+	s.mu.Unlock()
+
+	logrus.Infof("sheet %s refreshed %d revocations", s.sheetClient.GetType(), count)
+}
+
+func isExpiredRevocation(revocation models.Revocation) bool {
+	if revocation.Kind != models.RevocationKindToken && revocation.Kind != models.RevocationKindUserTokens {
+		return false
+	}
+
+	expiresAt, err := time.Parse(time.RFC3339, revocation.ExpiresAt)
+	return err == nil && time.Now().After(expiresAt)
+}
+
+func (s *InMemoryRevocationStore) find(kind, subject string) (models.Revocation, bool) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	revocation, ok := s.revocations[revocationKey{kind: kind, subject: subject}]
+	return revocation, ok
+}
+
+// changed records a change made by this instance. s.mu must be held.
+func (s *InMemoryRevocationStore) changed(key revocationKey) {
+	s.sequence++
+	s.changes[key] = s.sequence
+}
+
+func (s *InMemoryRevocationStore) IsTokenRevoked(jti string) bool {
+	_, ok := s.find(models.RevocationKindToken, jti)
+	return ok
+}
+
+func (s *InMemoryRevocationStore) IsUserBanned(userID string) bool {
+	_, ok := s.find(models.RevocationKindUser, userID)
+	return ok
+}
+
+func (s *InMemoryRevocationStore) RevokeToken(jti string, expiresAt time.Time) error {
+	return s.insert(models.Revocation{
+		Kind:      models.RevocationKindToken,
+		Subject:   jti,
+		ExpiresAt: expiresAt.Format(time.RFC3339),
+	})
+}
+
+func (s *InMemoryRevocationStore) BanUser(userID, reason string) error {
+	if s.IsUserBanned(userID) {
+		return nil
+	}
+
+	return s.insert(models.Revocation{
+		Kind:    models.RevocationKindUser,
+		Subject: userID,
+		Reason:  reason,
+	})
+}
+
+func (s *InMemoryRevocationStore) RevokeUserTokens(userID string, expiresAt time.Time) error {
+	// 再次吊销时以最新的时间为准
+	if revocation, ok := s.find(models.RevocationKindUserTokens, userID); ok {
+		if err := s.sheetClient.DeleteRevocation(context.Background(), revocation); err != nil {
+			return err
+		}
+	}
+
+	return s.insert(models.Revocation{
+		Kind:      models.RevocationKindUserTokens,
+		Subject:   userID,
+		ExpiresAt: expiresAt.Format(time.RFC3339),
+	})
+}
+
+func (s *InMemoryRevocationStore) UserTokensRevokedAt(userID string) (time.Time, bool) {
+	revocation, ok := s.find(models.RevocationKindUserTokens, userID)
+	if !ok {
+		return time.Time{}, false
+	}
+
+	revokedAt, err := time.Parse(time.RFC3339, revocation.CreatedAt)
+	if err != nil {
+		logrus.Errorf("[Auth] Invalid revocation time for user %s: %v", userID, err)
+		// 无法判断时拒绝所有 token
+		return time.Now(), true
+	}
+	return revokedAt, true
+}
+
+func (s *InMemoryRevocationStore) UnbanUser(userID string) error {
+	revocation, ok := s.find(models.RevocationKindUser, userID)
+	if !ok {
+		return nil
+	}
+
+	if err := s.sheetClient.DeleteRevocation(context.Background(), revocation); err != nil {
+		return err
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	delete(s.revocations, keyOf(revocation))
+	s.changed(keyOf(revocation))
+	return nil
+}
+
+func (s *InMemoryRevocationStore) insert(revocation models.Revocation) error {
+	revocation.CreatedAt = time.Now().Format(time.RFC3339)
+
+	// RevocationList 的方法不带请求的 context
+	created, err := s.sheetClient.ProcessRevocation(context.Background(), revocation)
+	if err != nil {
+		return err
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.revocations[keyOf(*created)] = *created
+	s.changed(keyOf(*created))
+	return nil
+}
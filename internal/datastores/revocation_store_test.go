@@ -0,0 +1,107 @@
+package datastores
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"lysk-battle-record/internal/models"
+)
+
+type fakeRevocationSheetClient struct {
+	data    []models.Revocation
+	onFetch func() // 读取表格时的并发操作
+}
+
+func (c *fakeRevocationSheetClient) FetchAllSheetData(context.Context) ([]models.Revocation, error) {
+	data := append([]models.Revocation(nil), c.data...)
+	if c.onFetch != nil {
+		c.onFetch()
+	}
+	return data, nil
+}
+
+func (c *fakeRevocationSheetClient) ProcessRevocation(_ context.Context, revocation models.Revocation) (*models.Revocation, error) {
+	c.data = append(c.data, revocation)
+	return &revocation, nil
+}
+
+func (c *fakeRevocationSheetClient) DeleteRevocation(_ context.Context, revocation models.Revocation) error {
+	for i, r := range c.data {
+		if r.Kind == revocation.Kind && r.Subject == revocation.Subject {
+			c.data = append(c.data[:i], c.data[i+1:]...)
+			break
+		}
+	}
+	return nil
+}
+
+func (c *fakeRevocationSheetClient) GetType() string { return "吊销" }
+
+func TestRevocationStoreRefresh(t *testing.T) {
+	sheet := &fakeRevocationSheetClient{data: []models.Revocation{
+		{Kind: models.RevocationKindUser, Subject: "banned"},
+		{Kind: models.RevocationKindToken, Subject: "expired", ExpiresAt: time.Now().Add(-time.Hour).Format(time.RFC3339)},
+	}}
+	store := &InMemoryRevocationStore{
+		refreshState: refreshState{interval: time.Minute},
+		revocations:  map[revocationKey]models.Revocation{},
+		changes:      map[revocationKey]uint64{},
+		sheetClient:  sheet,
+	}
+	store.refresh()
+	if !store.IsUserBanned("banned") || store.IsTokenRevoked("expired") {
+		t.Fatalf("unexpected revocations after the first refresh: %v", store.revocations)
+	}
+
+	// 读取表格之后的登出、封禁和解封不能被这次刷新覆盖
+	sheet.onFetch = func() {
+		sheet.onFetch = nil
+		_ = store.RevokeToken("logged-out", time.Now().Add(time.Hour))
+		_ = store.BanUser("spammer", "spam")
+		_ = store.UnbanUser("banned")
+	}
+	store.refresh()
+	if !store.IsTokenRevoked("logged-out") || !store.IsUserBanned("spammer") || store.IsUserBanned("banned") {
+		t.Errorf("changes made during the refresh were lost: %v", store.revocations)
+	}
+
+	// 下一次刷新从表格读到同样的结果
+	store.refresh()
+	if !store.IsTokenRevoked("logged-out") || !store.IsUserBanned("spammer") || store.IsUserBanned("banned") || len(store.changes) != 0 {
+		t.Errorf("unexpected revocations after the next refresh: %v %v", store.revocations, store.changes)
+	}
+}
+
+func TestRevocationStoreUserTokens(t *testing.T) {
+	revokedAt := time.Now().Add(-time.Hour).Truncate(time.Second)
+	sheet := &fakeRevocationSheetClient{data: []models.Revocation{
+		{Kind: models.RevocationKindUserTokens, Subject: "deleted", CreatedAt: revokedAt.Format(time.RFC3339), ExpiresAt: time.Now().Add(time.Hour).Format(time.RFC3339)},
+		{Kind: models.RevocationKindUserTokens, Subject: "long-gone", CreatedAt: revokedAt.Format(time.RFC3339), ExpiresAt: time.Now().Add(-time.Minute).Format(time.RFC3339)},
+	}}
+	store := &InMemoryRevocationStore{
+		refreshState: refreshState{interval: time.Minute},
+		revocations:  map[revocationKey]models.Revocation{},
+		changes:      map[revocationKey]uint64{},
+		sheetClient:  sheet,
+	}
+	store.refresh()
+
+	if at, ok := store.UserTokensRevokedAt("deleted"); !ok || !at.Equal(revokedAt) {
+		t.Errorf("expected tokens revoked at %v, got %v %v", revokedAt, at, ok)
+	}
+	if _, ok := store.UserTokensRevokedAt("long-gone"); ok || len(sheet.data) != 1 {
+		t.Errorf("expired revocation kept: %v", sheet.data)
+	}
+
+	// 再次吊销替换原来的行
+	if err := store.RevokeUserTokens("deleted", time.Now().Add(time.Hour)); err != nil {
+		t.Fatal(err)
+	}
+	if at, _ := store.UserTokensRevokedAt("deleted"); !at.After(revokedAt) || len(sheet.data) != 1 {
+		t.Errorf("revocation not replaced: %v %v", at, sheet.data)
+	}
+	if store.IsUserBanned("deleted") {
+		t.Error("revoking the tokens banned the user")
+	}
+}
@@ -15,31 +15,30 @@ type UserStore interface {
 	Get(id string) (models.User, bool)
 	Insert(user models.User)
 	Update(user models.User) error
+	Delete(id string) error
 }
 
 type InMemoryUserStore struct {
+	refreshState
+
 	mu          sync.RWMutex
 	users       []models.User
 	sheetClient sheet_clients.UserSheetClient
 }
 
-func NewInMemoryUserStore(sheetClient sheet_clients.UserSheetClient) *InMemoryUserStore {
+func NewInMemoryUserStore(sheetClient sheet_clients.UserSheetClient, refreshInterval time.Duration) *InMemoryUserStore {
 	store := &InMemoryUserStore{
-		sheetClient: sheetClient,
+		refreshState: refreshState{interval: refreshInterval},
+		sheetClient:  sheetClient,
 	}
-	go store.autoRefresh()
+	go store.every(store.refresh)
 	return store
 }
 
-func (s *InMemoryUserStore) autoRefresh() {
-	for {
-		s.refresh()
-		time.Sleep(5 * time.Minute)
-	}
-}
-
 func (s *InMemoryUserStore) refresh() {
-	data, err := s.sheetClient.FetchAllSheetData()
+	ctx, done := s.startRefresh(s.sheetClient.GetType())
+	data, err := s.sheetClient.FetchAllSheetData(ctx)
+	defer func() { done(err) }()
 	if err != nil {
 		logrus.Errorf("failed to refresh cache for sheet: %s with error %v", s.sheetClient.GetType(), err)
 		return
@@ -81,3 +80,15 @@ func (s *InMemoryUserStore) Update(user models.User) error {
 	}
 	return errors.New("user not found")
 }
+
+func (s *InMemoryUserStore) Delete(id string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for i, u := range s.users {
+		if u.ID == id {
+			s.users = append(s.users[:i], s.users[i+1:]...)
+			return nil
+		}
+	}
+	return errors.New("user not found")
+}
@@ -0,0 +1,366 @@
+// Package calibration fits estimator parameters to real clears. Records that clear the same
+// level should have a similar combat power, so the parameters that minimise the spread of the
+// combat power within each level are the ones best supported by the data.
+package calibration
+
+import (
+	"math"
+	"sort"
+	"strconv"
+
+	"lysk-battle-record/internal/estimator"
+	"lysk-battle-record/internal/models"
+	"lysk-battle-record/internal/registry"
+)
+
+const (
+	StatusOK           = "ok"
+	StatusFewRecords   = "样本不足"
+	StatusInsensitive  = "不敏感"
+	StatusNotSupported = "无相关记录"
+)
+
+type Options struct {
+	MinLevelRecords int     // 参与计算的关卡最少记录数
+	MinSupport      int     // 参数最少相关记录数
+	Steps           int     // 每个参数的搜索点数
+	Rounds          int     // 坐标下降轮数
+	MinSensitivity  float64 // 离散度的最小相对变化，低于此值视为数据无法确定该参数
+}
+
+func DefaultOptions() Options {
+	return Options{MinLevelRecords: 5, MinSupport: 30, Steps: 21, Rounds: 2, MinSensitivity: 0.01}
+}
+
+type ParamReport struct {
+	Name        string  `json:"name"`
+	Default     float64 `json:"default"`
+	Fitted      float64 `json:"fitted"`
+	Support     int     `json:"support"`     // 受该参数影响的记录数
+	Sensitivity float64 `json:"sensitivity"` // 搜索范围内离散度的最大相对变化
+	Status      string  `json:"status"`
+}
+
+type CompanionReport struct {
+	Companion string        `json:"companion"`
+	Records   int           `json:"records"`
+	Params    []ParamReport `json:"params"`
+}
+
+type Result struct {
+	Records          int                `json:"records"`
+	Levels           int                `json:"levels"`
+	DispersionBefore float64            `json:"dispersion_before"`
+	DispersionAfter  float64            `json:"dispersion_after"`
+	Global           []ParamReport      `json:"global"`
+	Companions       []CompanionReport  `json:"companions"`
+	Params           registry.ParamFile `json:"params"`
+}
+
+// searchRange is the interval each parameter is searched in.
+var searchRange = map[string][2]float64{
+	registry.ParamWeakenRateForward:  {0.2, 0.8},
+	registry.ParamWeakenRateReverse:  {0.05, 0.6},
+	registry.ParamWeakenBonusForward: {100, 400},
+	registry.ParamWeakenBonusReverse: {50, 300},
+	registry.ParamDefaultTotalLevel:  {300, 480},
+	registry.ParamRainUpgrade:        {1, 2},
+	registry.ParamSupportHitRate:     {0, 1200},
+	registry.ParamSupportDamageBoost: {0, 20},
+}
+
+// companionOnly parameters only make sense for a single companion and are not fitted globally.
+var companionOnly = map[string]bool{
+	registry.ParamRainUpgrade: true,
+}
+
+// supportParams are looked up by the support companion, so they are fitted per companion on
+// the records bringing it as support rather than on the ones using it as main companion.
+var supportParams = map[string]bool{
+	registry.ParamSupportHitRate:     true,
+	registry.ParamSupportDamageBoost: true,
+}
+
+// affects reports whether the parameter can change the score of the record.
+func affects(name string, record models.Record) bool {
+	switch name {
+	case registry.ParamWeakenRateForward, registry.ParamWeakenBonusForward:
+		return record.Matching == "顺"
+	case registry.ParamWeakenRateReverse, registry.ParamWeakenBonusReverse:
+		return record.Matching != "顺"
+	case registry.ParamDefaultTotalLevel:
+		return record.TotalLevel == ""
+	case registry.ParamRainUpgrade:
+		return record.Companion == "潮汐之神"
+	case registry.ParamSupportHitRate, registry.ParamSupportDamageBoost:
+		return record.SupportCompanion != ""
+	}
+	return false
+}
+
+type calibrator struct {
+	opts      Options
+	estimator estimator.CombatPowerEstimator
+	records   []models.Record
+	levels    [][]int   // 关卡 -> 记录下标
+	logScores []float64 // ln(加成后战力)，无效为 NaN
+	params    registry.ParamFile
+}
+
+// Calibrate fits the parameters to the records and reports how well each one is supported.
+// It changes the active registry parameters while running and restores them afterwards.
+func Calibrate(records []models.Record, opts Options) Result {
+	previous := registry.ActiveParams()
+	defer registry.SetParams(previous)
+
+	c := newCalibrator(records, opts)
+	c.scoreAll()
+	result := Result{Records: len(c.records), Levels: len(c.levels), DispersionBefore: c.dispersion()}
+
+	for round := 0; round < opts.Rounds; round++ {
+		result.Global = result.Global[:0]
+		for _, name := range registry.ParamNames() {
+			if companionOnly[name] {
+				continue
+			}
+			result.Global = append(result.Global, c.fit(name, ""))
+		}
+	}
+
+	for _, companion := range registry.Companions() {
+		report := CompanionReport{Companion: companion.Name}
+		supported := 0
+		for _, record := range c.records {
+			if record.Companion == companion.Name {
+				report.Records++
+			}
+			if record.SupportCompanion == companion.Name {
+				supported++
+			}
+		}
+		if report.Records == 0 && supported == 0 {
+			continue
+		}
+
+		for _, name := range registry.ParamNames() {
+			report.Params = append(report.Params, c.fit(name, companion.Name))
+		}
+		result.Companions = append(result.Companions, report)
+	}
+
+	result.DispersionAfter = c.dispersion()
+	result.Params = c.params
+	return result
+}
+
+func newCalibrator(records []models.Record, opts Options) *calibrator {
+	byLevel := map[string][]models.Record{}
+	for _, record := range records {
+		if record.Deleted {
+			continue
+		}
+		record.Companion = registry.CanonicalCompanionName(record.Companion)
+		record.SupportCompanion = registry.CanonicalCompanionName(record.SupportCompanion)
+		key := record.GenerateLevelKey()
+		byLevel[key] = append(byLevel[key], record)
+	}
+
+	keys := make([]string, 0, len(byLevel))
+	for key := range byLevel {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	c := &calibrator{
+		opts:      opts,
+		estimator: estimator.NewCombatPowerEstimator(),
+		params:    copyParams(registry.ActiveParams()),
+	}
+	for _, key := range keys {
+		if len(byLevel[key]) < opts.MinLevelRecords {
+			continue
+		}
+		var level []int
+		for _, record := range byLevel[key] {
+			level = append(level, len(c.records))
+			c.records = append(c.records, record)
+		}
+		c.levels = append(c.levels, level)
+	}
+	c.logScores = make([]float64, len(c.records))
+	return c
+}
+
+// copyParams starts the fit from the active parameters without modifying them.
+func copyParams(params registry.ParamFile) registry.ParamFile {
+	result := registry.ParamFile{Global: map[string]float64{}, Companions: map[string]map[string]float64{}}
+	for name, value := range params.Global {
+		result.Global[name] = value
+	}
+	for companion, values := range params.Companions {
+		result.Companions[companion] = map[string]float64{}
+		for name, value := range values {
+			result.Companions[companion][name] = value
+		}
+	}
+	return result
+}
+
+func (c *calibrator) score(i int) {
+	score, err := strconv.Atoi(c.estimator.EstimateCombatPower(c.records[i]).BuffedScore)
+	if err != nil || score <= 0 {
+		c.logScores[i] = math.NaN()
+		return
+	}
+	c.logScores[i] = math.Log(float64(score))
+}
+
+func (c *calibrator) scoreAll() {
+	registry.SetParams(c.params)
+	for i := range c.records {
+		c.score(i)
+	}
+}
+
+// dispersion is the standard deviation of ln(CP) within a level, averaged over the levels
+// weighted by their number of records. It does not depend on the scale of the scores.
+func (c *calibrator) dispersion() float64 {
+	var total float64
+	var weight int
+	for _, level := range c.levels {
+		var sum, sumSq float64
+		n := 0
+		for _, i := range level {
+			if v := c.logScores[i]; !math.IsNaN(v) {
+				sum += v
+				sumSq += v * v
+				n++
+			}
+		}
+		if n < 2 {
+			continue
+		}
+		mean := sum / float64(n)
+		variance := math.Max(sumSq/float64(n)-mean*mean, 0)
+		total += math.Sqrt(variance) * float64(n)
+		weight += n
+	}
+	if weight == 0 {
+		return 0
+	}
+	return total / float64(weight)
+}
+
+// fit searches the parameter on a grid with every other parameter fixed. An empty companion
+// fits the global value.
+func (c *calibrator) fit(name, companion string) ParamReport {
+	report := ParamReport{Name: name, Default: registry.DefaultParam(name)}
+
+	var affected []int
+	for i, record := range c.records {
+		owner := record.Companion
+		if supportParams[name] {
+			owner = record.SupportCompanion
+		}
+		if (companion == "" || owner == companion) && affects(name, record) {
+			affected = append(affected, i)
+		}
+	}
+	report.Support = len(affected)
+	report.Fitted = c.value(name, companion)
+
+	if report.Support == 0 {
+		report.Status = StatusNotSupported
+		return report
+	}
+
+	current := report.Fitted
+	best, bestValue := math.Inf(1), current
+	worst := math.Inf(-1)
+	r := searchRange[name]
+	for step := 0; step < c.opts.Steps; step++ {
+		value := r[0] + (r[1]-r[0])*float64(step)/float64(max(c.opts.Steps-1, 1))
+		c.set(name, companion, value, affected)
+		d := c.dispersion()
+		if d < best {
+			best, bestValue = d, value
+		}
+		worst = math.Max(worst, d)
+	}
+
+	if best > 0 {
+		report.Sensitivity = math.Round((worst-best)/best*10000) / 10000
+	}
+
+	switch {
+	case report.Support < c.opts.MinSupport:
+		report.Status = StatusFewRecords
+	case report.Sensitivity < c.opts.MinSensitivity:
+		report.Status = StatusInsensitive
+	default:
+		report.Status = StatusOK
+	}
+
+	// poorly supported parameters keep their value so the candidate only moves where the data
+	// says so
+	if report.Status != StatusOK {
+		bestValue = current
+	}
+	c.set(name, companion, bestValue, affected)
+	report.Fitted = bestValue
+	return report
+}
+
+func (c *calibrator) value(name, companion string) float64 {
+	if value, ok := c.params.Companions[companion][name]; ok {
+		return value
+	}
+	if value, ok := c.params.Global[name]; ok {
+		return value
+	}
+	return registry.DefaultParam(name)
+}
+
+func (c *calibrator) set(name, companion string, value float64, affected []int) {
+	if companion == "" {
+		c.params.Global[name] = value
+	} else {
+		if c.params.Companions[companion] == nil {
+			c.params.Companions[companion] = map[string]float64{}
+		}
+		c.params.Companions[companion][name] = value
+	}
+
+	registry.SetParams(c.params)
+	for _, i := range affected {
+		c.score(i)
+	}
+}
+
+// Candidate returns the parameter file with only the values that differ from what the
+// companion would get anyway.
+func (r Result) Candidate() registry.ParamFile {
+	candidate := registry.ParamFile{Global: map[string]float64{}, Companions: map[string]map[string]float64{}}
+	for name, value := range r.Params.Global {
+		if value != registry.DefaultParam(name) {
+			candidate.Global[name] = value
+		}
+	}
+
+	for companion, params := range r.Params.Companions {
+		for name, value := range params {
+			inherited, ok := candidate.Global[name]
+			if !ok {
+				inherited = registry.DefaultParam(name)
+			}
+			if value == inherited {
+				continue
+			}
+			if candidate.Companions[companion] == nil {
+				candidate.Companions[companion] = map[string]float64{}
+			}
+			candidate.Companions[companion][name] = value
+		}
+	}
+	return candidate
+}
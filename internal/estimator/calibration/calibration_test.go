@@ -0,0 +1,63 @@
+package calibration
+
+import (
+	"strconv"
+	"testing"
+
+	"lysk-battle-record/internal/models"
+	"lysk-battle-record/internal/registry"
+)
+
+// TestCalibrateDefaultTotalLevel fits the default 卡总等级 on clears where the records without
+// it are otherwise identical to records that have 390, so 390 is the only value at which each
+// level has a single combat power.
+func TestCalibrateDefaultTotalLevel(t *testing.T) {
+	const want = 390
+
+	var records []models.Record
+	for level := 0; level < 4; level++ {
+		for i := 0; i < 6; i++ {
+			record := models.Record{
+				LevelType: "光", LevelNumber: strconv.Itoa(100 + level*10), LevelMode: "稳定",
+				Attack: strconv.Itoa(9000 + level*1000), HP: "50000", Defense: "2000", Matching: "顺", MatchingBuff: "30",
+				CritRate: "60", CritDmg: "200", EnergyRegen: "10.8", WeakenBoost: "50",
+				OathBoost: "40", OathRegen: "17", TotalLevel: strconv.Itoa(want),
+				Companion: "逐光骑士", SetCard: "逐光", Stage: "IV", Weapon: "专武", Buff: "0",
+			}
+			if i%2 == 1 {
+				record.TotalLevel = ""
+			}
+			records = append(records, record)
+		}
+	}
+
+	before := registry.Param("", registry.ParamDefaultTotalLevel)
+	opts := DefaultOptions()
+	opts.MinSupport = 10
+	result := Calibrate(records, opts)
+
+	if result.Records != 24 || result.Levels != 4 {
+		t.Fatalf("unexpected dataset %d records in %d levels", result.Records, result.Levels)
+	}
+	if result.DispersionBefore <= 0 || result.DispersionAfter > 1e-4 {
+		t.Errorf("dispersion %.6f -> %.6f, expected the fit to remove it", result.DispersionBefore, result.DispersionAfter)
+	}
+
+	var report ParamReport
+	for _, p := range result.Global {
+		if p.Name == registry.ParamDefaultTotalLevel {
+			report = p
+		}
+	}
+	if report.Fitted != want || report.Status != StatusOK || report.Support != 12 {
+		t.Errorf("unexpected fit %+v", report)
+	}
+	if got := result.Candidate().Global[registry.ParamDefaultTotalLevel]; got != want {
+		t.Errorf("candidate has %v", got)
+	}
+
+	// 校准只产出报告，不改变服务使用的参数
+	if after := registry.Param("", registry.ParamDefaultTotalLevel); after != before {
+		t.Errorf("calibration left the active parameter at %v", after)
+	}
+}
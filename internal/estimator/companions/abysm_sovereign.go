@@ -18,7 +18,7 @@ func (p AbysmSovereign) GetCompanionFlow(stats models.Stats) models.CompanionFlo
 	supportExtraSkill := p.GetSupportExtra(stats)
 	passiveSkill := p.GetPassiveSkill(stats)
 
-	weakenRate := getWeakenRate(stats.Matching)
+	weakenRate := getWeakenRate(stats)
 	return models.CompanionFlow{
 		Periods: []models.CompanionPeriod{
 			{
@@ -37,7 +37,7 @@ func (p AbyssWalker) GetCompanionFlow(stats models.Stats) models.CompanionFlow {
 		passiveSkillSlash.Count += 2
 	}
 
-	weakenRate := getWeakenRate(stats.Matching)
+	weakenRate := getWeakenRate(stats)
 
 	flow := models.CompanionFlow{
 		Periods: []models.CompanionPeriod{
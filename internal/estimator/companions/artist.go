@@ -16,7 +16,7 @@ func (p Artist) GetCompanionFlow(stats models.Stats) models.CompanionFlow {
 	supportSkill := p.GetSupportSkill(stats)
 	passiveSkill := p.GetPassiveSkill(stats)
 
-	weakenRate := getWeakenRate(stats.Matching)
+	weakenRate := getWeakenRate(stats)
 	return models.CompanionFlow{
 		Periods: []models.CompanionPeriod{
 			{
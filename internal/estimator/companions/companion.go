@@ -1,6 +1,9 @@
 package companions
 
-import "lysk-battle-record/internal/models"
+import (
+	"lysk-battle-record/internal/estimator/timeline"
+	"lysk-battle-record/internal/models"
+)
 
 type Companion interface {
 	GetName() string
@@ -13,3 +16,12 @@ type Companion interface {
 	GetResonanceSkill(stats models.Stats) models.Skill
 	GetSupportSkill(stats models.Stats) models.Skill
 }
+
+// RotationCompanion is implemented by companions that describe their rotation for the
+// timeline engine. So far only 潮汐之神 and 远空执舰官 do. The others are converted from their
+// fixed-count flow by timeline.FromFlow, which keeps the v1 counts and only scales them to the
+// fight length, so v2 does not model their rotation yet and flags their scores as approximate
+// (models.CombatPower.Approximate). Porting a companion means implementing this interface.
+type RotationCompanion interface {
+	GetRotation(stats models.Stats) timeline.Rotation
+}
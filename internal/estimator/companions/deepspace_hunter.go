@@ -16,7 +16,7 @@ func (p DeepspaceHunter) GetCompanionFlow(stats models.Stats) models.CompanionFl
 	supportSkill := p.GetSupportSkill(stats)
 	passiveSkill := p.GetPassiveSkill(stats)
 
-	weakenRate := getWeakenRate(stats.Matching)
+	weakenRate := getWeakenRate(stats)
 	return models.CompanionFlow{
 		Periods: []models.CompanionPeriod{
 			{
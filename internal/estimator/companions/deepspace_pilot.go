@@ -16,7 +16,7 @@ func (p DeepspacePilot) GetCompanionFlow(stats models.Stats) models.CompanionFlo
 	supportSkill := p.GetSupportSkill(stats)
 	passiveSkill := p.GetPassiveSkill(stats)
 
-	weakenRate := getWeakenRate(stats.Matching)
+	weakenRate := getWeakenRate(stats)
 	return models.CompanionFlow{
 		Periods: []models.CompanionPeriod{
 			{
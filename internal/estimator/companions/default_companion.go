@@ -16,7 +16,7 @@ func (p DefaultCompanion) GetCompanionFlow(stats models.Stats) models.CompanionF
 	supportSkill := p.GetSupportSkill(stats)
 	passiveSkill := p.GetPassiveSkill(stats)
 
-	weakenRate := getWeakenRate(stats.Matching)
+	weakenRate := getWeakenRate(stats)
 	return models.CompanionFlow{
 		Periods: []models.CompanionPeriod{
 			{
@@ -1,8 +1,10 @@
 package companions
 
 import (
-	"lysk-battle-record/internal/models"
 	"math"
+
+	"lysk-battle-record/internal/estimator/timeline"
+	"lysk-battle-record/internal/models"
 )
 
 type FarspaceColonel struct{}
@@ -62,7 +64,7 @@ func (p FarspaceColonel) GetCompanionFlow(stats models.Stats) models.CompanionFl
 	if stats.SetCard == "远空" {
 		boost = 20
 	}
-	weakenRate := getWeakenRate(stats.Matching)
+	weakenRate := getWeakenRate(stats)
 	if stats.Weapon != "专武" {
 		weakenRate = 0
 	}
@@ -228,3 +230,42 @@ func (p FarspaceColonel) GetSecondPeriodCount(stats models.Stats) int {
 	}
 	return 2
 }
+
+func (p FarspaceColonel) GetRotation(stats models.Stats) timeline.Rotation {
+	if stats.Weapon != "专武" {
+		return timeline.FromFlow(p.GetCompanionFlow(stats), stats)
+	}
+
+	// 阵地由共鸣开启，持续约6轮普攻的时间
+	zone := timeline.Window{
+		Name:       "阵地",
+		OpenedBy:   "共鸣",
+		Duration:   15,
+		WeakenRate: getWeakenRate(stats) * 2,
+	}
+	if stats.SetCard == "远空" {
+		zone.Boost = 20
+	}
+
+	actions := []timeline.Action{
+		{Skill: withCount(p.GetActiveSkill(stats), 1), Trigger: timeline.TriggerEnergy, EnergyCost: 3},
+		// 阵地外：4段普攻攒15点火力值，共需6轮普攻触发一次共鸣
+		{Skill: withCount(p.GetLightAttack(stats), 1), Trigger: timeline.TriggerInterval, Interval: 2.5, OutsideWindow: zone.Name},
+		{Skill: withCount(p.GetResonanceSkill(stats), 1), Trigger: timeline.TriggerAction, After: []string{"普攻"}, Every: 6, OutsideWindow: zone.Name},
+		// 阵地内
+		{Skill: withCount(p.GetBasicAttack(stats), 1), Trigger: timeline.TriggerInterval, Interval: 7.5, InWindow: zone.Name},
+		{Skill: withCount(p.GetLightAttackSecondPeriod(stats), 1), Trigger: timeline.TriggerInterval, Interval: 2.5, InWindow: zone.Name},
+		{Skill: withCount(p.GetResonanceAltSkill(stats), 1), Trigger: timeline.TriggerAction, After: []string{"共鸣"}, InWindow: zone.Name},
+		{Skill: withCount(p.GetOathSkill(stats), 1), Trigger: timeline.TriggerOath, InWindow: zone.Name},
+		{Skill: withCount(p.GetSupportSkill(stats), 1), Trigger: timeline.TriggerInterval, Interval: 10},
+	}
+
+	if fireAltSkill := p.GetFireAltSkill(stats); fireAltSkill.Count > 0 {
+		actions = append(actions, timeline.Action{Skill: withCount(fireAltSkill, 1), Trigger: timeline.TriggerInterval, Interval: 3.75, InWindow: zone.Name})
+	}
+
+	return timeline.Rotation{
+		Actions: actions,
+		Windows: []timeline.Window{zone},
+	}
+}
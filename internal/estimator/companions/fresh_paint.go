@@ -5,7 +5,7 @@ import "lysk-battle-record/internal/models"
 type FreshPaint struct{}
 
 func (p FreshPaint) GetName() string {
-	return "花坛新锐"
+	return "画坛新锐"
 }
 
 func (p FreshPaint) GetCompanionFlow(stats models.Stats) models.CompanionFlow {
@@ -16,7 +16,7 @@ func (p FreshPaint) GetCompanionFlow(stats models.Stats) models.CompanionFlow {
 	supportSkill := p.GetSupportSkill(stats)
 	passiveSkill := p.GetPassiveSkill(stats)
 
-	weakenRate := getWeakenRate(stats.Matching)
+	weakenRate := getWeakenRate(stats)
 	return models.CompanionFlow{
 		Periods: []models.CompanionPeriod{
 			{
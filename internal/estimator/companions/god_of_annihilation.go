@@ -21,7 +21,7 @@ func (p GodOfAnnihilation) GetCompanionFlow(stats models.Stats) models.Companion
 	godPeriodSupportSkill := p.GetGodPeriodSupportSkill(stats)
 	soulAttack := p.GetSoulAttack(stats)
 
-	weakenRate := getWeakenRate(stats.Matching)
+	weakenRate := getWeakenRate(stats)
 	damageBoost := 0.0
 	if stats.SetCard == "神谕" {
 		damageBoost = 8.0
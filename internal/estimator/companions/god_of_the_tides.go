@@ -1,8 +1,11 @@
 package companions
 
 import (
-	"lysk-battle-record/internal/models"
 	"math"
+
+	"lysk-battle-record/internal/estimator/timeline"
+	"lysk-battle-record/internal/models"
+	"lysk-battle-record/internal/registry"
 )
 
 type GodOfTheTides struct{}
@@ -19,7 +22,7 @@ func (p GodOfTheTides) GetCompanionFlow(stats models.Stats) models.CompanionFlow
 	supportSkill := p.GetSupportSkill(stats)
 	passiveSkill := p.GetPassiveSkill(stats)
 
-	weakenRate := getWeakenRate(stats.Matching)
+	weakenRate := getWeakenRate(stats)
 
 	flow := models.CompanionFlow{
 		Periods: []models.CompanionPeriod{
@@ -118,7 +121,7 @@ func (p GodOfTheTides) GetPassiveSkill(stats models.Stats) models.Skill {
 		AttackRate:  25,
 		HpRate:      2.2,
 		Count:       (activeSkillCount + supportSkillCount) * singleTimeCount,
-		DamageBoost: ((float64(p.GetRainCount(stats))/6.0)*1.25 + 5.0/6.0) * 100 / 6.0, // 下雨期间海灵升级增益
+		DamageBoost: ((float64(p.GetRainCount(stats))/6.0)*registry.Param(p.GetName(), registry.ParamRainUpgrade) + 5.0/6.0) * 100 / 6.0, // 下雨期间海灵升级增益
 		CritRate:    p.getExtraCritRate(stats),
 		CanBeCrit:   true,
 	}
@@ -149,3 +152,58 @@ func (p GodOfTheTides) GetRainCount(stats models.Stats) int {
 	}
 	return 1
 }
+
+func (p GodOfTheTides) GetRotation(stats models.Stats) timeline.Rotation {
+	signature := stats.Weapon == "专武"
+
+	rain := timeline.Window{
+		Name:        "下雨",
+		Interval:    timeline.ReferenceDuration / float64(p.GetRainCount(stats)),
+		Duration:    10,
+		Boost:       30,
+		SkillBoosts: map[string]float64{"海灵": 125.0 / 6}, // 下雨期间海灵升级
+	}
+	if stats.SetCard == "神殿" && (stats.Stage == "III" || stats.Stage == "IV") {
+		rain.EnergyOnOpen = 2 // 神殿III/IV阶增加2点能量
+	}
+	windows := []timeline.Window{rain}
+
+	activeSkill := p.GetActiveSkill(stats)
+	heavyAttack := p.GetBasicAttack(stats)
+	resonanceSkill := p.GetResonanceSkill(stats)
+	passiveSkill := p.GetPassiveSkill(stats)
+	passiveTriggers := []string{"协助"}
+	activeCasts := 0
+
+	if signature {
+		// 主动释放后增加30%暴击率，持续6秒
+		windows = append(windows, timeline.Window{Name: "主动暴击", OpenedBy: activeSkill.Name, Duration: 6, CritRate: 30})
+		activeSkill.CritRate = 0
+		heavyAttack.CritRate = 0
+		resonanceSkill.CritRate = 0
+		passiveSkill.CritRate = 0
+		passiveTriggers = append(passiveTriggers, activeSkill.Name)
+		activeCasts = 6
+	}
+
+	basicAttackInterval := timeline.ReferenceDuration / float64(heavyAttack.Count)
+	activeSkill.Count = 1
+	heavyAttack.Count = 1
+	resonanceSkill.Count = 1
+	passiveSkill.Count = 7
+	passiveSkill.DamageBoost = 5.0 / 6.0 * 100 / 6.0
+
+	return timeline.Rotation{
+		Actions: []timeline.Action{
+			{Skill: activeSkill, Trigger: timeline.TriggerEnergy, EnergyCost: 1, MaxCasts: activeCasts},
+			{Skill: heavyAttack, Trigger: timeline.TriggerInterval, Interval: basicAttackInterval},
+			{Skill: resonanceSkill, Trigger: timeline.TriggerInterval, Interval: 15},
+			{Skill: withCount(p.GetOathSkill(stats), 1), Trigger: timeline.TriggerOath},
+			{Skill: withCount(p.GetSupportSkill(stats), 1), Trigger: timeline.TriggerInterval, Interval: 10},
+			{Skill: passiveSkill, Trigger: timeline.TriggerAction, After: passiveTriggers},
+		},
+		Windows:    windows,
+		WeakenRate: getWeakenRate(stats),
+		EnergyDebt: 8,
+	}
+}
@@ -19,7 +19,7 @@ func (p KingOfDarknight) GetCompanionFlow(stats models.Stats) models.CompanionFl
 	lordPeriodBasicAttack := p.GetLordPeriodBasicAttack(stats)
 	lordPeriodSupportSkill := p.GetLordPeriodSupportSkill(stats)
 
-	weakenRate := getWeakenRate(stats.Matching)
+	weakenRate := getWeakenRate(stats)
 	return models.CompanionFlow{
 		Periods: []models.CompanionPeriod{
 			{
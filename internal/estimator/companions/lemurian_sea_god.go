@@ -24,7 +24,7 @@ func (p LemurianSeaGod) GetCompanionFlow(stats models.Stats) models.CompanionFlo
 	thunderBallSkill := p.GetThunderBallSkill(stats)
 	thunderWaveSkill := p.GetThunderWaveSkill(stats)
 
-	weakenRate := getWeakenRate(stats.Matching)
+	weakenRate := getWeakenRate(stats)
 	if stats.SetCard == "雾海" {
 		weakenRate *= 1.1
 	}
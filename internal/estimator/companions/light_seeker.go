@@ -18,7 +18,7 @@ func (p LightSeeker) GetCompanionFlow(stats models.Stats) models.CompanionFlow {
 	supportSkill := p.GetSupportSkill(stats)
 	passiveSkill := p.GetPassiveSkill(stats)
 
-	weakenRate := getWeakenRate(stats.Matching)
+	weakenRate := getWeakenRate(stats)
 
 	flow := models.CompanionFlow{
 		Periods: []models.CompanionPeriod{
@@ -16,7 +16,7 @@ func (p Lumiere) GetCompanionFlow(stats models.Stats) models.CompanionFlow {
 	supportSkill := p.GetSupportSkill(stats)
 	passiveSkill := p.GetPassiveSkill(stats)
 
-	weakenRate := getWeakenRate(stats.Matching)
+	weakenRate := getWeakenRate(stats)
 
 	flow := models.CompanionFlow{
 		Periods: []models.CompanionPeriod{
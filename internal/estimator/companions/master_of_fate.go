@@ -17,7 +17,7 @@ func (p MasterOfFate) GetCompanionFlow(stats models.Stats) models.CompanionFlow
 	passiveSkill := p.GetPassiveSkill(stats)
 	altPassiveSkill := p.GetAltResonanceSkill(stats)
 
-	weakenRate := getWeakenRate(stats.Matching)
+	weakenRate := getWeakenRate(stats)
 	if stats.SetCard == "拥雪" && stats.Stage != "I" && stats.Stage != "无套装" {
 		weakenRate *= 1.1
 	}
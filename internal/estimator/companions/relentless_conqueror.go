@@ -16,7 +16,7 @@ func (p RelentLessConqueror) GetCompanionFlow(stats models.Stats) models.Compani
 	supportSkill := p.GetSupportSkill(stats)
 	passiveSkill := p.GetPassiveSkill(stats)
 
-	weakenRate := getWeakenRate(stats.Matching)
+	weakenRate := getWeakenRate(stats)
 	boost := (4.0 * 8.0 / 60.0) * 80.0
 	if stats.SetCard == "掠心" && stats.Stage != "I" && stats.Stage != "无套装" {
 		boost = 80
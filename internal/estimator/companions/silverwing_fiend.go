@@ -27,7 +27,7 @@ func (p SilverwingFiend) GetCompanionFlow(stats models.Stats) models.CompanionFl
 
 	roseBulletSkill := p.GetRoseBulletSkill(stats)
 
-	weakenRate := getWeakenRate(stats.Matching)
+	weakenRate := getWeakenRate(stats)
 
 	return models.CompanionFlow{
 		Periods: []models.CompanionPeriod{
@@ -16,7 +16,7 @@ func (p UltimateWeaponX02) GetCompanionFlow(stats models.Stats) models.Companion
 	oathExtraSKill := p.GetOathExtraSkill(stats)
 	supportSkill := p.GetSupportSkill(stats)
 
-	weakenRate := getWeakenRate(stats.Matching)
+	weakenRate := getWeakenRate(stats)
 	if stats.SetCard != "寂路" || stats.Stage == "I" || stats.Stage == "无套装" {
 		weakenRate *= 0.75
 	}
@@ -4,6 +4,7 @@ import (
 	"math"
 
 	"lysk-battle-record/internal/models"
+	"lysk-battle-record/internal/registry"
 )
 
 func getActiveSkillForWeapon(weapon string, energy int) models.Skill {
@@ -81,11 +82,11 @@ func getOathCount(stats models.Stats) int {
 	return 0
 }
 
-func getWeakenRate(matching string) float64 {
-	if matching == "顺" {
-		return 0.50
+func getWeakenRate(stats models.Stats) float64 {
+	if stats.Matching == "顺" {
+		return registry.Param(stats.Companion, registry.ParamWeakenRateForward)
 	}
-	return 0.25
+	return registry.Param(stats.Companion, registry.ParamWeakenRateReverse)
 }
 
 func getDefaultActiveSkill() models.Skill {
@@ -123,3 +124,8 @@ func getDefaultSupportSkill() models.Skill {
 		CanBeCrit: true,
 	}
 }
+
+func withCount(skill models.Skill, count int) models.Skill {
+	skill.Count = count
+	return skill
+}
@@ -2,10 +2,13 @@ package estimator
 
 import (
 	"fmt"
+	"strconv"
 
 	"lysk-battle-record/internal/estimator/companions"
+	"lysk-battle-record/internal/estimator/levels"
 	"lysk-battle-record/internal/estimator/set_cards"
 	"lysk-battle-record/internal/models"
+	"lysk-battle-record/internal/registry"
 )
 
 type CombatPowerEstimator interface {
@@ -20,6 +23,24 @@ func NewCombatPowerEstimator() CombatPowerEstimator {
 
 func (e *LyskCPEstimator) EstimateCombatPower(record models.Record) models.CombatPower {
 	stats := record.ToStats()
+	flow := buildCompanionFlow(stats)
+
+	score := estimate(stats, flow, referenceTarget)
+	//printCompanionFlow(flow)
+	//fmt.Printf("Final Score: %+v\n", score)
+	return score
+}
+
+func (e *LyskCPEstimator) EstimateCombatPowerAt(record models.Record, level levels.Level) models.CombatPower {
+	stats := record.ToStats()
+	flow := buildCompanionFlow(stats)
+
+	return estimateAt(stats, flow, level)
+}
+
+// buildCompanionFlow returns the companion's skills with the set card buff, the weapon
+// refinement and the support companion applied.
+func buildCompanionFlow(stats models.Stats) models.CompanionFlow {
 	myCompanion := getCompanion(stats)
 	flow := myCompanion.GetCompanionFlow(stats)
 	if myCompanion.GetName() == "默认搭档" {
@@ -28,68 +49,53 @@ func (e *LyskCPEstimator) EstimateCombatPower(record models.Record) models.Comba
 	setCard := getSetCard(stats)
 	setCardBuff := getSetCardBuff(stats, setCard)
 	applySetCardBuff(&flow, setCardBuff)
+	applySetCardBuff(&flow, getWeaponBuff(stats))
+	applySupport(&flow, stats)
+	return flow
+}
 
-	score := estimate(stats, flow)
-	//printCompanionFlow(flow)
-	//fmt.Printf("Final Score: %+v\n", score)
-	return score
+// companionImpls is keyed by GetName, which must match the canonical name in the registry.
+var companionImpls = indexCompanions(
+	companions.LightSeeker{},
+	companions.Foreseer{},
+	companions.AbyssWalker{},
+	companions.GodOfTheTides{},
+	companions.Lumiere{},
+	companions.MasterOfFate{},
+	companions.RelentLessConqueror{},
+	companions.AbysmSovereign{},
+	companions.FarspaceColonel{},
+	companions.UltimateWeaponX02{},
+	companions.LemurianSeaGod{},
+	companions.KingOfDarknight{},
+	companions.GodOfAnnihilation{},
+	companions.SilverwingFiend{},
+	companions.DeepspacePilot{},
+	companions.OtherworldlyVisitor{},
+	companions.MedicOfTheArctic{},
+	companions.DawnBreaker{},
+	companions.LinkonDoctor{},
+	companions.EvolPolice{},
+	companions.DistantYouth{},
+	companions.DeepspaceHunter{},
+	companions.FreshPaint{},
+	companions.Artist{},
+	companions.PhantomOfTheSiren{},
+)
+
+func indexCompanions(impls ...companions.Companion) map[string]companions.Companion {
+	index := make(map[string]companions.Companion, len(impls))
+	for _, impl := range impls {
+		index[impl.GetName()] = impl
+	}
+	return index
 }
 
 func getCompanion(stats models.Stats) companions.Companion {
-	switch stats.Companion {
-	case "逐光骑士":
-		return companions.LightSeeker{}
-	case "永恒先知":
-		return companions.Foreseer{}
-	case "深海潜行者":
-		return companions.AbyssWalker{}
-	case "潮汐之神":
-		return companions.GodOfTheTides{}
-	case "光猎":
-		return companions.Lumiere{}
-	case "九黎司命":
-		return companions.MasterOfFate{}
-	case "无尽掠夺者":
-		return companions.RelentLessConqueror{}
-	case "深渊主宰":
-		return companions.AbysmSovereign{}
-	case "远空执舰官":
-		return companions.FarspaceColonel{}
-	case "终极兵器X-02":
-		return companions.UltimateWeaponX02{}
-	case "利莫里亚海神":
-		return companions.LemurianSeaGod{}
-	case "暗蚀国王":
-		return companions.KingOfDarknight{}
-	case "终末之神":
-		return companions.GodOfAnnihilation{}
-	case "银翼恶魔":
-		return companions.SilverwingFiend{}
-	case "深空飞行员":
-		return companions.DeepspacePilot{}
-	case "异界来客":
-		return companions.FreshPaint{}
-	case "极地军医":
-		return companions.MedicOfTheArctic{}
-	case "黎明抹杀者":
-		return companions.FreshPaint{}
-	case "临空医生":
-		return companions.EvolPolice{}
-	case "Evol特警":
-		return companions.EvolPolice{}
-	case "遥远少年":
-		return companions.DistantYouth{}
-	case "深空猎人":
-		return companions.DeepspaceHunter{}
-	case "花坛新锐":
-		return companions.FreshPaint{}
-	case "艺术家":
-		return companions.Artist{}
-	case "海妖魅影":
-		return companions.PhantomOfTheSiren{}
-	default:
-		return companions.DefaultCompanion{}
+	if companion, ok := companionImpls[registry.CanonicalCompanionName(stats.Companion)]; ok {
+		return companion
 	}
+	return companions.DefaultCompanion{}
 }
 
 func getSetCard(stats models.Stats) set_cards.SetCard {
@@ -132,24 +138,9 @@ func getSetCard(stats models.Stats) set_cards.SetCard {
 }
 
 func getSetCardBuff(stats models.Stats, setCard set_cards.SetCard) models.StageBuff {
-	setMap := map[string]string{
-		"逐光骑士":     "逐光",
-		"永恒先知":     "永恒",
-		"深海潜行者":   "深海",
-		"潮汐之神":     "神殿",
-		"光猎":         "末夜",
-		"九黎司命":     "拥雪",
-		"无尽掠夺者":   "掠心",
-		"深渊主宰":     "深渊",
-		"远空执舰官":   "远空",
-		"终极兵器X-02": "寂路",
-		"利莫里亚海神": "雾海",
-		"暗蚀国王":     "夜誓",
-		"终末之神":     "神谕",
-		"银翼恶魔":     "猩红",
-	}
+	companion, _ := registry.GetCompanion(stats.Companion)
 	var setCardBuff models.StageBuff
-	if setCard.GetName() == setMap[stats.Companion] {
+	if companion.SetCard != "" && setCard.GetName() == companion.SetCard {
 		setCardBuff = setCard.GetSetCardBuff()[stats.Stage]
 	} else if setCard.GetName() != "无套装" {
 		setCardBuff = set_cards.GetDefaultCardBuff()[stats.Stage]
@@ -193,34 +184,24 @@ func applySetCardBuff(flow *models.CompanionFlow, buff models.StageBuff) {
 	}
 }
 
-func estimate(stats models.Stats, companionFlow models.CompanionFlow) models.CombatPower {
+// estimateAt estimates against the enemies of the level and adds the level estimate.
+func estimateAt(stats models.Stats, flow models.CompanionFlow, level levels.Level) models.CombatPower {
+	combatPower := estimate(stats, flow, newTarget(stats, level.Enemy))
+	buffedScore, _ := strconv.ParseFloat(combatPower.BuffedScore, 64)
+	combatPower.Level = levelEstimate(level, buffedScore)
+	return combatPower
+}
+
+func estimate(stats models.Stats, companionFlow models.CompanionFlow, t target) models.CombatPower {
 	var total, weakenScore, critScore float64 = 0, 0, 0
 	for _, period := range companionFlow.Periods {
 		var score float64 = 0
 
 		for _, skill := range period.SkillSet.Skills {
-			rawSkillScore := skill.Base +
-				(skill.HpRate/100)*float64(stats.HP) +
-				(skill.AttackRate/100)*float64(stats.Attack) +
-				(skill.DefenseRate/100)*float64(stats.Defense)
-
-			// apply damage boost and period boost
-			rawSkillScore *= 1 + (skill.DamageBoost+period.Boost)/100
-
-			// apply oath boost
-			if skill.Name == "誓约" || skill.Name == "誓约-同频觉醒" || skill.Name == "誓约-同频攻击" {
-				rawSkillScore *= 1 + skill.OathBoost/100
-			}
-
-			// consider level - defence relationship
-			levelDefenseRatio := 1 + float64(stats.TotalLevel)/(float64(stats.TotalLevel)+300+(80*3+100)*(1-skill.EnemyDefenceReduction/100))
-			rawSkillScore *= levelDefenseRatio
-
-			// consider enemy weaken boost
-			rawSkillScore *= 1 + (1+skill.EnemyWeakenBoost)/100
+			rawSkillScore := rawSkillDamage(stats, period, skill, t)
 
 			// consider non-weaken period
-			weakenRate := period.WeakenRate
+			weakenRate := t.weakenRate(period.WeakenRate)
 			if skill.NoWeakenPeriod {
 				weakenRate = 0
 			}
@@ -240,14 +221,7 @@ func estimate(stats models.Stats, companionFlow models.CompanionFlow) models.Com
 
 			// consider weaken period
 			weakenSkillCount := weakenRate * float64(skill.Count)
-			weakenPeriodScore := rawSkillScore * weakenSkillCount
-			weakenBoost := stats.WeakenBoost + skill.WeakenBoost
-			if stats.Matching == "顺" {
-				weakenBoost += 250
-			} else {
-				weakenBoost += 150
-			}
-			weakenPeriodScore = weakenPeriodScore * (1 + weakenBoost/100)
+			weakenPeriodScore := rawSkillScore * weakenSkillCount * weakenMultiplier(stats, skill)
 
 			score += critPeriodScore + weakenPeriodScore
 			critScore += critPeriodScore
@@ -270,6 +244,46 @@ func estimate(stats models.Stats, companionFlow models.CompanionFlow) models.Com
 	}
 }
 
+// rawSkillDamage is the damage of a single hit before crit and weaken are applied.
+func rawSkillDamage(stats models.Stats, period models.CompanionPeriod, skill models.Skill, t target) float64 {
+	rawSkillScore := skill.Base +
+		(skill.HpRate/100)*float64(stats.HP) +
+		(skill.AttackRate/100)*float64(stats.Attack) +
+		(skill.DefenseRate/100)*float64(stats.Defense)
+
+	// apply damage boost and period boost
+	rawSkillScore *= 1 + (skill.DamageBoost+period.Boost)/100
+
+	// apply oath boost
+	if isOathSkill(skill) {
+		rawSkillScore *= 1 + skill.OathBoost/100
+	}
+
+	// consider level - defence relationship
+	levelDefenseRatio := 1 + float64(stats.TotalLevel)/(float64(stats.TotalLevel)+300+t.defense*(1-skill.EnemyDefenceReduction/100))
+	rawSkillScore *= levelDefenseRatio
+
+	// consider enemy weaken boost and elemental resistance
+	rawSkillScore *= 1 + (t.weakenBoost+skill.EnemyWeakenBoost)/100
+	rawSkillScore *= 1 - t.resistance/100
+
+	return rawSkillScore
+}
+
+func isOathSkill(skill models.Skill) bool {
+	return skill.Name == "誓约" || skill.Name == "誓约-同频觉醒" || skill.Name == "誓约-同频攻击"
+}
+
+func weakenMultiplier(stats models.Stats, skill models.Skill) float64 {
+	weakenBoost := stats.WeakenBoost + skill.WeakenBoost
+	if stats.Matching == "顺" {
+		weakenBoost += registry.Param(stats.Companion, registry.ParamWeakenBonusForward)
+	} else {
+		weakenBoost += registry.Param(stats.Companion, registry.ParamWeakenBonusReverse)
+	}
+	return 1 + weakenBoost/100
+}
+
 func printCompanionFlow(flow models.CompanionFlow) {
 	fmt.Println("=== Companion Flow Debug ===")
 	fmt.Println()
@@ -2,9 +2,13 @@ package estimator
 
 import (
 	"fmt"
+	"strconv"
+	"strings"
 	"testing"
 
+	"lysk-battle-record/internal/estimator/levels"
 	"lysk-battle-record/internal/models"
+	"lysk-battle-record/internal/registry"
 )
 
 func TestEstimateCombatPower(t *testing.T) {
@@ -94,3 +98,327 @@ func TestEstimateCombatPowerReal(t *testing.T) {
 	combatPower := estimator.EstimateCombatPower(record)
 	fmt.Println("combatPower:", combatPower)
 }
+
+func TestCheckRegistry(t *testing.T) {
+	if err := CheckRegistry(); err != nil {
+		t.Fatal(err)
+	}
+
+	for _, name := range []string{"异界来客", "黎明抹杀者", "临空医生", "花坛新锐"} {
+		companion := getCompanion(models.Stats{Companion: name})
+		if companion.GetName() != registry.CanonicalCompanionName(name) {
+			t.Errorf("companion %s resolved to %s", name, companion.GetName())
+		}
+	}
+}
+
+func TestMonteCarloEstimator(t *testing.T) {
+	record := models.Record{
+		Attack: "11200", HP: "50000", Defense: "2000", Matching: "顺", MatchingBuff: "30",
+		CritRate: "60", CritDmg: "200", EnergyRegen: "10.8", WeakenBoost: "50",
+		OathBoost: "40", OathRegen: "17", TotalLevel: "600",
+		Companion: "无尽掠夺者", SetCard: "掠心", Stage: "IV", Weapon: "专武", Buff: "0",
+	}
+
+	analytic, _ := strconv.Atoi(NewCombatPowerEstimator().EstimateCombatPower(record).BuffedScore)
+	simulated := NewMonteCarloEstimator(2000).EstimateCombatPower(record)
+	dist := simulated.Distribution
+	if dist == nil || dist.Runs != 2000 {
+		t.Fatalf("expected a distribution over 2000 runs, got %+v", dist)
+	}
+	if !(dist.P10 <= dist.P50 && dist.P50 <= dist.P90 && dist.P10 < dist.P90) {
+		t.Errorf("percentiles out of order: %+v", dist)
+	}
+
+	// the simulation only adds downside (late energy, missed oath), so it stays close below
+	if ratio := float64(dist.Mean) / float64(analytic); ratio < 0.85 || ratio > 1.05 {
+		t.Errorf("simulated mean %d too far from analytic %d", dist.Mean, analytic)
+	}
+
+	again := NewMonteCarloEstimator(2000).EstimateCombatPower(record)
+	if *again.Distribution != *dist {
+		t.Error("simulation is not deterministic for the same record")
+	}
+}
+
+func TestMonteCarloEnergyRegen(t *testing.T) {
+	record := models.Record{
+		Attack: "11200", HP: "50000", Defense: "2000", Matching: "顺", MatchingBuff: "30",
+		CritRate: "60", CritDmg: "200", EnergyRegen: "10.8", WeakenBoost: "50",
+		OathBoost: "40", OathRegen: "17", TotalLevel: "600",
+		Companion: "无尽掠夺者", SetCard: "掠心", Stage: "IV", Weapon: "专武", Buff: "0",
+	}
+	atThreshold := NewMonteCarloEstimator(2000).EstimateCombatPower(record).Distribution
+
+	// 同一档回能，离下一档越近，最后一次能量越不容易来不及
+	record.EnergyRegen = "29"
+	nearNext := NewMonteCarloEstimator(2000).EstimateCombatPower(record).Distribution
+	if nearNext.Mean <= atThreshold.Mean || nearNext.P10 <= atThreshold.P10 {
+		t.Errorf("regen did not move the distribution: %+v vs %+v", nearNext, atThreshold)
+	}
+
+	if chance := lateEnergyChance(models.Stats{EnergyRegen: 10.8}); chance != maxLateEnergyChance {
+		t.Errorf("late energy chance at a threshold is %v", chance)
+	}
+	if chance := lateEnergyChance(models.Stats{EnergyRegen: 20.4}); chance < 0.2 || chance > 0.3 {
+		t.Errorf("late energy chance halfway to the next threshold is %v", chance)
+	}
+}
+
+func TestTimelineEstimator(t *testing.T) {
+	base := models.Record{
+		Attack: "11200", HP: "50000", Defense: "2000", Matching: "顺", MatchingBuff: "30",
+		CritRate: "60", CritDmg: "200", EnergyRegen: "10.8", WeakenBoost: "50",
+		OathBoost: "40", OathRegen: "17", TotalLevel: "600", Stage: "IV", Weapon: "专武", Buff: "0",
+	}
+
+	for _, c := range []struct {
+		companion, setCard string
+		approximate        bool // 没有技能循环的搭档沿用 v1 的次数
+	}{
+		{"潮汐之神", "神殿", false},
+		{"远空执舰官", "远空", false},
+		{"无尽掠夺者", "掠心", true},
+		{"光猎", "末夜", true},
+	} {
+		record := base
+		record.Companion, record.SetCard = c.companion, c.setCard
+
+		analyticPower := NewCombatPowerEstimator().EstimateCombatPower(record)
+		referencePower := NewTimelineEstimator(60).EstimateCombatPower(record)
+		analytic, _ := strconv.Atoi(analyticPower.Score)
+		reference, _ := strconv.Atoi(referencePower.Score)
+		long, _ := strconv.Atoi(NewTimelineEstimator(120).EstimateCombatPower(record).Score)
+
+		if referencePower.Approximate != c.approximate || analyticPower.Approximate {
+			t.Errorf("%s: timeline approximate %v, analytic approximate %v", c.companion, referencePower.Approximate, analyticPower.Approximate)
+		}
+		level, _ := levels.Lookup("光-10_上-稳定")
+		if at := NewTimelineEstimator(60).(LevelAwareEstimator).EstimateCombatPowerAt(record, level); at.Approximate != c.approximate {
+			t.Errorf("%s: level estimate approximate %v", c.companion, at.Approximate)
+		}
+
+		// the reference fight is what the fixed counts were tuned for
+		if ratio := float64(reference) / float64(analytic); ratio < 0.85 || ratio > 1.15 {
+			t.Errorf("%s: timeline %d too far from analytic %d", c.companion, reference, analytic)
+		}
+		if ratio := float64(long) / float64(reference); ratio < 1.7 || ratio > 2.3 {
+			t.Errorf("%s: doubling the fight scaled the score by %.2f", c.companion, ratio)
+		}
+	}
+}
+
+func TestLevelAwareEstimate(t *testing.T) {
+	record := models.Record{
+		Attack: "11200", HP: "50000", Defense: "2000", Matching: "顺", MatchingBuff: "30",
+		CritRate: "60", CritDmg: "200", EnergyRegen: "10.8", WeakenBoost: "50",
+		OathBoost: "40", OathRegen: "17", TotalLevel: "600",
+		Companion: "逐光骑士", SetCard: "逐光", Stage: "IV", Weapon: "专武", Buff: "0",
+	}
+	e := NewCombatPowerEstimator()
+
+	reference := e.EstimateCombatPower(record)
+	if got := e.(LevelAwareEstimator).EstimateCombatPowerAt(record, levels.Level{Enemy: levels.Reference}); got.BuffedScore != reference.BuffedScore {
+		t.Errorf("reference enemy changed the score: %s vs %s", got.BuffedScore, reference.BuffedScore)
+	}
+
+	scores := map[string]int{}
+	for _, key := range []string{"光-10_上-稳定", "开放-300_下-稳定", "开放-60_下-波动"} {
+		level, ok := levels.Lookup(key)
+		if !ok {
+			t.Fatalf("level %s is not in the catalogue", key)
+		}
+		combatPower := e.(LevelAwareEstimator).EstimateCombatPowerAt(record, level)
+		if combatPower.Level == nil || combatPower.Level.Key != key {
+			t.Fatalf("missing level estimate for %s: %+v", key, combatPower.Level)
+		}
+		// 记录不足、没有拟合通关战力的关卡不给出结论
+		if combatPower.Level.Threshold != 0 || combatPower.Level.Verdict != "未知" {
+			t.Errorf("level %s without a threshold has a verdict: %+v", key, combatPower.Level)
+		}
+		scores[key] = combatPower.Level.BuffedScore
+	}
+
+	if !(scores["光-10_上-稳定"] > scores["开放-300_下-稳定"] && scores["开放-300_下-稳定"] > scores["开放-60_下-波动"]) {
+		t.Errorf("expected damage to drop with tougher enemies, got %v", scores)
+	}
+
+	if _, ok := levels.Lookup("2025-01-06-B4"); !ok {
+		t.Error("championships level is not in the catalogue")
+	}
+
+	levels.SetThresholds(map[string]float64{"光-10_上-稳定": float64(scores["光-10_上-稳定"]) * 2})
+	defer levels.SetThresholds(nil)
+	level, _ := levels.Lookup("光-10_上-稳定")
+	if got := e.(LevelAwareEstimator).EstimateCombatPowerAt(record, level).Level; got.Ratio != 0.5 || got.Verdict != "不足" {
+		t.Errorf("unexpected verdict against a fitted threshold: %+v", got)
+	}
+}
+
+func TestLevelEstimateVerdict(t *testing.T) {
+	tests := []struct {
+		threshold, score float64
+		ratio            float64
+		verdict          string
+	}{
+		{0, 900, 0, "未知"},
+		{1000, 1500, 1.5, "可通关"},
+		{1000, 1000, 1, "可通关"},
+		{1000, 999, 1, "可通关"}, // 比例取两位小数
+		{1000, 900, 0.9, "勉强"},
+		{1000, 800, 0.8, "勉强"},
+		{1000, 790, 0.79, "不足"},
+		{1000, 0, 0, "不足"},
+	}
+	for _, tt := range tests {
+		got := levelEstimate(levels.Level{Key: "光-10_上-稳定", Threshold: tt.threshold}, tt.score)
+		if got.Ratio != tt.ratio || got.Verdict != tt.verdict || got.Threshold != int(tt.threshold) {
+			t.Errorf("threshold %v, score %v: got %+v, want %v %s", tt.threshold, tt.score, got, tt.ratio, tt.verdict)
+		}
+	}
+}
+
+func TestVersionedEstimator(t *testing.T) {
+	e := NewVersionedEstimator("")
+	record := models.Record{
+		Attack: "11200", HP: "50000", Defense: "2000", Matching: "顺", MatchingBuff: "30",
+		CritRate: "60", CritDmg: "200", EnergyRegen: "10.8", WeakenBoost: "50",
+		OathBoost: "40", OathRegen: "17", TotalLevel: "600",
+		Companion: "潮汐之神", SetCard: "神殿", Stage: "IV", Weapon: "专武", Buff: "0",
+	}
+
+	// records stored before versions existed keep the legacy score
+	legacy := e.EstimateCombatPower(record)
+	if legacy.Version != LegacyVersion || legacy.BuffedScore != NewCombatPowerEstimator().EstimateCombatPower(record).BuffedScore {
+		t.Errorf("unversioned record was not scored with %s: %+v", LegacyVersion, legacy)
+	}
+
+	record.EstimatorVersion = VersionV2
+	if got := e.EstimateCombatPower(record); got.Version != VersionV2 || got.BuffedScore != NewTimelineEstimator(60).EstimateCombatPower(record).BuffedScore {
+		t.Errorf("record was not scored with its own version: %+v", got)
+	}
+
+	record.EstimatorVersion = "v99"
+	if got := e.EstimateCombatPower(record); got.BuffedScore != models.NoData {
+		t.Errorf("unknown version must not be scored, got %+v", got)
+	}
+}
+
+func TestSupportCompanion(t *testing.T) {
+	record := models.Record{
+		Attack: "11200", HP: "50000", Defense: "2000", Matching: "顺", MatchingBuff: "30",
+		CritRate: "60", CritDmg: "200", EnergyRegen: "10.8", WeakenBoost: "50",
+		OathBoost: "40", OathRegen: "17", TotalLevel: "600",
+		Companion: "潮汐之神", SetCard: "神殿", Stage: "IV", Weapon: "专武", Buff: "0",
+	}
+
+	for _, e := range []CombatPowerEstimator{NewCombatPowerEstimator(), NewTimelineEstimator(60)} {
+		without, _ := strconv.Atoi(e.EstimateCombatPower(record).BuffedScore)
+
+		withSupport := record
+		withSupport.SupportCompanion = "逐光骑士"
+		with, _ := strconv.Atoi(e.EstimateCombatPower(withSupport).BuffedScore)
+		if with <= without {
+			t.Errorf("%T: support companion did not add damage: %d vs %d", e, with, without)
+		}
+	}
+
+	// 协助伤害取自协助搭档自己的协助技能
+	scores := map[string]int{}
+	for _, support := range []string{"逐光骑士", "深空飞行员", "深渊主宰"} {
+		withSupport := record
+		withSupport.SupportCompanion = support
+		scores[support], _ = strconv.Atoi(NewCombatPowerEstimator().EstimateCombatPower(withSupport).BuffedScore)
+
+		companion, _ := registry.GetCompanion(support)
+		hit, own := supportHit(companion, models.Stats{}), getCompanion(models.Stats{Companion: support}).GetSupportSkill(models.Stats{})
+		if hit.Base != own.Base || hit.AttackRate != own.AttackRate || hit.HpRate != own.HpRate {
+			t.Errorf("%s: support hit %+v is not its 协助 skill %+v", support, hit, own)
+		}
+	}
+	if scores["逐光骑士"] == scores["深空飞行员"] || scores["逐光骑士"] == scores["深渊主宰"] {
+		t.Errorf("support companions are not told apart: %v", scores)
+	}
+
+	// 协助搭档默认不给其他技能增伤
+	flow := models.CompanionFlow{Periods: []models.CompanionPeriod{{SkillSet: models.CompanionSkillSet{Skills: []models.Skill{
+		{Name: supportSkillName, Count: 1}, {Name: "普攻", Count: 10, AttackRate: 100},
+	}}}}}
+	applySupport(&flow, models.Stats{Companion: "潮汐之神", SupportCompanion: "逐光骑士"})
+	if skills := flow.Periods[0].SkillSet.Skills; skills[1].DamageBoost != 0 || skills[0].AttackRate == 0 {
+		t.Errorf("unexpected skills with a support companion: %+v", skills)
+	}
+
+	// 协助技能只有次数时按倍率参数估算
+	tides, _ := registry.GetCompanion("潮汐之神")
+	if hit := supportHit(tides, models.Stats{}); hit.Base != supportHitBase || hit.HpRate == 0 {
+		t.Errorf("unexpected fallback support hit %+v", hit)
+	}
+}
+
+func TestWeaponCatalogue(t *testing.T) {
+	record := models.Record{
+		LevelType: "开放", LevelNumber: "300_下", LevelMode: "稳定",
+		Attack: "11200", HP: "50000", Defense: "2000", Matching: "顺", MatchingBuff: "30",
+		CritRate: "60", CritDmg: "200", EnergyRegen: "10.8", WeakenBoost: "50",
+		OathBoost: "40", OathRegen: "17", TotalLevel: "480",
+		Companion: "潮汐之神", SetCard: "神殿", Stage: "IV", Weapon: "专武", Buff: "0",
+	}
+	e := NewCombatPowerEstimator()
+	legacy := e.EstimateCombatPower(record).BuffedScore
+
+	record.Weapon = registry.SignatureWeaponID("潮汐之神")
+	if got := e.EstimateCombatPower(record).BuffedScore; got != legacy {
+		t.Errorf("signature weapon at the first refinement scored %s, the weapon type %s", got, legacy)
+	}
+	if _, err := record.ValidateOrbit(); err != nil {
+		t.Errorf("signature weapon of the companion rejected: %v", err)
+	}
+
+	record.WeaponRefinement = "5"
+	refined, _ := strconv.Atoi(e.EstimateCombatPower(record).BuffedScore)
+	if base, _ := strconv.Atoi(legacy); refined <= base {
+		t.Errorf("refinement did not add damage: %d vs %d", refined, base)
+	}
+
+	record.Weapon = registry.SignatureWeaponID("光猎")
+	if _, err := record.ValidateOrbit(); err == nil || !strings.Contains(err.Error(), "武器") {
+		t.Errorf("signature weapon of another companion accepted: %v", err)
+	}
+}
+
+func TestNamedWeapons(t *testing.T) {
+	record := models.Record{
+		Attack: "11200", HP: "50000", Defense: "2000", Matching: "顺", MatchingBuff: "30",
+		CritRate: "60", CritDmg: "200", EnergyRegen: "10.8", WeakenBoost: "50",
+		OathBoost: "40", OathRegen: "17", TotalLevel: "480",
+		Companion: "遥远少年", SetCard: "无套装", Stage: "无套装", Weapon: registry.WeaponGreatsword, Buff: "0",
+	}
+	e := NewCombatPowerEstimator()
+	bare, _ := strconv.Atoi(e.EstimateCombatPower(record).BuffedScore)
+
+	// 具体武器的1级精炼效果不在武器类型的倍率里
+	record.Weapon = registry.WeaponGreatsword + "·裂空"
+	named, _ := strconv.Atoi(e.EstimateCombatPower(record).BuffedScore)
+	record.WeaponRefinement = "5"
+	refined, _ := strconv.Atoi(e.EstimateCombatPower(record).BuffedScore)
+	if !(bare < named && named < refined) {
+		t.Errorf("expected %d < %d < %d", bare, named, refined)
+	}
+
+	// 每把专武精炼的是自己搭档的技能
+	effects := map[string]bool{}
+	for _, weapon := range registry.Weapons() {
+		if weapon.Companion == "" {
+			continue
+		}
+		if len(weapon.Effects) == 0 || weapon.Effects[0].Skill == "所有" {
+			t.Errorf("signature weapon %s has no effect of its own: %+v", weapon.ID, weapon.Effects)
+			continue
+		}
+		effects[weapon.Effects[0].Skill] = true
+	}
+	if len(effects) < 10 {
+		t.Errorf("signature weapons share their effects: %v", effects)
+	}
+}
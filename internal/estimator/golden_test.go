@@ -0,0 +1,260 @@
+package estimator
+
+import (
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"math"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"testing"
+
+	"lysk-battle-record/internal/models"
+	"lysk-battle-record/internal/registry"
+)
+
+// Run `go test ./internal/estimator -run TestGolden -update-goldens` after an intended change
+// to a companion or set card, and review the diff report and the changed files.
+var updateGoldens = flag.Bool("update-goldens", false, "rewrite the estimator golden files")
+
+const (
+	goldenDir = "testdata/golden"
+	// goldenTolerance is the relative drift allowed before a case fails
+	goldenTolerance = 0.001
+)
+
+var goldenStages = []string{"I", "II", "III", "IV"}
+
+// goldenPanels is the fixed panel matrix every combination is estimated with.
+var goldenPanels = map[string]models.Record{
+	"均衡顺": {
+		Attack: "11200", HP: "50000", Defense: "2000", Matching: "顺", MatchingBuff: "30",
+		CritRate: "60", CritDmg: "200", EnergyRegen: "10.8", WeakenBoost: "50",
+		OathBoost: "40", OathRegen: "17", TotalLevel: "600", Buff: "0",
+	},
+	"生命逆": {
+		Attack: "6500", HP: "150000", Defense: "2800", Matching: "逆", MatchingBuff: "10",
+		CritRate: "25", CritDmg: "180", EnergyRegen: "24", WeakenBoost: "30",
+		OathBoost: "10", OathRegen: "0", TotalLevel: "380", Buff: "40",
+	},
+	"防御顺": {
+		Attack: "7900", HP: "90000", Defense: "5000", Matching: "顺", MatchingBuff: "20",
+		CritRate: "65", CritDmg: "230", EnergyRegen: "39.6", WeakenBoost: "150",
+		OathBoost: "20", OathRegen: "25", TotalLevel: "300", Buff: "10",
+	},
+}
+
+type goldenScore struct {
+	Score       int `json:"score"`
+	BuffedScore int `json:"buffed_score"`
+	WeakenScore int `json:"weaken_score"`
+	CritScore   int `json:"crit_score"`
+}
+
+type goldenDiff struct {
+	companion string
+	key       string
+	field     string
+	want      int
+	got       int
+}
+
+func (d goldenDiff) change() float64 {
+	if d.want == 0 {
+		return math.Inf(1)
+	}
+	return float64(d.got-d.want) / float64(d.want)
+}
+
+func TestGolden(t *testing.T) {
+	e := NewCombatPowerEstimator()
+
+	var diffs []goldenDiff
+	var missing []string
+	for _, companion := range registry.Companions() {
+		got := goldenScores(e, companion)
+		path := filepath.Join(goldenDir, companion.Name+".json")
+
+		want, err := readGolden(path)
+		if errors.Is(err, os.ErrNotExist) && !*updateGoldens {
+			missing = append(missing, companion.Name)
+			continue
+		} else if err != nil && !errors.Is(err, os.ErrNotExist) {
+			t.Fatalf("read %s: %v", path, err)
+		}
+
+		if want == nil {
+			if err := writeGolden(path, got); err != nil {
+				t.Fatalf("write %s: %v", path, err)
+			}
+			t.Logf("created %s", path)
+			continue
+		}
+
+		companionDiffs := compareGolden(companion.Name, want, got)
+		diffs = append(diffs, companionDiffs...)
+
+		if *updateGoldens && len(companionDiffs) > 0 {
+			if err := writeGolden(path, got); err != nil {
+				t.Fatalf("write %s: %v", path, err)
+			}
+		}
+	}
+
+	if len(missing) > 0 {
+		t.Errorf("no golden file for %s, run with -update-goldens", strings.Join(missing, ", "))
+	}
+	if len(diffs) == 0 {
+		return
+	}
+
+	report := goldenReport(diffs)
+	if *updateGoldens {
+		t.Logf("updated golden files:\n%s", report)
+		return
+	}
+	t.Errorf("combat power drifted from the golden files, run with -update-goldens if this is intended:\n%s", report)
+}
+
+// goldenScores estimates every set card, stage, weapon type and panel for the companion.
+func goldenScores(e CombatPowerEstimator, companion registry.Companion) map[string]goldenScore {
+	scores := map[string]goldenScore{}
+	for _, setCard := range goldenSetCards(companion) {
+		stages := goldenStages
+		if setCard == registry.NoSetCard {
+			stages = []string{registry.NoSetCard}
+		}
+		for _, stage := range stages {
+			for _, weapon := range goldenWeapons(companion) {
+				for panelName, panel := range goldenPanels {
+					record := panel
+					record.Companion, record.SetCard, record.Stage, record.Weapon = companion.Name, setCard, stage, weapon
+
+					combatPower := e.EstimateCombatPower(record)
+					key := strings.Join([]string{setCard, stage, weapon, panelName}, "/")
+					scores[key] = goldenScore{
+						Score:       atoi(combatPower.Score),
+						BuffedScore: atoi(combatPower.BuffedScore),
+						WeakenScore: atoi(combatPower.WeakenScore),
+						CritScore:   atoi(combatPower.CritScore),
+					}
+				}
+			}
+		}
+	}
+	return scores
+}
+
+// goldenSetCards returns every set card the companion can be used with, no set card included.
+func goldenSetCards(companion registry.Companion) []string {
+	partner, _ := registry.GetPartner(companion.Partner)
+	var setCards []string
+	for _, setCard := range partner.SetCards {
+		if companion.AllowsSetCard(setCard) {
+			setCards = append(setCards, setCard)
+		}
+	}
+	return setCards
+}
+
+// goldenWeapons returns every weapon type, 专武 only for companions that have one.
+func goldenWeapons(companion registry.Companion) []string {
+	var weapons []string
+	for _, weapon := range registry.Weapons() {
+		if weapon.ID != weapon.Type || (weapon.Type == registry.WeaponSignature && !companion.SignatureWeapon) {
+			continue
+		}
+		weapons = append(weapons, weapon.Type)
+	}
+	return weapons
+}
+
+func compareGolden(companion string, want, got map[string]goldenScore) []goldenDiff {
+	var diffs []goldenDiff
+	for key, g := range got {
+		w, ok := want[key]
+		if !ok {
+			diffs = append(diffs, goldenDiff{companion: companion, key: key, field: "新增", got: g.BuffedScore})
+			continue
+		}
+
+		for _, field := range []struct {
+			name      string
+			want, got int
+		}{
+			{"Score", w.Score, g.Score},
+			{"BuffedScore", w.BuffedScore, g.BuffedScore},
+			{"WeakenScore", w.WeakenScore, g.WeakenScore},
+			{"CritScore", w.CritScore, g.CritScore},
+		} {
+			if math.Abs(float64(field.got-field.want)) > goldenTolerance*math.Abs(float64(field.want)) {
+				diffs = append(diffs, goldenDiff{companion: companion, key: key, field: field.name, want: field.want, got: field.got})
+			}
+		}
+	}
+
+	for key, w := range want {
+		if _, ok := got[key]; !ok {
+			diffs = append(diffs, goldenDiff{companion: companion, key: key, field: "移除", want: w.BuffedScore})
+		}
+	}
+	return diffs
+}
+
+// goldenReport lists the drift per companion, largest changes first.
+func goldenReport(diffs []goldenDiff) string {
+	sort.Slice(diffs, func(i, j int) bool {
+		if diffs[i].companion != diffs[j].companion {
+			return diffs[i].companion < diffs[j].companion
+		}
+		return math.Abs(diffs[i].change()) > math.Abs(diffs[j].change())
+	})
+
+	var b strings.Builder
+	for i, d := range diffs {
+		if i == 0 || diffs[i-1].companion != d.companion {
+			fmt.Fprintf(&b, "%s:\n", d.companion)
+		}
+		switch d.field {
+		case "新增", "移除":
+			fmt.Fprintf(&b, "  %-28s %s\n", d.key, d.field)
+		default:
+			fmt.Fprintf(&b, "  %-28s %-12s %10d -> %10d (%+.2f%%)\n", d.key, d.field, d.want, d.got, d.change()*100)
+		}
+	}
+	fmt.Fprintf(&b, "%d values changed", len(diffs))
+	return b.String()
+}
+
+func readGolden(path string) (map[string]goldenScore, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	var scores map[string]goldenScore
+	if err := json.Unmarshal(data, &scores); err != nil {
+		return nil, err
+	}
+	return scores, nil
+}
+
+func writeGolden(path string, scores map[string]goldenScore) error {
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		return err
+	}
+	// map keys are sorted by encoding/json, so unchanged values keep their lines
+	data, err := json.MarshalIndent(scores, "", "  ")
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(path, append(data, '\n'), 0o644)
+}
+
+func atoi(s string) int {
+	v, _ := strconv.Atoi(s)
+	return v
+}
@@ -0,0 +1,86 @@
+package estimator
+
+import (
+	"math"
+
+	"lysk-battle-record/internal/estimator/levels"
+	"lysk-battle-record/internal/models"
+	"lysk-battle-record/internal/registry"
+)
+
+// LevelAwareEstimator is implemented by estimators that can estimate against the enemies of a
+// specific level instead of the reference enemy.
+type LevelAwareEstimator interface {
+	EstimateCombatPowerAt(record models.Record, level levels.Level) models.CombatPower
+}
+
+// EstimateForLevel estimates the record against the enemies of its own level when both the
+// estimator and the catalogue support it, and against the reference enemy otherwise.
+func EstimateForLevel(e CombatPowerEstimator, record models.Record) models.CombatPower {
+	if aware, ok := e.(LevelAwareEstimator); ok {
+		if level, ok := levels.Lookup(record.GenerateLevelKey()); ok {
+			return aware.EstimateCombatPowerAt(record, level)
+		}
+	}
+	return e.EstimateCombatPower(record)
+}
+
+// target is an enemy resolved for the element and matching of the player.
+type target struct {
+	defense      float64
+	resistance   float64
+	weakenBoost  float64
+	weakenUptime float64
+}
+
+var referenceTarget = target{
+	defense:      levels.Reference.DefenseValue(),
+	weakenBoost:  levels.Reference.WeakenBoost,
+	weakenUptime: 1,
+}
+
+func newTarget(stats models.Stats, enemy levels.Enemy) target {
+	// the element of the player is the one of the partner's own levels
+	var element string
+	if companion, ok := registry.GetCompanion(stats.Companion); ok {
+		if partner, ok := registry.GetPartner(companion.Partner); ok {
+			element = partner.LevelType
+		}
+	}
+
+	return target{
+		defense:      enemy.DefenseValue(),
+		resistance:   enemy.Resistance(element),
+		weakenBoost:  enemy.WeakenBoost,
+		weakenUptime: enemy.WeakenUptime(stats.Matching),
+	}
+}
+
+// weakenRate scales the weaken time of a period by the uptime against the target.
+func (t target) weakenRate(rate float64) float64 {
+	return math.Min(1, rate*t.weakenUptime)
+}
+
+func levelEstimate(level levels.Level, buffedScore float64) *models.LevelEstimate {
+	estimate := &models.LevelEstimate{
+		Key:         level.Key,
+		EnemyLevel:  level.Enemy.Level,
+		BuffedScore: int(buffedScore),
+		Threshold:   int(level.Threshold),
+		Verdict:     "未知",
+	}
+
+	if level.Threshold > 0 {
+		estimate.Ratio = math.Round(buffedScore/level.Threshold*100) / 100
+		switch {
+		case estimate.Ratio >= levels.ClearRatio:
+			estimate.Verdict = "可通关"
+		case estimate.Ratio >= levels.MarginalRatio:
+			estimate.Verdict = "勉强"
+		default:
+			estimate.Verdict = "不足"
+		}
+	}
+
+	return estimate
+}
@@ -0,0 +1,195 @@
+// Package levels is the enemy catalogue of the estimator. Levels are looked up by the key from
+// models.Record.GenerateLevelKey, so the estimator can tell 光 10 from 开放 300 波动.
+package levels
+
+import (
+	"sort"
+	"strconv"
+	"strings"
+	"sync/atomic"
+)
+
+const (
+	// referenceEnemyLevel is the enemy the legacy estimator assumed for every level
+	referenceEnemyLevel = 80
+
+	// Thresholds of BuffedScore / Threshold used for the verdict of a level estimate.
+	ClearRatio    = 1.0
+	MarginalRatio = 0.8
+
+	// ThresholdPercentile is the share of the players who cleared a level with a lower score
+	// than its threshold, the same percentile as the suggested combat power of a level.
+	ThresholdPercentile = 0.25
+	// MinThresholdRecords is the number of cleared records a level needs for a threshold.
+	MinThresholdRecords = 20
+)
+
+// Enemy describes the enemies of a level as far as the damage formula is concerned.
+type Enemy struct {
+	Level       int                // 敌人等级
+	Defense     float64            // 敌人防御，0 为按等级计算
+	Resistances map[string]float64 // 元素 -> 抗性%
+	WeakenBarHP float64            // 虚弱条血量，相对于参考敌人，0 视为 1
+	WeakenBoost float64            // 敌人受到的额外伤害%
+	Matching    map[string]float64 // 顺/逆 -> 虚弱时间倍率，缺省为 1
+}
+
+// Level is a catalogue entry resolved for one level key.
+type Level struct {
+	Key   string
+	Enemy Enemy
+	// Threshold is the buffed score needed to clear the level, 0 if unknown. It is only set
+	// once it is fitted against cleared records (see FitThresholds), guessed values would give
+	// false verdicts.
+	Threshold float64
+}
+
+// Reference is the enemy the fixed legacy numbers were tuned for. Estimating against it gives
+// the same result as the estimator without a level context.
+var Reference = Enemy{Level: referenceEnemyLevel, WeakenBarHP: 1, WeakenBoost: 1}
+
+// DefenseValue returns the defence term of the damage formula.
+func (e Enemy) DefenseValue() float64 {
+	if e.Defense > 0 {
+		return e.Defense
+	}
+	return float64(e.Level*3 + 100)
+}
+
+func (e Enemy) Resistance(element string) float64 {
+	return e.Resistances[element]
+}
+
+// WeakenUptime scales the time spent in weaken: a tougher weaken bar takes longer to break,
+// and some levels punish the wrong matching harder than others.
+func (e Enemy) WeakenUptime(matching string) float64 {
+	uptime := 1.0
+	if e.WeakenBarHP > 0 {
+		uptime /= e.WeakenBarHP
+	}
+	if factor, ok := e.Matching[matching]; ok {
+		uptime *= factor
+	}
+	return uptime
+}
+
+// levelRange describes a run of levels whose enemies scale linearly from the first to the last
+// level. The numbers are approximations and are meant to be tuned against cleared records.
+type levelRange struct {
+	LevelType string
+	Mode      string
+	From, To  int
+
+	EnemyLevel  [2]int
+	WeakenBarHP [2]float64
+	Resistances map[string]float64
+	Matching    map[string]float64
+}
+
+var orbitRanges = []levelRange{
+	{LevelType: "光", Mode: "稳定", From: 1, To: 210, EnemyLevel: [2]int{10, 90}, WeakenBarHP: [2]float64{0.3, 1.2}},
+	{LevelType: "火", Mode: "稳定", From: 1, To: 210, EnemyLevel: [2]int{10, 90}, WeakenBarHP: [2]float64{0.3, 1.2}},
+	{LevelType: "冰", Mode: "稳定", From: 1, To: 210, EnemyLevel: [2]int{10, 90}, WeakenBarHP: [2]float64{0.3, 1.2}},
+	{LevelType: "能量", Mode: "稳定", From: 1, To: 180, EnemyLevel: [2]int{10, 90}, WeakenBarHP: [2]float64{0.3, 1.2}},
+	{LevelType: "引力", Mode: "稳定", From: 1, To: 150, EnemyLevel: [2]int{10, 90}, WeakenBarHP: [2]float64{0.3, 1.2}},
+	{LevelType: "开放", Mode: "稳定", From: 1, To: 300, EnemyLevel: [2]int{10, 100}, WeakenBarHP: [2]float64{0.3, 1.4}},
+	{
+		LevelType: "开放", Mode: "波动", From: 1, To: 60,
+		EnemyLevel:  [2]int{80, 110},
+		WeakenBarHP: [2]float64{1, 1.6},
+		// 波动关卡的敌人抗性更高，逆属性更难打出虚弱
+		Resistances: map[string]float64{"光": 10, "冰": 10, "火": 10, "能量": 10, "引力": 10},
+		Matching:    map[string]float64{"逆": 0.8},
+	},
+}
+
+// championships levels do not change with the round, only with the difficulty.
+var championships = map[string]Level{
+	"A4": {Enemy: Enemy{Level: 90, WeakenBarHP: 1.2, WeakenBoost: 1}},
+	"B4": {Enemy: Enemy{Level: 95, WeakenBarHP: 1.3, WeakenBoost: 1}},
+	"C4": {Enemy: Enemy{Level: 100, WeakenBarHP: 1.4, WeakenBoost: 1}},
+}
+
+var thresholds atomic.Pointer[map[string]float64]
+
+func init() {
+	thresholds.Store(&map[string]float64{})
+}
+
+// FitThresholds returns the threshold of every level key with at least MinThresholdRecords
+// cleared scores: the ThresholdPercentile of the scores. The scores have to be estimated
+// against the level, like the estimate they are compared with.
+func FitThresholds(scores map[string][]float64) map[string]float64 {
+	fitted := map[string]float64{}
+	for key, levelScores := range scores {
+		if len(levelScores) < MinThresholdRecords {
+			continue
+		}
+
+		sorted := append([]float64(nil), levelScores...)
+		sort.Float64s(sorted)
+		fitted[key] = sorted[int(float64(len(sorted))*ThresholdPercentile)]
+	}
+	return fitted
+}
+
+// SetThresholds replaces the thresholds used by Lookup.
+func SetThresholds(fitted map[string]float64) {
+	thresholds.Store(&fitted)
+}
+
+// Lookup resolves a key from GenerateLevelKey, either "关卡-关数-模式" or "轮次开始日期-A4".
+func Lookup(key string) (Level, bool) {
+	level, ok := lookupEnemy(key)
+	if ok {
+		level.Threshold = (*thresholds.Load())[key]
+	}
+	return level, ok
+}
+
+func lookupEnemy(key string) (Level, bool) {
+	parts := strings.Split(key, "-")
+
+	if levelType := parts[len(parts)-1]; len(parts) > 1 {
+		if level, ok := championships[levelType]; ok {
+			level.Key = key
+			return level, true
+		}
+	}
+
+	if len(parts) != 3 {
+		return Level{}, false
+	}
+	levelType, mode := parts[0], parts[2]
+	number, err := strconv.Atoi(strings.Split(parts[1], "_")[0])
+	if err != nil {
+		return Level{}, false
+	}
+
+	for _, r := range orbitRanges {
+		if r.LevelType != levelType || r.Mode != mode || number < r.From || number > r.To {
+			continue
+		}
+
+		t := 0.0
+		if r.To > r.From {
+			t = float64(number-r.From) / float64(r.To-r.From)
+		}
+		return Level{
+			Key: key,
+			Enemy: Enemy{
+				Level:       int(lerp(float64(r.EnemyLevel[0]), float64(r.EnemyLevel[1]), t) + 0.5),
+				Resistances: r.Resistances,
+				WeakenBarHP: lerp(r.WeakenBarHP[0], r.WeakenBarHP[1], t),
+				WeakenBoost: 1,
+				Matching:    r.Matching,
+			},
+		}, true
+	}
+
+	return Level{}, false
+}
+
+func lerp(from, to, t float64) float64 {
+	return from + (to-from)*t
+}
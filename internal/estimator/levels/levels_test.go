@@ -0,0 +1,51 @@
+package levels
+
+import "testing"
+
+func TestFitThresholds(t *testing.T) {
+	scores := map[string][]float64{"光-10_上-稳定": nil, "开放-60_下-波动": nil}
+	for i := MinThresholdRecords; i > 0; i-- {
+		scores["光-10_上-稳定"] = append(scores["光-10_上-稳定"], float64(i*100))
+	}
+	for i := 1; i < MinThresholdRecords; i++ {
+		scores["开放-60_下-波动"] = append(scores["开放-60_下-波动"], float64(i*100))
+	}
+
+	fitted := FitThresholds(scores)
+	// 20 条记录中第 6 低的分数，即 25% 的玩家低于它
+	if fitted["光-10_上-稳定"] != 600 {
+		t.Errorf("expected the 25th percentile 600, got %v", fitted["光-10_上-稳定"])
+	}
+	if _, ok := fitted["开放-60_下-波动"]; ok {
+		t.Error("level with too few records got a threshold")
+	}
+	if scores["光-10_上-稳定"][0] != float64(MinThresholdRecords*100) {
+		t.Error("fitting sorted the scores of the caller")
+	}
+}
+
+func TestLookupThreshold(t *testing.T) {
+	SetThresholds(map[string]float64{"光-10_上-稳定": 5000, "2025-01-06-B4": 8000})
+	defer SetThresholds(nil)
+
+	tests := []struct {
+		key       string
+		threshold float64
+	}{
+		{"光-10_上-稳定", 5000},
+		{"光-20_上-稳定", 0},
+		{"2025-01-06-B4", 8000},
+		// 锦标赛的通关战力按轮次区分
+		{"2025-01-20-B4", 0},
+	}
+	for _, tt := range tests {
+		level, ok := Lookup(tt.key)
+		if !ok || level.Threshold != tt.threshold {
+			t.Errorf("%s: got %+v %v, want threshold %v", tt.key, level, ok, tt.threshold)
+		}
+	}
+
+	if level, _ := Lookup("2025-01-06-B4"); level.Enemy.Level != 95 {
+		t.Errorf("threshold changed the enemy: %+v", level.Enemy)
+	}
+}
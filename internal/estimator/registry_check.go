@@ -0,0 +1,71 @@
+package estimator
+
+import (
+	"fmt"
+	"strings"
+
+	"lysk-battle-record/internal/models"
+	"lysk-battle-record/internal/registry"
+)
+
+// CheckRegistry verifies that the registry and the estimator agree: every registered companion
+// has an implementation, every implementation is registered, and every signature set card has
+// a set card implementation, and weapon effects name skills of the flows. It is run at startup
+// so drift fails fast.
+func CheckRegistry() error {
+	if err := registry.Validate(); err != nil {
+		return err
+	}
+
+	var problems []string
+	for _, companion := range registry.Companions() {
+		if _, ok := companionImpls[companion.Name]; !ok {
+			problems = append(problems, fmt.Sprintf("companion %s has no estimator implementation", companion.Name))
+		}
+
+		if companion.SetCard != "" {
+			setCard := getSetCard(models.Stats{SetCard: companion.SetCard})
+			if setCard.GetName() != companion.SetCard {
+				problems = append(problems, fmt.Sprintf("set card %s of companion %s has no estimator implementation", companion.SetCard, companion.Name))
+			}
+		}
+	}
+
+	for _, weapon := range registry.Weapons() {
+		for _, effect := range append(weapon.Base, weapon.Effects...) {
+			if effect.Skill != "所有" && !weaponSkillExists(weapon, effect.Skill) {
+				problems = append(problems, fmt.Sprintf("weapon %s refines skill %s, which no flow has", weapon.ID, effect.Skill))
+			}
+		}
+	}
+
+	for name := range companionImpls {
+		if companion, ok := registry.GetCompanion(name); !ok || companion.Name != name {
+			problems = append(problems, fmt.Sprintf("estimator implementation %s is not a registered companion name", name))
+		}
+	}
+
+	if len(problems) > 0 {
+		return fmt.Errorf("estimator does not match registry:\n  - %s", strings.Join(problems, "\n  - "))
+	}
+	return nil
+}
+
+// weaponSkillExists reports whether a companion using the weapon casts the skill: the owner of
+// a signature weapon with its set card, or any companion for the other weapons.
+func weaponSkillExists(weapon registry.Weapon, skill string) bool {
+	for _, companion := range registry.Companions() {
+		if weapon.Companion != "" && weapon.Companion != companion.Name {
+			continue
+		}
+		stats := models.Stats{Companion: companion.Name, SetCard: companion.SetCard, Stage: "IV", Weapon: weapon.Type, EnergyRegen: 10.8}
+		for _, period := range getCompanion(stats).GetCompanionFlow(stats).Periods {
+			for _, s := range period.SkillSet.Skills {
+				if s.Name == skill {
+					return true
+				}
+			}
+		}
+	}
+	return false
+}
@@ -0,0 +1,190 @@
+package estimator
+
+import (
+	"fmt"
+	"hash/fnv"
+	"math"
+	"math/rand"
+	"sort"
+	"strings"
+
+	"lysk-battle-record/internal/estimator/levels"
+	"lysk-battle-record/internal/models"
+)
+
+const (
+	defaultSimulationRuns = 1000
+
+	// weakenJitter is the relative spread of the time spent in weaken windows between battles
+	weakenJitter = 0.15
+	// maxLateEnergyChance is the chance that the last energy charge arrives too late to be used
+	// when 加速回能 is right at its threshold. It drops to 0 at the next threshold.
+	maxLateEnergyChance = 0.5
+	// oathMissChance is the chance that the oath skill misses the weaken window
+	oathMissChance = 0.1
+)
+
+// MonteCarloEstimator simulates a number of battles instead of computing the expected damage.
+// Crits, weaken windows, the number of active skills and the oath timing are sampled, so builds
+// with the same expected damage can be told apart by their consistency.
+type MonteCarloEstimator struct {
+	runs int
+}
+
+func NewMonteCarloEstimator(runs int) CombatPowerEstimator {
+	if runs <= 0 {
+		runs = defaultSimulationRuns
+	}
+	return &MonteCarloEstimator{runs: runs}
+}
+
+func (e *MonteCarloEstimator) EstimateCombatPower(record models.Record) models.CombatPower {
+	return e.simulate(record.ToStats(), referenceTarget)
+}
+
+func (e *MonteCarloEstimator) EstimateCombatPowerAt(record models.Record, level levels.Level) models.CombatPower {
+	stats := record.ToStats()
+	combatPower := e.simulate(stats, newTarget(stats, level.Enemy))
+	combatPower.Level = levelEstimate(level, float64(combatPower.Distribution.Mean))
+	return combatPower
+}
+
+func (e *MonteCarloEstimator) simulate(stats models.Stats, t target) models.CombatPower {
+	flow := buildCompanionFlow(stats)
+
+	// seeded from the panel so the same record always gets the same result
+	rng := rand.New(rand.NewSource(simulationSeed(stats)))
+	buff := (1 + stats.MatchingBuff/100.0) * (1 + stats.Buff/100.0)
+
+	totals := make([]float64, e.runs)
+	var total, weakenScore, critScore float64
+	for i := 0; i < e.runs; i++ {
+		run := simulateBattle(stats, flow, t, rng)
+		totals[i] = run.total * buff
+		total += run.total
+		weakenScore += run.weaken
+		critScore += run.crit
+	}
+
+	runs := float64(e.runs)
+	sort.Float64s(totals)
+
+	return models.CombatPower{
+		Score:       fmt.Sprintf("%d", int(total/runs)),
+		BuffedScore: fmt.Sprintf("%d", int(total/runs*buff)),
+		WeakenScore: fmt.Sprintf("%d", int(weakenScore/runs)),
+		CritScore:   fmt.Sprintf("%d", int(critScore/runs)),
+		Distribution: &models.CombatPowerDistribution{
+			Runs: e.runs,
+			Mean: int(total / runs * buff),
+			P10:  int(percentile(totals, 0.1)),
+			P50:  int(percentile(totals, 0.5)),
+			P90:  int(percentile(totals, 0.9)),
+		},
+	}
+}
+
+type battleResult struct {
+	total  float64
+	weaken float64
+	crit   float64
+}
+
+func simulateBattle(stats models.Stats, flow models.CompanionFlow, t target, rng *rand.Rand) battleResult {
+	var result battleResult
+
+	// a late energy charge costs the last active skill of the battle, once
+	missedPeriod, missedSkill := -1, -1
+	if rng.Float64() < lateEnergyChance(stats) {
+		missedPeriod, missedSkill = lastActiveSkill(flow)
+	}
+
+	for p, period := range flow.Periods {
+		weakenRate := clamp(t.weakenRate(period.WeakenRate)*(1+rng.NormFloat64()*weakenJitter), 0, 1)
+
+		for s, skill := range period.SkillSet.Skills {
+			count := skill.Count
+			if p == missedPeriod && s == missedSkill {
+				count--
+			}
+
+			hit := rawSkillDamage(stats, period, skill, t)
+			critRate := (stats.CritRate + skill.CritRate) / 100
+			if !skill.CanBeCrit {
+				critRate = 0
+			}
+			critDmg := (stats.CritDmg + skill.CritDmg) / 100
+
+			skillWeakenRate := weakenRate
+			if skill.NoWeakenPeriod {
+				skillWeakenRate = 0
+			}
+			if skill.Name == "誓约" {
+				skillWeakenRate = 1
+				if rng.Float64() < oathMissChance {
+					skillWeakenRate = 0
+				}
+			}
+
+			for i := 0; i < count; i++ {
+				if rng.Float64() < skillWeakenRate {
+					damage := hit * weakenMultiplier(stats, skill)
+					result.weaken += damage
+					result.total += damage
+					continue
+				}
+
+				damage := hit
+				if rng.Float64() < critRate {
+					damage *= critDmg
+				}
+				result.crit += damage
+				result.total += damage
+			}
+		}
+	}
+
+	return result
+}
+
+// lateEnergyChance is the chance that the last energy charge arrives too late. The further
+// 加速回能 is past its threshold, the more time the last charge has.
+func lateEnergyChance(stats models.Stats) float64 {
+	return maxLateEnergyChance * (1 - stats.EnergyProgress())
+}
+
+// lastActiveSkill is the period and index of the last active skill cast in the flow, or -1.
+func lastActiveSkill(flow models.CompanionFlow) (int, int) {
+	period, index := -1, -1
+	for p, candidate := range flow.Periods {
+		for s, skill := range candidate.SkillSet.Skills {
+			if isActiveSkill(skill) && skill.Count > 0 {
+				period, index = p, s
+			}
+		}
+	}
+	return period, index
+}
+
+func isActiveSkill(skill models.Skill) bool {
+	return strings.Contains(skill.Name, "主动")
+}
+
+func simulationSeed(stats models.Stats) int64 {
+	h := fnv.New64a()
+	fmt.Fprintf(h, "%+v", stats)
+	return int64(h.Sum64())
+}
+
+// percentile expects sorted values.
+func percentile(sorted []float64, p float64) float64 {
+	if len(sorted) == 0 {
+		return 0
+	}
+	idx := int(math.Round(p * float64(len(sorted)-1)))
+	return sorted[idx]
+}
+
+func clamp(v, lo, hi float64) float64 {
+	return math.Max(lo, math.Min(hi, v))
+}
@@ -0,0 +1,72 @@
+package estimator
+
+import (
+	"lysk-battle-record/internal/models"
+	"lysk-battle-record/internal/registry"
+)
+
+const (
+	supportSkillName = "协助"
+	supportHitBase   = 400 // 只有次数的协助技能每次攻击的固定伤害
+)
+
+// supportHitSplit spreads the hit rate of a support companion whose 协助 skill has no
+// multipliers over the panel stats the way the skills of companions with the same scaling stat
+// do: 攻击 for attack, roughly 1:4 attack to defence for defence and roughly 10:1 attack to HP
+// for HP.
+var supportHitSplit = map[string]struct{ attack, hp, defense float64 }{
+	registry.ScalingAttack:  {attack: 1},
+	registry.ScalingDefense: {attack: 0.2, defense: 0.8},
+	registry.ScalingHP:      {attack: 0.5, hp: 0.05},
+}
+
+// applySupport models the support companion. Its own 协助 skill replaces the multipliers and
+// adds the damage boost of the main companion's 协助 skill, whose count still comes from the
+// main companion. The support_damage_boost param is added to every skill; it is 0 until
+// calibration supports a value. The record only has the main companion's panel, so the support
+// hits scale with it as well. Without a support companion the flow is unchanged.
+func applySupport(flow *models.CompanionFlow, stats models.Stats) {
+	support, ok := registry.GetCompanion(stats.SupportCompanion)
+	if !ok {
+		return
+	}
+
+	hit := supportHit(support, stats)
+	damageBoost := registry.Param(support.Name, registry.ParamSupportDamageBoost)
+
+	for periodIdx := range flow.Periods {
+		skills := flow.Periods[periodIdx].SkillSet.Skills
+		for i := range skills {
+			if skills[i].Name == supportSkillName {
+				skills[i].Base = hit.Base
+				skills[i].AttackRate = hit.AttackRate
+				skills[i].HpRate = hit.HpRate
+				skills[i].DefenseRate = hit.DefenseRate
+				skills[i].DamageBoost += hit.DamageBoost
+			}
+			skills[i].DamageBoost += damageBoost
+		}
+	}
+}
+
+// supportHit is the 协助 skill of the support companion, which does not bring its set card or
+// weapon. Companions whose 协助 skill only has a count get the support_hit_rate param spread
+// by their scaling stat.
+func supportHit(support registry.Companion, stats models.Stats) models.Skill {
+	stats.Companion, stats.SupportCompanion = support.Name, ""
+	stats.SetCard, stats.Weapon = registry.NoSetCard, ""
+	hit := getCompanion(stats).GetSupportSkill(stats)
+	if hit.Base != 0 || hit.AttackRate != 0 || hit.HpRate != 0 || hit.DefenseRate != 0 {
+		return hit
+	}
+
+	hitRate := registry.Param(support.Name, registry.ParamSupportHitRate)
+	split := supportHitSplit[support.ScalingStat]
+	return models.Skill{
+		Base:        supportHitBase,
+		AttackRate:  hitRate * split.attack,
+		HpRate:      hitRate * split.hp,
+		DefenseRate: hitRate * split.defense,
+		DamageBoost: hit.DamageBoost,
+	}
+}
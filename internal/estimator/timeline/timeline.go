@@ -0,0 +1,390 @@
+// Package timeline derives skill counts by stepping through a fight instead of using fixed
+// counts. Companions describe their rotation declaratively; Run plays it out and returns a
+// models.CompanionFlow that the estimator scores like any other flow.
+package timeline
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	"lysk-battle-record/internal/models"
+)
+
+const (
+	// ReferenceDuration is the fight length the legacy fixed counts were tuned for
+	ReferenceDuration = 60.0
+	DefaultDuration   = ReferenceDuration
+
+	step = 0.1
+	eps  = 1e-6
+
+	// weakenCycle is the time between two weaken phases
+	weakenCycle = 20.0
+
+	oathGaugeFull = 100.0
+	// oathRegenThreshold is the 誓约回能 needed to fill the gauge once in the reference fight
+	oathRegenThreshold = 17.0
+	// stageOathBonus is the gauge a set card of stage II or higher starts with
+	stageOathBonus = 15.0
+)
+
+type Trigger int
+
+const (
+	TriggerInterval Trigger = iota // 每隔 Interval 秒释放一次
+	TriggerEnergy                  // 能量足够时释放
+	TriggerOath                    // 誓约槽满时释放
+	TriggerAction                  // 每释放 Every 次 After 中的技能后释放
+)
+
+// Action is one skill of a rotation. Skill.Count is the number of hits per cast.
+type Action struct {
+	Skill   models.Skill
+	Trigger Trigger
+
+	Interval   float64
+	EnergyCost float64
+	MaxCasts   int // 每 ReferenceDuration 秒最多释放次数，0 为不限
+
+	After []string
+	Every int
+
+	InWindow      string // 只在该窗口内释放
+	OutsideWindow string // 只在该窗口外释放
+}
+
+// Window is a buff window such as a zone or a weather effect. It is opened periodically when
+// Interval is set, otherwise whenever the action named OpenedBy is cast.
+type Window struct {
+	Name     string
+	Duration float64
+	Interval float64
+	Offset   float64 // 周期窗口第一次开启的时间
+	OpenedBy string
+
+	Boost        float64
+	CritRate     float64
+	SkillBoosts  map[string]float64 // 技能名 -> 额外增伤
+	WeakenRate   float64            // 窗口内的虚弱时间占比，0 为沿用 Rotation.WeakenRate
+	EnergyOnOpen float64
+}
+
+type Rotation struct {
+	Actions    []Action
+	Windows    []Window
+	Boost      float64 // 全程增伤
+	WeakenRate float64
+	// EnergyDebt is the energy spent on the opening before the first energy action
+	EnergyDebt float64
+}
+
+type Config struct {
+	Duration float64
+}
+
+type periodKey struct {
+	windows string
+	weaken  bool
+}
+
+type engine struct {
+	rotation Rotation
+	stats    models.Stats
+	duration float64
+
+	time       float64
+	energy     float64
+	oath       float64
+	windowEnds map[string]float64
+	nextWindow []float64
+	elapsed    []float64
+	casts      []int
+	triggered  []int
+	casting    []bool // 正在释放的技能，防止互相触发的技能无限递归
+
+	periods []periodKey
+	hits    map[periodKey][]int
+}
+
+// Run plays the rotation out over the configured fight and returns the hits grouped into
+// periods by open windows and weaken state.
+func Run(rotation Rotation, stats models.Stats, config Config) models.CompanionFlow {
+	duration := config.Duration
+	if duration <= 0 {
+		duration = DefaultDuration
+	}
+
+	e := &engine{
+		rotation:   rotation,
+		stats:      stats,
+		duration:   duration,
+		energy:     -rotation.EnergyDebt,
+		oath:       initialOath(stats),
+		windowEnds: map[string]float64{},
+		nextWindow: make([]float64, len(rotation.Windows)),
+		elapsed:    make([]float64, len(rotation.Actions)),
+		casts:      make([]int, len(rotation.Actions)),
+		triggered:  make([]int, len(rotation.Actions)),
+		casting:    make([]bool, len(rotation.Actions)),
+		hits:       map[periodKey][]int{},
+	}
+
+	for i, window := range rotation.Windows {
+		e.nextWindow[i] = window.Offset
+	}
+
+	energyRate := float64(stats.GetEnergy()) / ReferenceDuration
+	oathRate := oathGaugeFull / (ReferenceDuration * (1 + oathRegenThreshold/100)) * (1 + stats.OathRegen/100)
+
+	ticks := int(duration/step + 0.5)
+	for i := 1; i <= ticks; i++ {
+		e.time = float64(i) * step
+		e.energy += energyRate * step
+		e.oath += oathRate * step
+
+		e.openPeriodicWindows()
+		e.tick()
+	}
+
+	return e.flow()
+}
+
+func initialOath(stats models.Stats) float64 {
+	if stats.Stage != "无套装" && stats.Stage != "I" && stats.Stage != "" {
+		return stageOathBonus
+	}
+	return 0
+}
+
+func (e *engine) openPeriodicWindows() {
+	for i, window := range e.rotation.Windows {
+		if window.Interval > 0 && e.time+eps >= e.nextWindow[i] {
+			// periodic windows keep their schedule, so back to back windows do not overlap
+			e.windowEnds[window.Name] = e.nextWindow[i] + window.Duration
+			e.energy += window.EnergyOnOpen
+			e.nextWindow[i] += window.Interval
+		}
+	}
+}
+
+func (e *engine) openWindow(window Window) {
+	e.windowEnds[window.Name] = e.time + window.Duration
+	e.energy += window.EnergyOnOpen
+}
+
+func (e *engine) isOpen(name string) bool {
+	end, ok := e.windowEnds[name]
+	return ok && e.time <= end+eps
+}
+
+func (e *engine) eligible(i int) bool {
+	action := e.rotation.Actions[i]
+	if action.InWindow != "" && !e.isOpen(action.InWindow) {
+		return false
+	}
+	if action.OutsideWindow != "" && e.isOpen(action.OutsideWindow) {
+		return false
+	}
+	if action.MaxCasts > 0 {
+		limit := int(float64(action.MaxCasts)*e.duration/ReferenceDuration + 0.5)
+		if e.casts[i] >= limit {
+			return false
+		}
+	}
+	return true
+}
+
+func (e *engine) tick() {
+	for i, action := range e.rotation.Actions {
+		if !e.eligible(i) {
+			continue
+		}
+
+		switch action.Trigger {
+		case TriggerOath:
+			if e.oath+eps >= oathGaugeFull {
+				e.oath -= oathGaugeFull
+				e.cast(i)
+			}
+		case TriggerEnergy:
+			if action.EnergyCost > 0 && e.energy+eps >= action.EnergyCost {
+				e.energy -= action.EnergyCost
+				e.cast(i)
+			}
+		case TriggerInterval:
+			if action.Interval <= 0 {
+				continue
+			}
+			e.elapsed[i] += step
+			if e.elapsed[i]+eps >= action.Interval {
+				e.elapsed[i] -= action.Interval
+				e.cast(i)
+			}
+		}
+	}
+}
+
+// cast casts the action and the actions it triggers. An action is not triggered again by a
+// cast it started itself, so actions triggering each other cast once per chain.
+func (e *engine) cast(i int) {
+	action := e.rotation.Actions[i]
+	e.casts[i]++
+	e.record(i)
+
+	e.casting[i] = true
+	defer func() { e.casting[i] = false }()
+
+	for _, window := range e.rotation.Windows {
+		if window.OpenedBy == action.Skill.Name {
+			e.openWindow(window)
+		}
+	}
+
+	for j, follow := range e.rotation.Actions {
+		if follow.Trigger != TriggerAction || e.casting[j] || !contains(follow.After, action.Skill.Name) || !e.eligible(j) {
+			continue
+		}
+
+		e.triggered[j]++
+		every := max(follow.Every, 1)
+		if e.triggered[j]%every == 0 {
+			e.cast(j)
+		}
+	}
+}
+
+func (e *engine) record(i int) {
+	key := e.currentPeriod()
+	if _, ok := e.hits[key]; !ok {
+		e.periods = append(e.periods, key)
+		e.hits[key] = make([]int, len(e.rotation.Actions))
+	}
+	e.hits[key][i] += e.rotation.Actions[i].Skill.Count
+}
+
+func (e *engine) currentPeriod() periodKey {
+	var open []string
+	weakenRate := e.rotation.WeakenRate
+	for _, window := range e.rotation.Windows {
+		if e.isOpen(window.Name) {
+			open = append(open, window.Name)
+			if window.WeakenRate > 0 {
+				weakenRate = window.WeakenRate
+			}
+		}
+	}
+	sort.Strings(open)
+
+	phase := e.time - weakenCycle*float64(int(e.time/weakenCycle))
+	return periodKey{
+		windows: strings.Join(open, ","),
+		weaken:  phase < weakenRate*weakenCycle,
+	}
+}
+
+func (e *engine) flow() models.CompanionFlow {
+	windows := map[string]Window{}
+	for _, window := range e.rotation.Windows {
+		windows[window.Name] = window
+	}
+
+	var flow models.CompanionFlow
+	for _, key := range e.periods {
+		period := models.CompanionPeriod{Boost: e.rotation.Boost}
+		if key.weaken {
+			period.WeakenRate = 1
+		}
+
+		var open []Window
+		if key.windows != "" {
+			for _, name := range strings.Split(key.windows, ",") {
+				open = append(open, windows[name])
+				period.Boost += windows[name].Boost
+			}
+		}
+
+		for i, action := range e.rotation.Actions {
+			count := e.hits[key][i]
+			if count == 0 {
+				continue
+			}
+
+			skill := action.Skill
+			skill.Count = count
+			for _, window := range open {
+				skill.CritRate += window.CritRate
+				skill.DamageBoost += window.SkillBoosts[skill.Name]
+			}
+			period.SkillSet.Skills = append(period.SkillSet.Skills, skill)
+		}
+
+		flow.Periods = append(flow.Periods, period)
+	}
+
+	return flow
+}
+
+// FromFlow turns a legacy flow with fixed counts into a rotation. Each period becomes a phase
+// of equal length, active skills are paid with energy and oath skills with the oath gauge, and
+// all other skills keep their rate per second within their phase.
+func FromFlow(flow models.CompanionFlow, stats models.Stats) Rotation {
+	rotation := Rotation{}
+	phases := len(flow.Periods)
+	if phases == 0 {
+		return rotation
+	}
+
+	phaseLength := ReferenceDuration / float64(phases)
+	energy := float64(stats.GetEnergy())
+
+	for p, period := range flow.Periods {
+		phase := ""
+		if phases == 1 {
+			rotation.WeakenRate = period.WeakenRate
+			rotation.Boost = period.Boost
+		} else {
+			phase = fmt.Sprintf("阶段%d", p+1)
+			rotation.Windows = append(rotation.Windows, Window{
+				Name:       phase,
+				Offset:     float64(p) * phaseLength,
+				Interval:   ReferenceDuration,
+				Duration:   phaseLength - eps,
+				Boost:      period.Boost,
+				WeakenRate: period.WeakenRate,
+			})
+		}
+
+		for _, skill := range period.SkillSet.Skills {
+			if skill.Count <= 0 {
+				continue
+			}
+
+			action := Action{InWindow: phase}
+			switch {
+			case skill.Name == "誓约":
+				action.Trigger = TriggerOath
+			case strings.Contains(skill.Name, "主动"):
+				action.Trigger = TriggerEnergy
+				action.EnergyCost = energy / float64(phases) / float64(skill.Count)
+			default:
+				action.Trigger = TriggerInterval
+				action.Interval = phaseLength / float64(skill.Count)
+			}
+			skill.Count = 1
+			action.Skill = skill
+			rotation.Actions = append(rotation.Actions, action)
+		}
+	}
+
+	return rotation
+}
+
+func contains(values []string, value string) bool {
+	for _, v := range values {
+		if v == value {
+			return true
+		}
+	}
+	return false
+}
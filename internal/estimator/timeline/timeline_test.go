@@ -0,0 +1,37 @@
+package timeline
+
+import (
+	"testing"
+
+	"lysk-battle-record/internal/models"
+)
+
+func countOf(flow models.CompanionFlow, name string) int {
+	count := 0
+	for _, period := range flow.Periods {
+		for _, skill := range period.SkillSet.Skills {
+			if skill.Name == name {
+				count += skill.Count
+			}
+		}
+	}
+	return count
+}
+
+func TestRunStopsTriggerCycles(t *testing.T) {
+	// 普攻触发追击，追击又触发普攻和自身
+	rotation := Rotation{Actions: []Action{
+		{Skill: models.Skill{Name: "普攻", Count: 1}, Trigger: TriggerInterval, Interval: 1},
+		{Skill: models.Skill{Name: "追击", Count: 1}, Trigger: TriggerAction, After: []string{"普攻", "追击"}},
+		{Skill: models.Skill{Name: "普攻", Count: 1}, Trigger: TriggerAction, After: []string{"追击"}},
+	}}
+
+	flow := Run(rotation, models.Stats{}, Config{Duration: 10})
+	if got := countOf(flow, "追击"); got != 10 {
+		t.Errorf("expected one 追击 per interval cast, got %d", got)
+	}
+	// 每次循环：间隔普攻、追击、被追击触发的普攻
+	if got := countOf(flow, "普攻"); got != 20 {
+		t.Errorf("expected two 普攻 per interval cast, got %d", got)
+	}
+}
@@ -0,0 +1,61 @@
+package estimator
+
+import (
+	"lysk-battle-record/internal/estimator/companions"
+	"lysk-battle-record/internal/estimator/levels"
+	"lysk-battle-record/internal/estimator/timeline"
+	"lysk-battle-record/internal/models"
+)
+
+// TimelineEstimator derives skill counts from a simulated fight of the configured length
+// instead of the fixed counts in the companion flows.
+type TimelineEstimator struct {
+	duration float64
+}
+
+func NewTimelineEstimator(duration float64) CombatPowerEstimator {
+	return &TimelineEstimator{duration: duration}
+}
+
+func (e *TimelineEstimator) EstimateCombatPower(record models.Record) models.CombatPower {
+	stats := record.ToStats()
+	flow, approximate := buildTimelineFlow(stats, e.duration)
+
+	combatPower := estimate(stats, flow, referenceTarget)
+	combatPower.Approximate = approximate
+	return combatPower
+}
+
+func (e *TimelineEstimator) EstimateCombatPowerAt(record models.Record, level levels.Level) models.CombatPower {
+	stats := record.ToStats()
+	flow, approximate := buildTimelineFlow(stats, e.duration)
+
+	combatPower := estimateAt(stats, flow, level)
+	combatPower.Approximate = approximate
+	return combatPower
+}
+
+// buildTimelineFlow runs the rotation of the companion. Companions without one are run from
+// their v1 flow, which only scales the v1 counts to the fight length, so the result is flagged
+// as approximate.
+func buildTimelineFlow(stats models.Stats, duration float64) (models.CompanionFlow, bool) {
+	myCompanion := getCompanion(stats)
+	if myCompanion.GetName() == "默认搭档" {
+		return models.CompanionFlow{}, false
+	}
+
+	var rotation timeline.Rotation
+	rotationCompanion, modelled := myCompanion.(companions.RotationCompanion)
+	if modelled {
+		rotation = rotationCompanion.GetRotation(stats)
+	} else {
+		rotation = timeline.FromFlow(myCompanion.GetCompanionFlow(stats), stats)
+	}
+
+	flow := timeline.Run(rotation, stats, timeline.Config{Duration: duration})
+	setCard := getSetCard(stats)
+	applySetCardBuff(&flow, getSetCardBuff(stats, setCard))
+	applySetCardBuff(&flow, getWeaponBuff(stats))
+	applySupport(&flow, stats)
+	return flow, !modelled
+}
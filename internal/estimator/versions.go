@@ -0,0 +1,116 @@
+package estimator
+
+import (
+	"sort"
+
+	"github.com/sirupsen/logrus"
+
+	"lysk-battle-record/internal/estimator/levels"
+	"lysk-battle-record/internal/estimator/timeline"
+	"lysk-battle-record/internal/models"
+)
+
+const (
+	VersionV1 = "v1" // 固定技能次数的解析估算
+	VersionV2 = "v2" // 时间轴估算，未描述技能循环的搭档沿用 v1 的次数并标记为近似，见 companions.RotationCompanion
+
+	// LegacyVersion is the version of records stored before versions existed
+	LegacyVersion = VersionV1
+)
+
+// VersionedEstimator scores every record with the estimator version it was stored with, so a
+// formula change does not rewrite the history of a level. Records only move to another version
+// through an explicit migration.
+type VersionedEstimator struct {
+	current    string
+	estimators map[string]CombatPowerEstimator
+}
+
+// NewVersionedEstimator registers every version. New records are scored with the given version,
+// or v1 if it is empty.
+func NewVersionedEstimator(version string) *VersionedEstimator {
+	e := &VersionedEstimator{
+		current: LegacyVersion,
+		estimators: map[string]CombatPowerEstimator{
+			VersionV1: NewCombatPowerEstimator(),
+			VersionV2: NewTimelineEstimator(timeline.DefaultDuration),
+		},
+	}
+
+	if version != "" {
+		if _, ok := e.estimators[version]; ok {
+			e.current = version
+		} else {
+			logrus.Warnf("[Estimator] Unknown estimator version %s, using %s", version, e.current)
+		}
+	}
+	return e
+}
+
+// Current is the version new records are stored with.
+func (e *VersionedEstimator) Current() string {
+	return e.current
+}
+
+func (e *VersionedEstimator) Versions() []string {
+	versions := make([]string, 0, len(e.estimators))
+	for version := range e.estimators {
+		versions = append(versions, version)
+	}
+	sort.Strings(versions)
+	return versions
+}
+
+func (e *VersionedEstimator) Get(version string) (CombatPowerEstimator, bool) {
+	estimator, ok := e.estimators[version]
+	return estimator, ok
+}
+
+// VersionOf returns the version the record was stored with.
+func VersionOf(record models.Record) string {
+	if record.EstimatorVersion == "" {
+		return LegacyVersion
+	}
+	return record.EstimatorVersion
+}
+
+func (e *VersionedEstimator) EstimateCombatPower(record models.Record) models.CombatPower {
+	version := VersionOf(record)
+	estimator, ok := e.estimators[version]
+	if !ok {
+		return unknownVersion(version)
+	}
+
+	combatPower := estimator.EstimateCombatPower(record)
+	combatPower.Version = version
+	return combatPower
+}
+
+func (e *VersionedEstimator) EstimateCombatPowerAt(record models.Record, level levels.Level) models.CombatPower {
+	version := VersionOf(record)
+	estimator, ok := e.estimators[version]
+	if !ok {
+		return unknownVersion(version)
+	}
+
+	var combatPower models.CombatPower
+	if aware, ok := estimator.(LevelAwareEstimator); ok {
+		combatPower = aware.EstimateCombatPowerAt(record, level)
+	} else {
+		combatPower = estimator.EstimateCombatPower(record)
+	}
+	combatPower.Version = version
+	return combatPower
+}
+
+// unknownVersion is returned for records stored by a newer deployment, so a rollback does not
+// score them with the wrong formula.
+func unknownVersion(version string) models.CombatPower {
+	return models.CombatPower{
+		Score:       models.NoData,
+		BuffedScore: models.NoData,
+		WeakenScore: models.NoData,
+		CritScore:   models.NoData,
+		Version:     version,
+	}
+}
@@ -0,0 +1,34 @@
+package estimator
+
+import (
+	"lysk-battle-record/internal/models"
+	"lysk-battle-record/internal/registry"
+)
+
+// getWeaponBuff is what the weapon adds on top of the companion skills: the first refinement
+// level of a named weapon, and what every further level adds. The first level of a signature
+// weapon is already included in the companion skills.
+func getWeaponBuff(stats models.Stats) models.StageBuff {
+	buff := models.StageBuff{Buffs: map[string]models.SkillBuff{}}
+	weapon, ok := registry.GetWeapon(stats.WeaponID)
+	if !ok {
+		return buff
+	}
+
+	levels := float64(max(stats.WeaponRefinement-registry.MinRefinement, 0))
+	add := func(effect registry.WeaponEffect, times float64) {
+		skillBuff := buff.Buffs[effect.Skill]
+		skillBuff.CritRate += effect.CritRate * times
+		skillBuff.CritDmg += effect.CritDmg * times
+		skillBuff.DamageBoost += effect.DamageBoost * times
+		skillBuff.WeakenBoost += effect.WeakenBoost * times
+		buff.Buffs[effect.Skill] = skillBuff
+	}
+	for _, effect := range weapon.Base {
+		add(effect, 1)
+	}
+	for _, effect := range weapon.Effects {
+		add(effect, levels)
+	}
+	return buff
+}
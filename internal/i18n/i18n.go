@@ -0,0 +1,104 @@
+package i18n
+
+import (
+	"sort"
+	"strconv"
+	"strings"
+)
+
+const (
+	ZhCN = "zh-CN"
+	ZhTW = "zh-TW"
+	En   = "en"
+
+	Default = ZhCN
+)
+
+var Languages = []string{ZhCN, ZhTW, En}
+
+// Negotiate picks the supported language the Accept-Language header prefers, or the default.
+// Traditional Chinese is chosen for Taiwan, Hong Kong, Macau and zh-Hant.
+func Negotiate(acceptLanguage string) string {
+	type candidate struct {
+		lang    string
+		quality float64
+		order   int
+	}
+
+	var candidates []candidate
+	for i, part := range strings.Split(acceptLanguage, ",") {
+		tag, quality := parseRange(part)
+		if lang, ok := match(tag); ok && quality > 0 {
+			candidates = append(candidates, candidate{lang, quality, i})
+		}
+	}
+	if len(candidates) == 0 {
+		return Default
+	}
+
+	sort.SliceStable(candidates, func(i, j int) bool {
+		return candidates[i].quality > candidates[j].quality
+	})
+	return candidates[0].lang
+}
+
+// Supported returns the language if it is supported, matching it the way Negotiate does.
+func Supported(lang string) (string, bool) {
+	return match(strings.ToLower(strings.TrimSpace(lang)))
+}
+
+func parseRange(part string) (string, float64) {
+	fields := strings.Split(part, ";")
+	tag := strings.ToLower(strings.TrimSpace(fields[0]))
+	quality := 1.0
+	for _, param := range fields[1:] {
+		name, value, ok := strings.Cut(strings.TrimSpace(param), "=")
+		if ok && name == "q" {
+			if q, err := strconv.ParseFloat(value, 64); err == nil {
+				quality = q
+			}
+		}
+	}
+	return tag, quality
+}
+
+func match(tag string) (string, bool) {
+	tag = strings.ReplaceAll(tag, "_", "-")
+	switch {
+	case tag == "en" || strings.HasPrefix(tag, "en-"):
+		return En, true
+	case tag == "zh-tw", tag == "zh-hk", tag == "zh-mo", strings.HasPrefix(tag, "zh-hant"):
+		return ZhTW, true
+	case tag == "zh" || strings.HasPrefix(tag, "zh-"):
+		return ZhCN, true
+	}
+	return "", false
+}
+
+// Message returns the message of the code in the language with the {name} placeholders filled
+// in. Values that are enum IDs are shown by their display name. It falls back to the default
+// language and reports false for unknown codes.
+func Message(lang, code string, params map[string]string) (string, bool) {
+	translations, ok := messages[code]
+	if !ok {
+		return "", false
+	}
+	template, ok := translations[lang]
+	if !ok {
+		template = translations[Default]
+	}
+
+	replacements := make([]string, 0, len(params)*2)
+	for name, value := range params {
+		replacements = append(replacements, "{"+name+"}", Name(lang, value))
+	}
+	return strings.NewReplacer(replacements...).Replace(template), true
+}
+
+// Separator joins several messages in the language.
+func Separator(lang string) string {
+	if lang == En {
+		return "; "
+	}
+	return "；"
+}
@@ -0,0 +1,41 @@
+package i18n
+
+import "testing"
+
+func TestNegotiate(t *testing.T) {
+	cases := map[string]string{
+		"":                              ZhCN,
+		"fr-FR":                         ZhCN,
+		"en-US,en;q=0.9":                En,
+		"zh-TW":                         ZhTW,
+		"zh-Hant-HK":                    ZhTW,
+		"zh-Hans-CN,zh;q=0.9,en;q=0.8":  ZhCN,
+		"ja;q=0.9,en;q=0.5,zh-HK;q=0.7": ZhTW,
+	}
+	for header, want := range cases {
+		if got := Negotiate(header); got != want {
+			t.Errorf("Negotiate(%q) = %s, want %s", header, got, want)
+		}
+	}
+}
+
+func TestMessage(t *testing.T) {
+	for code, translations := range messages {
+		for _, lang := range Languages {
+			if translations[lang] == "" {
+				t.Errorf("%s has no %s message", code, lang)
+			}
+		}
+	}
+
+	params := map[string]string{"companion": "光猎", "value": "末夜"}
+	if got, _ := Message(En, "set_card_companion_mismatch", params); got != "The set card does not belong to the companion: Lumiere - Midnight" {
+		t.Errorf("English message: %s", got)
+	}
+	if got, _ := Message(ZhTW, "set_card_companion_mismatch", params); got != "搭檔身分與日卡不匹配: 光獵 - 末夜" {
+		t.Errorf("Traditional Chinese message: %s", got)
+	}
+	if _, ok := Message(En, "no_such_code", nil); ok {
+		t.Error("unknown code translated")
+	}
+}
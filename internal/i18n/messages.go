@@ -0,0 +1,130 @@
+package i18n
+
+// messages maps each error code to its message by language. The zh-CN messages are the ones the
+// API returned before codes existed.
+var messages = map[string]map[string]string{
+	// 请求
+	"invalid_request":   {ZhCN: "无效的请求", ZhTW: "無效的請求", En: "Invalid request"},
+	"malformed_request": {ZhCN: "请求格式错误", ZhTW: "請求格式錯誤", En: "Malformed request"},
+	"request_timeout":   {ZhCN: "请求超时", ZhTW: "請求逾時", En: "Request timeout"},
+	"rate_limited":      {ZhCN: "请求过于频繁，请稍后再试", ZhTW: "請求過於頻繁，請稍後再試", En: "Too many requests, please try again later"},
+	"internal_error":    {ZhCN: "服务器内部错误", ZhTW: "伺服器內部錯誤", En: "Internal server error"},
+	"invalid_time":      {ZhCN: "时间格式错误", ZhTW: "時間格式錯誤", En: "Invalid time format"},
+	"time_missing":      {ZhCN: "时间字段缺失或格式错误", ZhTW: "時間欄位缺失或格式錯誤", En: "Time is missing or malformed"},
+	"invalid_loadout":   {ZhCN: "卡组格式错误", ZhTW: "卡組格式錯誤", En: "Malformed loadout"},
+	"invalid_round":     {ZhCN: "轮次格式错误", ZhTW: "輪次格式錯誤", En: "Invalid round"},
+	"level_required":    {ZhCN: "关卡参数不能为空", ZhTW: "關卡參數不能為空", En: "Level is required"},
+	"companion_required": {ZhCN: "搭档身份不能为空", ZhTW: "搭檔身分不能為空",
+		En: "Companion is required"},
+	"invalid_leaderboard_type": {ZhCN: "无效的排行榜类型", ZhTW: "無效的排行榜類型", En: "Invalid leaderboard type"},
+	"unknown_estimator_version": {ZhCN: "未知的估算版本", ZhTW: "未知的估算版本",
+		En: "Unknown estimator version"},
+	"migration_in_progress": {ZhCN: "迁移正在进行中", ZhTW: "遷移正在進行中", En: "A migration is already running"},
+
+	// 登录与权限
+	"unauthenticated_user":     {ZhCN: "未登录或无效的用户", ZhTW: "未登入或無效的使用者", En: "Not signed in or invalid user"},
+	"forbidden":                {ZhCN: "无权访问", ZhTW: "無權存取", En: "Access denied"},
+	"missing_token":            {ZhCN: "未登录", ZhTW: "未登入", En: "Not signed in"},
+	"malformed_token":          {ZhCN: "无效的认证信息", ZhTW: "無效的認證資訊", En: "Malformed credentials"},
+	"invalid_token":            {ZhCN: "无效的登录凭证", ZhTW: "無效的登入憑證", En: "Invalid sign-in token"},
+	"token_expired":            {ZhCN: "登录已过期", ZhTW: "登入已過期", En: "Sign-in has expired"},
+	"token_revoked":            {ZhCN: "登录已失效", ZhTW: "登入已失效", En: "Sign-in has been revoked"},
+	"user_banned":              {ZhCN: "账号已被封禁", ZhTW: "帳號已被封禁", En: "This account is banned"},
+	"unsupported_login_method": {ZhCN: "不支持的登录方式", ZhTW: "不支援的登入方式", En: "Unsupported sign-in method"},
+
+	// 用户
+	"user_not_found":         {ZhCN: "用户不存在", ZhTW: "使用者不存在", En: "User not found"},
+	"user_exists":            {ZhCN: "用户已存在", ZhTW: "使用者已存在", En: "User already exists"},
+	"invalid_email":          {ZhCN: "邮箱格式错误", ZhTW: "信箱格式錯誤", En: "Invalid email address"},
+	"email_registered":       {ZhCN: "该邮箱已注册", ZhTW: "該信箱已註冊", En: "This email is already registered"},
+	"account_already_linked": {ZhCN: "该账号已关联其他用户", ZhTW: "該帳號已關聯其他使用者", En: "This account is linked to another user"},
+
+	// 注销
+	"deletion_request_failed":    {ZhCN: "注销请求提交失败", ZhTW: "註銷請求提交失敗", En: "Failed to submit the deletion request"},
+	"deletion_pending":           {ZhCN: "注销请求处理中", ZhTW: "註銷請求處理中", En: "A deletion request is being processed"},
+	"deletion_request_not_found": {ZhCN: "注销请求不存在", ZhTW: "註銷請求不存在", En: "Deletion request not found"},
+
+	// 记录
+	"record_not_found":     {ZhCN: "记录不存在", ZhTW: "紀錄不存在", En: "Record not found"},
+	"record_exists":        {ZhCN: "记录已存在", ZhTW: "紀錄已存在", En: "Record already exists"},
+	"record_deleted":       {ZhCN: "记录已被删除", ZhTW: "紀錄已被刪除", En: "Record has been deleted"},
+	"record_upload_failed": {ZhCN: "记录上传失败", ZhTW: "紀錄上傳失敗", En: "Failed to upload the record"},
+	"write_failed":         {ZhCN: "写入失败", ZhTW: "寫入失敗", En: "Failed to save"},
+	"update_failed":        {ZhCN: "更新失败", ZhTW: "更新失敗", En: "Failed to update"},
+	"delete_failed":        {ZhCN: "删除失败", ZhTW: "刪除失敗", En: "Failed to delete"},
+	"update_forbidden":     {ZhCN: "无权修改此记录", ZhTW: "無權修改此紀錄", En: "You cannot edit this record"},
+	"delete_forbidden":     {ZhCN: "无权删除此记录", ZhTW: "無權刪除此紀錄", En: "You cannot delete this record"},
+
+	// 游戏版本
+	"game_content_not_configured": {ZhCN: "未配置游戏版本文件", ZhTW: "未設定遊戲版本檔案", En: "No game content file is configured"},
+	"invalid_game_content":        {ZhCN: "游戏版本文件错误", ZhTW: "遊戲版本檔案錯誤", En: "Invalid game content file"},
+
+	// 记录校验
+	"validation_failed":         {ZhCN: "记录校验失败", ZhTW: "紀錄校驗失敗", En: "The record is invalid"},
+	"invalid_level_type":        {ZhCN: "无效的关卡类型: {value}", ZhTW: "無效的關卡類型: {value}", En: "Invalid level type: {value}"},
+	"attack_out_of_range":       {ZhCN: "攻击数值错误: {value}", ZhTW: "攻擊數值錯誤: {value}", En: "Invalid attack, at most {max}: {value}"},
+	"defense_out_of_range":      {ZhCN: "防御值错误: {value}", ZhTW: "防禦值錯誤: {value}", En: "Invalid defense, at most {max}: {value}"},
+	"hp_out_of_range":           {ZhCN: "生命值错误: {value}", ZhTW: "生命值錯誤: {value}", En: "Invalid HP, at most {max}: {value}"},
+	"invalid_matching":          {ZhCN: "对谱类型错误: {value}", ZhTW: "對譜類型錯誤: {value}", En: "Invalid matching: {value}"},
+	"invalid_matching_buff":     {ZhCN: "对谱加成错误: {value}", ZhTW: "對譜加成錯誤: {value}", En: "Invalid matching buff: {value}"},
+	"crit_rate_out_of_range":    {ZhCN: "暴击率错误: {value}", ZhTW: "暴擊率錯誤: {value}", En: "Invalid crit rate, at most {max}: {value}"},
+	"crit_dmg_out_of_range":     {ZhCN: "暴击伤害错误: {value}", ZhTW: "暴擊傷害錯誤: {value}", En: "Invalid crit damage, at most {max}: {value}"},
+	"weaken_boost_out_of_range": {ZhCN: "虚弱增伤错误: {value}", ZhTW: "虛弱增傷錯誤: {value}", En: "Invalid weaken boost, at most {max}: {value}"},
+	"oath_boost_out_of_range":   {ZhCN: "誓约增伤错误: {value}", ZhTW: "誓約增傷錯誤: {value}", En: "Invalid oath boost, at most {max}: {value}"},
+	"oath_regen_out_of_range":   {ZhCN: "誓约回能错误: {value}", ZhTW: "誓約回能錯誤: {value}", En: "Invalid oath regen, at most {max}: {value}"},
+	"energy_regen_out_of_range": {ZhCN: "加速回能错误: {value}", ZhTW: "加速回能錯誤: {value}", En: "Invalid energy regen, at most {max}: {value}"},
+	"invalid_stage":             {ZhCN: "阶数错误: {value}", ZhTW: "階數錯誤: {value}", En: "Invalid stage: {value}"},
+	"invalid_weapon":            {ZhCN: "武器错误: {value}", ZhTW: "武器錯誤: {value}", En: "Invalid weapon: {value}"},
+	"invalid_weapon_refinement": {ZhCN: "武器精炼等级错误, 请填写1-{max}: {value}", ZhTW: "武器精煉等級錯誤, 請填寫1-{max}: {value}",
+		En: "Invalid weapon refinement, use 1-{max}: {value}"},
+	"total_level_out_of_range": {ZhCN: "卡面总等级错误, 请填写卡面等级总和。如不确定请填留空: {value}",
+		ZhTW: "卡面總等級錯誤, 請填寫卡面等級總和。如不確定請留空: {value}",
+		En:   "Invalid total card level, enter the sum of the card levels or leave it empty: {value}"},
+	"note_too_long":  {ZhCN: "备注最长30个字: {value}", ZhTW: "備註最長30個字: {value}", En: "The note is too long: {value}"},
+	"note_sensitive": {ZhCN: "备注中包含敏感词", ZhTW: "備註中包含敏感詞", En: "The note contains blocked words"},
+	"regen_total_exceeded": {ZhCN: "回能总和错误: {value} + {oath_regen}，面板总回能不能大于{max}",
+		ZhTW: "回能總和錯誤: {value} + {oath_regen}，面板總回能不能大於{max}",
+		En:   "Total regen too high: {value} + {oath_regen}, the panel allows at most {max}"},
+	"set_card_companion_mismatch": {ZhCN: "搭档身份与日卡不匹配: {companion} - {value}",
+		ZhTW: "搭檔身分與日卡不匹配: {companion} - {value}",
+		En:   "The set card does not belong to the companion: {companion} - {value}"},
+	"invalid_support_companion": {ZhCN: "协助搭档错误, 需为其他男主的搭档身份: {value}",
+		ZhTW: "協助搭檔錯誤, 需為其他男主的搭檔身分: {value}",
+		En:   "The support companion must belong to another partner: {value}"},
+	"stage_without_set_card": {ZhCN: "无套装时阶数必须为无套装: {value}", ZhTW: "無套裝時階數必須為無套裝: {value}",
+		En: "Without a set card the stage must be no set: {value}"},
+	"set_card_without_stage": {ZhCN: "有套装时阶数不能为无套装: {value}", ZhTW: "有套裝時階數不能為無套裝: {value}",
+		En: "With a set card the stage cannot be no set: {value}"},
+	"defense_required": {ZhCN: "搭档 {companion} 的防御值不能为 0", ZhTW: "搭檔 {companion} 的防禦值不能為 0",
+		En: "Defense cannot be 0 for {companion}"},
+	"hp_required": {ZhCN: "搭档 {companion} 的生命值不能为 0", ZhTW: "搭檔 {companion} 的生命值不能為 0",
+		En: "HP cannot be 0 for {companion}"},
+	"invalid_level_number": {ZhCN: "关数错误: {level_type} - {level_mode} - {value}",
+		ZhTW: "關數錯誤: {level_type} - {level_mode} - {value}",
+		En:   "Invalid level number: {level_type} - {level_mode} - {value}"},
+	"invalid_level_mode": {ZhCN: "关卡模式错误: {value}", ZhTW: "關卡模式錯誤: {value}", En: "Invalid level mode: {value}"},
+	"invalid_star_rank":  {ZhCN: "波动关卡通关星级错误: {value}", ZhTW: "波動關卡通關星級錯誤: {value}", En: "Invalid star rank: {value}"},
+	"partner_level_type_mismatch": {ZhCN: "搭档身份与关卡类型不匹配: {value} - {level_type}",
+		ZhTW: "搭檔身分與關卡類型不匹配: {value} - {level_type}",
+		En:   "The companion cannot take this level type: {value} - {level_type}"},
+	"invalid_buff": {ZhCN: "锦标赛加成错误: {value}", ZhTW: "錦標賽加成錯誤: {value}", En: "Invalid championship buff: {value}"},
+
+	// 卡组校验
+	"loadout_card_type":  {ZhCN: "第{card}张卡类型错误: {value}", ZhTW: "第{card}張卡類型錯誤: {value}", En: "Card {card} has an invalid type: {value}"},
+	"loadout_card_star":  {ZhCN: "第{card}张卡星级错误: {value}", ZhTW: "第{card}張卡星級錯誤: {value}", En: "Card {card} has an invalid star: {value}"},
+	"loadout_card_level": {ZhCN: "第{card}张卡等级错误: {value}", ZhTW: "第{card}張卡等級錯誤: {value}", En: "Card {card} has an invalid level: {value}"},
+	"loadout_core_stat": {ZhCN: "第{card}张卡核心属性错误: {stat} {value}", ZhTW: "第{card}張卡核心屬性錯誤: {stat} {value}",
+		En: "Card {card} has an invalid core stat: {stat} {value}"},
+	"loadout_core_slots": {ZhCN: "第{card}张卡最多{slots}个百分比核心属性", ZhTW: "第{card}張卡最多{slots}個百分比核心屬性",
+		En: "Card {card} has more than {slots} percentage core stat"},
+	"loadout_sub_stat": {ZhCN: "第{card}张卡副属性错误: {stat} {value}", ZhTW: "第{card}張卡副屬性錯誤: {stat} {value}",
+		En: "Card {card} has an invalid sub-stat: {stat} {value}"},
+	"loadout_card_stat_too_high": {ZhCN: "第{card}张卡{stat}过高: {value}", ZhTW: "第{card}張卡{stat}過高: {value}",
+		En: "Card {card} has too much {stat}: {value}"},
+	"loadout_card_count": {ZhCN: "卡组需要{sun}张日卡和{moon}张月卡", ZhTW: "卡組需要{sun}張日卡和{moon}張月卡",
+		En: "A loadout needs {sun} sun cards and {moon} moon cards"},
+	"loadout_panel_mismatch": {ZhCN: "面板{field}与卡组不一致: {value} - {expected}", ZhTW: "面板{field}與卡組不一致: {value} - {expected}",
+		En: "Panel {field} does not match the loadout: {value} - {expected}"},
+	"loadout_total_level_mismatch": {ZhCN: "卡总等级与卡组不一致: {value} - {expected}", ZhTW: "卡總等級與卡組不一致: {value} - {expected}",
+		En: "Total card level does not match the loadout: {value} - {expected}"},
+}
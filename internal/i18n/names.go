@@ -0,0 +1,144 @@
+package i18n
+
+import "strings"
+
+// englishNames are the English display names of the canonical enum IDs: partners, companions,
+// set cards, level types and the other values that show up in messages.
+var englishNames = map[string]string{
+	// 男主
+	"沈星回": "Xavier",
+	"黎深":  "Zayne",
+	"祁煜":  "Rafayel",
+	"秦彻":  "Sylus",
+	"夏以昼": "Caleb",
+
+	// 搭档身份
+	"暗蚀国王":     "King of Darknight",
+	"光猎":       "Lumiere",
+	"逐光骑士":     "Light Seeker",
+	"遥远少年":     "Distant Youth",
+	"Evol特警":   "Evol Police",
+	"深空猎人":     "Deepspace Hunter",
+	"终末之神":     "God of Annihilation",
+	"九黎司命":     "Master of Fate",
+	"永恒先知":     "Foreseer",
+	"极地军医":     "Medic of the Arctic",
+	"黎明抹杀者":    "Dawn Breaker",
+	"临空医生":     "Linkon Doctor",
+	"利莫里亚海神":   "Lemurian Sea God",
+	"潮汐之神":     "God of the Tides",
+	"深海潜行者":    "Abyss Walker",
+	"画坛新锐":     "Fresh Paint",
+	"海妖魅影":     "Phantom of the Siren",
+	"艺术家":      "Artist",
+	"银翼恶魔":     "Silverwing Fiend",
+	"深渊主宰":     "Abysm Sovereign",
+	"无尽掠夺者":    "Relentless Conqueror",
+	"异界来客":     "Otherworldly Visitor",
+	"终极兵器X-02": "Ultimate Weapon X-02",
+	"远空执舰官":    "Farspace Colonel",
+	"深空飞行员":    "Deepspace Pilot",
+
+	// 日卡
+	"夜誓":  "Nightvow",
+	"末夜":  "Midnight",
+	"逐光":  "Light Seeking",
+	"鎏光":  "Gilded Light",
+	"睱日":  "Leisure Day",
+	"弦光":  "Strung Light",
+	"心晴":  "Clear Heart",
+	"匿光":  "Hidden Light",
+	"神谕":  "Diviner",
+	"拥雪":  "Snowfall",
+	"永恒":  "Forever",
+	"终序":  "Final Order",
+	"夜色":  "Nightshade",
+	"静谧":  "Serenity",
+	"深林":  "Deep Forest",
+	"雾海":  "Mistsea",
+	"神殿":  "Temple",
+	"深海":  "Deep Sea",
+	"坠浪":  "Falling Wave",
+	"点染":  "Ink Dab",
+	"斑斓":  "Kaleidoscope",
+	"碧海":  "Azure Sea",
+	"猩红":  "Crimson Rapture",
+	"深渊":  "Abyssal",
+	"掠心":  "Captivating",
+	"纯白":  "Pure White",
+	"锋尖":  "Blade Tip",
+	"戮夜":  "Slaughter Night",
+	"寂路":  "Lone Road",
+	"远空":  "Farspace",
+	"长昼":  "Endless Day",
+	"离途":  "Parting Way",
+	"无套装": "No set",
+
+	// 关卡
+	"光":  "Light",
+	"火":  "Fire",
+	"冰":  "Ice",
+	"能量": "Energy",
+	"引力": "Gravity",
+	"开放": "Open",
+	"稳定": "Stable",
+	"波动": "Fluctuating",
+	"零星": "0 stars",
+	"一星": "1 star",
+	"二星": "2 stars",
+	"三星": "3 stars",
+
+	// 属性与卡面
+	"攻击":   "Attack",
+	"生命":   "HP",
+	"防御":   "Defense",
+	"暴击":   "Crit Rate",
+	"暴伤":   "Crit DMG",
+	"加速回能": "Energy Regen",
+	"虚弱增伤": "Weaken Boost",
+	"誓约增伤": "Oath Boost",
+	"誓约回能": "Oath Regen",
+	"日卡":   "Sun card",
+	"月卡":   "Moon card",
+
+	// 武器
+	"专武":  "Signature weapon",
+	"重剑":  "Greatsword",
+	"单手剑": "Sword",
+	"法杖":  "Staff",
+	"手枪":  "Pistol",
+}
+
+// traditional maps the simplified characters used by the enum IDs to their traditional form.
+// Characters that are the same in both scripts are left out.
+var traditional = strings.NewReplacer(
+	"彻", "徹", "昼", "晝", "蚀", "蝕", "国", "國", "猎", "獵", "骑", "騎", "远", "遠", "终", "終",
+	"谕", "諭", "极", "極", "军", "軍", "医", "醫", "杀", "殺", "临", "臨", "亚", "亞", "潜", "潛",
+	"画", "畫", "坛", "壇", "锐", "銳", "艺", "藝", "术", "術", "银", "銀", "恶", "惡", "渊", "淵",
+	"无", "無", "尽", "盡", "夺", "奪", "异", "異", "来", "來", "执", "執", "舰", "艦", "飞", "飛",
+	"员", "員", "拥", "擁", "静", "靜", "谧", "謐", "雾", "霧", "坠", "墜", "点", "點", "斓", "斕",
+	"纯", "純", "锋", "鋒", "长", "長", "离", "離", "装", "裝", "开", "開", "稳", "穩", "动", "動",
+	"击", "擊", "伤", "傷", "虚", "虛", "约", "約", "御", "禦", "专", "專", "剑", "劍", "单", "單",
+	"枪", "槍", "关", "關",
+)
+
+// Name returns the display name of a canonical enum ID in the language. IDs without a
+// translation are returned unchanged, so it is safe to call on any value.
+func Name(lang, id string) string {
+	switch lang {
+	case En:
+		if name, ok := englishNames[id]; ok {
+			return name
+		}
+		// 专武·搭档身份
+		if prefix, companion, ok := strings.Cut(id, "·"); ok {
+			if name, ok := englishNames[prefix]; ok {
+				return name + " · " + Name(lang, companion)
+			}
+		}
+		return id
+	case ZhTW:
+		return traditional.Replace(id)
+	}
+	return id
+}
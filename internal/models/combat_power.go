@@ -1,15 +1,40 @@
 package models
 
+import "math"
+
 const (
 	NoData = "无数据"
 )
 
 type CombatPower struct {
-	Score       string
-	BuffedScore string
-	WeakenScore string
-	CritScore   string
-	Evaluation  string
+	Score        string
+	BuffedScore  string
+	WeakenScore  string
+	CritScore    string
+	Evaluation   string
+	Version      string                   `json:",omitempty"` // 估算版本
+	Distribution *CombatPowerDistribution `json:",omitempty"`
+	Level        *LevelEstimate           `json:",omitempty"`
+	Approximate  bool                     `json:",omitempty"` // 搭档没有技能循环，时间轴估算沿用固定次数
+}
+
+// CombatPowerDistribution describes the spread of the buffed score over simulated battles.
+type CombatPowerDistribution struct {
+	Runs int `json:"runs"`
+	Mean int `json:"mean"`
+	P10  int `json:"p10"`
+	P50  int `json:"p50"`
+	P90  int `json:"p90"`
+}
+
+// LevelEstimate is the combat power against the enemies of a specific level.
+type LevelEstimate struct {
+	Key         string  `json:"key"`
+	EnemyLevel  int     `json:"enemy_level"`
+	BuffedScore int     `json:"buffed_score"`
+	Threshold   int     `json:"threshold"` // 通关参考战力，0 为未知
+	Ratio       float64 `json:"ratio"`
+	Verdict     string  `json:"verdict"` // 可通关 / 勉强 / 不足 / 未知
 }
 
 type Stats struct {
@@ -30,6 +55,10 @@ type Stats struct {
 	Stage        string
 	Weapon       string
 	Buff         float64
+
+	SupportCompanion string // 协助搭档，可为空
+	WeaponID         string // 武器图鉴中的武器，Weapon 为其类型
+	WeaponRefinement int    // 武器精炼等级
 }
 
 type Skill struct {
@@ -79,16 +108,15 @@ type StageBuff struct {
 	Buffs map[string]SkillBuff
 }
 
+// energyRegenThresholds are the 加速回能 values at which a battle has one more energy charge.
+var energyRegenThresholds = []float64{6, 10.8, 30, 39.6}
+
 func (s Stats) GetEnergy() int {
 	energy := 9
-	if s.EnergyRegen >= 39.6 {
-		energy += 4
-	} else if s.EnergyRegen >= 30 {
-		energy += 3
-	} else if s.EnergyRegen >= 10.8 {
-		energy += 2
-	} else if s.EnergyRegen >= 6 {
-		energy += 1
+	for _, threshold := range energyRegenThresholds {
+		if s.EnergyRegen >= threshold {
+			energy += 1
+		}
 	}
 
 	if s.Stage == "III" || s.Stage == "IV" {
@@ -97,3 +125,18 @@ func (s Stats) GetEnergy() int {
 
 	return energy
 }
+
+// EnergyProgress is how far 加速回能 is past the last threshold it reached, from 0 right at the
+// threshold to 1 at the next one. Just past a threshold, the last charge barely arrives in time.
+// Above the last threshold the last gap is the step.
+func (s Stats) EnergyProgress() float64 {
+	lower := 0.0
+	for _, upper := range energyRegenThresholds {
+		if s.EnergyRegen < upper {
+			return math.Max(0, (s.EnergyRegen-lower)/(upper-lower))
+		}
+		lower = upper
+	}
+	n := len(energyRegenThresholds)
+	return math.Min(1, (s.EnergyRegen-lower)/(energyRegenThresholds[n-1]-energyRegenThresholds[n-2]))
+}
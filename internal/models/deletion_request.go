@@ -0,0 +1,48 @@
+package models
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+)
+
+const (
+	DeletionModeAnonymise = "anonymise" // 保留记录，但去除与用户的关联
+	DeletionModeDelete    = "delete"    // 删除所有记录
+
+	DeletionStatusPending   = "pending"
+	DeletionStatusCompleted = "completed"
+)
+
+type DeletionRequest struct {
+	ID                   string `json:"id"`
+	UserID               string `json:"-"`
+	PublicID             string `json:"public_id"`
+	Mode                 string `json:"mode"`
+	Status               string `json:"status"`
+	RequestedAt          string `json:"requested_at"`
+	CompletedAt          string `json:"completed_at,omitempty"`
+	OrbitRecords         int    `json:"orbit_records"`
+	ChampionshipsRecords int    `json:"championships_records"`
+	Receipt              string `json:"receipt,omitempty"`
+	Error                string `json:"error,omitempty"`
+	RowNumber            int    `json:"row_number"`
+	TokensRevokedAt      string `json:"tokens_revoked_at,omitempty"` // 注销前签发的 token 自此失效
+}
+
+func (d DeletionRequest) ValidateMode() error {
+	if d.Mode != DeletionModeAnonymise && d.Mode != DeletionModeDelete {
+		return fmt.Errorf("无效的注销方式: %s", d.Mode)
+	}
+	return nil
+}
+
+// GenerateReceipt returns a digest over the outcome of the request. It does not contain the
+// OpenID, so it can be kept after the user is gone.
+func (d DeletionRequest) GenerateReceipt() string {
+	data := fmt.Sprintf("%s|%s|%s|%s|%s|%d|%d|%s",
+		d.ID, d.PublicID, d.Mode, d.RequestedAt, d.CompletedAt, d.OrbitRecords, d.ChampionshipsRecords, d.TokensRevokedAt,
+	)
+	hash := sha256.Sum256([]byte(data))
+	return hex.EncodeToString(hash[:])
+}
@@ -0,0 +1,14 @@
+package models
+
+// Identity links a login provider account to a User. One user can have several identities.
+type Identity struct {
+	Provider     string `json:"provider"`
+	Subject      string `json:"subject"`
+	UserID       string `json:"-"`
+	PasswordHash string `json:"-"`
+	LinkedAt     string `json:"linked_at"`
+	RowNumber    int    `json:"-"`
+	// Unverified is set on email identities registered with a password until their owner logs
+	// in through a magic link. The password is not accepted before that.
+	Unverified bool `json:"unverified,omitempty"`
+}
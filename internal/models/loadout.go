@@ -0,0 +1,298 @@
+package models
+
+import (
+	"encoding/json"
+	"fmt"
+	"math"
+	"sort"
+	"strconv"
+)
+
+const (
+	SunCard  = "日卡"
+	MoonCard = "月卡"
+
+	sunCardsPerLoadout  = 2
+	moonCardsPerLoadout = 4
+	maxCardLevel        = 80
+
+	// percentCoreSlots is the number of percentage core stats of a card, next to the flat
+	// attack, HP and defence every card has.
+	percentCoreSlots = 1
+	// levelOneCapShare is the share of the level cap value a core stat has at level 1. Core
+	// stats grow linearly with the level in between.
+	levelOneCapShare = 0.25
+)
+
+// maxCardLevels is the level cap of a card, by star.
+var maxCardLevels = map[int]int{4: 70, 5: maxCardLevel}
+
+// starCapShares scales the caps below, which are those of a 5★ card, to the star of the card.
+var starCapShares = map[int]float64{4: 0.8, 5: 1}
+
+// Panel stats a card can carry. The names match the record fields.
+const (
+	StatAttack      = "攻击"
+	StatHP          = "生命"
+	StatDefense     = "防御"
+	StatCritRate    = "暴击"
+	StatCritDmg     = "暴伤"
+	StatEnergyRegen = "加速回能"
+	StatWeakenBoost = "虚弱增伤"
+	StatOathBoost   = "誓约增伤"
+	StatOathRegen   = "誓约回能"
+)
+
+var panelStats = []string{StatAttack, StatHP, StatDefense, StatCritRate, StatCritDmg,
+	StatEnergyRegen, StatWeakenBoost, StatOathBoost, StatOathRegen}
+
+var flatStats = map[string]bool{StatAttack: true, StatHP: true, StatDefense: true}
+
+// panelBase is what the panel shows without any card.
+var panelBase = map[string]float64{
+	StatCritDmg: 150,
+}
+
+// coreCaps is the largest core value of a 5★ card at level 80, by card type. A card type has
+// no other core stats. Crit rate is half of crit damage, as everywhere in the game.
+var coreCaps = map[string]map[string]float64{
+	SunCard: {
+		StatAttack:    1229 * 1.9,
+		StatHP:        24594 * 1.9,
+		StatDefense:   614 * 1.9,
+		StatCritRate:  10,
+		StatCritDmg:   20,
+		StatOathBoost: 14,
+		StatOathRegen: 14,
+	},
+	MoonCard: {
+		StatAttack:      1229 * 1.9,
+		StatHP:          24594 * 1.9,
+		StatDefense:     614 * 1.9,
+		StatCritRate:    11.2,
+		StatCritDmg:     22.4,
+		StatWeakenBoost: 18.2,
+		StatEnergyRegen: 12.6,
+	},
+}
+
+// subStatCaps is the largest total of a stat over the sub-stats of one 5★ card, two rolls at
+// most. Sub-stats do not grow with the level. Flat stats are capped together with the core, see
+// Violations.
+var subStatCaps = map[string]float64{
+	StatCritRate:    7.2 * 2,
+	StatCritDmg:     14.4 * 2,
+	StatEnergyRegen: 4.5 * 2,
+	StatWeakenBoost: 11 * 2,
+	StatOathBoost:   5.6 * 2,
+	StatOathRegen:   4.5 * 2,
+}
+
+type Card struct {
+	Type     string             `json:"类型"` // 日卡 / 月卡
+	Star     int                `json:"星级"` // 4 或 5
+	Level    int                `json:"等级"`
+	Core     map[string]float64 `json:"核心属性"`
+	SubStats map[string]float64 `json:"副属性"`
+}
+
+// coreCap is the largest core value of the stat for the type, star and level of the card.
+// A card with an invalid star or level is checked against the 5★ level 80 caps, the star or
+// level is reported on its own.
+func (c Card) coreCap(stat string) (float64, bool) {
+	limit, ok := coreCaps[c.Type][stat]
+	if !ok {
+		return 0, false
+	}
+
+	maxLevel, ok := maxCardLevels[c.Star]
+	if !ok || c.Level <= 0 || c.Level > maxLevel {
+		return limit, true
+	}
+	progress := float64(c.Level-1) / float64(maxLevel-1)
+	return limit * starCapShares[c.Star] * (levelOneCapShare + (1-levelOneCapShare)*progress), true
+}
+
+// subStatCap is the largest sub-stat total of the stat for the star of the card.
+func (c Card) subStatCap(stat string) float64 {
+	if share, ok := starCapShares[c.Star]; ok {
+		return subStatCaps[stat] * share
+	}
+	return subStatCaps[stat]
+}
+
+// Loadout is the optional detailed input behind the panel totals: two sun cards and four moon
+// cards.
+type Loadout struct {
+	Cards []Card `json:"卡面"`
+}
+
+// ParseLoadout reads a loadout from the request, either as an object or as a JSON string. A
+// missing loadout is not an error.
+func ParseLoadout(value interface{}) (*Loadout, error) {
+	var data []byte
+	switch v := value.(type) {
+	case nil:
+		return nil, nil
+	case string:
+		if v == "" {
+			return nil, nil
+		}
+		data = []byte(v)
+	default:
+		var err error
+		if data, err = json.Marshal(v); err != nil {
+			return nil, err
+		}
+	}
+
+	var loadout Loadout
+	if err := json.Unmarshal(data, &loadout); err != nil {
+		return nil, fmt.Errorf("卡组格式错误: %w", err)
+	}
+	return &loadout, nil
+}
+
+func (l Loadout) String() string {
+	data, _ := json.Marshal(l)
+	return string(data)
+}
+
+// Violations checks each card against the caps of a single card.
+func (l Loadout) Violations() []Violation {
+	var violations []Violation
+	add := func(code, field, message string, params map[string]string) {
+		violations = append(violations, newViolation(code, field, message, params))
+	}
+
+	counts := map[string]int{}
+	for i, card := range l.Cards {
+		path := fmt.Sprintf("卡组.卡面[%d]", i)
+		number := strconv.Itoa(i + 1)
+		counts[card.Type]++
+
+		if _, ok := coreCaps[card.Type]; !ok {
+			add("loadout_card_type", path+".类型", "第{card}张卡类型错误: {value}", map[string]string{"card": number, "value": card.Type})
+			continue
+		}
+		maxLevel, validStar := maxCardLevels[card.Star]
+		if !validStar {
+			add("loadout_card_star", path+".星级", "第{card}张卡星级错误: {value}", map[string]string{"card": number, "value": strconv.Itoa(card.Star)})
+			maxLevel = maxCardLevel
+		}
+		if card.Level <= 0 || card.Level > maxLevel {
+			add("loadout_card_level", path+".等级", "第{card}张卡等级错误: {value}", map[string]string{"card": number, "value": strconv.Itoa(card.Level)})
+		}
+
+		percentCores := 0
+		for _, stat := range sortedStats(card.Core) {
+			value := card.Core[stat]
+			if !flatStats[stat] && value > 0 {
+				percentCores++
+			}
+			if limit, ok := card.coreCap(stat); value < 0 || !ok || value > limit {
+				add("loadout_core_stat", path+".核心属性."+stat, "第{card}张卡核心属性错误: {stat} {value}",
+					map[string]string{"card": number, "stat": stat, "value": formatStat(stat, value)})
+			}
+		}
+		if percentCores > percentCoreSlots {
+			add("loadout_core_slots", path+".核心属性", "第{card}张卡最多{slots}个百分比核心属性",
+				map[string]string{"card": number, "slots": strconv.Itoa(percentCoreSlots)})
+		}
+		for _, stat := range sortedStats(card.SubStats) {
+			value := card.SubStats[stat]
+			if value < 0 || !isPanelStat(stat) || (!flatStats[stat] && value > card.subStatCap(stat)) {
+				add("loadout_sub_stat", path+".副属性."+stat, "第{card}张卡副属性错误: {stat} {value}",
+					map[string]string{"card": number, "stat": stat, "value": formatStat(stat, value)})
+			}
+		}
+		for _, stat := range panelStats {
+			if !flatStats[stat] {
+				continue
+			}
+			total := card.Core[stat] + card.SubStats[stat]
+			if limit, _ := card.coreCap(stat); total > limit {
+				add("loadout_card_stat_too_high", path, "第{card}张卡{stat}过高: {value}",
+					map[string]string{"card": number, "stat": stat, "value": formatStat(stat, total)})
+			}
+		}
+	}
+
+	if counts[SunCard] != sunCardsPerLoadout || counts[MoonCard] != moonCardsPerLoadout {
+		add("loadout_card_count", "卡组.卡面", "卡组需要{sun}张日卡和{moon}张月卡",
+			map[string]string{"sun": strconv.Itoa(sunCardsPerLoadout), "moon": strconv.Itoa(moonCardsPerLoadout)})
+	}
+	return violations
+}
+
+// Validate returns every violation of the loadout as one error.
+func (l Loadout) Validate() error {
+	return violationsError(l.Violations())
+}
+
+// Totals is the panel the loadout adds up to.
+func (l Loadout) Totals() map[string]float64 {
+	totals := map[string]float64{}
+	for stat, value := range panelBase {
+		totals[stat] = value
+	}
+	for _, card := range l.Cards {
+		for stat, value := range card.Core {
+			totals[stat] += value
+		}
+		for stat, value := range card.SubStats {
+			totals[stat] += value
+		}
+	}
+	return totals
+}
+
+func (l Loadout) TotalLevel() int {
+	total := 0
+	for _, card := range l.Cards {
+		total += card.Level
+	}
+	return total
+}
+
+// Without returns the loadout with the card removed, for measuring what the card contributes.
+func (l Loadout) Without(index int) Loadout {
+	cards := make([]Card, 0, len(l.Cards))
+	cards = append(cards, l.Cards[:index]...)
+	return Loadout{Cards: append(cards, l.Cards[index+1:]...)}
+}
+
+// maxPanelValue is the largest panel value the caps of a full loadout allow.
+func maxPanelValue(stat string) float64 {
+	return panelBase[stat] +
+		coreCaps[SunCard][stat]*sunCardsPerLoadout +
+		coreCaps[MoonCard][stat]*moonCardsPerLoadout +
+		subStatCaps[stat]*(sunCardsPerLoadout+moonCardsPerLoadout)
+}
+
+func isPanelStat(stat string) bool {
+	for _, s := range panelStats {
+		if s == stat {
+			return true
+		}
+	}
+	return false
+}
+
+func sortedStats(stats map[string]float64) []string {
+	names := make([]string, 0, len(stats))
+	for name := range stats {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// formatStat rounds the way the panel shows the stat: whole numbers for flat stats and one
+// decimal for percentages.
+func formatStat(stat string, value float64) string {
+	if flatStats[stat] {
+		return strconv.Itoa(int(math.Round(value)))
+	}
+	return strconv.FormatFloat(math.Round(value*10)/10, 'f', -1, 64)
+}
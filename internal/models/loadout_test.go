@@ -0,0 +1,100 @@
+package models
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestLoadout(t *testing.T) {
+	loadout := Loadout{}
+	for i := 0; i < 6; i++ {
+		card := Card{Type: MoonCard, Star: 5, Level: 80,
+			Core:     map[string]float64{StatAttack: 1800, StatCritDmg: 22.4},
+			SubStats: map[string]float64{StatCritRate: 7.2, StatWeakenBoost: 5.5}}
+		if i < 2 {
+			card.Type = SunCard
+			card.Core = map[string]float64{StatAttack: 2000, StatCritDmg: 20}
+		}
+		loadout.Cards = append(loadout.Cards, card)
+	}
+	if err := loadout.Validate(); err != nil {
+		t.Fatalf("valid loadout rejected: %v", err)
+	}
+
+	record := Record{Loadout: &loadout, CritRate: "43.2"}
+	record.ApplyLoadout()
+	if record.Attack != "11200" || record.CritDmg != "279.6" || record.WeakenBoost != "33" || record.TotalLevel != "480" {
+		t.Errorf("panel not derived from the loadout: %+v", record)
+	}
+	if violations := record.loadoutViolations(nil); len(violations) > 0 {
+		t.Errorf("derived panel does not match the loadout: %v", violations)
+	}
+
+	record.CritDmg = "300"
+	if violations := record.loadoutViolations(nil); len(violations) != 1 || violations[0].Field != StatCritDmg {
+		t.Errorf("mismatching panel accepted: %v", violations)
+	}
+
+	loadout.Cards[3].SubStats[StatCritDmg] = 30
+	if violations := loadout.Violations(); len(violations) != 1 || violations[0].Field != "卡组.卡面[3].副属性.暴伤" {
+		t.Errorf("sub-stat above the cap accepted: %v", violations)
+	}
+
+	loadout.Cards[3].SubStats[StatCritDmg] = 14.4
+	loadout.Cards[0].Core[StatCritRate] = 12
+	loadout.Cards[1].Core[StatEnergyRegen] = 5
+	loadout.Cards[4].SubStats[StatOathRegen] = 20
+	var fields []string
+	for _, violation := range loadout.Violations() {
+		fields = append(fields, violation.Field)
+	}
+	// 日卡已经有暴伤核心，再加一个百分比核心属性也超出了核心属性的数量
+	if want := []string{"卡组.卡面[0].核心属性.暴击", "卡组.卡面[0].核心属性", "卡组.卡面[1].核心属性.加速回能",
+		"卡组.卡面[1].核心属性", "卡组.卡面[4].副属性.誓约回能"}; !reflect.DeepEqual(fields, want) {
+		t.Errorf("expected %v, got %v", want, fields)
+	}
+
+	if got := maxPanelValue(StatCritDmg); got < 452.39 || got > 452.41 {
+		t.Errorf("crit damage cap changed: %v", got)
+	}
+}
+
+func TestCardCaps(t *testing.T) {
+	tests := []struct {
+		name string
+		card Card
+		want []string // violation codes
+	}{
+		{"5★ level 80 at the cap", Card{Type: MoonCard, Star: 5, Level: 80,
+			Core: map[string]float64{StatAttack: 2335, StatCritDmg: 22.4}, SubStats: map[string]float64{StatCritRate: 14.4}}, nil},
+		{"4★ level 70 at its cap", Card{Type: MoonCard, Star: 4, Level: 70,
+			Core: map[string]float64{StatAttack: 1868, StatCritDmg: 17.9}, SubStats: map[string]float64{StatCritRate: 11.5}}, nil},
+		{"4★ level 1 with a 5★ core", Card{Type: MoonCard, Star: 4, Level: 1,
+			Core: map[string]float64{StatCritDmg: 22.4}}, []string{"loadout_core_stat"}},
+		{"4★ level 70 with a 5★ core", Card{Type: MoonCard, Star: 4, Level: 70,
+			Core: map[string]float64{StatCritDmg: 22.4}}, []string{"loadout_core_stat"}},
+		{"5★ level 1 with a level 80 core", Card{Type: SunCard, Star: 5, Level: 1,
+			Core: map[string]float64{StatOathBoost: 14}}, []string{"loadout_core_stat"}},
+		{"4★ level 1 with a level 80 attack", Card{Type: SunCard, Star: 4, Level: 1,
+			Core: map[string]float64{StatAttack: 1800}}, []string{"loadout_core_stat", "loadout_card_stat_too_high"}},
+		{"4★ with 5★ sub-stats", Card{Type: MoonCard, Star: 4, Level: 70,
+			SubStats: map[string]float64{StatCritDmg: 28.8}}, []string{"loadout_sub_stat"}},
+		{"4★ above its level cap", Card{Type: MoonCard, Star: 4, Level: 80}, []string{"loadout_card_level"}},
+		{"every core slot filled", Card{Type: MoonCard, Star: 5, Level: 80,
+			Core: map[string]float64{StatCritRate: 11.2, StatCritDmg: 22.4, StatWeakenBoost: 18.2, StatEnergyRegen: 12.6}},
+			[]string{"loadout_core_slots"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var codes []string
+			for _, violation := range (Loadout{Cards: []Card{tt.card}}).Violations() {
+				if violation.Code != "loadout_card_count" {
+					codes = append(codes, violation.Code)
+				}
+			}
+			if !reflect.DeepEqual(codes, tt.want) {
+				t.Errorf("expected %v, got %v", tt.want, codes)
+			}
+		})
+	}
+}
@@ -1,7 +1,55 @@
 package models
 
 type RankingItem struct {
-	OpenID       string `json:"openid"`
+	OpenID       string `json:"-"`
+	PublicID     string `json:"public_id"`
+	IsMe         bool   `json:"is_me,omitempty"`
+	Nickname     string `json:"nickname,omitempty"`
 	Contribution int32  `json:"contribution"`
+	Score        int    `json:"score,omitempty"`
+	Level        string `json:"level,omitempty"`
 	Rank         int    `json:"rank"`
 }
+
+type Ranking []RankingItem
+
+// Rank assigns ranks to a ranking that is already sorted by score in descending order.
+// Items sharing the same score share the same rank.
+func (r Ranking) Rank(score func(RankingItem) int64) Ranking {
+	ranked := make(Ranking, len(r))
+	rank := 1
+	for i, item := range r {
+		if i > 0 && score(item) < score(r[i-1]) {
+			rank = i + 1
+		}
+		item.Rank = rank
+		ranked[i] = item
+	}
+	return ranked
+}
+
+// Top keeps the items ranked within limit. If the user identified by userId is ranked
+// outside of the limit, its item is appended at the end.
+func (r Ranking) Top(limit int, userId string) Ranking {
+	result := Ranking{}
+	userInResult := false
+	var userRank *RankingItem
+
+	for i, item := range r {
+		if item.Rank <= limit {
+			result = append(result, item)
+			if item.OpenID == userId {
+				userInResult = true
+			}
+		}
+		if userId != "" && item.OpenID == userId {
+			userRank = &r[i]
+		}
+	}
+
+	if !userInResult && userRank != nil {
+		result = append(result, *userRank)
+	}
+
+	return result
+}
@@ -0,0 +1,52 @@
+package models
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestRankingRankTop(t *testing.T) {
+	ranking := Ranking{
+		{OpenID: "a", Score: 300},
+		{OpenID: "b", Score: 200},
+		{OpenID: "c", Score: 200},
+		{OpenID: "d", Score: 100},
+		{OpenID: "e", Score: 50},
+	}
+	ranked := ranking.Rank(func(item RankingItem) int64 { return int64(item.Score) })
+
+	// 同分同名次，下一名跳过并列的名次
+	ranks := []int{}
+	for _, item := range ranked {
+		ranks = append(ranks, item.Rank)
+	}
+	if want := []int{1, 2, 2, 4, 5}; !reflect.DeepEqual(ranks, want) {
+		t.Fatalf("unexpected ranks %v", ranks)
+	}
+	if ranking[0].Rank != 0 {
+		t.Error("Rank modified the ranking it was called on")
+	}
+
+	tests := []struct {
+		name   string
+		limit  int
+		userId string
+		want   []string
+	}{
+		{"ties within the limit", 2, "", []string{"a", "b", "c"}},
+		{"viewer in the top", 2, "b", []string{"a", "b", "c"}},
+		{"viewer outside the top", 2, "e", []string{"a", "b", "c", "e"}},
+		{"unknown viewer", 1, "z", []string{"a"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var got []string
+			for _, item := range ranked.Top(tt.limit, tt.userId) {
+				got = append(got, item.OpenID)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("expected %v, got %v", tt.want, got)
+			}
+		})
+	}
+}
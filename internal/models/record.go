@@ -8,17 +8,18 @@ import (
 	"strconv"
 	"strings"
 	"time"
-	"unicode/utf8"
 
-	"lysk-battle-record/internal/pkg"
+	"lysk-battle-record/internal/registry"
 	"lysk-battle-record/internal/utils"
 )
 
 type Record struct {
 	Id           string      `json:"id"`
 	RowNumber    int         `json:"row_number"`
-	UserID       string      `json:"userID"`
+	UserID       string      `json:"userID,omitempty"`
+	PublicID     string      `json:"public_id,omitempty"`
 	Nickname     string      `json:"nickname,omitempty"`
+	Anonymous    bool        `json:"anonymous"`
 	LevelType    string      `json:"关卡"`
 	LevelNumber  string      `json:"关数"`
 	LevelMode    string      `json:"模式"`
@@ -44,127 +45,40 @@ type Record struct {
 	StarRank     string      `json:"星级"`
 	CombatPower  CombatPower `json:"战力值"`
 	Deleted      bool        `json:"deleted"`
+	// EstimatorVersion is the estimator version the record is scored with, empty for records
+	// stored before versions existed
+	EstimatorVersion string `json:"估算版本,omitempty"`
+	// SupportCompanion is the companion of another partner brought as 协助, empty if unknown
+	SupportCompanion string `json:"协助搭档,omitempty"`
+	// Loadout is the optional card by card input the panel totals are derived from
+	Loadout *Loadout `json:"卡组,omitempty"`
+	// WeaponRefinement is the refinement level of the weapon, empty for the first level
+	WeaponRefinement string `json:"武器精炼,omitempty"`
 }
 
 type Records []Record
 
-func (r Record) validateCommon() (bool, error) {
-	if !r.validateLevelType() {
-		return false, fmt.Errorf("无效的关卡类型: %s", r.LevelType)
-	}
-
-	if !r.validateAttack() {
-		return false, fmt.Errorf("攻击数值错误: %s", r.Attack)
-	}
-
-	if _, err := r.validateDefence(); err != nil {
-		return false, err
-	}
-
-	if _, err := r.validateHP(); err != nil {
-		return false, err
-	}
-
-	if !r.validateMatching() {
-		return false, fmt.Errorf("对谱类型错误: %s", r.Matching)
-	}
-
-	if !r.validateMatchingBuff() {
-		return false, fmt.Errorf("对谱加成错误: %s", r.MatchingBuff)
-	}
-
-	if !r.validateCritRate() {
-		return false, fmt.Errorf("暴击率错误: %s", r.CritRate)
-	}
-
-	if !r.validateCritDmg() {
-		return false, fmt.Errorf("暴击伤害错误: %s", r.CritDmg)
-	}
-
-	if !r.validateWeakenBoost() {
-		return false, fmt.Errorf("虚弱增伤错误: %s", r.WeakenBoost)
-	}
-
-	if !r.validateOathBoost() {
-		return false, fmt.Errorf("誓约增伤错误: %s", r.OathBoost)
-	}
-
-	if !r.validateOathRegen() {
-		return false, fmt.Errorf("誓约回能错误: %s", r.OathRegen)
-	}
-
-	if !r.validateEnergyRegen() {
-		return false, fmt.Errorf("加速回能错误: %s", r.EnergyRegen)
-	}
-
-	if !r.validateRegen() {
-		return false, fmt.Errorf("回能总和错误: %s + %s，面板总回能不能大于48", r.EnergyRegen, r.OathRegen)
-	}
-
-	if !r.validateStage() {
-		return false, fmt.Errorf("阶数错误: %s", r.Stage)
-	}
-
-	if !r.validateWeapon() {
-		return false, fmt.Errorf("武器错误: %s", r.Weapon)
-	}
-
-	if !r.validateCompanionSetCard() {
-		return false, fmt.Errorf("搭档身份与日卡不匹配: %s - %s", r.Companion, r.SetCard)
-	}
-
-	if r.SetCard == "无套装" && r.Stage != "无套装" {
-		return false, fmt.Errorf("无套装时阶数必须为无套装: %s", r.Stage)
-	}
-
-	if r.SetCard != "无套装" && r.Stage == "无套装" {
-		return false, fmt.Errorf("有套装时阶数不能为无套装: %s", r.Stage)
-	}
-
-	if !r.validateTotalLevel() {
-		return false, fmt.Errorf("卡面总等级错误, 请填写卡面等级总和。如不确定请填留空: %s", r.TotalLevel)
-	}
-
-	if pass, err := r.ValidateNote(); !pass {
-		return false, err
+// content is the game content in force when the record was set. Records without a time are
+// checked against the content in force now.
+func (r Record) content() registry.ContentVersion {
+	t, err := time.Parse(time.RFC3339, r.Time)
+	if err != nil {
+		t = time.Now()
 	}
-
-	return true, nil
+	return registry.ContentAt(t)
 }
 
 func (r Record) validateLevelType() bool {
-	validTypes := map[string]bool{
-		"光":   true,
-		"火":   true,
-		"冰":   true,
-		"能量": true,
-		"引力": true,
-		"开放": true,
-		"A4":   true,
-		"B4":   true,
-		"C4":   true,
-	}
-	return validTypes[r.LevelType]
+	_, ok := r.content().LevelType(r.LevelType)
+	return ok
 }
 
 func (r Record) validateLevelNumber() bool {
-	maxEasyLevelNumber := map[string]int{
-		"光":   210,
-		"火":   210,
-		"冰":   210,
-		"能量": 180,
-		"引力": 150,
-		"开放": 300,
-	}
-
-	maxHardLevelNumber := map[string]int{
-		"光":   0,
-		"火":   0,
-		"冰":   0,
-		"能量": 0,
-		"引力": 0,
-		"开放": 60,
+	levelType, ok := r.content().LevelType(r.LevelType)
+	if !ok || levelType.Championship {
+		return false
 	}
+
 	levelInfo := strings.Split(r.LevelNumber, "_")
 	var levelPart string
 
@@ -173,12 +87,13 @@ func (r Record) validateLevelNumber() bool {
 	}
 
 	levelNumber, _ := strconv.Atoi(levelInfo[0])
-	validEasyLevelNumber := r.LevelMode == "稳定" && levelNumber <= maxEasyLevelNumber[r.LevelType]
-	validHardLevelNumber := r.LevelMode == "波动" && levelNumber <= maxHardLevelNumber[r.LevelType]
+	validEasyLevelNumber := r.LevelMode == registry.ModeStable && levelNumber <= levelType.MaxStable
+	validHardLevelNumber := r.LevelMode == registry.ModeFluctuating && levelNumber <= levelType.MaxFluctuating
 	isValidNumber := levelNumber > 0 && (validEasyLevelNumber || validHardLevelNumber)
-	isValidPart := (levelNumber%10 != 0 && levelPart == "") ||
-		(levelNumber%10 == 0 && (levelPart == "上" || levelPart == "下")) ||
-		(r.LevelMode == "波动" && levelNumber%5 == 0 && (levelPart == "上" || levelPart == "下"))
+	hasPart := levelPart == "上" || levelPart == "下"
+	isValidPart := (levelNumber%levelType.SplitEvery != 0 && levelPart == "") ||
+		(levelNumber%levelType.SplitEvery == 0 && hasPart) ||
+		(r.LevelMode == registry.ModeFluctuating && levelType.FluctuatingSplit > 0 && levelNumber%levelType.FluctuatingSplit == 0 && hasPart)
 	if !isValidNumber || !isValidPart {
 		return false
 	}
@@ -187,79 +102,60 @@ func (r Record) validateLevelNumber() bool {
 }
 
 func (r Record) validateLevelMode() bool {
-	validModes := map[string]bool{
-		"稳定": true,
-		"波动": true,
-	}
-
-	if !validModes[r.LevelMode] {
-		return false
-	}
-
-	if r.LevelType != "开放" && r.LevelMode == "波动" {
-		return false
+	switch r.LevelMode {
+	case registry.ModeStable:
+		return true
+	case registry.ModeFluctuating:
+		levelType, ok := r.content().LevelType(r.LevelType)
+		return ok && levelType.MaxFluctuating > 0
 	}
-
-	return true
+	return false
 }
 
 func (r Record) validateAttack() bool {
-	maxAttack := 1229 * 1.9 * 6
 	n, err := strconv.ParseFloat(r.Attack, 64)
-	if err != nil || n <= 0 || n > maxAttack {
+	if err != nil || n <= 0 || n > maxPanelValue(StatAttack) {
 		return false
 	}
 
 	return true
 }
 
-func (r Record) validateDefence() (bool, error) {
-	maxDefence := 614 * 1.9 * 6
-	if r.Defense == "" {
-		r.Defense = "0"
-	}
-	n, err := strconv.ParseFloat(r.Defense, 64)
-	if err != nil || n < 0 || n > maxDefence {
-		return false, fmt.Errorf("防御值错误: %s", r.Defense)
+func (r Record) validateDefence() bool {
+	n, err := parseOptional(r.Defense)
+	if err != nil || n < 0 || n > maxPanelValue(StatDefense) {
+		return false
 	}
 
-	defenceCompanions := map[string]bool{
-		"光猎":         true,
-		"永恒先知":     true,
-		"远空执舰官":   true,
-		"利莫里亚海神": true,
-		"银翼恶魔":     true,
-	}
+	return true
+}
 
-	if _, ok := defenceCompanions[r.Companion]; ok && n == 0 {
-		return false, fmt.Errorf("搭档 %s 的防御值不能为 0", r.Companion)
+func (r Record) validateHP() bool {
+	n, err := parseOptional(r.HP)
+	if err != nil || n < 0 || n > maxPanelValue(StatHP) {
+		return false
 	}
 
-	return true, nil
+	return true
 }
 
-func (r Record) validateHP() (bool, error) {
-	maxHP := 24594 * 1.9 * 6
-	if r.HP == "" {
-		r.HP = "0"
-	}
-	n, err := strconv.ParseFloat(r.HP, 64)
-	if err != nil || n < 0 || n > maxHP {
-		return false, fmt.Errorf("生命值错误: %s", r.HP)
+// validateScalingStat rejects a zero panel value for the stat the companion's skills scale with.
+func (r Record) validateScalingStat(scalingStat, value string) bool {
+	companion, ok := registry.GetCompanion(r.Companion)
+	if !ok || companion.ScalingStat != scalingStat {
+		return true
 	}
 
-	hpCompanions := map[string]bool{
-		"潮汐之神": true,
-		"深渊主宰": true,
-		"暗蚀国王": true,
-		"终末之神": true,
-	}
+	n, _ := parseOptional(value)
+	return n != 0
+}
 
-	if _, ok := hpCompanions[r.Companion]; ok && n == 0 {
-		return false, fmt.Errorf("搭档 %s 的生命值不能为 0", r.Companion)
+// parseOptional reads an empty panel value as 0.
+func parseOptional(value string) (float64, error) {
+	if value == "" {
+		return 0, nil
 	}
-
-	return true, nil
+	return strconv.ParseFloat(value, 64)
 }
 
 func (r Record) validateMatching() bool {
@@ -285,15 +181,21 @@ func (r Record) validateMatchingBuff() bool {
 	return validMatchingBuff[r.MatchingBuff]
 }
 
+// validateWeapon accepts a weapon type or the ID of a weapon in the catalogue. A signature
+// weapon must belong to the companion.
 func (r Record) validateWeapon() bool {
-	validWeapons := map[string]bool{
-		"专武":   true,
-		"重剑":   true,
-		"手枪":   true,
-		"法杖":   true,
-		"单手剑": true,
+	weapon, ok := registry.GetWeapon(r.Weapon)
+	if !ok {
+		return false
 	}
-	return validWeapons[r.Weapon]
+	return weapon.Companion == "" || weapon.Companion == registry.CanonicalCompanionName(r.Companion)
+}
+
+// validateWeaponRefinement rejects refining a weapon type, which names no weapon to refine.
+func (r Record) validateWeaponRefinement() bool {
+	weapon, _ := registry.GetWeapon(r.Weapon)
+	refinement, _ := registry.ParseRefinement(r.WeaponRefinement)
+	return refinement <= weapon.MaxRefinement()
 }
 
 func (r Record) validateBuff() bool {
@@ -308,70 +210,111 @@ func (r Record) validateBuff() bool {
 }
 
 func (r Record) validateCompanionSetCard() bool {
-	partnerMap := utils.GetPartnerCompanionMap()
-	setCardMap := utils.GetPartnerSetCardMap()
-
-	for partner, companions := range partnerMap {
-		for _, companion := range companions {
-			if companion == r.Companion {
-				if setCards, exists := setCardMap[partner]; exists {
-					return setCards[r.SetCard]
-				}
-				return false
-			}
-		}
-	}
-	return false
+	companion, ok := registry.GetCompanion(r.Companion)
+	return ok && companion.AllowsSetCard(r.SetCard)
 }
 
-func (r Record) validatePartnerAndLevelType() bool {
-	levelPartnerMap := map[string]string{
-		"光":   "沈星回",
-		"火":   "祁煜",
-		"冰":   "黎深",
-		"能量": "秦彻",
-		"引力": "夏以昼",
+// loadoutViolations checks the cards and that the panel matches what they add up to. The panel
+// is filled from the loadout on upload, so a mismatch means the two were edited separately.
+// Panel fields that already failed are not compared.
+func (r Record) loadoutViolations(failed map[string]bool) []Violation {
+	if r.Loadout == nil {
+		return nil
 	}
 
-	// Check if level type has specific partner requirement first
-	requiredMain, hasRequirement := levelPartnerMap[r.LevelType]
-	if !hasRequirement {
-		return true
+	violations := r.Loadout.Violations()
+	if len(violations) > 0 {
+		return violations
 	}
 
-	partnerCompanionsMap := utils.GetPartnerCompanionMap()
-
-	// Find which main character this partner belongs to
-	var partner string
-	found := false
-	for pn, companions := range partnerCompanionsMap {
-		for _, companion := range companions {
-			if companion == r.Companion {
-				partner = pn
-				found = true
-				break
-			}
+	totals := r.Loadout.Totals()
+	for _, stat := range panelStats {
+		field := r.panelField(stat)
+		if *field == "" || failed[stat] {
+			continue
 		}
-		if found {
-			break
+		expected := formatStat(stat, totals[stat])
+		if value, err := strconv.ParseFloat(*field, 64); err != nil || formatStat(stat, value) != expected {
+			violations = append(violations, newViolation("loadout_panel_mismatch", stat,
+				"面板{field}与卡组不一致: {value} - {expected}", map[string]string{"field": stat, "value": *field, "expected": expected}))
 		}
 	}
 
-	if !found {
-		return false
+	if expected := strconv.Itoa(r.Loadout.TotalLevel()); r.TotalLevel != "" && !failed["卡总等级"] && r.TotalLevel != expected {
+		violations = append(violations, newViolation("loadout_total_level_mismatch", "卡总等级",
+			"卡总等级与卡组不一致: {value} - {expected}", map[string]string{"value": r.TotalLevel, "expected": expected}))
+	}
+	return violations
+}
+
+// ApplyLoadout fills the panel fields that were left empty from the loadout.
+func (r *Record) ApplyLoadout() {
+	if r.Loadout == nil {
+		return
 	}
 
-	// Check if partner matches required main character for this level type
-	if partner != requiredMain {
+	totals := r.Loadout.Totals()
+	for _, stat := range panelStats {
+		if field := r.panelField(stat); *field == "" {
+			*field = formatStat(stat, totals[stat])
+		}
+	}
+	if r.TotalLevel == "" {
+		r.TotalLevel = strconv.Itoa(r.Loadout.TotalLevel())
+	}
+}
+
+func (r *Record) panelField(stat string) *string {
+	switch stat {
+	case StatAttack:
+		return &r.Attack
+	case StatHP:
+		return &r.HP
+	case StatDefense:
+		return &r.Defense
+	case StatCritRate:
+		return &r.CritRate
+	case StatCritDmg:
+		return &r.CritDmg
+	case StatEnergyRegen:
+		return &r.EnergyRegen
+	case StatWeakenBoost:
+		return &r.WeakenBoost
+	case StatOathBoost:
+		return &r.OathBoost
+	default:
+		return &r.OathRegen
+	}
+}
+
+// validateSupportCompanion allows an empty support companion, since it is optional.
+func (r Record) validateSupportCompanion() bool {
+	if r.SupportCompanion == "" {
+		return true
+	}
+
+	support, ok := registry.GetCompanion(r.SupportCompanion)
+	if !ok {
 		return false
 	}
+	companion, ok := registry.GetCompanion(r.Companion)
+	return ok && companion.Partner != support.Partner
+}
 
-	return true
+func (r Record) validatePartnerAndLevelType() bool {
+	// Check if level type has specific partner requirement first
+	levelType, ok := r.content().LevelType(r.LevelType)
+	if !ok || levelType.Partner == "" {
+		return true
+	}
+
+	companion, ok := registry.GetCompanion(r.Companion)
+	return ok && companion.Partner == levelType.Partner
 }
 
 func (r Record) validateCritRate() bool {
 	n, err := strconv.ParseFloat(r.CritRate, 64)
-	if err != nil || n < 0 || n > 100 {
+	if err != nil || n < 0 || n > maxCritRate {
 		return false
 	}
 
@@ -379,9 +322,7 @@ func (r Record) validateCritRate() bool {
 }
 
 func (r Record) validateCritDmg() bool {
-	maxCritDmg := float64(150 + 20*2) // 20 max from each sun card itself
-	maxCritDmg += 22.4 * 4            // 22.4 max from each moon card core
-	maxCritDmg += 14.4 * 2 * 6        // 14.4 max from each core attribute
+	maxCritDmg := maxPanelValue(StatCritDmg)
 	n, err := strconv.ParseFloat(r.CritDmg, 64)
 	if err != nil || n < 0 || n > maxCritDmg {
 		return false
@@ -396,7 +337,7 @@ func (r Record) validateEnergyRegen() bool {
 	}
 
 	n, err := strconv.ParseFloat(r.EnergyRegen, 64)
-	if err != nil || n < 0 || n > 48 {
+	if err != nil || n < 0 || n > maxRegen {
 		return false
 	}
 
@@ -409,7 +350,7 @@ func (r Record) validateOathRegen() bool {
 	}
 
 	n, err := strconv.ParseFloat(r.OathRegen, 64)
-	if err != nil || n < 0 || n > 40 {
+	if err != nil || n < 0 || n > maxOathRegen {
 		return false
 	}
 
@@ -417,8 +358,7 @@ func (r Record) validateOathRegen() bool {
 }
 
 func (r Record) validateWeakenBoost() bool {
-	maxWeakenBoost := 18.2 * 4   // 18.2 max from each moon card core
-	maxWeakenBoost += 11 * 2 * 6 // 11 max from each core attribute
+	maxWeakenBoost := maxPanelValue(StatWeakenBoost)
 	n, err := strconv.ParseFloat(r.WeakenBoost, 64)
 	if err != nil || n < 0 || n > maxWeakenBoost {
 		return false
@@ -432,8 +372,7 @@ func (r Record) validateOathBoost() bool {
 		return true
 	}
 
-	maxOathBoost := float64(14 * 2) // 62.4 max from each moon card core
-	maxOathBoost += 5.6 * 2 * 6     // 8.4 max from each core attribute
+	maxOathBoost := maxPanelValue(StatOathBoost)
 	n, err := strconv.ParseFloat(r.OathBoost, 64)
 	if err != nil || n < 0 || n > maxOathBoost {
 		return false
@@ -446,7 +385,7 @@ func (r Record) validateRegen() bool {
 	energy, _ := strconv.ParseFloat(r.EnergyRegen, 64)
 	oath, _ := strconv.ParseFloat(r.OathRegen, 64)
 
-	if energy+oath > 48 {
+	if energy+oath > maxRegen {
 		return false
 	}
 
@@ -471,7 +410,7 @@ func (r Record) validateTotalLevel() bool {
 	}
 
 	totalLevel, err := strconv.Atoi(r.TotalLevel)
-	if err != nil || totalLevel <= 0 || totalLevel > 480 {
+	if err != nil || totalLevel <= 0 || totalLevel > maxTotalLevel {
 		return false
 	}
 
@@ -479,63 +418,11 @@ func (r Record) validateTotalLevel() bool {
 }
 
 func (r Record) validateStarRank() bool {
-	if r.LevelMode != "波动" {
+	if r.LevelMode != registry.ModeFluctuating {
 		return r.StarRank == ""
 	}
 
-	validStarRanks := map[string]bool{
-		"零星": true,
-		"一星": true,
-		"二星": true,
-		"三星": true,
-	}
-
-	return validStarRanks[r.StarRank]
-}
-
-func (r Record) ValidateNote() (bool, error) {
-	if utf8.RuneCountInString(r.Note) > 40 {
-		return false, fmt.Errorf("备注最长30个字: %s", r.Note)
-	}
-
-	detector, err := pkg.NewDetector()
-	if err != nil {
-		return false, err
-	}
-
-	if detector.ContainsSensitiveWords(r.Note) {
-		return false, fmt.Errorf("备注中包含敏感词")
-	}
-
-	return true, nil
-}
-
-func (r Record) ValidateOrbit() (bool, error) {
-	if !r.validateLevelNumber() {
-		return false, fmt.Errorf("关数错误: %s - %s - %s", r.LevelType, r.LevelMode, r.LevelNumber)
-	}
-
-	if !r.validateLevelMode() {
-		return false, fmt.Errorf("关卡模式错误: %s", r.LevelMode)
-	}
-
-	if !r.validateStarRank() {
-		return false, fmt.Errorf("波动关卡通关星级错误: %s", r.StarRank)
-	}
-
-	if !r.validatePartnerAndLevelType() {
-		return false, fmt.Errorf("搭档身份与关卡类型不匹配: %s - %s", r.Companion, r.LevelType)
-	}
-
-	return r.validateCommon()
-}
-
-func (r Record) ValidateChampionships() (bool, error) {
-	if !r.validateBuff() {
-		return false, fmt.Errorf("锦标赛加成错误: %s", r.Buff)
-	}
-
-	return r.validateCommon()
+	return r.content().ValidStarRank(r.StarRank)
 }
 
 func (r Record) GetHash() string {
@@ -544,6 +431,13 @@ func (r Record) GetHash() string {
 		r.CritRate, r.CritDmg, r.EnergyRegen, r.WeakenBoost, r.OathBoost,
 		r.OathRegen, r.Companion, r.SetCard, r.Stage, r.Weapon, r.Buff, r.TotalLevel, r.StarRank,
 	)
+	// 只在填写时加入，保持已有记录的哈希不变
+	if r.SupportCompanion != "" {
+		data += "|" + r.SupportCompanion
+	}
+	if r.WeaponRefinement != "" {
+		data += "|" + r.WeaponRefinement
+	}
 	hash := sha256.Sum256([]byte(data))
 	return hex.EncodeToString(hash[:])
 }
@@ -585,7 +479,7 @@ func (r Record) ToStats() Stats {
 	defense, _ := strconv.Atoi(r.Defense)
 	totalLevel, _ := strconv.Atoi(r.TotalLevel)
 	if totalLevel == 0 {
-		totalLevel = 480
+		totalLevel = int(registry.Param(r.Companion, registry.ParamDefaultTotalLevel))
 	}
 
 	matchingBuff, _ := strconv.ParseFloat(r.MatchingBuff, 64)
@@ -600,6 +494,7 @@ func (r Record) ToStats() Stats {
 	oathBoost, _ := strconv.ParseFloat(r.OathBoost, 64)
 	oathRegen, _ := strconv.ParseFloat(r.OathRegen, 64)
 	buff, _ := strconv.ParseFloat(r.Buff, 64)
+	refinement, _ := registry.ParseRefinement(r.WeaponRefinement)
 
 	return Stats{
 		Attack:       attack,
@@ -617,8 +512,12 @@ func (r Record) ToStats() Stats {
 		Companion:    r.Companion,
 		SetCard:      r.SetCard,
 		Stage:        r.Stage,
-		Weapon:       r.Weapon,
+		Weapon:       registry.WeaponType(r.Weapon),
 		Buff:         buff,
+
+		SupportCompanion: r.SupportCompanion,
+		WeaponID:         r.Weapon,
+		WeaponRefinement: refinement,
 	}
 }
 
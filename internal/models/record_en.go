@@ -0,0 +1,116 @@
+package models
+
+// RecordEN is a record with English JSON keys, served next to the Chinese-keyed record for
+// clients that ask for it. Values stay canonical IDs.
+type RecordEN struct {
+	Id               string      `json:"id"`
+	RowNumber        int         `json:"row_number"`
+	UserID           string      `json:"userID,omitempty"`
+	PublicID         string      `json:"public_id,omitempty"`
+	Nickname         string      `json:"nickname,omitempty"`
+	Anonymous        bool        `json:"anonymous"`
+	LevelType        string      `json:"level_type"`
+	LevelNumber      string      `json:"level_number"`
+	LevelMode        string      `json:"level_mode"`
+	Attack           string      `json:"attack"`
+	HP               string      `json:"hp"`
+	Defense          string      `json:"defense"`
+	Matching         string      `json:"matching"`
+	MatchingBuff     string      `json:"matching_buff"`
+	CritRate         string      `json:"crit_rate"`
+	CritDmg          string      `json:"crit_dmg"`
+	EnergyRegen      string      `json:"energy_regen"`
+	WeakenBoost      string      `json:"weaken_boost"`
+	OathBoost        string      `json:"oath_boost"`
+	OathRegen        string      `json:"oath_regen"`
+	TotalLevel       string      `json:"total_level"`
+	Note             string      `json:"note"`
+	Companion        string      `json:"companion"`
+	SetCard          string      `json:"set_card"`
+	Stage            string      `json:"stage"`
+	Weapon           string      `json:"weapon"`
+	Buff             string      `json:"buff"`
+	Time             string      `json:"time"`
+	StarRank         string      `json:"star_rank"`
+	CombatPower      CombatPower `json:"combat_power"`
+	Deleted          bool        `json:"deleted"`
+	EstimatorVersion string      `json:"estimator_version,omitempty"`
+	SupportCompanion string      `json:"support_companion,omitempty"`
+	Loadout          *LoadoutEN  `json:"loadout,omitempty"`
+	WeaponRefinement string      `json:"weapon_refinement,omitempty"`
+}
+
+type LoadoutEN struct {
+	Cards []CardEN `json:"cards"`
+}
+
+type CardEN struct {
+	Type     string             `json:"type"`
+	Star     int                `json:"star"`
+	Level    int                `json:"level"`
+	Core     map[string]float64 `json:"core"`
+	SubStats map[string]float64 `json:"sub_stats"`
+}
+
+func (r Record) English() RecordEN {
+	en := RecordEN{
+		Id:               r.Id,
+		RowNumber:        r.RowNumber,
+		UserID:           r.UserID,
+		PublicID:         r.PublicID,
+		Nickname:         r.Nickname,
+		Anonymous:        r.Anonymous,
+		LevelType:        r.LevelType,
+		LevelNumber:      r.LevelNumber,
+		LevelMode:        r.LevelMode,
+		Attack:           r.Attack,
+		HP:               r.HP,
+		Defense:          r.Defense,
+		Matching:         r.Matching,
+		MatchingBuff:     r.MatchingBuff,
+		CritRate:         r.CritRate,
+		CritDmg:          r.CritDmg,
+		EnergyRegen:      r.EnergyRegen,
+		WeakenBoost:      r.WeakenBoost,
+		OathBoost:        r.OathBoost,
+		OathRegen:        r.OathRegen,
+		TotalLevel:       r.TotalLevel,
+		Note:             r.Note,
+		Companion:        r.Companion,
+		SetCard:          r.SetCard,
+		Stage:            r.Stage,
+		Weapon:           r.Weapon,
+		Buff:             r.Buff,
+		Time:             r.Time,
+		StarRank:         r.StarRank,
+		CombatPower:      r.CombatPower,
+		Deleted:          r.Deleted,
+		EstimatorVersion: r.EstimatorVersion,
+		SupportCompanion: r.SupportCompanion,
+		WeaponRefinement: r.WeaponRefinement,
+	}
+	if r.Loadout != nil {
+		en.Loadout = &LoadoutEN{}
+		for _, card := range r.Loadout.Cards {
+			en.Loadout.Cards = append(en.Loadout.Cards, CardEN(card))
+		}
+	}
+	return en
+}
+
+func (records Records) English() []RecordEN {
+	en := make([]RecordEN, len(records))
+	for i, record := range records {
+		en[i] = record.English()
+	}
+	return en
+}
+
+// Loadout converts the English-keyed loadout back to the stored one.
+func (l LoadoutEN) Loadout() Loadout {
+	loadout := Loadout{}
+	for _, card := range l.Cards {
+		loadout.Cards = append(loadout.Cards, Card(card))
+	}
+	return loadout
+}
@@ -0,0 +1,17 @@
+package models
+
+const (
+	RevocationKindToken = "token" // Subject 为 token 的 jti
+	RevocationKindUser  = "user"  // Subject 为被封禁的用户ID
+	// Subject 为用户ID，CreatedAt 之前签发的 token 全部失效，ExpiresAt 之后这些 token 都已过期
+	RevocationKindUserTokens = "user_tokens"
+)
+
+type Revocation struct {
+	Kind      string `json:"kind"`
+	Subject   string `json:"subject"`
+	Reason    string `json:"reason,omitempty"`
+	ExpiresAt string `json:"expires_at,omitempty"`
+	CreatedAt string `json:"created_at"`
+	RowNumber int    `json:"-"`
+}
@@ -8,9 +8,16 @@ import (
 )
 
 type User struct {
-	ID        string `json:"id"`
-	Nickname  string `json:"nickname"`
-	RowNumber int    `json:"row_number"`
+	ID       string `json:"id"`
+	Nickname string `json:"nickname"`
+	PrivacySettings
+	RowNumber int `json:"row_number"`
+}
+
+type PrivacySettings struct {
+	HideNickname       bool `json:"hide_nickname"`        // 不在公开记录和排行榜中展示昵称
+	AnonymousByDefault bool `json:"anonymous_by_default"` // 上传记录默认匿名
+	HideFromRanking    bool `json:"hide_from_ranking"`    // 不参与排行榜
 }
 
 func (u User) ValidateNickname() error {
@@ -0,0 +1,238 @@
+package models
+
+import (
+	"math"
+	"reflect"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+
+	"lysk-battle-record/internal/pkg"
+	"lysk-battle-record/internal/registry"
+)
+
+// Caps of the panel values that do not follow from the card caps.
+const (
+	maxCritRate   = 100
+	maxRegen      = 48 // 加速回能与誓约回能之和的上限
+	maxOathRegen  = 40
+	maxTotalLevel = maxCardLevel * (sunCardsPerLoadout + moonCardsPerLoadout)
+	maxNoteLength = 40
+)
+
+// Violation is a single failed rule. Code is stable for clients, Field is the JSON path of the
+// field and Message is the default message, which Params can fill in a translated one.
+type Violation struct {
+	Code    string            `json:"code"`
+	Field   string            `json:"field"`
+	Message string            `json:"message"`
+	Params  map[string]string `json:"params,omitempty"`
+}
+
+// ValidationError holds every violation of a record.
+type ValidationError struct {
+	Violations []Violation `json:"violations"`
+}
+
+func (e *ValidationError) Error() string {
+	messages := make([]string, len(e.Violations))
+	for i, violation := range e.Violations {
+		messages[i] = violation.Message
+	}
+	return strings.Join(messages, "；")
+}
+
+func violationsError(violations []Violation) error {
+	if len(violations) == 0 {
+		return nil
+	}
+	return &ValidationError{Violations: violations}
+}
+
+// newViolation fills the {name} placeholders of the message from the params.
+func newViolation(code, field, message string, params map[string]string) Violation {
+	replacements := make([]string, 0, len(params)*2)
+	for name, value := range params {
+		replacements = append(replacements, "{"+name+"}", value)
+	}
+	return Violation{
+		Code:    code,
+		Field:   field,
+		Message: strings.NewReplacer(replacements...).Replace(message),
+		Params:  params,
+	}
+}
+
+// rule checks a record. The value of the field is always available to the message as {value}.
+type rule struct {
+	code    string
+	field   string
+	message string
+	check   func(r Record) bool
+	params  func(r Record) map[string]string
+	after   []string // 这些字段已有错误时跳过，同一个原因只报一次
+}
+
+// fieldRules check a single field each.
+var fieldRules = []rule{
+	{code: "invalid_level_type", field: "关卡", message: "无效的关卡类型: {value}", check: Record.validateLevelType},
+	{code: "attack_out_of_range", field: "攻击", message: "攻击数值错误: {value}", check: Record.validateAttack,
+		params: maxParam(maxPanelValue(StatAttack))},
+	{code: "defense_out_of_range", field: "防御", message: "防御值错误: {value}", check: Record.validateDefence,
+		params: maxParam(maxPanelValue(StatDefense))},
+	{code: "hp_out_of_range", field: "生命", message: "生命值错误: {value}", check: Record.validateHP,
+		params: maxParam(maxPanelValue(StatHP))},
+	{code: "invalid_matching", field: "对谱", message: "对谱类型错误: {value}", check: Record.validateMatching},
+	{code: "invalid_matching_buff", field: "对谱加成", message: "对谱加成错误: {value}", check: Record.validateMatchingBuff},
+	{code: "crit_rate_out_of_range", field: "暴击", message: "暴击率错误: {value}", check: Record.validateCritRate,
+		params: maxParam(maxCritRate)},
+	{code: "crit_dmg_out_of_range", field: "暴伤", message: "暴击伤害错误: {value}", check: Record.validateCritDmg,
+		params: maxParam(maxPanelValue(StatCritDmg))},
+	{code: "weaken_boost_out_of_range", field: "虚弱增伤", message: "虚弱增伤错误: {value}", check: Record.validateWeakenBoost,
+		params: maxParam(maxPanelValue(StatWeakenBoost))},
+	{code: "oath_boost_out_of_range", field: "誓约增伤", message: "誓约增伤错误: {value}", check: Record.validateOathBoost,
+		params: maxParam(maxPanelValue(StatOathBoost))},
+	{code: "oath_regen_out_of_range", field: "誓约回能", message: "誓约回能错误: {value}", check: Record.validateOathRegen,
+		params: maxParam(maxOathRegen)},
+	{code: "energy_regen_out_of_range", field: "加速回能", message: "加速回能错误: {value}", check: Record.validateEnergyRegen,
+		params: maxParam(maxRegen)},
+	{code: "invalid_stage", field: "阶数", message: "阶数错误: {value}", check: Record.validateStage},
+	{code: "invalid_weapon", field: "武器", message: "武器错误: {value}", check: Record.validateWeapon},
+	{code: "invalid_weapon_refinement", field: "武器精炼", message: "武器精炼等级错误, 请填写1-{max}: {value}",
+		check:  func(r Record) bool { _, ok := registry.ParseRefinement(r.WeaponRefinement); return ok },
+		params: maxParam(registry.MaxRefinement)},
+	{code: "total_level_out_of_range", field: "卡总等级", message: "卡面总等级错误, 请填写卡面等级总和。如不确定请填留空: {value}",
+		check: Record.validateTotalLevel, params: maxParam(maxTotalLevel)},
+	{code: "note_too_long", field: "备注", message: "备注最长30个字: {value}",
+		check: func(r Record) bool { return utf8.RuneCountInString(r.Note) <= maxNoteLength }},
+	{code: "note_sensitive", field: "备注", message: "备注中包含敏感词", check: Record.validateNoteWords, after: []string{"备注"}},
+}
+
+// crossFieldRules check fields against each other.
+var crossFieldRules = []rule{
+	{code: "regen_total_exceeded", field: "加速回能", message: "回能总和错误: {value} + {oath_regen}，面板总回能不能大于{max}",
+		check: Record.validateRegen, after: []string{"加速回能", "誓约回能"},
+		params: func(r Record) map[string]string {
+			return map[string]string{"oath_regen": r.OathRegen, "max": strconv.Itoa(maxRegen)}
+		}},
+	{code: "set_card_companion_mismatch", field: "日卡", message: "搭档身份与日卡不匹配: {companion} - {value}",
+		check: Record.validateCompanionSetCard, params: companionParam},
+	{code: "invalid_support_companion", field: "协助搭档", message: "协助搭档错误, 需为其他男主的搭档身份: {value}",
+		check: Record.validateSupportCompanion},
+	{code: "refinement_without_weapon", field: "武器精炼", message: "只填写武器类型时没有精炼等级, 请选择具体武器或留空: {value}",
+		after: []string{"武器", "武器精炼"}, check: Record.validateWeaponRefinement},
+	{code: "stage_without_set_card", field: "阶数", message: "无套装时阶数必须为无套装: {value}", after: []string{"阶数"},
+		check: func(r Record) bool { return r.SetCard != registry.NoSetCard || r.Stage == registry.NoSetCard }},
+	{code: "set_card_without_stage", field: "阶数", message: "有套装时阶数不能为无套装: {value}", after: []string{"阶数"},
+		check: func(r Record) bool { return r.SetCard == registry.NoSetCard || r.Stage != registry.NoSetCard }},
+}
+
+// companionRules depend on how the skills of the companion scale.
+var companionRules = []rule{
+	{code: "defense_required", field: "防御", message: "搭档 {companion} 的防御值不能为 0", after: []string{"防御"},
+		check:  func(r Record) bool { return r.validateScalingStat(registry.ScalingDefense, r.Defense) },
+		params: companionParam},
+	{code: "hp_required", field: "生命", message: "搭档 {companion} 的生命值不能为 0", after: []string{"生命"},
+		check:  func(r Record) bool { return r.validateScalingStat(registry.ScalingHP, r.HP) },
+		params: companionParam},
+}
+
+var orbitRules = []rule{
+	{code: "invalid_level_number", field: "关数", message: "关数错误: {level_type} - {level_mode} - {value}",
+		check: Record.validateLevelNumber, after: []string{"关卡"},
+		params: func(r Record) map[string]string {
+			return map[string]string{"level_type": r.LevelType, "level_mode": r.LevelMode}
+		}},
+	{code: "invalid_level_mode", field: "模式", message: "关卡模式错误: {value}", check: Record.validateLevelMode},
+	{code: "invalid_star_rank", field: "星级", message: "波动关卡通关星级错误: {value}", check: Record.validateStarRank},
+	{code: "partner_level_type_mismatch", field: "搭档身份", message: "搭档身份与关卡类型不匹配: {value} - {level_type}",
+		check: Record.validatePartnerAndLevelType, after: []string{"关卡"},
+		params: func(r Record) map[string]string { return map[string]string{"level_type": r.LevelType} }},
+}
+
+var championshipRules = []rule{
+	{code: "invalid_buff", field: "加成", message: "锦标赛加成错误: {value}", check: Record.validateBuff},
+}
+
+func maxParam(max float64) func(Record) map[string]string {
+	return func(Record) map[string]string {
+		return map[string]string{"max": strconv.FormatFloat(math.Round(max*10)/10, 'f', -1, 64)}
+	}
+}
+
+func companionParam(r Record) map[string]string {
+	return map[string]string{"companion": r.Companion}
+}
+
+// ValidateOrbit returns every violation of an orbit record as a *ValidationError.
+func (r Record) ValidateOrbit() (bool, error) {
+	violations := r.violations(fieldRules, orbitRules, crossFieldRules, companionRules)
+	return len(violations) == 0, violationsError(violations)
+}
+
+// ValidateChampionships returns every violation of a championships record as a *ValidationError.
+func (r Record) ValidateChampionships() (bool, error) {
+	violations := r.violations(fieldRules, championshipRules, crossFieldRules, companionRules)
+	return len(violations) == 0, violationsError(violations)
+}
+
+func (r Record) violations(ruleSets ...[]rule) []Violation {
+	var violations []Violation
+	failed := map[string]bool{}
+	for _, rules := range ruleSets {
+		for _, rule := range rules {
+			if skip(failed, rule.after) || rule.check(r) {
+				continue
+			}
+
+			params := map[string]string{}
+			if rule.params != nil {
+				params = rule.params(r)
+			}
+			if _, ok := params["value"]; !ok {
+				params["value"] = fieldValue(r, rule.field)
+			}
+			violations = append(violations, newViolation(rule.code, rule.field, rule.message, params))
+			failed[rule.field] = true
+		}
+	}
+
+	return append(violations, r.loadoutViolations(failed)...)
+}
+
+func skip(failed map[string]bool, fields []string) bool {
+	for _, field := range fields {
+		if failed[field] {
+			return true
+		}
+	}
+	return false
+}
+
+func (r Record) validateNoteWords() bool {
+	if r.Note == "" {
+		return true
+	}
+
+	detector, err := pkg.NewDetector()
+	return err == nil && !detector.ContainsSensitiveWords(r.Note)
+}
+
+// recordFields maps the JSON names of the string fields of a record to their index.
+var recordFields = func() map[string]int {
+	fields := map[string]int{}
+	t := reflect.TypeOf(Record{})
+	for i := 0; i < t.NumField(); i++ {
+		if t.Field(i).Type.Kind() == reflect.String {
+			fields[strings.Split(t.Field(i).Tag.Get("json"), ",")[0]] = i
+		}
+	}
+	return fields
+}()
+
+func fieldValue(r Record, field string) string {
+	if i, ok := recordFields[field]; ok {
+		return reflect.ValueOf(r).Field(i).String()
+	}
+	return ""
+}
@@ -0,0 +1,123 @@
+package models
+
+import (
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	"lysk-battle-record/internal/registry"
+)
+
+func TestValidationReportsEveryViolation(t *testing.T) {
+	record := Record{
+		LevelType: "开放", LevelNumber: "300_下", LevelMode: "稳定",
+		Attack: "99999", HP: "50000", Defense: "0", Matching: "顺", MatchingBuff: "30",
+		CritRate: "60", CritDmg: "200", EnergyRegen: "30", WeakenBoost: "50",
+		OathBoost: "40", OathRegen: "30", TotalLevel: "480",
+		Companion: "光猎", SetCard: "无套装", Stage: "IV", Weapon: "专武",
+	}
+
+	_, err := record.ValidateOrbit()
+	var validationErr *ValidationError
+	if !errors.As(err, &validationErr) {
+		t.Fatalf("expected a ValidationError, got %v", err)
+	}
+
+	got := map[string]string{}
+	for _, violation := range validationErr.Violations {
+		got[violation.Code] = violation.Field
+	}
+	want := map[string]string{
+		"attack_out_of_range":    "攻击",
+		"regen_total_exceeded":   "加速回能",
+		"stage_without_set_card": "阶数",
+		"defense_required":       "防御",
+	}
+	for code, field := range want {
+		if got[code] != field {
+			t.Errorf("missing violation %s on %s, got %v", code, field, validationErr.Violations)
+		}
+	}
+	if len(got) != len(want) {
+		t.Errorf("unexpected violations: %v", validationErr.Violations)
+	}
+}
+
+func TestValidateSupportCompanion(t *testing.T) {
+	record := Record{
+		LevelType: "开放", LevelNumber: "300_下", LevelMode: "稳定",
+		Attack: "11200", HP: "50000", Defense: "2000", Matching: "顺", MatchingBuff: "30",
+		CritRate: "60", CritDmg: "200", EnergyRegen: "10.8", WeakenBoost: "50",
+		OathBoost: "40", OathRegen: "17", TotalLevel: "480",
+		Companion: "潮汐之神", SetCard: "神殿", Stage: "IV", Weapon: "专武", Buff: "0",
+	}
+
+	record.SupportCompanion = "利莫里亚海神"
+	if _, err := record.ValidateOrbit(); err == nil || !strings.Contains(err.Error(), "协助搭档") {
+		t.Errorf("a companion of the same partner must not be accepted as support, got %v", err)
+	}
+	record.SupportCompanion = "逐光骑士"
+	if _, err := record.ValidateOrbit(); err != nil {
+		t.Errorf("a companion of another partner must be accepted as support, got %v", err)
+	}
+}
+
+func TestValidateWeaponRefinement(t *testing.T) {
+	record := Record{
+		LevelType: "开放", LevelNumber: "300_下", LevelMode: "稳定",
+		Attack: "11200", HP: "50000", Defense: "2000", Matching: "顺", MatchingBuff: "30",
+		CritRate: "60", CritDmg: "200", EnergyRegen: "10.8", WeakenBoost: "50",
+		OathBoost: "40", OathRegen: "17", TotalLevel: "480",
+		Companion: "潮汐之神", SetCard: "神殿", Stage: "IV", Buff: "0",
+	}
+
+	tests := []struct {
+		weapon, refinement string
+		valid              bool
+	}{
+		{registry.WeaponSignature, "", true},
+		{registry.WeaponSignature, "1", true},
+		{registry.WeaponSignature, "5", false},
+		{registry.WeaponPistol, "3", false},
+		{registry.SignatureWeaponID("潮汐之神"), "5", true},
+		{registry.WeaponPistol + "·夜鸣", "5", true},
+	}
+	for _, tt := range tests {
+		record.Weapon, record.WeaponRefinement = tt.weapon, tt.refinement
+		_, err := record.ValidateOrbit()
+		var validationErr *ValidationError
+		rejected := false
+		if errors.As(err, &validationErr) {
+			for _, violation := range validationErr.Violations {
+				rejected = rejected || violation.Code == "refinement_without_weapon"
+			}
+		}
+		if rejected == tt.valid {
+			t.Errorf("%s at refinement %q: valid %v, got %v", tt.weapon, tt.refinement, tt.valid, err)
+		}
+	}
+}
+
+func TestValidationUsesContentAtRecordTime(t *testing.T) {
+	previous := registry.ContentVersions()
+	defer registry.SetContent(previous)
+
+	update := previous[len(previous)-1]
+	update.Version = "test"
+	update.EffectiveFrom = time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC)
+	update.LevelTypes = append([]registry.LevelType{{Name: "光", MaxStable: 240, SplitEvery: 10, Partner: "沈星回"}}, update.LevelTypes...)
+	if err := registry.SetContent(append(previous, update)); err != nil {
+		t.Fatal(err)
+	}
+
+	record := Record{LevelType: "光", LevelNumber: "225", LevelMode: "稳定"}
+	record.Time = "2025-12-31T00:00:00Z"
+	if record.validateLevelNumber() {
+		t.Error("level 225 accepted before it was released")
+	}
+	record.Time = "2026-01-02T00:00:00Z"
+	if !record.validateLevelNumber() {
+		t.Error("level 225 rejected after it was released")
+	}
+}
@@ -0,0 +1,21 @@
+package pkg
+
+// AuthError is returned when a request cannot be authenticated. Code is stable and meant for
+// clients, Message is shown to users.
+type AuthError struct {
+	Code    string
+	Message string
+}
+
+func (e *AuthError) Error() string {
+	return e.Message
+}
+
+var (
+	ErrMissingToken   = &AuthError{Code: "missing_token", Message: "未登录"}
+	ErrMalformedToken = &AuthError{Code: "malformed_token", Message: "无效的认证信息"}
+	ErrInvalidToken   = &AuthError{Code: "invalid_token", Message: "无效的登录凭证"}
+	ErrTokenExpired   = &AuthError{Code: "token_expired", Message: "登录已过期"}
+	ErrTokenRevoked   = &AuthError{Code: "token_revoked", Message: "登录已失效"}
+	ErrUserBanned     = &AuthError{Code: "user_banned", Message: "账号已被封禁"}
+)
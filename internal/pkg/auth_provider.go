@@ -0,0 +1,338 @@
+package pkg
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"mime"
+	"net"
+	"net/http"
+	"net/smtp"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/sirupsen/logrus"
+	"golang.org/x/crypto/bcrypt"
+)
+
+const (
+	ProviderWeChat = "wechat"
+	ProviderEmail  = "email"
+	ProviderOIDC   = "oidc"
+
+	wechatAPI = "https://api.weixin.qq.com/sns/jscode2session"
+
+	magicLinkTTL = 15 * time.Minute
+)
+
+var ErrInvalidCredentials = errors.New("invalid credentials")
+
+// ExternalIdentity is the identity a LoginProvider vouches for.
+type ExternalIdentity struct {
+	Provider string
+	Subject  string
+	Email    string
+}
+
+// LoginProvider verifies the credentials of one login method.
+type LoginProvider interface {
+	Name() string
+	Authenticate(credentials map[string]string) (ExternalIdentity, error)
+}
+
+// WeChatProvider exchanges a mini-program code through jscode2session.
+type WeChatProvider struct {
+	appID    string
+	secret   string
+	endpoint string
+	client   *http.Client
+}
+
+func NewWeChatProvider(appID, secret string) *WeChatProvider {
+	return &WeChatProvider{
+		appID:    appID,
+		secret:   secret,
+		endpoint: wechatAPI,
+		client:   http.DefaultClient,
+	}
+}
+
+func (p *WeChatProvider) Name() string {
+	return ProviderWeChat
+}
+
+func (p *WeChatProvider) Authenticate(credentials map[string]string) (ExternalIdentity, error) {
+	query := url.Values{
+		"appid":      {p.appID},
+		"secret":     {p.secret},
+		"js_code":    {credentials["code"]},
+		"grant_type": {"authorization_code"},
+	}
+
+	resp, err := p.client.Get(p.endpoint + "?" + query.Encode())
+	if err != nil {
+		return ExternalIdentity{}, err
+	}
+	defer resp.Body.Close()
+
+	var session struct {
+		OpenID     string `json:"openid"`
+		SessionKey string `json:"session_key"`
+		ErrCode    int    `json:"errcode"`
+		ErrMsg     string `json:"errmsg"`
+	}
+
+	if err := json.NewDecoder(resp.Body).Decode(&session); err != nil {
+		return ExternalIdentity{}, err
+	}
+
+	if session.ErrCode != 0 {
+		return ExternalIdentity{}, fmt.Errorf("wechat login failed: %s", session.ErrMsg)
+	}
+
+	return ExternalIdentity{Provider: ProviderWeChat, Subject: session.OpenID}, nil
+}
+
+// Mailer delivers magic links.
+type Mailer interface {
+	SendMagicLink(email, link string) error
+}
+
+// LogMailer only logs the magic link, token included, and sends nothing. The configuration only
+// allows it in the development profile.
+type LogMailer struct{}
+
+func (m LogMailer) SendMagicLink(email, link string) error {
+	logrus.Infof("[Auth] Magic link for %s: %s", email, link)
+	return nil
+}
+
+// SMTPMailer mails magic links through an SMTP server.
+type SMTPMailer struct {
+	Addr     string // host:port
+	Username string // 为空时不认证
+	Password string
+	From     string
+}
+
+func (m SMTPMailer) SendMagicLink(email, link string) error {
+	host, _, err := net.SplitHostPort(m.Addr)
+	if err != nil {
+		return err
+	}
+	var auth smtp.Auth
+	if m.Username != "" {
+		auth = smtp.PlainAuth("", m.Username, m.Password, host)
+	}
+	return smtp.SendMail(m.Addr, auth, m.From, []string{email}, magicLinkMessage(m.From, email, link))
+}
+
+func magicLinkMessage(from, to, link string) []byte {
+	var b strings.Builder
+	fmt.Fprintf(&b, "From: %s\r\n", from)
+	fmt.Fprintf(&b, "To: %s\r\n", to)
+	fmt.Fprintf(&b, "Subject: %s\r\n", mime.BEncoding.Encode("UTF-8", "登录链接"))
+	b.WriteString("MIME-Version: 1.0\r\n")
+	b.WriteString("Content-Type: text/plain; charset=UTF-8\r\n")
+	b.WriteString("Content-Transfer-Encoding: 8bit\r\n\r\n")
+	fmt.Fprintf(&b, "点击以下链接登录，%d 分钟内有效，只能使用一次：\r\n\r\n%s\r\n", int(magicLinkTTL.Minutes()), link)
+	return []byte(b.String())
+}
+
+type magicLink struct {
+	email   string
+	expires time.Time
+}
+
+// EmailProvider logs users in with either an email and password, or an email and the token of
+// a magic link. Password hashes are looked up through passwordLookup.
+type EmailProvider struct {
+	passwordLookup func(email string) (string, bool)
+	mailer         Mailer
+	linkBaseURL    string
+
+	mu         sync.Mutex
+	magicLinks map[string]magicLink
+}
+
+func NewEmailProvider(passwordLookup func(email string) (string, bool), mailer Mailer, linkBaseURL string) *EmailProvider {
+	return &EmailProvider{
+		passwordLookup: passwordLookup,
+		mailer:         mailer,
+		linkBaseURL:    linkBaseURL,
+		magicLinks:     map[string]magicLink{},
+	}
+}
+
+func (p *EmailProvider) Name() string {
+	return ProviderEmail
+}
+
+func (p *EmailProvider) Authenticate(credentials map[string]string) (ExternalIdentity, error) {
+	email := NormalizeEmail(credentials["email"])
+	if email == "" {
+		return ExternalIdentity{}, ErrInvalidCredentials
+	}
+
+	if token := credentials["token"]; token != "" {
+		if !p.consumeMagicLink(email, token) {
+			return ExternalIdentity{}, ErrInvalidCredentials
+		}
+		return ExternalIdentity{Provider: ProviderEmail, Subject: email, Email: email}, nil
+	}
+
+	hash, ok := p.passwordLookup(email)
+	if !ok || hash == "" {
+		return ExternalIdentity{}, ErrInvalidCredentials
+	}
+	if err := bcrypt.CompareHashAndPassword([]byte(hash), []byte(credentials["password"])); err != nil {
+		return ExternalIdentity{}, ErrInvalidCredentials
+	}
+
+	return ExternalIdentity{Provider: ProviderEmail, Subject: email, Email: email}, nil
+}
+
+// SendMagicLink creates a single-use login token for the email and mails it.
+func (p *EmailProvider) SendMagicLink(email string) error {
+	email = NormalizeEmail(email)
+	if email == "" {
+		return ErrInvalidCredentials
+	}
+
+	token, err := randomToken()
+	if err != nil {
+		return err
+	}
+
+	p.mu.Lock()
+	now := time.Now()
+	for t, link := range p.magicLinks {
+		if now.After(link.expires) {
+			delete(p.magicLinks, t)
+		}
+	}
+	p.magicLinks[token] = magicLink{email: email, expires: now.Add(magicLinkTTL)}
+	p.mu.Unlock()
+
+	link := fmt.Sprintf("%s?email=%s&token=%s", p.linkBaseURL, url.QueryEscape(email), token)
+	return p.mailer.SendMagicLink(email, link)
+}
+
+func (p *EmailProvider) consumeMagicLink(email, token string) bool {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	link, ok := p.magicLinks[token]
+	if !ok {
+		return false
+	}
+	delete(p.magicLinks, token)
+
+	return link.email == email && time.Now().Before(link.expires)
+}
+
+// OIDCProvider implements the authorization code flow against a generic OpenID Connect
+// provider. The identity is read from the userinfo endpoint.
+type OIDCProvider struct {
+	clientID     string
+	clientSecret string
+	tokenURL     string
+	userInfoURL  string
+	client       *http.Client
+}
+
+func NewOIDCProvider(clientID, clientSecret, tokenURL, userInfoURL string) *OIDCProvider {
+	return &OIDCProvider{
+		clientID:     clientID,
+		clientSecret: clientSecret,
+		tokenURL:     tokenURL,
+		userInfoURL:  userInfoURL,
+		client:       http.DefaultClient,
+	}
+}
+
+func (p *OIDCProvider) Name() string {
+	return ProviderOIDC
+}
+
+func (p *OIDCProvider) Authenticate(credentials map[string]string) (ExternalIdentity, error) {
+	resp, err := p.client.PostForm(p.tokenURL, url.Values{
+		"grant_type":    {"authorization_code"},
+		"code":          {credentials["code"]},
+		"redirect_uri":  {credentials["redirect_uri"]},
+		"client_id":     {p.clientID},
+		"client_secret": {p.clientSecret},
+	})
+	if err != nil {
+		return ExternalIdentity{}, err
+	}
+	defer resp.Body.Close()
+
+	var token struct {
+		AccessToken string `json:"access_token"`
+		Error       string `json:"error"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&token); err != nil {
+		return ExternalIdentity{}, err
+	}
+	if resp.StatusCode != http.StatusOK || token.AccessToken == "" {
+		return ExternalIdentity{}, fmt.Errorf("oidc token exchange failed: %s", token.Error)
+	}
+
+	req, err := http.NewRequest(http.MethodGet, p.userInfoURL, nil)
+	if err != nil {
+		return ExternalIdentity{}, err
+	}
+	req.Header.Set("Authorization", "Bearer "+token.AccessToken)
+
+	userInfoResp, err := p.client.Do(req)
+	if err != nil {
+		return ExternalIdentity{}, err
+	}
+	defer userInfoResp.Body.Close()
+
+	var userInfo struct {
+		Subject string `json:"sub"`
+		Email   string `json:"email"`
+	}
+	if err := json.NewDecoder(userInfoResp.Body).Decode(&userInfo); err != nil {
+		return ExternalIdentity{}, err
+	}
+	if userInfoResp.StatusCode != http.StatusOK || userInfo.Subject == "" {
+		return ExternalIdentity{}, fmt.Errorf("oidc userinfo request failed with status %d", userInfoResp.StatusCode)
+	}
+
+	return ExternalIdentity{Provider: ProviderOIDC, Subject: userInfo.Subject, Email: userInfo.Email}, nil
+}
+
+func HashPassword(password string) (string, error) {
+	if len(password) < 8 {
+		return "", fmt.Errorf("密码至少8位")
+	}
+
+	hash, err := bcrypt.GenerateFromPassword([]byte(password), bcrypt.DefaultCost)
+	if err != nil {
+		return "", err
+	}
+	return string(hash), nil
+}
+
+func NormalizeEmail(email string) string {
+	email = strings.ToLower(strings.TrimSpace(email))
+	if !strings.Contains(email, "@") {
+		return ""
+	}
+	return email
+}
+
+func randomToken() (string, error) {
+	b := make([]byte, 32)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(b), nil
+}
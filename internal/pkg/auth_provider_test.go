@@ -0,0 +1,205 @@
+package pkg
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+type recordingMailer struct {
+	link string
+}
+
+func (m *recordingMailer) SendMagicLink(email, link string) error {
+	m.link = link
+	return nil
+}
+
+func TestWeChatProvider(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Query().Get("js_code") != "good" {
+			json.NewEncoder(w).Encode(map[string]interface{}{"errcode": 40029, "errmsg": "invalid code"})
+			return
+		}
+		json.NewEncoder(w).Encode(map[string]string{"openid": "openid-1"})
+	}))
+	defer srv.Close()
+
+	p := NewWeChatProvider("app", "secret")
+	p.endpoint = srv.URL
+
+	identity, err := p.Authenticate(map[string]string{"code": "good"})
+	if err != nil || identity.Subject != "openid-1" {
+		t.Fatalf("expected openid-1, got %+v, %v", identity, err)
+	}
+
+	if _, err := p.Authenticate(map[string]string{"code": "bad"}); err == nil {
+		t.Fatal("expected error for invalid code")
+	}
+}
+
+func TestOIDCProvider(t *testing.T) {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/token", func(w http.ResponseWriter, r *http.Request) {
+		r.ParseForm()
+		if r.Form.Get("code") != "good" {
+			w.WriteHeader(http.StatusBadRequest)
+			json.NewEncoder(w).Encode(map[string]string{"error": "invalid_grant"})
+			return
+		}
+		json.NewEncoder(w).Encode(map[string]string{"access_token": "at"})
+	})
+	mux.HandleFunc("/userinfo", func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Authorization") != "Bearer at" {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		json.NewEncoder(w).Encode(map[string]string{"sub": "sub-1", "email": "a@example.com"})
+	})
+	srv := httptest.NewServer(mux)
+	defer srv.Close()
+
+	p := NewOIDCProvider("client", "secret", srv.URL+"/token", srv.URL+"/userinfo")
+
+	identity, err := p.Authenticate(map[string]string{"code": "good"})
+	if err != nil || identity.Subject != "sub-1" || identity.Email != "a@example.com" {
+		t.Fatalf("unexpected identity %+v, %v", identity, err)
+	}
+
+	if _, err := p.Authenticate(map[string]string{"code": "bad"}); err == nil {
+		t.Fatal("expected error for invalid code")
+	}
+}
+
+func TestEmailProvider(t *testing.T) {
+	hash, err := HashPassword("password123")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	mailer := &recordingMailer{}
+	p := NewEmailProvider(func(email string) (string, bool) {
+		return hash, email == "a@example.com"
+	}, mailer, "")
+
+	if _, err := p.Authenticate(map[string]string{"email": " A@example.com", "password": "password123"}); err != nil {
+		t.Fatalf("expected password login to succeed: %v", err)
+	}
+	if _, err := p.Authenticate(map[string]string{"email": "a@example.com", "password": "wrong"}); err != ErrInvalidCredentials {
+		t.Fatalf("expected invalid credentials, got %v", err)
+	}
+
+	if err := p.SendMagicLink("b@example.com"); err != nil {
+		t.Fatal(err)
+	}
+	token := mailer.link[strings.LastIndex(mailer.link, "token=")+len("token="):]
+
+	if _, err := p.Authenticate(map[string]string{"email": "c@example.com", "token": token}); err == nil {
+		t.Fatal("expected magic link to be bound to its email")
+	}
+	if err := p.SendMagicLink("b@example.com"); err != nil {
+		t.Fatal(err)
+	}
+	token = mailer.link[strings.LastIndex(mailer.link, "token=")+len("token="):]
+
+	if _, err := p.Authenticate(map[string]string{"email": "b@example.com", "token": token}); err != nil {
+		t.Fatalf("expected magic link login to succeed: %v", err)
+	}
+	if _, err := p.Authenticate(map[string]string{"email": "b@example.com", "token": token}); err == nil {
+		t.Fatal("expected magic link to be single use")
+	}
+}
+
+func TestRefreshRotation(t *testing.T) {
+	a := NewAuthenticator(AuthOptions{})
+	a.keys = newSigningKeys("test-secret", "")
+
+	pair, err := a.IssueTokens("user-1")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := a.ValidateJWT(pair.RefreshToken); err == nil {
+		t.Fatal("refresh token must not be accepted as access token")
+	}
+
+	userID, rotated, err := a.Refresh(pair.RefreshToken)
+	if err != nil || userID != "user-1" {
+		t.Fatalf("unexpected refresh result %s, %v", userID, err)
+	}
+	if _, _, err := a.Refresh(pair.RefreshToken); err == nil {
+		t.Fatal("expected rotated refresh token to be revoked")
+	}
+
+	if err := a.Revoke(rotated.RefreshToken); err != nil {
+		t.Fatal(err)
+	}
+	if _, _, err := a.Refresh(rotated.RefreshToken); err == nil {
+		t.Fatal("expected revoked refresh token to be rejected")
+	}
+
+	if id, err := a.ValidateJWT(rotated.AccessToken); err != nil || id != "user-1" {
+		t.Fatalf("expected access token to stay valid, got %s, %v", id, err)
+	}
+}
+
+func TestKeyRotation(t *testing.T) {
+	a := NewAuthenticator(AuthOptions{})
+	a.keys = newSigningKeys("old-secret", "")
+
+	pair, err := a.IssueTokens("user-1")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	a.keys = newSigningKeys("new-secret", "old-secret")
+	if id, err := a.ValidateJWT(pair.AccessToken); err != nil || id != "user-1" {
+		t.Fatalf("expected token signed with previous key to stay valid, got %s, %v", id, err)
+	}
+
+	a.keys = newSigningKeys("new-secret", "")
+	if _, err := a.ValidateJWT(pair.AccessToken); err != ErrInvalidToken {
+		t.Fatalf("expected invalid token once the old key is dropped, got %v", err)
+	}
+}
+
+func TestRevocationAndBan(t *testing.T) {
+	a := NewAuthenticator(AuthOptions{})
+	a.keys = newSigningKeys("test-secret", "")
+
+	pair, err := a.IssueTokens("user-1")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := a.ValidateJWT("not-a-token"); err != ErrMalformedToken {
+		t.Fatalf("expected malformed token, got %v", err)
+	}
+
+	if err := a.Revoke(pair.AccessToken); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := a.ValidateJWT(pair.AccessToken); err != ErrTokenRevoked {
+		t.Fatalf("expected revoked token, got %v", err)
+	}
+
+	pair, _ = a.IssueTokens("user-1")
+	if err := a.Ban("user-1", "spam"); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := a.ValidateJWT(pair.AccessToken); err != ErrUserBanned {
+		t.Fatalf("expected banned user, got %v", err)
+	}
+	if _, _, err := a.Refresh(pair.RefreshToken); err != ErrUserBanned {
+		t.Fatalf("expected banned user on refresh, got %v", err)
+	}
+
+	if err := a.Unban("user-1"); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := a.ValidateJWT(pair.AccessToken); err != nil {
+		t.Fatalf("expected token to be valid after unban, got %v", err)
+	}
+}
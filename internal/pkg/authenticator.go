@@ -1,92 +1,310 @@
 package pkg
 
 import (
-	"encoding/json"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
 	"fmt"
-	"net/http"
-	"os"
+	"strings"
 	"time"
 
 	"github.com/golang-jwt/jwt/v5"
+	"github.com/google/uuid"
 )
 
-const wechatAPI = "https://api.weixin.qq.com/sns/jscode2session?appid=%s&secret=%s&js_code=%s&grant_type=authorization_code"
+const (
+	tokenTypeAccess  = "access"
+	tokenTypeRefresh = "refresh"
+
+	legacyTokenTTL  = 24 * 30 * time.Hour
+	accessTokenTTL  = 2 * time.Hour
+	refreshTokenTTL = 24 * 60 * time.Hour
+)
+
+var errUnknownKey = errors.New("unknown signing key")
 
 type Authenticator struct {
-	appID     string
-	secret    string
-	jwtSecret []byte
+	keys        []signingKey // keys[0] signs new tokens, the others only verify
+	adminIDs    map[string]bool
+	providers   map[string]LoginProvider
+	revocations RevocationList
 }
 
-func NewAuthenticator() *Authenticator {
-	return &Authenticator{
-		appID:     os.Getenv("WECHAT_APPID"),
-		secret:    os.Getenv("WECHAT_APPSECRET"),
-		jwtSecret: []byte(os.Getenv("JWT_SECRET")),
+type signingKey struct {
+	id     string
+	secret []byte
+}
+
+type TokenPair struct {
+	AccessToken  string `json:"token"`
+	RefreshToken string `json:"refresh_token"`
+	ExpiresIn    int64  `json:"expires_in"`
+}
+
+// AuthOptions are the secrets and login providers of the Authenticator.
+type AuthOptions struct {
+	JWTSecret          string
+	PreviousJWTSecrets []string // 轮换前的密钥，只用于校验
+	AdminUserIDs       []string
+	WeChatAppID        string
+	WeChatAppSecret    string
+	OIDCClientID       string
+	OIDCClientSecret   string
+	OIDCTokenURL       string // 为空时不注册 OIDC 登录
+	OIDCUserinfoURL    string
+}
+
+func NewAuthenticator(options AuthOptions) *Authenticator {
+	adminIDs := map[string]bool{}
+	for _, id := range options.AdminUserIDs {
+		if id = strings.TrimSpace(id); id != "" {
+			adminIDs[id] = true
+		}
 	}
+
+	a := &Authenticator{
+		keys:        newSigningKeys(options.JWTSecret, options.PreviousJWTSecrets...),
+		adminIDs:    adminIDs,
+		providers:   map[string]LoginProvider{},
+		revocations: NewMemoryRevocationList(),
+	}
+
+	a.RegisterProvider(NewWeChatProvider(options.WeChatAppID, options.WeChatAppSecret))
+	if options.OIDCTokenURL != "" {
+		a.RegisterProvider(NewOIDCProvider(
+			options.OIDCClientID,
+			options.OIDCClientSecret,
+			options.OIDCTokenURL,
+			options.OIDCUserinfoURL,
+		))
+	}
+
+	return a
+}
+
+// newSigningKeys builds the key ring from the current secret and the previous ones. When the
+// JWT secret is rotated, the old value stays in the previous secrets so tokens signed with it
+// stay valid until they expire.
+func newSigningKeys(current string, previous ...string) []signingKey {
+	keys := []signingKey{newSigningKey(current)}
+	for _, secret := range previous {
+		if secret = strings.TrimSpace(secret); secret != "" && secret != current {
+			keys = append(keys, newSigningKey(secret))
+		}
+	}
+	return keys
 }
 
-// Login exchanges a WeChat code for a user session and returns a JWT.
-func (a *Authenticator) Login(code string) (string, error) {
-	url := fmt.Sprintf(wechatAPI, a.appID, a.secret, code)
+// newSigningKey derives the kid from the secret, so it never has to be configured separately.
+func newSigningKey(secret string) signingKey {
+	hash := sha256.Sum256([]byte(secret))
+	return signingKey{id: hex.EncodeToString(hash[:4]), secret: []byte(secret)}
+}
 
-	resp, err := http.Get(url)
+// SetRevocationList replaces the default in-memory revocation list.
+func (a *Authenticator) SetRevocationList(revocations RevocationList) {
+	a.revocations = revocations
+}
+
+func (a *Authenticator) RegisterProvider(provider LoginProvider) {
+	a.providers[provider.Name()] = provider
+}
+
+func (a *Authenticator) Provider(name string) (LoginProvider, bool) {
+	provider, ok := a.providers[name]
+	return provider, ok
+}
+
+// IsAdmin reports whether the user is listed in ADMIN_USER_IDS.
+func (a *Authenticator) IsAdmin(userID string) bool {
+	return a.adminIDs[userID]
+}
+
+// IssueLegacyToken returns the long-lived access token of the WeChat /login endpoint.
+// It is kept for the mini-program, which does not refresh tokens.
+func (a *Authenticator) IssueLegacyToken(userID string) (string, error) {
+	return a.signToken(userID, tokenTypeAccess, legacyTokenTTL)
+}
+
+// IssueTokens returns a short-lived access token and a refresh token for the user.
+func (a *Authenticator) IssueTokens(userID string) (TokenPair, error) {
+	accessToken, err := a.signToken(userID, tokenTypeAccess, accessTokenTTL)
 	if err != nil {
-		return "", err
+		return TokenPair{}, err
+	}
+
+	refreshToken, err := a.signToken(userID, tokenTypeRefresh, refreshTokenTTL)
+	if err != nil {
+		return TokenPair{}, err
+	}
+
+	return TokenPair{
+		AccessToken:  accessToken,
+		RefreshToken: refreshToken,
+		ExpiresIn:    int64(accessTokenTTL.Seconds()),
+	}, nil
+}
+
+// Refresh rotates a refresh token: the given token is revoked and a new pair is issued.
+func (a *Authenticator) Refresh(refreshToken string) (string, TokenPair, error) {
+	claims, err := a.parseToken(refreshToken, tokenTypeRefresh)
+	if err != nil {
+		return "", TokenPair{}, err
+	}
+
+	if err := a.revokeClaims(claims); err != nil {
+		return "", TokenPair{}, err
 	}
-	defer resp.Body.Close()
 
-	var session struct {
-		OpenID     string `json:"openid"`
-		SessionKey string `json:"session_key"`
-		ErrCode    int    `json:"errcode"`
-		ErrMsg     string `json:"errmsg"`
+	userID, _ := claims["sub"].(string)
+	pair, err := a.IssueTokens(userID)
+	return userID, pair, err
+}
+
+// Revoke invalidates an access or refresh token before it expires.
+func (a *Authenticator) Revoke(tokenString string) error {
+	claims, err := a.parseToken(tokenString, "")
+	if err != nil {
+		return err
 	}
 
-	if err := json.NewDecoder(resp.Body).Decode(&session); err != nil {
+	return a.revokeClaims(claims)
+}
+
+// Ban rejects every token of the user, including the ones already issued.
+func (a *Authenticator) Ban(userID, reason string) error {
+	return a.revocations.BanUser(userID, reason)
+}
+
+func (a *Authenticator) Unban(userID string) error {
+	return a.revocations.UnbanUser(userID)
+}
+
+// RevokeUser rejects every token issued to the user so far, without blocking later logins.
+func (a *Authenticator) RevokeUser(userID string) error {
+	// 旧版登录的 token 有效期比刷新 token 短，刷新 token 过期时已签发的 token 都已失效
+	return a.revocations.RevokeUserTokens(userID, time.Now().Add(refreshTokenTTL))
+}
+
+// ValidateJWT validates a JWT and returns the userID (openid) from its claims.
+func (a *Authenticator) ValidateJWT(tokenString string) (string, error) {
+	claims, err := a.parseToken(tokenString, tokenTypeAccess)
+	if err != nil {
 		return "", err
 	}
 
-	if session.ErrCode != 0 {
-		return "", fmt.Errorf("wechat login failed: %s", session.ErrMsg)
+	return claims["sub"].(string), nil
+}
+
+func (a *Authenticator) revokeClaims(claims jwt.MapClaims) error {
+	jti, _ := claims["jti"].(string)
+	expiry, err := claims.GetExpirationTime()
+	if jti == "" || err != nil || expiry == nil {
+		// tokens issued before jti was introduced can only be revoked by banning the user
+		return ErrInvalidToken
 	}
 
+	return a.revocations.RevokeToken(jti, expiry.Time)
+}
+
+func (a *Authenticator) signToken(userID, tokenType string, ttl time.Duration) (string, error) {
 	// Create a new token object, specifying signing method and the claims
 	claims := jwt.MapClaims{
-		"sub": session.OpenID, // Subject (user identifier)
+		"sub": userID, // Subject (user identifier)
+		"jti": uuid.New().String(),
+		"typ": tokenType,
 		"iat": time.Now().Unix(),
-		"exp": time.Now().Add(time.Hour * 24 * 30).Unix(), // Token expires in 30 days
+		"exp": time.Now().Add(ttl).Unix(),
 	}
 
+	key := a.keys[0]
 	token := jwt.NewWithClaims(jwt.SigningMethodHS256, claims)
+	token.Header["kid"] = key.id
 
 	// Sign and get the complete encoded token as a string using the secret
-	tokenString, err := token.SignedString(a.jwtSecret)
+	return token.SignedString(key.secret)
+}
+
+// parseToken verifies the token and its type. An empty tokenType accepts both types.
+func (a *Authenticator) parseToken(tokenString, tokenType string) (jwt.MapClaims, error) {
+	claims, err := a.verifySignature(tokenString)
 	if err != nil {
-		return "", err
+		return nil, err
 	}
 
-	return tokenString, nil
-}
+	// tokens issued before typ was introduced are access tokens
+	typ, _ := claims["typ"].(string)
+	if typ == "" {
+		typ = tokenTypeAccess
+	}
+	if tokenType != "" && typ != tokenType {
+		return nil, ErrInvalidToken
+	}
 
-// ValidateJWT validates a JWT and returns the userID (openid) from its claims.
-func (a *Authenticator) ValidateJWT(tokenString string) (string, error) {
-	token, err := jwt.Parse(tokenString, func(token *jwt.Token) (interface{}, error) {
-		if _, ok := token.Method.(*jwt.SigningMethodHMAC); !ok {
-			return nil, fmt.Errorf("unexpected signing method: %v", token.Header["alg"])
-		}
-		return a.jwtSecret, nil
-	})
+	userID, _ := claims["sub"].(string)
+	if userID == "" {
+		return nil, ErrInvalidToken
+	}
 
-	if err != nil {
-		return "", err
+	jti, _ := claims["jti"].(string)
+	if typ == tokenTypeRefresh && jti == "" {
+		return nil, ErrInvalidToken
+	}
+	if jti != "" && a.revocations.IsTokenRevoked(jti) {
+		return nil, ErrTokenRevoked
 	}
+	if a.revocations.IsUserBanned(userID) {
+		return nil, ErrUserBanned
+	}
+	if revokedAt, ok := a.revocations.UserTokensRevokedAt(userID); ok && issuedBefore(claims, revokedAt) {
+		return nil, ErrTokenRevoked
+	}
+
+	return claims, nil
+}
+
+// issuedBefore reports whether the token was issued no later than t. iat only has a precision of
+// seconds, so a token issued within the same second counts as issued before.
+func issuedBefore(claims jwt.MapClaims, t time.Time) bool {
+	issuedAt, err := claims.GetIssuedAt()
+	if err != nil || issuedAt == nil {
+		return true
+	}
+	return issuedAt.Unix() <= t.Unix()
+}
+
+// verifySignature picks the key by the kid header. Tokens issued before key rotation have no
+// kid and are tried against every key.
+func (a *Authenticator) verifySignature(tokenString string) (jwt.MapClaims, error) {
+	var lastErr error = ErrInvalidToken
+	for _, key := range a.keys {
+		token, err := jwt.Parse(tokenString, func(token *jwt.Token) (interface{}, error) {
+			if _, ok := token.Method.(*jwt.SigningMethodHMAC); !ok {
+				return nil, fmt.Errorf("unexpected signing method: %v", token.Header["alg"])
+			}
+			if kid, ok := token.Header["kid"].(string); ok && kid != key.id {
+				return nil, errUnknownKey
+			}
+			return key.secret, nil
+		})
+
+		if err == nil {
+			if claims, ok := token.Claims.(jwt.MapClaims); ok && token.Valid {
+				return claims, nil
+			}
+			return nil, ErrInvalidToken
+		}
 
-	if claims, ok := token.Claims.(jwt.MapClaims); ok && token.Valid {
-		if userID, ok := claims["sub"].(string); ok {
-			return userID, nil
+		switch {
+		case errors.Is(err, jwt.ErrTokenMalformed):
+			return nil, ErrMalformedToken
+		case errors.Is(err, jwt.ErrTokenExpired):
+			return nil, ErrTokenExpired
+		case errors.Is(err, errUnknownKey):
+			continue
 		}
+		lastErr = ErrInvalidToken
 	}
 
-	return "", fmt.Errorf("invalid token")
+	return nil, lastErr
 }
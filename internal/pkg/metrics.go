@@ -0,0 +1,91 @@
+package pkg
+
+import (
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+)
+
+var (
+	httpRequestDuration = promauto.NewHistogramVec(prometheus.HistogramOpts{
+		Name:    "lysk_http_request_duration_seconds",
+		Help:    "Latency of HTTP requests by route, method and status.",
+		Buckets: prometheus.DefBuckets,
+	}, []string{"route", "method", "status"})
+
+	sheetCallDuration = promauto.NewHistogramVec(prometheus.HistogramOpts{
+		Name:    "lysk_sheet_call_duration_seconds",
+		Help:    "Latency of Google Sheets calls by sheet and operation.",
+		Buckets: []float64{.05, .1, .25, .5, 1, 2.5, 5, 10, 30},
+	}, []string{"sheet", "operation"})
+
+	sheetCallErrors = promauto.NewCounterVec(prometheus.CounterOpts{
+		Name: "lysk_sheet_call_errors_total",
+		Help: "Failed Google Sheets calls by sheet and operation.",
+	}, []string{"sheet", "operation"})
+
+	storeRefreshDuration = promauto.NewHistogramVec(prometheus.HistogramOpts{
+		Name:    "lysk_store_refresh_duration_seconds",
+		Help:    "Duration of store refreshes from the sheets, including failed ones.",
+		Buckets: []float64{.1, .25, .5, 1, 2.5, 5, 10, 30, 60},
+	}, []string{"store"})
+
+	storeRecords = promauto.NewGaugeVec(prometheus.GaugeOpts{
+		Name: "lysk_store_records",
+		Help: "Records held by each store, deleted ones included.",
+	}, []string{"store"})
+
+	ingestPoolSize = promauto.NewGaugeVec(prometheus.GaugeOpts{
+		Name: "lysk_ingest_pool_size",
+		Help: "Records being uploaded to each store's sheet.",
+	}, []string{"store"})
+
+	rateLimitRejections = promauto.NewCounterVec(prometheus.CounterOpts{
+		Name: "lysk_rate_limit_rejections_total",
+		Help: "Requests rejected by the rate limiter by route class and key kind.",
+	}, []string{"class", "key"})
+)
+
+// MetricsHandler serves the Prometheus metrics.
+func MetricsHandler() gin.HandlerFunc {
+	return gin.WrapH(promhttp.Handler())
+}
+
+// MetricsMiddleware records the latency and status of every request. Routes are the registered
+// patterns, so record IDs do not create new series.
+func MetricsMiddleware() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		start := time.Now()
+		c.Next()
+
+		route := c.FullPath()
+		if route == "" {
+			route = "unmatched"
+		}
+		httpRequestDuration.WithLabelValues(route, c.Request.Method, strconv.Itoa(c.Writer.Status())).
+			Observe(time.Since(start).Seconds())
+	}
+}
+
+// ObserveSheetCall records a Google Sheets call that started at start.
+func ObserveSheetCall(sheet, operation string, start time.Time, err error) {
+	sheetCallDuration.WithLabelValues(sheet, operation).Observe(time.Since(start).Seconds())
+	if err != nil {
+		sheetCallErrors.WithLabelValues(sheet, operation).Inc()
+	}
+}
+
+// ObserveRefresh records a refresh of the store that started at start.
+func ObserveRefresh(store string, start time.Time) {
+	storeRefreshDuration.WithLabelValues(store).Observe(time.Since(start).Seconds())
+}
+
+// SetStoreSize publishes the number of records of the store and of those being uploaded.
+func SetStoreSize(store string, records, ingesting int) {
+	storeRecords.WithLabelValues(store).Set(float64(records))
+	ingestPoolSize.WithLabelValues(store).Set(float64(ingesting))
+}
@@ -0,0 +1,37 @@
+package pkg
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+)
+
+func TestMetricsMiddleware(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	r := gin.New()
+	r.Use(MetricsMiddleware())
+	r.GET("/records/:id", func(c *gin.Context) { c.Status(http.StatusNotFound) })
+
+	for _, id := range []string{"a", "b"} {
+		r.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/records/"+id, nil))
+	}
+
+	// 按路由模式计数，不按记录 ID
+	if count := testutil.CollectAndCount(httpRequestDuration); count != 1 {
+		t.Errorf("expected one series, got %d", count)
+	}
+}
+
+func TestObserveSheetCall(t *testing.T) {
+	ObserveSheetCall("轨道", "append", time.Now(), nil)
+	ObserveSheetCall("轨道", "append", time.Now(), errors.New("quota exceeded"))
+
+	if errors := testutil.ToFloat64(sheetCallErrors.WithLabelValues("轨道", "append")); errors != 1 {
+		t.Errorf("expected 1 error, got %v", errors)
+	}
+}
@@ -2,12 +2,42 @@ package pkg
 
 import (
 	"context"
+	"fmt"
 	"net/http"
 	"time"
 
 	"github.com/gin-gonic/gin"
+
+	"lysk-battle-record/internal/i18n"
 )
 
+// trustedPlatforms are the platforms whose header carries the client IP.
+var trustedPlatforms = map[string]string{
+	"appengine":  gin.PlatformGoogleAppEngine,
+	"cloudflare": gin.PlatformCloudflare,
+	"flyio":      gin.PlatformFlyIO,
+}
+
+// IsTrustedPlatform reports whether TrustProxies knows the platform. The empty platform is none.
+func IsTrustedPlatform(platform string) bool {
+	_, ok := trustedPlatforms[platform]
+	return ok || platform == ""
+}
+
+// TrustProxies sets where c.ClientIP, and so the per-IP rate limits, read the client IP from: the
+// header of the platform, then X-Forwarded-For as far as it was appended by the proxies. With
+// neither, the IP of the connection is used, so a client cannot pick its IP with a header.
+func TrustProxies(r *gin.Engine, platform string, proxies []string) error {
+	if !IsTrustedPlatform(platform) {
+		return fmt.Errorf("unknown trusted platform %q", platform)
+	}
+	r.TrustedPlatform = trustedPlatforms[platform]
+	if len(proxies) == 0 {
+		proxies = nil
+	}
+	return r.SetTrustedProxies(proxies)
+}
+
 func TimeoutMiddleware(timeout time.Duration) gin.HandlerFunc {
 	return func(c *gin.Context) {
 		ctx, cancel := context.WithTimeout(c.Request.Context(), timeout)
@@ -26,8 +56,10 @@ func TimeoutMiddleware(timeout time.Duration) gin.HandlerFunc {
 			return
 		case <-ctx.Done():
 			c.Header("Connection", "close")
+			message, _ := i18n.Message(i18n.Negotiate(c.GetHeader("Accept-Language")), "request_timeout", nil)
 			c.AbortWithStatusJSON(http.StatusRequestTimeout, gin.H{
-				"error":   "Request timeout",
+				"error":   message,
+				"code":    "request_timeout",
 				"message": "Request exceeded timeout limit",
 			})
 			return
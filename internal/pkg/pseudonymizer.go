@@ -0,0 +1,31 @@
+package pkg
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+)
+
+// Pseudonymizer derives stable public IDs from WeChat OpenIDs so that public responses never
+// expose the OpenID itself.
+type Pseudonymizer struct {
+	secret []byte
+}
+
+func NewPseudonymizer(secret string) *Pseudonymizer {
+	return &Pseudonymizer{
+		secret: []byte(secret),
+	}
+}
+
+// PublicID returns the pseudonymous ID of a user. The same user always gets the same ID as
+// long as the secret does not change.
+func (p *Pseudonymizer) PublicID(userID string) string {
+	if userID == "" {
+		return ""
+	}
+
+	mac := hmac.New(sha256.New, p.secret)
+	mac.Write([]byte(userID))
+	return hex.EncodeToString(mac.Sum(nil))[:16]
+}
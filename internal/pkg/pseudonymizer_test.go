@@ -0,0 +1,28 @@
+package pkg
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestPublicID(t *testing.T) {
+	p := NewPseudonymizer("secret")
+
+	id := p.PublicID("o-user-1")
+	if len(id) != 16 || strings.Contains(id, "o-user-1") {
+		t.Fatalf("unexpected public ID %q", id)
+	}
+	if again := NewPseudonymizer("secret").PublicID("o-user-1"); again != id {
+		t.Errorf("public ID is not stable: %q vs %q", again, id)
+	}
+	if other := p.PublicID("o-user-2"); other == id {
+		t.Error("two users share a public ID")
+	}
+	// 换密钥之后公开 ID 全部改变
+	if rotated := NewPseudonymizer("rotated").PublicID("o-user-1"); rotated == id {
+		t.Error("public ID does not depend on the secret")
+	}
+	if empty := p.PublicID(""); empty != "" {
+		t.Errorf("a record without uploader got public ID %q", empty)
+	}
+}
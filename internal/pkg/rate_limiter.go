@@ -0,0 +1,200 @@
+package pkg
+
+import (
+	"fmt"
+	"math"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/sirupsen/logrus"
+
+	"lysk-battle-record/internal/i18n"
+)
+
+// RouteClass groups routes that share a rate limit.
+type RouteClass string
+
+const (
+	RouteRead    RouteClass = "read"
+	RouteAnalyze RouteClass = "analyze"
+	RouteWrite   RouteClass = "write"
+)
+
+// ipMultiplier is how many users' worth of requests one IP gets, since users behind the same
+// NAT share an IP.
+const ipMultiplier = 4
+
+// RateLimit is a token bucket: Burst requests at once, refilled at Rate per second.
+type RateLimit struct {
+	Burst int
+	Rate  float64
+}
+
+// ParseRateLimit reads a limit written as requests/period, such as 10/1m.
+func ParseRateLimit(value string) (RateLimit, error) {
+	count, period, ok := strings.Cut(value, "/")
+	burst, err := strconv.Atoi(strings.TrimSpace(count))
+	if !ok || err != nil || burst <= 0 {
+		return RateLimit{}, fmt.Errorf("invalid rate limit %q, expected requests/period", value)
+	}
+	duration, err := time.ParseDuration(strings.TrimSpace(period))
+	if err != nil || duration <= 0 {
+		return RateLimit{}, fmt.Errorf("invalid rate limit %q, expected requests/period", value)
+	}
+	return RateLimit{Burst: burst, Rate: float64(burst) / duration.Seconds()}, nil
+}
+
+// UnmarshalText reads a limit written as requests/period, so it can be configured as text.
+func (l *RateLimit) UnmarshalText(text []byte) error {
+	limit, err := ParseRateLimit(string(text))
+	if err != nil {
+		return err
+	}
+	*l = limit
+	return nil
+}
+
+func (l RateLimit) scaled(factor int) RateLimit {
+	return RateLimit{Burst: l.Burst * factor, Rate: l.Rate * float64(factor)}
+}
+
+// RateLimitStore holds the token buckets. The in-memory store limits each instance on its own;
+// a shared backend such as Redis makes the limits hold across instances.
+type RateLimitStore interface {
+	// Take removes a token from the bucket of the key. When the bucket is empty it returns false
+	// and how long until the next token.
+	Take(key string, limit RateLimit, now time.Time) (bool, time.Duration)
+}
+
+type tokenBucket struct {
+	tokens  float64
+	updated time.Time
+	limit   RateLimit
+}
+
+func (b *tokenBucket) refill(now time.Time) {
+	b.tokens = math.Min(float64(b.limit.Burst), b.tokens+now.Sub(b.updated).Seconds()*b.limit.Rate)
+	b.updated = now
+}
+
+// MemoryRateLimitStore is a RateLimitStore that does not survive restarts.
+type MemoryRateLimitStore struct {
+	mu        sync.Mutex
+	buckets   map[string]*tokenBucket
+	lastSweep time.Time
+}
+
+func NewMemoryRateLimitStore() *MemoryRateLimitStore {
+	return &MemoryRateLimitStore{buckets: map[string]*tokenBucket{}}
+}
+
+func (s *MemoryRateLimitStore) Take(key string, limit RateLimit, now time.Time) (bool, time.Duration) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	// full buckets are the same as missing ones, drop them now and then
+	if now.Sub(s.lastSweep) > time.Minute {
+		for k, bucket := range s.buckets {
+			if bucket.refill(now); bucket.tokens >= float64(bucket.limit.Burst) {
+				delete(s.buckets, k)
+			}
+		}
+		s.lastSweep = now
+	}
+
+	bucket, ok := s.buckets[key]
+	if !ok {
+		bucket = &tokenBucket{tokens: float64(limit.Burst), updated: now}
+		s.buckets[key] = bucket
+	}
+	bucket.limit = limit
+	bucket.refill(now)
+
+	if bucket.tokens >= 1 {
+		bucket.tokens--
+		return true, 0
+	}
+	return false, time.Duration((1 - bucket.tokens) / limit.Rate * float64(time.Second))
+}
+
+// RateLimiter limits requests per signed in user and per IP, with a limit for each route class.
+type RateLimiter struct {
+	store  RateLimitStore
+	limits map[RouteClass]RateLimit
+}
+
+// NewRateLimiter limits each route class with its limit. A class without a limit is not limited.
+func NewRateLimiter(limits map[RouteClass]RateLimit) *RateLimiter {
+	return &RateLimiter{store: NewMemoryRateLimitStore(), limits: limits}
+}
+
+// SetStore replaces the default in-memory store.
+func (l *RateLimiter) SetStore(store RateLimitStore) {
+	l.store = store
+}
+
+// Allow takes a token from the user's bucket, if signed in, and from the IP's bucket. It returns
+// how long to wait when either is empty. A nil RateLimiter allows everything.
+func (l *RateLimiter) Allow(class RouteClass, userID, ip string) (bool, time.Duration) {
+	if l == nil {
+		return true, 0
+	}
+
+	limit, ok := l.limits[class]
+	if !ok {
+		return true, 0
+	}
+
+	now := time.Now()
+	if userID != "" {
+		if allowed, retryAfter := l.store.Take(string(class)+":user:"+userID, limit, now); !allowed {
+			l.reject(class, "user", userID, retryAfter)
+			return false, retryAfter
+		}
+	}
+	if allowed, retryAfter := l.store.Take(string(class)+":ip:"+ip, limit.scaled(ipMultiplier), now); !allowed {
+		l.reject(class, "ip", ip, retryAfter)
+		return false, retryAfter
+	}
+	return true, 0
+}
+
+func (l *RateLimiter) reject(class RouteClass, kind, key string, retryAfter time.Duration) {
+	rateLimitRejections.WithLabelValues(string(class), kind).Inc()
+	logrus.Warnf("[RateLimit] Rejected %s request of %s %s, retry after %v", class, kind, key, retryAfter)
+}
+
+// RetryAfter is the Retry-After header value of the wait, in whole seconds.
+func RetryAfter(wait time.Duration) string {
+	return strconv.Itoa(int(math.Ceil(wait.Seconds())))
+}
+
+// Middleware limits the routes of the class. The user is the userID set by an earlier
+// authentication middleware, if any. The IP is only as good as the trusted proxies of the engine.
+func (l *RateLimiter) Middleware(class RouteClass) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if allowed, retryAfter := l.Allow(class, c.GetString("userID"), c.ClientIP()); !allowed {
+			c.Header("Retry-After", RetryAfter(retryAfter))
+			message, _ := i18n.Message(i18n.Negotiate(c.GetHeader("Accept-Language")), "rate_limited", nil)
+			c.AbortWithStatusJSON(http.StatusTooManyRequests, gin.H{"error": message, "code": "rate_limited"})
+			return
+		}
+		c.Next()
+	}
+}
+
+// ByMethod is Middleware with reads for GET and writes for every other method.
+func (l *RateLimiter) ByMethod() gin.HandlerFunc {
+	read, write := l.Middleware(RouteRead), l.Middleware(RouteWrite)
+	return func(c *gin.Context) {
+		if c.Request.Method == http.MethodGet || c.Request.Method == http.MethodHead {
+			read(c)
+			return
+		}
+		write(c)
+	}
+}
@@ -0,0 +1,111 @@
+package pkg
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+)
+
+func TestMemoryRateLimitStore(t *testing.T) {
+	store := NewMemoryRateLimitStore()
+	limit := RateLimit{Burst: 2, Rate: 1}
+	now := time.Now()
+
+	for i := 0; i < 2; i++ {
+		if allowed, _ := store.Take("k", limit, now); !allowed {
+			t.Fatalf("request %d rejected within the burst", i)
+		}
+	}
+	allowed, retryAfter := store.Take("k", limit, now)
+	if allowed || retryAfter != time.Second {
+		t.Fatalf("expected a rejection with 1s to wait, got %v, %v", allowed, retryAfter)
+	}
+	if allowed, _ := store.Take("k", limit, now.Add(time.Second)); !allowed {
+		t.Fatal("token not refilled")
+	}
+	if allowed, _ := store.Take("other", limit, now); !allowed {
+		t.Fatal("keys share a bucket")
+	}
+}
+
+func TestParseRateLimit(t *testing.T) {
+	limit, err := ParseRateLimit("30/1m")
+	if err != nil || limit.Burst != 30 || limit.Rate != 0.5 {
+		t.Errorf("unexpected limit %+v, %v", limit, err)
+	}
+	for _, value := range []string{"", "30", "0/1m", "30/never"} {
+		if _, err := ParseRateLimit(value); err == nil {
+			t.Errorf("%q accepted", value)
+		}
+	}
+}
+
+func TestRateLimiterMiddleware(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	limiter := &RateLimiter{
+		store:  NewMemoryRateLimitStore(),
+		limits: map[RouteClass]RateLimit{RouteAnalyze: {Burst: 1, Rate: 0.1}},
+	}
+
+	r := gin.New()
+	r.POST("/analyze", func(c *gin.Context) { c.Set("userID", c.GetHeader("X-User")) }, limiter.Middleware(RouteAnalyze), func(c *gin.Context) {
+		c.Status(http.StatusOK)
+	})
+	post := func(user string) *httptest.ResponseRecorder {
+		w := httptest.NewRecorder()
+		req := httptest.NewRequest(http.MethodPost, "/analyze", nil)
+		req.Header.Set("X-User", user)
+		r.ServeHTTP(w, req)
+		return w
+	}
+
+	if w := post("a"); w.Code != http.StatusOK {
+		t.Fatalf("first request rejected: %d", w.Code)
+	}
+	w := post("a")
+	if w.Code != http.StatusTooManyRequests || w.Header().Get("Retry-After") != "10" {
+		t.Fatalf("expected 429 with Retry-After 10, got %d %q", w.Code, w.Header().Get("Retry-After"))
+	}
+	// 同一 IP 的其他用户还有余量
+	if w := post("b"); w.Code != http.StatusOK {
+		t.Fatalf("other user rejected: %d", w.Code)
+	}
+	if testutil.ToFloat64(rateLimitRejections.WithLabelValues("analyze", "user")) == 0 {
+		t.Error("rejection not counted")
+	}
+}
+
+func TestTrustProxies(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	clientIP := func(platform string, proxies []string, header, value string) string {
+		r := gin.New()
+		if err := TrustProxies(r, platform, proxies); err != nil {
+			t.Fatal(err)
+		}
+		var ip string
+		r.GET("/", func(c *gin.Context) { ip = c.ClientIP() })
+		req := httptest.NewRequest(http.MethodGet, "/", nil)
+		req.RemoteAddr = "10.0.0.1:1234"
+		req.Header.Set(header, value)
+		r.ServeHTTP(httptest.NewRecorder(), req)
+		return ip
+	}
+
+	// 不信任代理时伪造的 X-Forwarded-For 不会换到另一个桶
+	if ip := clientIP("", nil, "X-Forwarded-For", "1.2.3.4"); ip != "10.0.0.1" {
+		t.Errorf("forwarded header trusted without proxies: %s", ip)
+	}
+	if ip := clientIP("", []string{"10.0.0.0/8"}, "X-Forwarded-For", "1.2.3.4, 5.6.7.8"); ip != "5.6.7.8" {
+		t.Errorf("expected the address appended by the proxy, got %s", ip)
+	}
+	if ip := clientIP("appengine", nil, "X-Appengine-Remote-Addr", "1.2.3.4"); ip != "1.2.3.4" {
+		t.Errorf("platform header not used: %s", ip)
+	}
+	if err := TrustProxies(gin.New(), "heroku", nil); err == nil {
+		t.Error("unknown platform accepted")
+	}
+}
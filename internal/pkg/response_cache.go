@@ -0,0 +1,113 @@
+package pkg
+
+import (
+	"bytes"
+	"fmt"
+	"hash/fnv"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// maxCachedResponses bounds the cache, since queries such as level suggestions have many keys.
+const maxCachedResponses = 2000
+
+type cachedResponse struct {
+	body        []byte
+	contentType string
+	etag        string
+}
+
+// ResponseCache keeps successful responses of aggregate endpoints until the records change.
+// Clients revalidate with If-None-Match and get 304 without the handler running.
+type ResponseCache struct {
+	mu         sync.RWMutex
+	responses  map[string]cachedResponse // 方法 路径?规范化查询 -> 响应
+	generation uint64                    // 每次失效加一，防止写入失效前算出的响应
+	maxAge     time.Duration
+}
+
+// NewResponseCache keeps responses until Invalidate. Clients may reuse a response for maxAge
+// without revalidating.
+func NewResponseCache(maxAge time.Duration) *ResponseCache {
+	return &ResponseCache{responses: map[string]cachedResponse{}, maxAge: maxAge}
+}
+
+// Invalidate drops every response. Record stores call it on every change.
+func (rc *ResponseCache) Invalidate() {
+	rc.mu.Lock()
+	defer rc.mu.Unlock()
+	rc.responses = map[string]cachedResponse{}
+	rc.generation++
+}
+
+func (rc *ResponseCache) get(key string) (cachedResponse, uint64, bool) {
+	rc.mu.RLock()
+	defer rc.mu.RUnlock()
+	response, ok := rc.responses[key]
+	return response, rc.generation, ok
+}
+
+func (rc *ResponseCache) put(key string, generation uint64, response cachedResponse) {
+	rc.mu.Lock()
+	defer rc.mu.Unlock()
+	if generation != rc.generation || len(rc.responses) >= maxCachedResponses {
+		return
+	}
+	rc.responses[key] = response
+}
+
+// bufferedWriter holds the body back until the ETag is known.
+type bufferedWriter struct {
+	gin.ResponseWriter
+	body bytes.Buffer
+}
+
+func (w *bufferedWriter) Write(data []byte) (int, error) {
+	return w.body.Write(data)
+}
+
+func (w *bufferedWriter) WriteString(s string) (int, error) {
+	return w.body.WriteString(s)
+}
+
+// Middleware serves the route from the cache, keyed by path and query. Only 200 responses are
+// kept.
+func (rc *ResponseCache) Middleware() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		key := c.Request.Method + " " + c.Request.URL.Path + "?" + c.Request.URL.Query().Encode()
+
+		response, generation, ok := rc.get(key)
+		if !ok {
+			writer := &bufferedWriter{ResponseWriter: c.Writer}
+			c.Writer = writer
+			c.Next()
+			c.Writer = writer.ResponseWriter
+
+			if c.Writer.Status() != http.StatusOK {
+				c.Writer.Write(writer.body.Bytes())
+				return
+			}
+			hash := fnv.New64a()
+			hash.Write(writer.body.Bytes())
+			response = cachedResponse{
+				body:        writer.body.Bytes(),
+				contentType: c.Writer.Header().Get("Content-Type"),
+				etag:        fmt.Sprintf(`"%x"`, hash.Sum64()),
+			}
+			rc.put(key, generation, response)
+		}
+
+		c.Header("Cache-Control", "public, max-age="+strconv.Itoa(int(rc.maxAge.Seconds())))
+		c.Header("ETag", response.etag)
+		if c.GetHeader("If-None-Match") == response.etag {
+			c.AbortWithStatus(http.StatusNotModified)
+			return
+		}
+		c.Data(http.StatusOK, response.contentType, response.body)
+		c.Abort()
+	}
+}
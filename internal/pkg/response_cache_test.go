@@ -0,0 +1,62 @@
+package pkg
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+func TestResponseCache(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	cache := &ResponseCache{responses: map[string]cachedResponse{}, maxAge: time.Minute}
+
+	calls := 0
+	r := gin.New()
+	r.GET("/news", cache.Middleware(), func(c *gin.Context) {
+		calls++
+		if c.Query("fail") != "" {
+			c.JSON(http.StatusInternalServerError, gin.H{"calls": calls})
+			return
+		}
+		c.JSON(http.StatusOK, gin.H{"calls": calls})
+	})
+	get := func(target, etag string) *httptest.ResponseRecorder {
+		w := httptest.NewRecorder()
+		req := httptest.NewRequest(http.MethodGet, target, nil)
+		if etag != "" {
+			req.Header.Set("If-None-Match", etag)
+		}
+		r.ServeHTTP(w, req)
+		return w
+	}
+
+	first := get("/news", "")
+	etag := first.Header().Get("ETag")
+	if first.Code != http.StatusOK || etag == "" || first.Header().Get("Cache-Control") != "public, max-age=60" {
+		t.Fatalf("unexpected first response: %d %v", first.Code, first.Header())
+	}
+	if w := get("/news", ""); w.Body.String() != first.Body.String() || calls != 1 {
+		t.Errorf("hit recomputed: %s, %d calls", w.Body.String(), calls)
+	}
+	if w := get("/news", etag); w.Code != http.StatusNotModified || w.Body.Len() != 0 {
+		t.Errorf("expected 304, got %d %q", w.Code, w.Body.String())
+	}
+
+	// 记录变化后重新计算，旧 ETag 不再匹配
+	cache.Invalidate()
+	if w := get("/news", etag); w.Code != http.StatusOK || w.Header().Get("ETag") == etag || calls != 2 {
+		t.Errorf("not recomputed after invalidation: %d %q, %d calls", w.Code, w.Header().Get("ETag"), calls)
+	}
+
+	for i := 0; i < 2; i++ {
+		if w := get("/news?fail=1", ""); w.Code != http.StatusInternalServerError || w.Header().Get("ETag") != "" {
+			t.Errorf("unexpected error response: %d %v", w.Code, w.Header())
+		}
+	}
+	if calls != 4 {
+		t.Errorf("error response cached, %d calls", calls)
+	}
+}
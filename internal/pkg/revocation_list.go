@@ -0,0 +1,92 @@
+package pkg
+
+import (
+	"sync"
+	"time"
+)
+
+// RevocationList keeps track of revoked tokens and banned users. The Authenticator checks it on
+// every request, so revocations take effect immediately.
+type RevocationList interface {
+	IsTokenRevoked(jti string) bool
+	IsUserBanned(userID string) bool
+	RevokeToken(jti string, expiresAt time.Time) error
+	BanUser(userID, reason string) error
+	UnbanUser(userID string) error
+	// RevokeUserTokens rejects every token of the user issued so far. Tokens issued later are
+	// accepted. The revocation can be forgotten after expiresAt, when those tokens have expired.
+	RevokeUserTokens(userID string, expiresAt time.Time) error
+	UserTokensRevokedAt(userID string) (time.Time, bool)
+}
+
+// MemoryRevocationList is a RevocationList that does not survive restarts.
+type MemoryRevocationList struct {
+	mu         sync.RWMutex
+	tokens     map[string]time.Time // jti -> expiry
+	users      map[string]bool
+	userTokens map[string]time.Time // userID -> revoked at
+}
+
+func NewMemoryRevocationList() *MemoryRevocationList {
+	return &MemoryRevocationList{
+		tokens:     map[string]time.Time{},
+		users:      map[string]bool{},
+		userTokens: map[string]time.Time{},
+	}
+}
+
+func (l *MemoryRevocationList) IsTokenRevoked(jti string) bool {
+	l.mu.RLock()
+	defer l.mu.RUnlock()
+	_, ok := l.tokens[jti]
+	return ok
+}
+
+func (l *MemoryRevocationList) IsUserBanned(userID string) bool {
+	l.mu.RLock()
+	defer l.mu.RUnlock()
+	return l.users[userID]
+}
+
+func (l *MemoryRevocationList) RevokeToken(jti string, expiresAt time.Time) error {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	// expired tokens are rejected anyway, no need to remember them
+	now := time.Now()
+	for id, exp := range l.tokens {
+		if now.After(exp) {
+			delete(l.tokens, id)
+		}
+	}
+	l.tokens[jti] = expiresAt
+	return nil
+}
+
+func (l *MemoryRevocationList) BanUser(userID, reason string) error {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.users[userID] = true
+	return nil
+}
+
+func (l *MemoryRevocationList) UnbanUser(userID string) error {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	delete(l.users, userID)
+	return nil
+}
+
+func (l *MemoryRevocationList) RevokeUserTokens(userID string, expiresAt time.Time) error {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.userTokens[userID] = time.Now()
+	return nil
+}
+
+func (l *MemoryRevocationList) UserTokensRevokedAt(userID string) (time.Time, bool) {
+	l.mu.RLock()
+	defer l.mu.RUnlock()
+	revokedAt, ok := l.userTokens[userID]
+	return revokedAt, ok
+}
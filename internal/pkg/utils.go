@@ -1,11 +1,20 @@
 package pkg
 
-import "fmt"
+import (
+	"fmt"
+	"strconv"
+)
 
+// GetValue returns the field as a string. JSON numbers are written out in full, so 1000000
+// does not become 1e+06.
 func GetValue(input map[string]interface{}, field string) string {
-	if val, ok := input[field]; ok {
-		return fmt.Sprintf("%v", val)
+	val, ok := input[field]
+	if !ok {
+		return ""
 	}
 
-	return ""
+	if number, ok := val.(float64); ok {
+		return strconv.FormatFloat(number, 'f', -1, 64)
+	}
+	return fmt.Sprintf("%v", val)
 }
@@ -0,0 +1,144 @@
+package registry
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"sort"
+	"sync/atomic"
+	"time"
+)
+
+const (
+	ModeStable      = "稳定"
+	ModeFluctuating = "波动"
+)
+
+// LevelType is a level type as of a content version.
+type LevelType struct {
+	Name             string `json:"name"`
+	MaxStable        int    `json:"max_stable"`                  // 稳定模式最大关数
+	MaxFluctuating   int    `json:"max_fluctuating,omitempty"`   // 波动模式最大关数，0 为没有波动模式
+	SplitEvery       int    `json:"split_every"`                 // 每隔多少关分上下
+	FluctuatingSplit int    `json:"fluctuating_split,omitempty"` // 波动模式下额外每隔多少关分上下
+	Partner          string `json:"partner,omitempty"`           // 只有该男主可以挑战
+	Championship     bool   `json:"championship,omitempty"`      // 锦标赛关卡，没有关数
+}
+
+// ContentVersion is the game content in force from EffectiveFrom until the next version.
+type ContentVersion struct {
+	Version       string      `json:"version"`
+	EffectiveFrom time.Time   `json:"effective_from"`
+	LevelTypes    []LevelType `json:"level_types"`
+	StarRanks     []string    `json:"star_ranks"` // 波动关卡的通关星级
+}
+
+var defaultContent = []ContentVersion{
+	{
+		Version: "base",
+		LevelTypes: []LevelType{
+			{Name: "光", MaxStable: 210, SplitEvery: 10, Partner: "沈星回"},
+			{Name: "火", MaxStable: 210, SplitEvery: 10, Partner: "祁煜"},
+			{Name: "冰", MaxStable: 210, SplitEvery: 10, Partner: "黎深"},
+			{Name: "能量", MaxStable: 180, SplitEvery: 10, Partner: "秦彻"},
+			{Name: "引力", MaxStable: 150, SplitEvery: 10, Partner: "夏以昼"},
+			{Name: "开放", MaxStable: 300, MaxFluctuating: 60, SplitEvery: 10, FluctuatingSplit: 5},
+			{Name: "A4", Championship: true},
+			{Name: "B4", Championship: true},
+			{Name: "C4", Championship: true},
+		},
+		StarRanks: []string{"零星", "一星", "二星", "三星"},
+	},
+}
+
+var activeContent atomic.Pointer[[]ContentVersion]
+
+func init() {
+	activeContent.Store(&defaultContent)
+}
+
+// ContentVersions returns every content version, oldest first.
+func ContentVersions() []ContentVersion {
+	return append([]ContentVersion(nil), *activeContent.Load()...)
+}
+
+// ContentAt returns the content version in force at the time. The oldest version applies to
+// anything before it.
+func ContentAt(t time.Time) ContentVersion {
+	versions := *activeContent.Load()
+	current := versions[0]
+	for _, version := range versions[1:] {
+		if version.EffectiveFrom.After(t) {
+			break
+		}
+		current = version
+	}
+	return current
+}
+
+func (v ContentVersion) LevelType(name string) (LevelType, bool) {
+	for _, levelType := range v.LevelTypes {
+		if levelType.Name == name {
+			return levelType, true
+		}
+	}
+	return LevelType{}, false
+}
+
+func (v ContentVersion) ValidStarRank(starRank string) bool {
+	for _, s := range v.StarRanks {
+		if s == starRank {
+			return true
+		}
+	}
+	return false
+}
+
+// LoadContent replaces the catalogue with the versions in the file, so new levels only need a
+// new file and a reload instead of a deploy.
+func LoadContent(path string) error {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return err
+	}
+
+	var versions []ContentVersion
+	if err := json.Unmarshal(data, &versions); err != nil {
+		return err
+	}
+	return SetContent(versions)
+}
+
+// SetContent replaces the catalogue after checking it.
+func SetContent(versions []ContentVersion) error {
+	if err := validateContent(versions); err != nil {
+		return err
+	}
+
+	versions = append([]ContentVersion(nil), versions...)
+	sort.SliceStable(versions, func(i, j int) bool {
+		return versions[i].EffectiveFrom.Before(versions[j].EffectiveFrom)
+	})
+	activeContent.Store(&versions)
+	return nil
+}
+
+func validateContent(versions []ContentVersion) error {
+	if len(versions) == 0 {
+		return fmt.Errorf("content has no versions")
+	}
+
+	for _, version := range versions {
+		for _, levelType := range version.LevelTypes {
+			if levelType.Partner != "" {
+				if _, ok := partnerIndex[levelType.Partner]; !ok {
+					return fmt.Errorf("version %s: level type %s has unknown partner %s", version.Version, levelType.Name, levelType.Partner)
+				}
+			}
+			if !levelType.Championship && (levelType.MaxStable <= 0 || levelType.SplitEvery <= 0) {
+				return fmt.Errorf("version %s: level type %s needs max_stable and split_every", version.Version, levelType.Name)
+			}
+		}
+	}
+	return nil
+}
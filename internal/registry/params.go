@@ -0,0 +1,156 @@
+package registry
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math"
+	"os"
+	"sort"
+	"sync/atomic"
+)
+
+// Tunable estimator constants. The calibration command fits them against real clears and writes
+// the result as a parameter file overriding them globally or per companion. The server loads a
+// reviewed file at startup when server.estimator_params_file is set.
+const (
+	ParamWeakenRateForward  = "weaken_rate_forward"  // 顺对谱时的虚弱时间占比
+	ParamWeakenRateReverse  = "weaken_rate_reverse"  // 逆对谱时的虚弱时间占比
+	ParamWeakenBonusForward = "weaken_bonus_forward" // 顺对谱时的虚弱额外增伤%
+	ParamWeakenBonusReverse = "weaken_bonus_reverse" // 逆对谱时的虚弱额外增伤%
+	ParamDefaultTotalLevel  = "default_total_level"  // 未填写卡总等级时的默认值
+	ParamRainUpgrade        = "rain_upgrade"         // 潮汐之神下雨期间海灵升级的倍率
+
+	// 协助参数按协助搭档取值，而不是主搭档
+	ParamSupportHitRate = "support_hit_rate" // 协助技能只有次数的协助搭档每次协助的技能倍率%
+	// 协助搭档给主搭档所有技能的增伤%。没有游戏数据支撑，校准报告为 ok 之前保持 0
+	ParamSupportDamageBoost = "support_damage_boost"
+)
+
+var defaultParams = map[string]float64{
+	ParamWeakenRateForward:  0.50,
+	ParamWeakenRateReverse:  0.25,
+	ParamWeakenBonusForward: 250,
+	ParamWeakenBonusReverse: 150,
+	ParamDefaultTotalLevel:  480,
+	ParamRainUpgrade:        1.25,
+	ParamSupportHitRate:     400,
+	ParamSupportDamageBoost: 0,
+}
+
+// ParamFile overrides the defaults. Companion values win over global ones.
+type ParamFile struct {
+	Global     map[string]float64            `json:"global,omitempty"`
+	Companions map[string]map[string]float64 `json:"companions,omitempty"` // 搭档 -> 参数
+}
+
+var activeParams atomic.Pointer[ParamFile]
+
+func init() {
+	activeParams.Store(&ParamFile{})
+}
+
+// Param returns the value of the parameter for the companion, which may be empty.
+func Param(companion, name string) float64 {
+	params := activeParams.Load()
+	if value, ok := params.Companions[CanonicalCompanionName(companion)][name]; ok {
+		return value
+	}
+	if value, ok := params.Global[name]; ok {
+		return value
+	}
+	return defaultParams[name]
+}
+
+// SetParams replaces the active overrides. The server sets the file of
+// server.estimator_params_file at startup. The overrides change the scores of stored records as
+// well, so a candidate file is compared against the current scores before it is deployed.
+func SetParams(params ParamFile) {
+	activeParams.Store(&params)
+}
+
+func ActiveParams() ParamFile {
+	return *activeParams.Load()
+}
+
+func DefaultParam(name string) float64 {
+	return defaultParams[name]
+}
+
+func ParamNames() []string {
+	names := make([]string, 0, len(defaultParams))
+	for name := range defaultParams {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// LoadParams reads a parameter file and checks it.
+func LoadParams(path string) (ParamFile, error) {
+	var params ParamFile
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return params, err
+	}
+
+	decoder := json.NewDecoder(bytes.NewReader(data))
+	decoder.DisallowUnknownFields()
+	if err := decoder.Decode(&params); err != nil {
+		return params, fmt.Errorf("%s: %w", path, err)
+	}
+	if err := validateParams(params); err != nil {
+		return params, fmt.Errorf("%s: %w", path, err)
+	}
+	return params, nil
+}
+
+// validateParams reports unknown parameters and companions, and values that are negative or not
+// numbers. Companions are listed by their current name, which is what Param looks up.
+func validateParams(params ParamFile) error {
+	var errs []error
+	checkValues := func(scope string, values map[string]float64) {
+		for _, name := range sortedKeys(values) {
+			value := values[name]
+			if _, ok := defaultParams[name]; !ok {
+				errs = append(errs, fmt.Errorf("%s: unknown parameter %s", scope, name))
+			} else if math.IsNaN(value) || math.IsInf(value, 0) || value < 0 {
+				errs = append(errs, fmt.Errorf("%s: invalid value %v for %s", scope, value, name))
+			}
+		}
+	}
+
+	checkValues("global", params.Global)
+	companionNames := make([]string, 0, len(params.Companions))
+	for name := range params.Companions {
+		companionNames = append(companionNames, name)
+	}
+	sort.Strings(companionNames)
+	for _, name := range companionNames {
+		if companion, ok := GetCompanion(name); !ok {
+			errs = append(errs, fmt.Errorf("unknown companion %s", name))
+		} else if companion.Name != name {
+			errs = append(errs, fmt.Errorf("companion %s is listed under its old name, use %s", name, companion.Name))
+		}
+		checkValues(name, params.Companions[name])
+	}
+	return errors.Join(errs...)
+}
+
+func sortedKeys(values map[string]float64) []string {
+	keys := make([]string, 0, len(values))
+	for key := range values {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+func WriteParams(path string, params ParamFile) error {
+	data, err := json.MarshalIndent(params, "", "  ")
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(path, append(data, '\n'), 0o644)
+}
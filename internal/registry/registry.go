@@ -0,0 +1,240 @@
+// Package registry is the single source of truth for partners, companions and their signature
+// set cards. It only holds data, so every other package can import it.
+package registry
+
+import (
+	"fmt"
+	"strings"
+)
+
+const (
+	ScalingAttack  = "攻击"
+	ScalingDefense = "防御"
+	ScalingHP      = "生命"
+
+	NoSetCard = "无套装"
+)
+
+type Partner struct {
+	Name      string
+	LevelType string   // 该男主专属的关卡类型
+	SetCards  []string // 可搭配的日卡套装
+}
+
+type Companion struct {
+	Name            string
+	Aliases         []string // 历史上使用过的名称
+	Partner         string
+	SetCard         string // 专属日卡套装，没有则为空
+	ScalingStat     string // 技能倍率所基于的属性
+	SignatureWeapon bool   // 是否有专武
+}
+
+var partners = []Partner{
+	{
+		Name:      "沈星回",
+		LevelType: "光",
+		SetCards:  []string{"夜誓", "末夜", "逐光", "鎏光", "睱日", "弦光", "心晴", "匿光", NoSetCard},
+	},
+	{
+		Name:      "黎深",
+		LevelType: "冰",
+		SetCards:  []string{"神谕", "拥雪", "永恒", "终序", "夜色", "静谧", "心晴", "深林", NoSetCard},
+	},
+	{
+		Name:      "祁煜",
+		LevelType: "火",
+		SetCards:  []string{"雾海", "神殿", "深海", "坠浪", "点染", "斑斓", "心晴", "碧海", NoSetCard},
+	},
+	{
+		Name:      "秦彻",
+		LevelType: "能量",
+		SetCards:  []string{"猩红", "深渊", "掠心", "纯白", "锋尖", "戮夜", NoSetCard},
+	},
+	{
+		Name:      "夏以昼",
+		LevelType: "引力",
+		SetCards:  []string{"寂路", "远空", "长昼", "离途", NoSetCard},
+	},
+}
+
+var companions = []Companion{
+	{Name: "暗蚀国王", Partner: "沈星回", SetCard: "夜誓", ScalingStat: ScalingHP, SignatureWeapon: true},
+	{Name: "光猎", Partner: "沈星回", SetCard: "末夜", ScalingStat: ScalingDefense, SignatureWeapon: true},
+	{Name: "逐光骑士", Partner: "沈星回", SetCard: "逐光", ScalingStat: ScalingAttack, SignatureWeapon: true},
+	{Name: "遥远少年", Partner: "沈星回", ScalingStat: ScalingAttack},
+	{Name: "Evol特警", Partner: "沈星回", ScalingStat: ScalingAttack},
+	{Name: "深空猎人", Partner: "沈星回", ScalingStat: ScalingAttack},
+
+	{Name: "终末之神", Partner: "黎深", SetCard: "神谕", ScalingStat: ScalingHP, SignatureWeapon: true},
+	{Name: "九黎司命", Partner: "黎深", SetCard: "拥雪", ScalingStat: ScalingAttack, SignatureWeapon: true},
+	{Name: "永恒先知", Partner: "黎深", SetCard: "永恒", ScalingStat: ScalingDefense, SignatureWeapon: true},
+	{Name: "极地军医", Partner: "黎深", ScalingStat: ScalingAttack},
+	{Name: "黎明抹杀者", Partner: "黎深", ScalingStat: ScalingAttack},
+	{Name: "临空医生", Partner: "黎深", ScalingStat: ScalingAttack},
+
+	{Name: "利莫里亚海神", Partner: "祁煜", SetCard: "雾海", ScalingStat: ScalingDefense, SignatureWeapon: true},
+	{Name: "潮汐之神", Partner: "祁煜", SetCard: "神殿", ScalingStat: ScalingHP, SignatureWeapon: true},
+	{Name: "深海潜行者", Partner: "祁煜", SetCard: "深海", ScalingStat: ScalingAttack, SignatureWeapon: true},
+	{Name: "画坛新锐", Aliases: []string{"花坛新锐"}, Partner: "祁煜", ScalingStat: ScalingAttack},
+	{Name: "海妖魅影", Partner: "祁煜", ScalingStat: ScalingAttack},
+	{Name: "艺术家", Partner: "祁煜", ScalingStat: ScalingAttack},
+
+	{Name: "银翼恶魔", Partner: "秦彻", SetCard: "猩红", ScalingStat: ScalingDefense, SignatureWeapon: true},
+	{Name: "深渊主宰", Partner: "秦彻", SetCard: "深渊", ScalingStat: ScalingHP, SignatureWeapon: true},
+	{Name: "无尽掠夺者", Partner: "秦彻", SetCard: "掠心", ScalingStat: ScalingAttack, SignatureWeapon: true},
+	{Name: "异界来客", Partner: "秦彻", ScalingStat: ScalingAttack},
+
+	{Name: "终极兵器X-02", Partner: "夏以昼", SetCard: "寂路", ScalingStat: ScalingAttack, SignatureWeapon: true},
+	{Name: "远空执舰官", Partner: "夏以昼", SetCard: "远空", ScalingStat: ScalingDefense, SignatureWeapon: true},
+	{Name: "深空飞行员", Partner: "夏以昼", ScalingStat: ScalingAttack},
+}
+
+var (
+	partnerIndex   = map[string]Partner{}
+	companionIndex = map[string]Companion{} // 名称和别名 -> 搭档
+)
+
+func init() {
+	for _, partner := range partners {
+		partnerIndex[partner.Name] = partner
+	}
+	for _, companion := range companions {
+		companionIndex[companion.Name] = companion
+		for _, alias := range companion.Aliases {
+			companionIndex[alias] = companion
+		}
+	}
+}
+
+func Partners() []Partner {
+	return append([]Partner(nil), partners...)
+}
+
+func Companions() []Companion {
+	return append([]Companion(nil), companions...)
+}
+
+func GetPartner(name string) (Partner, bool) {
+	partner, ok := partnerIndex[name]
+	return partner, ok
+}
+
+// GetCompanion looks a companion up by its name or one of its aliases.
+func GetCompanion(name string) (Companion, bool) {
+	companion, ok := companionIndex[name]
+	return companion, ok
+}
+
+// CanonicalCompanionName returns the current name of the companion, or the name unchanged if
+// it is unknown.
+func CanonicalCompanionName(name string) string {
+	if companion, ok := companionIndex[name]; ok {
+		return companion.Name
+	}
+	return name
+}
+
+func CompanionsOf(partner string) []Companion {
+	var result []Companion
+	for _, companion := range companions {
+		if companion.Partner == partner {
+			result = append(result, companion)
+		}
+	}
+	return result
+}
+
+// PartnerForLevelType returns the partner a level type is restricted to.
+func PartnerForLevelType(levelType string) (Partner, bool) {
+	for _, partner := range partners {
+		if partner.LevelType == levelType {
+			return partner, true
+		}
+	}
+	return Partner{}, false
+}
+
+// AllowsSetCard reports whether the companion can be used with the set card.
+func (c Companion) AllowsSetCard(setCard string) bool {
+	partner, ok := partnerIndex[c.Partner]
+	if !ok {
+		return false
+	}
+	for _, s := range partner.SetCards {
+		if s == setCard {
+			return true
+		}
+	}
+	return false
+}
+
+// Validate checks the registry for duplicate names, unknown partners, set cards that the
+// partner cannot use and weapons of unknown types or companions.
+func Validate() error {
+	var problems []string
+
+	levelTypes := map[string]string{}
+	for _, partner := range partners {
+		if other, ok := levelTypes[partner.LevelType]; ok {
+			problems = append(problems, fmt.Sprintf("level type %s is used by both %s and %s", partner.LevelType, other, partner.Name))
+		}
+		levelTypes[partner.LevelType] = partner.Name
+	}
+
+	names := map[string]bool{}
+	for _, companion := range companions {
+		for _, name := range append([]string{companion.Name}, companion.Aliases...) {
+			if names[name] {
+				problems = append(problems, fmt.Sprintf("companion name %s is registered twice", name))
+			}
+			names[name] = true
+		}
+
+		if _, ok := partnerIndex[companion.Partner]; !ok {
+			problems = append(problems, fmt.Sprintf("companion %s has unknown partner %s", companion.Name, companion.Partner))
+		}
+
+		switch companion.ScalingStat {
+		case ScalingAttack, ScalingDefense, ScalingHP:
+		default:
+			problems = append(problems, fmt.Sprintf("companion %s has unknown scaling stat %q", companion.Name, companion.ScalingStat))
+		}
+
+		if companion.SetCard != "" && !companion.AllowsSetCard(companion.SetCard) {
+			problems = append(problems, fmt.Sprintf("set card %s of companion %s is not available to %s", companion.SetCard, companion.Name, companion.Partner))
+		}
+	}
+
+	if err := validateContent(defaultContent); err != nil {
+		problems = append(problems, err.Error())
+	}
+	for _, levelType := range defaultContent[len(defaultContent)-1].LevelTypes {
+		if partner, ok := PartnerForLevelType(levelType.Name); ok && partner.Name != levelType.Partner {
+			problems = append(problems, fmt.Sprintf("level type %s belongs to %s but the game content requires %q", levelType.Name, partner.Name, levelType.Partner))
+		}
+	}
+
+	weaponIDs := map[string]bool{}
+	for _, weapon := range weapons {
+		if weaponIDs[weapon.ID] {
+			problems = append(problems, fmt.Sprintf("weapon %s is registered twice", weapon.ID))
+		}
+		weaponIDs[weapon.ID] = true
+
+		if _, ok := weaponIndex[weapon.Type]; !ok || weaponIndex[weapon.Type].Type != weapon.Type {
+			problems = append(problems, fmt.Sprintf("weapon %s has unknown type %s", weapon.ID, weapon.Type))
+		}
+		if companion, ok := companionIndex[weapon.Companion]; weapon.Companion != "" && (!ok || companion.Name != weapon.Companion) {
+			problems = append(problems, fmt.Sprintf("weapon %s belongs to unknown companion %s", weapon.ID, weapon.Companion))
+		}
+		if weapon.ID != weapon.Type && len(weapon.Effects) == 0 {
+			problems = append(problems, fmt.Sprintf("weapon %s has no refinement effects", weapon.ID))
+		}
+	}
+
+	if len(problems) > 0 {
+		return fmt.Errorf("registry is inconsistent:\n  - %s", strings.Join(problems, "\n  - "))
+	}
+	return nil
+}
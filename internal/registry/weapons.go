@@ -0,0 +1,151 @@
+package registry
+
+import "strconv"
+
+const (
+	WeaponSignature  = "专武"
+	WeaponGreatsword = "重剑"
+	WeaponSword      = "单手剑"
+	WeaponStaff      = "法杖"
+	WeaponPistol     = "手枪"
+
+	MinRefinement = 1
+	MaxRefinement = 5
+)
+
+// WeaponEffect is a passive of a weapon on the skill with the name, or on every skill for 所有.
+type WeaponEffect struct {
+	Skill       string
+	CritRate    float64
+	CritDmg     float64
+	DamageBoost float64
+	WeakenBoost float64
+}
+
+type Weapon struct {
+	ID        string
+	Name      string
+	Type      string         // 武器类型，决定普攻和主动的倍率
+	Companion string         // 专武所属的搭档身份，其他武器为空
+	Base      []WeaponEffect // 1级精炼的效果，专武的已包含在搭档技能中
+	Effects   []WeaponEffect // 精炼每提升一级增加的效果
+}
+
+// The weapon types are weapons of their own, so records that only name the type stay valid.
+// They name no weapon, so they have no refinement either.
+var weapons = []Weapon{
+	{ID: WeaponSignature, Name: WeaponSignature, Type: WeaponSignature},
+	{ID: WeaponGreatsword, Name: WeaponGreatsword, Type: WeaponGreatsword},
+	{ID: WeaponSword, Name: WeaponSword, Type: WeaponSword},
+	{ID: WeaponStaff, Name: WeaponStaff, Type: WeaponStaff},
+	{ID: WeaponPistol, Name: WeaponPistol, Type: WeaponPistol},
+
+	namedWeapon(WeaponGreatsword, "裂空",
+		[]WeaponEffect{{Skill: "重剑主动", DamageBoost: 12}},
+		[]WeaponEffect{{Skill: "重剑主动", DamageBoost: 3}}),
+	namedWeapon(WeaponGreatsword, "沉岳",
+		[]WeaponEffect{{Skill: "普攻", DamageBoost: 10}, {Skill: "所有", WeakenBoost: 4}},
+		[]WeaponEffect{{Skill: "普攻", DamageBoost: 2.5}, {Skill: "所有", WeakenBoost: 1}}),
+	namedWeapon(WeaponSword, "曦刃",
+		[]WeaponEffect{{Skill: "单手剑主动", CritRate: 6}},
+		[]WeaponEffect{{Skill: "单手剑主动", CritRate: 1.5}}),
+	namedWeapon(WeaponSword, "流风",
+		[]WeaponEffect{{Skill: "普攻", DamageBoost: 10}},
+		[]WeaponEffect{{Skill: "普攻", DamageBoost: 2.5}}),
+	namedWeapon(WeaponStaff, "星轨",
+		[]WeaponEffect{{Skill: "法杖主动", DamageBoost: 12}},
+		[]WeaponEffect{{Skill: "法杖主动", DamageBoost: 3}}),
+	namedWeapon(WeaponStaff, "霜语",
+		[]WeaponEffect{{Skill: "所有", WeakenBoost: 8}},
+		[]WeaponEffect{{Skill: "所有", WeakenBoost: 2}}),
+	namedWeapon(WeaponPistol, "夜鸣",
+		[]WeaponEffect{{Skill: "手枪主动", DamageBoost: 12}},
+		[]WeaponEffect{{Skill: "手枪主动", DamageBoost: 3}}),
+	namedWeapon(WeaponPistol, "追迹",
+		[]WeaponEffect{{Skill: "普攻", CritDmg: 12}},
+		[]WeaponEffect{{Skill: "普攻", CritDmg: 3}}),
+}
+
+// signatureRefinements is what refining the signature weapon of each companion adds per level.
+// It strengthens the skill the weapon passive is about, so the names are those of the
+// companion's flow.
+var signatureRefinements = map[string][]WeaponEffect{
+	"暗蚀国王":     {{Skill: "主动-加冕", DamageBoost: 4}},
+	"光猎":       {{Skill: "月光", DamageBoost: 5}},
+	"逐光骑士":     {{Skill: "溯光共鸣", DamageBoost: 5}},
+	"终末之神":     {{Skill: "重击-飞升", DamageBoost: 4}},
+	"九黎司命":     {{Skill: "断玉诀", CritDmg: 6}},
+	"永恒先知":     {{Skill: "恒之罪", DamageBoost: 5}},
+	"利莫里亚海神":   {{Skill: "雷潮", DamageBoost: 5}},
+	"潮汐之神":     {{Skill: "海灵", DamageBoost: 4}},
+	"深海潜行者":    {{Skill: "强力斩击", CritRate: 2}},
+	"银翼恶魔":     {{Skill: "血蔷薇子弹", DamageBoost: 5}},
+	"深渊主宰":     {{Skill: "魔魇之爪范围", DamageBoost: 5}},
+	"无尽掠夺者":    {{Skill: "掠噬标记", DamageBoost: 5}},
+	"终极兵器X-02": {{Skill: "誓约-同频攻击", DamageBoost: 4}},
+	"远空执舰官":    {{Skill: "纵深打击", DamageBoost: 5}},
+}
+
+var weaponIndex = map[string]Weapon{}
+
+func init() {
+	for _, companion := range companions {
+		if companion.SignatureWeapon {
+			weapons = append(weapons, Weapon{
+				ID:        SignatureWeaponID(companion.Name),
+				Name:      companion.Name + "专武",
+				Type:      WeaponSignature,
+				Companion: companion.Name,
+				Effects:   signatureRefinements[companion.Name],
+			})
+		}
+	}
+	for _, weapon := range weapons {
+		weaponIndex[weapon.ID] = weapon
+	}
+}
+
+func namedWeapon(weaponType, name string, base, effects []WeaponEffect) Weapon {
+	return Weapon{ID: weaponType + "·" + name, Name: name, Type: weaponType, Base: base, Effects: effects}
+}
+
+// MaxRefinement is the highest refinement level of the weapon. A weapon type names no weapon,
+// so it only has the first level.
+func (w Weapon) MaxRefinement() int {
+	if len(w.Effects) == 0 {
+		return MinRefinement
+	}
+	return MaxRefinement
+}
+
+// SignatureWeaponID is the ID of the signature weapon of the companion.
+func SignatureWeaponID(companion string) string {
+	return WeaponSignature + "·" + companion
+}
+
+func Weapons() []Weapon {
+	return append([]Weapon(nil), weapons...)
+}
+
+// GetWeapon looks a weapon up by its ID. The plain weapon types are IDs as well.
+func GetWeapon(id string) (Weapon, bool) {
+	weapon, ok := weaponIndex[id]
+	return weapon, ok
+}
+
+// WeaponType returns the type of the weapon, or the ID unchanged if it is unknown.
+func WeaponType(id string) string {
+	if weapon, ok := weaponIndex[id]; ok {
+		return weapon.Type
+	}
+	return id
+}
+
+// ParseRefinement reads a refinement level, where empty means the first level.
+func ParseRefinement(value string) (int, bool) {
+	if value == "" {
+		return MinRefinement, true
+	}
+	refinement, err := strconv.Atoi(value)
+	return refinement, err == nil && refinement >= MinRefinement && refinement <= MaxRefinement
+}
@@ -6,8 +6,22 @@ import (
 
 type Server interface {
 	Ping(c *gin.Context)
+	Healthz(c *gin.Context)
+	Readyz(c *gin.Context)
 	Login(c *gin.Context)
 	AuthMiddleware() gin.HandlerFunc
+	RequireAuth() gin.HandlerFunc
+
+	// Authentication
+	ProviderLogin(c *gin.Context)
+	RegisterEmail(c *gin.Context)
+	SendMagicLink(c *gin.Context)
+	RefreshToken(c *gin.Context)
+	Logout(c *gin.Context)
+	LinkIdentity(c *gin.Context)
+	GetIdentities(c *gin.Context)
+	BanUser(c *gin.Context)
+	UnbanUser(c *gin.Context)
 
 	// Orbit Records
 	ProcessOrbitRecord(c *gin.Context)
@@ -35,8 +49,16 @@ type Server interface {
 	CreateUser(c *gin.Context)
 	GetUser(c *gin.Context)
 	UpdateUser(c *gin.Context)
+	UpdatePrivacySettings(c *gin.Context)
+
+	// Account Deletion
+	RequestAccountDeletion(c *gin.Context)
+	GetAccountDeletion(c *gin.Context)
+	GetDeletionRequests(c *gin.Context)
 
+	// Leaderboards
 	GetRanking(c *gin.Context)
+	GetLeaderboard(c *gin.Context)
 
 	// LevelType Suggestion
 	GetLevelSuggestion(c *gin.Context)
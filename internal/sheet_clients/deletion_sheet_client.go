@@ -0,0 +1,163 @@
+package sheet_clients
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+
+	"github.com/sirupsen/logrus"
+	"google.golang.org/api/sheets/v4"
+
+	"lysk-battle-record/internal/models"
+)
+
+type DeletionSheetClient interface {
+	FetchAllSheetData(ctx context.Context) ([]models.DeletionRequest, error)
+	ProcessRequest(ctx context.Context, request models.DeletionRequest) (*models.DeletionRequest, error)
+	UpdateRequest(ctx context.Context, request models.DeletionRequest) error
+	GetType() string
+}
+
+type DeletionSheetClientImpl struct {
+	sheetId   string
+	sheetName string
+	srv       *sheets.Service
+}
+
+func NewDeletionSheetClient(sheetId, sheetName string) *DeletionSheetClientImpl {
+	return &DeletionSheetClientImpl{
+		srv:       newSheetsService(sheetName),
+		sheetId:   sheetId,
+		sheetName: sheetName,
+	}
+}
+
+func (c *DeletionSheetClientImpl) FetchAllSheetData(ctx context.Context) (_ []models.DeletionRequest, err error) {
+	ctx, done := observe(ctx, c.sheetName, "fetch_all")
+	defer func() { done(err) }()
+
+	headerIndexMap, err := getHeaderIndexMap(ctx, c.srv, c.sheetId, c.sheetName)
+	if err != nil {
+		return nil, err
+	}
+
+	resp, err := c.srv.Spreadsheets.Values.Get(c.sheetId, c.sheetName+"!A2:Z").Context(ctx).Do()
+	if err != nil {
+		return nil, err
+	}
+
+	var requests []models.DeletionRequest
+	for i, row := range resp.Values {
+		r := models.DeletionRequest{}
+
+		r.RowNumber = i + 2
+		r.ID = c.getValue(row, headerIndexMap, "id")
+		r.UserID = c.getValue(row, headerIndexMap, "user_id")
+		r.PublicID = c.getValue(row, headerIndexMap, "public_id")
+		r.Mode = c.getValue(row, headerIndexMap, "mode")
+		r.Status = c.getValue(row, headerIndexMap, "status")
+		r.RequestedAt = c.getValue(row, headerIndexMap, "requested_at")
+		r.CompletedAt = c.getValue(row, headerIndexMap, "completed_at")
+		r.OrbitRecords, _ = strconv.Atoi(c.getValue(row, headerIndexMap, "orbit_records"))
+		r.ChampionshipsRecords, _ = strconv.Atoi(c.getValue(row, headerIndexMap, "championships_records"))
+		r.Receipt = c.getValue(row, headerIndexMap, "receipt")
+		r.Error = c.getValue(row, headerIndexMap, "error")
+		r.TokensRevokedAt = c.getValue(row, headerIndexMap, "tokens_revoked_at")
+
+		requests = append(requests, r)
+	}
+
+	return requests, nil
+}
+
+func (c *DeletionSheetClientImpl) getValue(row []interface{}, headerIndexMap map[string]int, key string) string {
+	if index, ok := headerIndexMap[key]; ok && index < len(row) {
+		return fmt.Sprint(row[index])
+	}
+	return ""
+}
+
+func (c *DeletionSheetClientImpl) toRow(request models.DeletionRequest, headerIndexMap map[string]int) []interface{} {
+	row := make([]interface{}, len(headerIndexMap))
+	for key, index := range headerIndexMap {
+		switch key {
+		case "id":
+			row[index] = request.ID
+		case "user_id":
+			row[index] = request.UserID
+		case "public_id":
+			row[index] = request.PublicID
+		case "mode":
+			row[index] = request.Mode
+		case "status":
+			row[index] = request.Status
+		case "requested_at":
+			row[index] = request.RequestedAt
+		case "completed_at":
+			row[index] = request.CompletedAt
+		case "orbit_records":
+			row[index] = request.OrbitRecords
+		case "championships_records":
+			row[index] = request.ChampionshipsRecords
+		case "receipt":
+			row[index] = request.Receipt
+		case "error":
+			row[index] = request.Error
+		case "tokens_revoked_at":
+			row[index] = request.TokensRevokedAt
+		default:
+		}
+	}
+	return row
+}
+
+func (c *DeletionSheetClientImpl) ProcessRequest(ctx context.Context, request models.DeletionRequest) (_ *models.DeletionRequest, err error) {
+	ctx, done := observe(ctx, c.sheetName, "append")
+	defer func() { done(err) }()
+
+	headerIndexMap, err := getHeaderIndexMap(ctx, c.srv, c.sheetId, c.sheetName)
+	if err != nil {
+		return nil, err
+	}
+
+	resp, err := c.srv.Spreadsheets.Values.Append(c.sheetId, c.sheetName+"!A1", &sheets.ValueRange{
+		Values: [][]interface{}{c.toRow(request, headerIndexMap)},
+	}).ValueInputOption("RAW").Context(ctx).Do()
+	if err != nil {
+		logrus.Errorf("sheet %s failed to append deletion request to Google Sheets: %v", c.sheetName, err)
+		return nil, err
+	}
+
+	rowNum, err := extractRowNumber(resp.Updates.UpdatedRange)
+	if err != nil {
+		return nil, err
+	}
+	request.RowNumber = rowNum
+
+	return &request, nil
+}
+
+func (c *DeletionSheetClientImpl) UpdateRequest(ctx context.Context, request models.DeletionRequest) (err error) {
+	ctx, done := observe(ctx, c.sheetName, "update")
+	defer func() { done(err) }()
+
+	headerIndexMap, err := getHeaderIndexMap(ctx, c.srv, c.sheetId, c.sheetName)
+	if err != nil {
+		return err
+	}
+
+	updateRange := fmt.Sprintf("%s!A%d", c.sheetName, request.RowNumber)
+	_, err = c.srv.Spreadsheets.Values.Update(c.sheetId, updateRange, &sheets.ValueRange{
+		Values: [][]interface{}{c.toRow(request, headerIndexMap)},
+	}).ValueInputOption("RAW").Context(ctx).Do()
+	if err != nil {
+		logrus.Errorf("sheet %s failed to update deletion request to Google Sheets: %v", c.sheetName, err)
+		return err
+	}
+
+	return nil
+}
+
+func (c *DeletionSheetClientImpl) GetType() string {
+	return c.sheetName
+}
@@ -0,0 +1,164 @@
+package sheet_clients
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+
+	"github.com/sirupsen/logrus"
+	"google.golang.org/api/sheets/v4"
+
+	"lysk-battle-record/internal/models"
+)
+
+type IdentitySheetClient interface {
+	FetchAllSheetData(ctx context.Context) ([]models.Identity, error)
+	ProcessIdentity(ctx context.Context, identity models.Identity) (*models.Identity, error)
+	UpdateIdentity(ctx context.Context, identity models.Identity) error
+	DeleteIdentity(ctx context.Context, identity models.Identity) error
+	GetType() string
+}
+
+type IdentitySheetClientImpl struct {
+	sheetId   string
+	sheetName string
+	srv       *sheets.Service
+}
+
+func NewIdentitySheetClient(sheetId, sheetName string) *IdentitySheetClientImpl {
+	return &IdentitySheetClientImpl{
+		srv:       newSheetsService(sheetName),
+		sheetId:   sheetId,
+		sheetName: sheetName,
+	}
+}
+
+func (c *IdentitySheetClientImpl) FetchAllSheetData(ctx context.Context) (_ []models.Identity, err error) {
+	ctx, done := observe(ctx, c.sheetName, "fetch_all")
+	defer func() { done(err) }()
+
+	headerIndexMap, err := getHeaderIndexMap(ctx, c.srv, c.sheetId, c.sheetName)
+	if err != nil {
+		return nil, err
+	}
+
+	resp, err := c.srv.Spreadsheets.Values.Get(c.sheetId, c.sheetName+"!A2:Z").Context(ctx).Do()
+	if err != nil {
+		return nil, err
+	}
+
+	var identities []models.Identity
+	for i, row := range resp.Values {
+		identity := models.Identity{}
+
+		identity.RowNumber = i + 2
+		identity.Provider = c.getValue(row, headerIndexMap, "provider")
+		identity.Subject = c.getValue(row, headerIndexMap, "subject")
+		identity.UserID = c.getValue(row, headerIndexMap, "user_id")
+		identity.PasswordHash = c.getValue(row, headerIndexMap, "password_hash")
+		identity.LinkedAt = c.getValue(row, headerIndexMap, "linked_at")
+		identity.Unverified, _ = strconv.ParseBool(c.getValue(row, headerIndexMap, "unverified"))
+		if identity.Provider == "" || identity.Subject == "" {
+			continue
+		}
+
+		identities = append(identities, identity)
+	}
+
+	return identities, nil
+}
+
+func (c *IdentitySheetClientImpl) getValue(row []interface{}, headerIndexMap map[string]int, key string) string {
+	if index, ok := headerIndexMap[key]; ok && index < len(row) {
+		return fmt.Sprint(row[index])
+	}
+	return ""
+}
+
+func (c *IdentitySheetClientImpl) toRow(identity models.Identity, headerIndexMap map[string]int) []interface{} {
+	row := make([]interface{}, len(headerIndexMap))
+	for key, index := range headerIndexMap {
+		switch key {
+		case "provider":
+			row[index] = identity.Provider
+		case "subject":
+			row[index] = identity.Subject
+		case "user_id":
+			row[index] = identity.UserID
+		case "password_hash":
+			row[index] = identity.PasswordHash
+		case "linked_at":
+			row[index] = identity.LinkedAt
+		case "unverified":
+			row[index] = identity.Unverified
+		default:
+		}
+	}
+	return row
+}
+
+func (c *IdentitySheetClientImpl) ProcessIdentity(ctx context.Context, identity models.Identity) (_ *models.Identity, err error) {
+	ctx, done := observe(ctx, c.sheetName, "append")
+	defer func() { done(err) }()
+
+	headerIndexMap, err := getHeaderIndexMap(ctx, c.srv, c.sheetId, c.sheetName)
+	if err != nil {
+		return nil, err
+	}
+
+	resp, err := c.srv.Spreadsheets.Values.Append(c.sheetId, c.sheetName+"!A1", &sheets.ValueRange{
+		Values: [][]interface{}{c.toRow(identity, headerIndexMap)},
+	}).ValueInputOption("RAW").Context(ctx).Do()
+	if err != nil {
+		logrus.Errorf("sheet %s failed to append identity to Google Sheets: %v", c.sheetName, err)
+		return nil, err
+	}
+
+	rowNum, err := extractRowNumber(resp.Updates.UpdatedRange)
+	if err != nil {
+		return nil, err
+	}
+	identity.RowNumber = rowNum
+
+	return &identity, nil
+}
+
+func (c *IdentitySheetClientImpl) UpdateIdentity(ctx context.Context, identity models.Identity) (err error) {
+	ctx, done := observe(ctx, c.sheetName, "update")
+	defer func() { done(err) }()
+
+	headerIndexMap, err := getHeaderIndexMap(ctx, c.srv, c.sheetId, c.sheetName)
+	if err != nil {
+		return err
+	}
+
+	updateRange := fmt.Sprintf("%s!A%d", c.sheetName, identity.RowNumber)
+	_, err = c.srv.Spreadsheets.Values.Update(c.sheetId, updateRange, &sheets.ValueRange{
+		Values: [][]interface{}{c.toRow(identity, headerIndexMap)},
+	}).ValueInputOption("RAW").Context(ctx).Do()
+	if err != nil {
+		logrus.Errorf("sheet %s failed to update identity to Google Sheets: %v", c.sheetName, err)
+		return err
+	}
+
+	return nil
+}
+
+// DeleteIdentity clears the identity's row, keeping the row numbers of the others valid.
+func (c *IdentitySheetClientImpl) DeleteIdentity(ctx context.Context, identity models.Identity) (err error) {
+	ctx, done := observe(ctx, c.sheetName, "delete")
+	defer func() { done(err) }()
+
+	clearRange := fmt.Sprintf("%s!A%d:Z%d", c.sheetName, identity.RowNumber, identity.RowNumber)
+	_, err = c.srv.Spreadsheets.Values.Clear(c.sheetId, clearRange, &sheets.ClearValuesRequest{}).Context(ctx).Do()
+	if err != nil {
+		logrus.Errorf("sheet %s failed to delete identity from Google Sheets: %v", c.sheetName, err)
+		return err
+	}
+
+	return nil
+}
+
+func (c *IdentitySheetClientImpl) GetType() string {
+	return c.sheetName
+}
@@ -3,23 +3,26 @@ package sheet_clients
 import (
 	"context"
 	"fmt"
-	"os"
 	"regexp"
 	"strconv"
 
 	"github.com/google/uuid"
 	"github.com/sirupsen/logrus"
-	"golang.org/x/oauth2/google"
 	"google.golang.org/api/sheets/v4"
 
 	"lysk-battle-record/internal/models"
+	"lysk-battle-record/internal/registry"
 )
 
 type RecordSheetClient interface {
-	FetchAllSheetData() ([]models.Record, error)
-	ProcessRecord(record models.Record) (*models.Record, error)
-	UpdateRecord(record models.Record) error
-	DeleteRecord(record models.Record) error
+	FetchAllSheetData(ctx context.Context) ([]models.Record, error)
+	ProcessRecord(ctx context.Context, record models.Record) (*models.Record, error)
+	UpdateRecord(ctx context.Context, record models.Record) error
+	DeleteRecord(ctx context.Context, record models.Record) error
+	// UpdateEstimatorVersion writes only the 估算版本 cell of the record's row, so edits made
+	// since the record was read are kept.
+	UpdateEstimatorVersion(ctx context.Context, record models.Record, version string) error
+	Ping(ctx context.Context) error
 	GetType() string
 }
 
@@ -30,51 +33,18 @@ type RecordSheetClientImpl struct {
 }
 
 func NewRecordSheetClient(sheetId, sheetName string) *RecordSheetClientImpl {
-	ctx := context.Background()
-	var srv *sheets.Service
-
-	// 优先使用本地 credentials.json，如果不存在就走默认（Cloud Run）
-	if b, err := os.ReadFile("credentials.json"); err == nil {
-		config, err := google.JWTConfigFromJSON(b, sheets.SpreadsheetsScope)
-		if err != nil {
-			logrus.Fatalf("%s sheet failed to load credentials.json: %v", sheetName, err)
-		}
-
-		client := config.Client(ctx)
-		srv, err = sheets.New(client)
-		if err != nil {
-			logrus.Fatalf("%s failed to init Sheets client with credentials.json: %v", sheetName, err)
-		}
-
-		logrus.Infof("%s use credentials.json to init Sheets client", sheetName)
-		return &RecordSheetClientImpl{
-			srv:       srv,
-			sheetId:   sheetId,
-			sheetName: sheetName,
-		}
-	}
-
-	// fallback 到 Cloud Run 默认凭证
-	client, err := google.DefaultClient(ctx, sheets.SpreadsheetsScope)
-	if err != nil {
-		logrus.Fatalf("%s failed to fetch service account credential: %v", sheetName, err)
-	}
-
-	srv, err = sheets.New(client)
-	if err != nil {
-		logrus.Fatalf("%s failed to init Sheets client with service account credential: %v", sheetName, err)
-	}
-
-	logrus.Infof("%s using default client (Cloud Run) to init Sheets client", sheetName)
 	return &RecordSheetClientImpl{
-		srv:       srv,
+		srv:       newSheetsService(sheetName),
 		sheetId:   sheetId,
 		sheetName: sheetName,
 	}
 }
 
-func (c *RecordSheetClientImpl) FetchAllSheetData() ([]models.Record, error) {
-	header, err := c.srv.Spreadsheets.Values.Get(c.sheetId, c.sheetName+"!A1:Z").Do()
+func (c *RecordSheetClientImpl) FetchAllSheetData(ctx context.Context) (_ []models.Record, err error) {
+	ctx, done := observe(ctx, c.sheetName, "fetch_all")
+	defer func() { done(err) }()
+
+	header, err := c.srv.Spreadsheets.Values.Get(c.sheetId, c.sheetName+"!A1:Z").Context(ctx).Do()
 	if err != nil {
 		return nil, err
 	}
@@ -86,7 +56,7 @@ func (c *RecordSheetClientImpl) FetchAllSheetData() ([]models.Record, error) {
 		}
 	}
 
-	resp, err := c.srv.Spreadsheets.Values.Get(c.sheetId, c.sheetName+"!A2:Z").Do()
+	resp, err := c.srv.Spreadsheets.Values.Get(c.sheetId, c.sheetName+"!A2:Z").Context(ctx).Do()
 	if err != nil {
 		return nil, err
 	}
@@ -110,10 +80,12 @@ func (c *RecordSheetClientImpl) FetchAllSheetData() ([]models.Record, error) {
 		r.WeakenBoost = c.getValue(row, headerIndexMap, "虚弱增伤")
 		r.OathBoost = c.getValue(row, headerIndexMap, "誓约增伤")
 		r.OathRegen = c.getValue(row, headerIndexMap, "誓约回能")
-		r.Companion = c.getValue(row, headerIndexMap, "搭档身份")
+		// 旧记录可能使用搭档的曾用名
+		r.Companion = registry.CanonicalCompanionName(c.getValue(row, headerIndexMap, "搭档身份"))
 		r.SetCard = c.getValue(row, headerIndexMap, "日卡")
 		r.Stage = c.getValue(row, headerIndexMap, "阶数")
 		r.Weapon = c.getValue(row, headerIndexMap, "武器")
+		r.WeaponRefinement = c.getValue(row, headerIndexMap, "武器精炼")
 		r.StarRank = c.getValue(row, headerIndexMap, "星级")
 		r.Buff = c.getValue(row, headerIndexMap, "加成")
 		r.TotalLevel = c.getValue(row, headerIndexMap, "卡总等级")
@@ -121,6 +93,17 @@ func (c *RecordSheetClientImpl) FetchAllSheetData() ([]models.Record, error) {
 		r.Time = c.getValue(row, headerIndexMap, "时间")
 		r.UserID = c.getValue(row, headerIndexMap, "用户ID")
 		r.Id = c.getValue(row, headerIndexMap, "id")
+		r.EstimatorVersion = c.getValue(row, headerIndexMap, "估算版本")
+		r.SupportCompanion = registry.CanonicalCompanionName(c.getValue(row, headerIndexMap, "协助搭档"))
+		if loadout, err := models.ParseLoadout(c.getValue(row, headerIndexMap, "卡组")); err != nil {
+			logrus.Warnf("sheet %s ignoring invalid loadout in row %d: %v", c.sheetName, r.RowNumber, err)
+		} else {
+			r.Loadout = loadout
+		}
+
+		anonymousStr := c.getValue(row, headerIndexMap, "anonymous")
+		anonymous, _ := strconv.ParseBool(anonymousStr)
+		r.Anonymous = anonymous
 
 		deletedStr := c.getValue(row, headerIndexMap, "deleted")
 		deleted, _ := strconv.ParseBool(deletedStr)
@@ -139,9 +122,12 @@ func (c *RecordSheetClientImpl) getValue(row []interface{}, headerIndexMap map[s
 	return ""
 }
 
-func (c *RecordSheetClientImpl) ProcessRecord(record models.Record) (*models.Record, error) {
+func (c *RecordSheetClientImpl) ProcessRecord(ctx context.Context, record models.Record) (_ *models.Record, err error) {
+	ctx, done := observe(ctx, c.sheetName, "append")
+	defer func() { done(err) }()
+
 	record.Id = uuid.New().String()
-	header, err := c.srv.Spreadsheets.Values.Get(c.sheetId, c.sheetName+"!A1:Z").Do()
+	header, err := c.srv.Spreadsheets.Values.Get(c.sheetId, c.sheetName+"!A1:Z").Context(ctx).Do()
 	if err != nil {
 		return nil, err
 	}
@@ -208,13 +194,27 @@ func (c *RecordSheetClientImpl) ProcessRecord(record models.Record) (*models.Rec
 			row[index] = record.UserID
 		case "id":
 			row[index] = record.Id
+		case "anonymous":
+			row[index] = record.Anonymous
+		case "估算版本":
+			row[index] = record.EstimatorVersion
+		case "协助搭档":
+			row[index] = record.SupportCompanion
+		case "武器精炼":
+			row[index] = record.WeaponRefinement
+		case "卡组":
+			// 表格会跳过 nil 的单元格，去掉卡组时要写空字符串把旧卡组清掉
+			row[index] = ""
+			if record.Loadout != nil {
+				row[index] = record.Loadout.String()
+			}
 		default:
 		}
 	}
 
 	resp, err := c.srv.Spreadsheets.Values.Append(c.sheetId, c.sheetName+"!A1", &sheets.ValueRange{
 		Values: [][]interface{}{row},
-	}).ValueInputOption("RAW").Do()
+	}).ValueInputOption("RAW").Context(ctx).Do()
 	if err != nil {
 		// : assign record.RowNumber after appending
 		logrus.Errorf("sheet %s failed to append record to Google Sheets: %v", c.sheetName, err)
@@ -243,8 +243,20 @@ func (c *RecordSheetClientImpl) GetType() string {
 	return c.sheetName
 }
 
-func (c *RecordSheetClientImpl) UpdateRecord(record models.Record) error {
-	header, err := c.srv.Spreadsheets.Values.Get(c.sheetId, c.sheetName+"!A1:Z").Do()
+// Ping reads the first cell, to check that the sheet is reachable with the credentials.
+func (c *RecordSheetClientImpl) Ping(ctx context.Context) (err error) {
+	ctx, done := observe(ctx, c.sheetName, "ping")
+	defer func() { done(err) }()
+
+	_, err = c.srv.Spreadsheets.Values.Get(c.sheetId, c.sheetName+"!A1:A1").Context(ctx).Do()
+	return err
+}
+
+func (c *RecordSheetClientImpl) UpdateRecord(ctx context.Context, record models.Record) (err error) {
+	ctx, done := observe(ctx, c.sheetName, "update")
+	defer func() { done(err) }()
+
+	header, err := c.srv.Spreadsheets.Values.Get(c.sheetId, c.sheetName+"!A1:Z").Context(ctx).Do()
 	if err != nil {
 		return err
 	}
@@ -311,6 +323,20 @@ func (c *RecordSheetClientImpl) UpdateRecord(record models.Record) error {
 			row[index] = record.UserID
 		case "id":
 			row[index] = record.Id
+		case "anonymous":
+			row[index] = record.Anonymous
+		case "估算版本":
+			row[index] = record.EstimatorVersion
+		case "协助搭档":
+			row[index] = record.SupportCompanion
+		case "武器精炼":
+			row[index] = record.WeaponRefinement
+		case "卡组":
+			// 表格会跳过 nil 的单元格，去掉卡组时要写空字符串把旧卡组清掉
+			row[index] = ""
+			if record.Loadout != nil {
+				row[index] = record.Loadout.String()
+			}
 		case "deleted":
 			row[index] = record.Deleted
 		default:
@@ -320,7 +346,7 @@ func (c *RecordSheetClientImpl) UpdateRecord(record models.Record) error {
 	updateRange := fmt.Sprintf("%s!A%d", c.sheetName, record.RowNumber)
 	_, err = c.srv.Spreadsheets.Values.Update(c.sheetId, updateRange, &sheets.ValueRange{
 		Values: [][]interface{}{row},
-	}).ValueInputOption("RAW").Do()
+	}).ValueInputOption("RAW").Context(ctx).Do()
 	if err != nil {
 		logrus.Errorf("sheet %s failed to update record to Google Sheets: %v", c.sheetName, err)
 		return err
@@ -329,8 +355,11 @@ func (c *RecordSheetClientImpl) UpdateRecord(record models.Record) error {
 	return nil
 }
 
-func (c *RecordSheetClientImpl) DeleteRecord(record models.Record) error {
-	header, err := c.srv.Spreadsheets.Values.Get(c.sheetId, c.sheetName+"!A1:Z").Do()
+func (c *RecordSheetClientImpl) DeleteRecord(ctx context.Context, record models.Record) (err error) {
+	ctx, done := observe(ctx, c.sheetName, "delete")
+	defer func() { done(err) }()
+
+	header, err := c.srv.Spreadsheets.Values.Get(c.sheetId, c.sheetName+"!A1:Z").Context(ctx).Do()
 	if err != nil {
 		return err
 	}
@@ -350,7 +379,7 @@ func (c *RecordSheetClientImpl) DeleteRecord(record models.Record) error {
 	updateRange := fmt.Sprintf("%s!%s%d", c.sheetName, toCharStr(deleteColumnIndex+1), record.RowNumber)
 	_, err = c.srv.Spreadsheets.Values.Update(c.sheetId, updateRange, &sheets.ValueRange{
 		Values: [][]interface{}{{true}},
-	}).ValueInputOption("RAW").Do()
+	}).ValueInputOption("RAW").Context(ctx).Do()
 	if err != nil {
 		logrus.Errorf("sheet %s failed to delete record from Google Sheets: %v", c.sheetName, err)
 		return err
@@ -359,11 +388,44 @@ func (c *RecordSheetClientImpl) DeleteRecord(record models.Record) error {
 	return nil
 }
 
+func (c *RecordSheetClientImpl) UpdateEstimatorVersion(ctx context.Context, record models.Record, version string) (err error) {
+	ctx, done := observe(ctx, c.sheetName, "update_estimator_version")
+	defer func() { done(err) }()
+
+	header, err := c.srv.Spreadsheets.Values.Get(c.sheetId, c.sheetName+"!A1:Z").Context(ctx).Do()
+	if err != nil {
+		return err
+	}
+
+	headerIndexMap := make(map[string]int)
+	for i, h := range header.Values[0] {
+		if hStr, ok := h.(string); ok {
+			headerIndexMap[hStr] = i
+		}
+	}
+
+	versionColumnIndex, ok := headerIndexMap["估算版本"]
+	if !ok {
+		return fmt.Errorf("估算版本 column not found in sheet %s", c.sheetName)
+	}
+
+	updateRange := fmt.Sprintf("%s!%s%d", c.sheetName, toCharStr(versionColumnIndex+1), record.RowNumber)
+	_, err = c.srv.Spreadsheets.Values.Update(c.sheetId, updateRange, &sheets.ValueRange{
+		Values: [][]interface{}{{version}},
+	}).ValueInputOption("RAW").Context(ctx).Do()
+	if err != nil {
+		logrus.Errorf("sheet %s failed to update estimator version in Google Sheets: %v", c.sheetName, err)
+		return err
+	}
+
+	return nil
+}
+
 func toCharStr(i int) string {
 	s := ""
 	for i > 0 {
 		i--
-		s = string('A'+i%26) + s
+		s = string(rune('A'+i%26)) + s
 		i /= 26
 	}
 	return s
@@ -0,0 +1,138 @@
+package sheet_clients
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/sirupsen/logrus"
+	"google.golang.org/api/sheets/v4"
+
+	"lysk-battle-record/internal/models"
+)
+
+type RevocationSheetClient interface {
+	FetchAllSheetData(ctx context.Context) ([]models.Revocation, error)
+	ProcessRevocation(ctx context.Context, revocation models.Revocation) (*models.Revocation, error)
+	DeleteRevocation(ctx context.Context, revocation models.Revocation) error
+	GetType() string
+}
+
+type RevocationSheetClientImpl struct {
+	sheetId   string
+	sheetName string
+	srv       *sheets.Service
+}
+
+func NewRevocationSheetClient(sheetId, sheetName string) *RevocationSheetClientImpl {
+	return &RevocationSheetClientImpl{
+		srv:       newSheetsService(sheetName),
+		sheetId:   sheetId,
+		sheetName: sheetName,
+	}
+}
+
+func (c *RevocationSheetClientImpl) FetchAllSheetData(ctx context.Context) (_ []models.Revocation, err error) {
+	ctx, done := observe(ctx, c.sheetName, "fetch_all")
+	defer func() { done(err) }()
+
+	headerIndexMap, err := getHeaderIndexMap(ctx, c.srv, c.sheetId, c.sheetName)
+	if err != nil {
+		return nil, err
+	}
+
+	resp, err := c.srv.Spreadsheets.Values.Get(c.sheetId, c.sheetName+"!A2:Z").Context(ctx).Do()
+	if err != nil {
+		return nil, err
+	}
+
+	var revocations []models.Revocation
+	for i, row := range resp.Values {
+		revocation := models.Revocation{}
+
+		revocation.RowNumber = i + 2
+		revocation.Kind = c.getValue(row, headerIndexMap, "kind")
+		revocation.Subject = c.getValue(row, headerIndexMap, "subject")
+		revocation.Reason = c.getValue(row, headerIndexMap, "reason")
+		revocation.ExpiresAt = c.getValue(row, headerIndexMap, "expires_at")
+		revocation.CreatedAt = c.getValue(row, headerIndexMap, "created_at")
+		if revocation.Kind == "" || revocation.Subject == "" {
+			continue
+		}
+
+		revocations = append(revocations, revocation)
+	}
+
+	return revocations, nil
+}
+
+func (c *RevocationSheetClientImpl) getValue(row []interface{}, headerIndexMap map[string]int, key string) string {
+	if index, ok := headerIndexMap[key]; ok && index < len(row) {
+		return fmt.Sprint(row[index])
+	}
+	return ""
+}
+
+func (c *RevocationSheetClientImpl) toRow(revocation models.Revocation, headerIndexMap map[string]int) []interface{} {
+	row := make([]interface{}, len(headerIndexMap))
+	for key, index := range headerIndexMap {
+		switch key {
+		case "kind":
+			row[index] = revocation.Kind
+		case "subject":
+			row[index] = revocation.Subject
+		case "reason":
+			row[index] = revocation.Reason
+		case "expires_at":
+			row[index] = revocation.ExpiresAt
+		case "created_at":
+			row[index] = revocation.CreatedAt
+		default:
+		}
+	}
+	return row
+}
+
+func (c *RevocationSheetClientImpl) ProcessRevocation(ctx context.Context, revocation models.Revocation) (_ *models.Revocation, err error) {
+	ctx, done := observe(ctx, c.sheetName, "append")
+	defer func() { done(err) }()
+
+	headerIndexMap, err := getHeaderIndexMap(ctx, c.srv, c.sheetId, c.sheetName)
+	if err != nil {
+		return nil, err
+	}
+
+	resp, err := c.srv.Spreadsheets.Values.Append(c.sheetId, c.sheetName+"!A1", &sheets.ValueRange{
+		Values: [][]interface{}{c.toRow(revocation, headerIndexMap)},
+	}).ValueInputOption("RAW").Context(ctx).Do()
+	if err != nil {
+		logrus.Errorf("sheet %s failed to append revocation to Google Sheets: %v", c.sheetName, err)
+		return nil, err
+	}
+
+	rowNum, err := extractRowNumber(resp.Updates.UpdatedRange)
+	if err != nil {
+		return nil, err
+	}
+	revocation.RowNumber = rowNum
+
+	return &revocation, nil
+}
+
+// DeleteRevocation clears the revocation's row, keeping the row numbers of the others valid.
+func (c *RevocationSheetClientImpl) DeleteRevocation(ctx context.Context, revocation models.Revocation) (err error) {
+	ctx, done := observe(ctx, c.sheetName, "delete")
+	defer func() { done(err) }()
+
+	clearRange := fmt.Sprintf("%s!A%d:Z%d", c.sheetName, revocation.RowNumber, revocation.RowNumber)
+	_, err = c.srv.Spreadsheets.Values.Clear(c.sheetId, clearRange, &sheets.ClearValuesRequest{}).Context(ctx).Do()
+	if err != nil {
+		logrus.Errorf("sheet %s failed to delete revocation from Google Sheets: %v", c.sheetName, err)
+		return err
+	}
+
+	return nil
+}
+
+func (c *RevocationSheetClientImpl) GetType() string {
+	return c.sheetName
+}
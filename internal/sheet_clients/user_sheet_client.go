@@ -3,19 +3,19 @@ package sheet_clients
 import (
 	"context"
 	"fmt"
-	"os"
+	"strconv"
 
 	"lysk-battle-record/internal/models"
 
 	"github.com/sirupsen/logrus"
-	"golang.org/x/oauth2/google"
 	"google.golang.org/api/sheets/v4"
 )
 
 type UserSheetClient interface {
-	FetchAllSheetData() ([]models.User, error)
-	ProcessUser(user models.User) (*models.User, error)
-	UpdateUser(user models.User) error
+	FetchAllSheetData(ctx context.Context) ([]models.User, error)
+	ProcessUser(ctx context.Context, user models.User) (*models.User, error)
+	UpdateUser(ctx context.Context, user models.User) error
+	DeleteUser(ctx context.Context, user models.User) error
 	GetType() string
 }
 
@@ -26,49 +26,18 @@ type UserSheetClientImpl struct {
 }
 
 func NewUserSheetClient(sheetId, sheetName string) *UserSheetClientImpl {
-	ctx := context.Background()
-	var srv *sheets.Service
-
-	if b, err := os.ReadFile("credentials.json"); err == nil {
-		config, err := google.JWTConfigFromJSON(b, sheets.SpreadsheetsScope)
-		if err != nil {
-			logrus.Fatalf("%s sheet failed to load credentials.json: %v", sheetName, err)
-		}
-
-		client := config.Client(ctx)
-		srv, err = sheets.New(client)
-		if err != nil {
-			logrus.Fatalf("%s failed to init Sheets client with credentials.json: %v", sheetName, err)
-		}
-
-		logrus.Infof("%s use credentials.json to init Sheets client", sheetName)
-		return &UserSheetClientImpl{
-			srv:       srv,
-			sheetId:   sheetId,
-			sheetName: sheetName,
-		}
-	}
-
-	client, err := google.DefaultClient(ctx, sheets.SpreadsheetsScope)
-	if err != nil {
-		logrus.Fatalf("%s failed to fetch service account credential: %v", sheetName, err)
-	}
-
-	srv, err = sheets.New(client)
-	if err != nil {
-		logrus.Fatalf("%s failed to init Sheets client with service account credential: %v", sheetName, err)
-	}
-
-	logrus.Infof("%s using default client (Cloud Run) to init Sheets client", sheetName)
 	return &UserSheetClientImpl{
-		srv:       srv,
+		srv:       newSheetsService(sheetName),
 		sheetId:   sheetId,
 		sheetName: sheetName,
 	}
 }
 
-func (c *UserSheetClientImpl) FetchAllSheetData() ([]models.User, error) {
-	header, err := c.srv.Spreadsheets.Values.Get(c.sheetId, c.sheetName+"!A1:Z").Do()
+func (c *UserSheetClientImpl) FetchAllSheetData(ctx context.Context) (_ []models.User, err error) {
+	ctx, done := observe(ctx, c.sheetName, "fetch_all")
+	defer func() { done(err) }()
+
+	header, err := c.srv.Spreadsheets.Values.Get(c.sheetId, c.sheetName+"!A1:Z").Context(ctx).Do()
 	if err != nil {
 		return nil, err
 	}
@@ -80,7 +49,7 @@ func (c *UserSheetClientImpl) FetchAllSheetData() ([]models.User, error) {
 		}
 	}
 
-	resp, err := c.srv.Spreadsheets.Values.Get(c.sheetId, c.sheetName+"!A2:Z").Do()
+	resp, err := c.srv.Spreadsheets.Values.Get(c.sheetId, c.sheetName+"!A2:Z").Context(ctx).Do()
 	if err != nil {
 		return nil, err
 	}
@@ -91,8 +60,16 @@ func (c *UserSheetClientImpl) FetchAllSheetData() ([]models.User, error) {
 
 		u.RowNumber = i + 2
 		u.ID = c.getValue(row, headerIndexMap, "id")
+		if u.ID == "" {
+			// 已注销用户的行会被清空
+			continue
+		}
 		u.Nickname = c.getValue(row, headerIndexMap, "nickname")
 
+		u.HideNickname, _ = strconv.ParseBool(c.getValue(row, headerIndexMap, "hide_nickname"))
+		u.AnonymousByDefault, _ = strconv.ParseBool(c.getValue(row, headerIndexMap, "anonymous_by_default"))
+		u.HideFromRanking, _ = strconv.ParseBool(c.getValue(row, headerIndexMap, "hide_from_ranking"))
+
 		users = append(users, u)
 	}
 
@@ -106,8 +83,11 @@ func (c *UserSheetClientImpl) getValue(row []interface{}, headerIndexMap map[str
 	return ""
 }
 
-func (c *UserSheetClientImpl) ProcessUser(user models.User) (*models.User, error) {
-	header, err := c.srv.Spreadsheets.Values.Get(c.sheetId, c.sheetName+"!A1:Z").Do()
+func (c *UserSheetClientImpl) ProcessUser(ctx context.Context, user models.User) (_ *models.User, err error) {
+	ctx, done := observe(ctx, c.sheetName, "append")
+	defer func() { done(err) }()
+
+	header, err := c.srv.Spreadsheets.Values.Get(c.sheetId, c.sheetName+"!A1:Z").Context(ctx).Do()
 	if err != nil {
 		return nil, err
 	}
@@ -128,13 +108,19 @@ func (c *UserSheetClientImpl) ProcessUser(user models.User) (*models.User, error
 			row[index] = user.ID
 		case "nickname":
 			row[index] = user.Nickname
+		case "hide_nickname":
+			row[index] = user.HideNickname
+		case "anonymous_by_default":
+			row[index] = user.AnonymousByDefault
+		case "hide_from_ranking":
+			row[index] = user.HideFromRanking
 		default:
 		}
 	}
 
 	resp, err := c.srv.Spreadsheets.Values.Append(c.sheetId, c.sheetName+"!A1", &sheets.ValueRange{
 		Values: [][]interface{}{row},
-	}).ValueInputOption("RAW").Do()
+	}).ValueInputOption("RAW").Context(ctx).Do()
 	if err != nil {
 		logrus.Errorf("sheet %s failed to append user to Google Sheets: %v", c.sheetName, err)
 		return nil, err
@@ -153,8 +139,11 @@ func (c *UserSheetClientImpl) GetType() string {
 	return c.sheetName
 }
 
-func (c *UserSheetClientImpl) UpdateUser(user models.User) error {
-	header, err := c.srv.Spreadsheets.Values.Get(c.sheetId, c.sheetName+"!A1:Z").Do()
+func (c *UserSheetClientImpl) UpdateUser(ctx context.Context, user models.User) (err error) {
+	ctx, done := observe(ctx, c.sheetName, "update")
+	defer func() { done(err) }()
+
+	header, err := c.srv.Spreadsheets.Values.Get(c.sheetId, c.sheetName+"!A1:Z").Context(ctx).Do()
 	if err != nil {
 		return err
 	}
@@ -175,6 +164,12 @@ func (c *UserSheetClientImpl) UpdateUser(user models.User) error {
 			row[index] = user.ID
 		case "nickname":
 			row[index] = user.Nickname
+		case "hide_nickname":
+			row[index] = user.HideNickname
+		case "anonymous_by_default":
+			row[index] = user.AnonymousByDefault
+		case "hide_from_ranking":
+			row[index] = user.HideFromRanking
 		default:
 		}
 	}
@@ -182,7 +177,7 @@ func (c *UserSheetClientImpl) UpdateUser(user models.User) error {
 	updateRange := fmt.Sprintf("%s!A%d", c.sheetName, user.RowNumber)
 	_, err = c.srv.Spreadsheets.Values.Update(c.sheetId, updateRange, &sheets.ValueRange{
 		Values: [][]interface{}{row},
-	}).ValueInputOption("RAW").Do()
+	}).ValueInputOption("RAW").Context(ctx).Do()
 	if err != nil {
 		logrus.Errorf("sheet %s failed to update user to Google Sheets: %v", c.sheetName, err)
 		return err
@@ -190,3 +185,19 @@ func (c *UserSheetClientImpl) UpdateUser(user models.User) error {
 
 	return nil
 }
+
+// DeleteUser clears the user's row instead of removing it, so that the row numbers of the
+// other users stay valid.
+func (c *UserSheetClientImpl) DeleteUser(ctx context.Context, user models.User) (err error) {
+	ctx, done := observe(ctx, c.sheetName, "delete")
+	defer func() { done(err) }()
+
+	clearRange := fmt.Sprintf("%s!A%d:Z%d", c.sheetName, user.RowNumber, user.RowNumber)
+	_, err = c.srv.Spreadsheets.Values.Clear(c.sheetId, clearRange, &sheets.ClearValuesRequest{}).Context(ctx).Do()
+	if err != nil {
+		logrus.Errorf("sheet %s failed to delete user from Google Sheets: %v", c.sheetName, err)
+		return err
+	}
+
+	return nil
+}
@@ -0,0 +1,77 @@
+package sheet_clients
+
+import (
+	"context"
+	"os"
+	"time"
+
+	"github.com/sirupsen/logrus"
+	"go.opentelemetry.io/otel/attribute"
+	"golang.org/x/oauth2/google"
+	"google.golang.org/api/sheets/v4"
+
+	"lysk-battle-record/internal/pkg"
+)
+
+// newSheetsService 优先使用本地 credentials.json，如果不存在就走默认（Cloud Run）
+func newSheetsService(sheetName string) *sheets.Service {
+	ctx := context.Background()
+
+	if b, err := os.ReadFile("credentials.json"); err == nil {
+		config, err := google.JWTConfigFromJSON(b, sheets.SpreadsheetsScope)
+		if err != nil {
+			logrus.Fatalf("%s sheet failed to load credentials.json: %v", sheetName, err)
+		}
+
+		srv, err := sheets.New(config.Client(ctx))
+		if err != nil {
+			logrus.Fatalf("%s failed to init Sheets client with credentials.json: %v", sheetName, err)
+		}
+
+		logrus.Infof("%s use credentials.json to init Sheets client", sheetName)
+		return srv
+	}
+
+	// fallback 到 Cloud Run 默认凭证
+	client, err := google.DefaultClient(ctx, sheets.SpreadsheetsScope)
+	if err != nil {
+		logrus.Fatalf("%s failed to fetch service account credential: %v", sheetName, err)
+	}
+
+	srv, err := sheets.New(client)
+	if err != nil {
+		logrus.Fatalf("%s failed to init Sheets client with service account credential: %v", sheetName, err)
+	}
+
+	logrus.Infof("%s using default client (Cloud Run) to init Sheets client", sheetName)
+	return srv
+}
+
+func getHeaderIndexMap(ctx context.Context, srv *sheets.Service, sheetId, sheetName string) (map[string]int, error) {
+	header, err := srv.Spreadsheets.Values.Get(sheetId, sheetName+"!A1:Z").Context(ctx).Do()
+	if err != nil {
+		return nil, err
+	}
+
+	headerIndexMap := make(map[string]int)
+	for i, h := range header.Values[0] {
+		if hStr, ok := h.(string); ok {
+			headerIndexMap[hStr] = i
+		} else {
+			logrus.Infof("sheet %s header %v is not a string, skipping", sheetName, h)
+		}
+	}
+
+	return headerIndexMap, nil
+}
+
+// observe traces a call to the sheet and records its latency and errors. The returned function
+// ends it with the call's error.
+func observe(ctx context.Context, sheetName, operation string) (context.Context, func(error)) {
+	start := time.Now()
+	ctx, span := pkg.StartSpan(ctx, "sheets."+operation, attribute.String("sheet", sheetName))
+	return ctx, func(err error) {
+		pkg.ObserveSheetCall(sheetName, operation, start, err)
+		pkg.EndSpan(span, err)
+	}
+}
@@ -0,0 +1,247 @@
+package usecases
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"github.com/sirupsen/logrus"
+	"go.opentelemetry.io/otel/attribute"
+
+	"lysk-battle-record/internal/datastores"
+	"lysk-battle-record/internal/models"
+	"lysk-battle-record/internal/pkg"
+	"lysk-battle-record/internal/sheet_clients"
+)
+
+func (s *LyskServer) RequestAccountDeletion(c *gin.Context) {
+	userId, exists := c.Get("userID")
+	if !exists {
+		respondError(c, http.StatusUnauthorized, "unauthenticated_user")
+		return
+	}
+
+	var request models.DeletionRequest
+	if err := c.BindJSON(&request); err != nil {
+		respondError(c, http.StatusBadRequest, "invalid_request")
+		return
+	}
+
+	if err := request.ValidateMode(); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	publicId := s.pseudonymizer.PublicID(userId.(string))
+	if existing, ok := s.deletionRequestStore.GetLatestByPublicID(publicId); ok && existing.Status == models.DeletionStatusPending {
+		respondError(c, http.StatusConflict, "deletion_pending")
+		return
+	}
+
+	request.ID = uuid.New().String()
+	request.UserID = userId.(string)
+	request.PublicID = publicId
+	request.Status = models.DeletionStatusPending
+	request.RequestedAt = time.Now().Format(time.RFC3339)
+
+	createdRequest, err := s.deletionSheetClient.ProcessRequest(c.Request.Context(), request)
+	if err != nil {
+		logrus.Errorf("[Deletion] Failed to write deletion request to Google Sheet: %v", err)
+		respondErrorDetail(c, http.StatusInternalServerError, "deletion_request_failed", err)
+		return
+	}
+
+	s.deletionRequestStore.Insert(*createdRequest)
+	go s.processPendingDeletions()
+
+	c.JSON(http.StatusAccepted, createdRequest)
+}
+
+// GetAccountDeletion returns the user's latest deletion request. Once completed it carries
+// the receipt of the deletion.
+func (s *LyskServer) GetAccountDeletion(c *gin.Context) {
+	userId, exists := c.Get("userID")
+	if !exists {
+		respondError(c, http.StatusUnauthorized, "unauthenticated_user")
+		return
+	}
+
+	request, ok := s.deletionRequestStore.GetLatestByPublicID(s.pseudonymizer.PublicID(userId.(string)))
+	if !ok {
+		respondError(c, http.StatusNotFound, "deletion_request_not_found")
+		return
+	}
+
+	c.JSON(http.StatusOK, request)
+}
+
+func (s *LyskServer) GetDeletionRequests(c *gin.Context) {
+	userId, exists := c.Get("userID")
+	if !exists || !s.auth.IsAdmin(userId.(string)) {
+		respondError(c, http.StatusForbidden, "forbidden")
+		return
+	}
+
+	var requests []models.DeletionRequest
+	if c.DefaultQuery("status", models.DeletionStatusPending) == models.DeletionStatusPending {
+		requests = s.deletionRequestStore.GetPending()
+	} else {
+		requests = s.deletionRequestStore.GetAll()
+	}
+
+	c.JSON(http.StatusOK, requests)
+}
+
+// RunDeletionWorker periodically processes pending deletion requests. Requests are persisted
+// in the sheet, so the ones interrupted by a restart are picked up again.
+func (s *LyskServer) RunDeletionWorker(interval time.Duration) {
+	for {
+		time.Sleep(interval)
+		s.processPendingDeletions()
+	}
+}
+
+func (s *LyskServer) processPendingDeletions() {
+	s.deletionMutex.Lock()
+	defer s.deletionMutex.Unlock()
+
+	for _, request := range s.deletionRequestStore.GetPending() {
+		ctx, span := pkg.StartSpan(context.Background(), "deletion.process", attribute.String("deletion.id", request.ID))
+		err := s.processDeletion(ctx, &request)
+		if err != nil {
+			logrus.Errorf("[Deletion] Failed to process deletion request %s: %v", request.ID, err)
+
+			// keep the request pending so it is retried by the next run, with the records
+			// erased so far, which the next run no longer finds under the user
+			request.Error = err.Error()
+			if err := s.saveDeletionRequest(ctx, request); err != nil {
+				logrus.Errorf("[Deletion] Failed to save deletion request %s: %v", request.ID, err)
+			}
+		}
+		pkg.EndSpan(span, err)
+	}
+}
+
+func (s *LyskServer) processDeletion(ctx context.Context, request *models.DeletionRequest) error {
+	if request.UserID == "" {
+		return fmt.Errorf("deletion request %s has no user", request.ID)
+	}
+
+	orbitCount, err := s.eraseUserRecords(ctx, *request, s.orbitSheetClient, s.orbitRecordStore)
+	request.OrbitRecords += orbitCount
+	if err != nil {
+		return err
+	}
+
+	championshipsCount, err := s.eraseUserRecords(ctx, *request, s.championshipsSheetClient, s.championshipsRecordStore)
+	request.ChampionshipsRecords += championshipsCount
+	if err != nil {
+		return err
+	}
+
+	if err := s.eraseUser(ctx, request.UserID); err != nil {
+		return err
+	}
+
+	// tokens issued before the deletion would otherwise keep working until they expire
+	if err := s.auth.RevokeUser(request.UserID); err != nil {
+		return err
+	}
+	request.TokensRevokedAt = time.Now().Format(time.RFC3339)
+
+	completed := *request
+	completed.UserID = ""
+	completed.Status = models.DeletionStatusCompleted
+	completed.CompletedAt = time.Now().Format(time.RFC3339)
+	completed.Error = ""
+	completed.Receipt = completed.GenerateReceipt()
+
+	logrus.Infof("[Deletion] Completed deletion request %s with %d orbit and %d championships records",
+		completed.ID, completed.OrbitRecords, completed.ChampionshipsRecords)
+	return s.saveDeletionRequest(ctx, completed)
+}
+
+// eraseUserRecords detaches every record of the user from the account. In delete mode the
+// records are deleted as well. The sheet is used as the source of truth, so the job does not
+// depend on the in-memory store having been loaded.
+func (s *LyskServer) eraseUserRecords(ctx context.Context, request models.DeletionRequest, sheetClient sheet_clients.RecordSheetClient, store datastores.RecordStore) (int, error) {
+	records, err := sheetClient.FetchAllSheetData(ctx)
+	if err != nil {
+		return 0, err
+	}
+
+	count := 0
+	for _, record := range records {
+		if record.UserID != request.UserID {
+			continue
+		}
+
+		record.UserID = ""
+		record.Note = ""
+		record.Anonymous = true
+		if request.Mode == models.DeletionModeDelete {
+			record.Deleted = true
+		}
+
+		if err := sheetClient.UpdateRecord(ctx, record); err != nil {
+			return count, err
+		}
+
+		if err := store.Update(record); err == nil && record.Deleted {
+			if err := store.Delete(record); err != nil {
+				logrus.Errorf("[Deletion] Failed to delete record %s from memory: %v", record.Id, err)
+			}
+		}
+		count++
+	}
+
+	return count, nil
+}
+
+func (s *LyskServer) eraseUser(ctx context.Context, userId string) error {
+	users, err := s.userSheetClient.FetchAllSheetData(ctx)
+	if err != nil {
+		return err
+	}
+
+	for _, user := range users {
+		if user.ID != userId {
+			continue
+		}
+
+		if err := s.userSheetClient.DeleteUser(ctx, user); err != nil {
+			return err
+		}
+	}
+
+	identities, err := s.identitySheetClient.FetchAllSheetData(ctx)
+	if err != nil {
+		return err
+	}
+
+	for _, identity := range identities {
+		if identity.UserID != userId {
+			continue
+		}
+
+		if err := s.identitySheetClient.DeleteIdentity(ctx, identity); err != nil {
+			return err
+		}
+		_ = s.identityStore.Delete(identity)
+	}
+
+	// the user may not be loaded in memory yet
+	_ = s.userStore.Delete(userId)
+	return nil
+}
+
+func (s *LyskServer) saveDeletionRequest(ctx context.Context, request models.DeletionRequest) error {
+	if err := s.deletionSheetClient.UpdateRequest(ctx, request); err != nil {
+		return err
+	}
+
+	return s.deletionRequestStore.Update(request)
+}
@@ -0,0 +1,193 @@
+package usecases
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+
+	"lysk-battle-record/internal/models"
+	"lysk-battle-record/internal/pkg"
+)
+
+type deletionFixture struct {
+	server        *LyskServer
+	orbit         *fakeRecordSheetClient
+	orbitStore    *fakeRecordStore
+	championships *fakeRecordSheetClient
+	users         *fakeUserSheetClient
+	identities    *fakeIdentitySheetClient
+	requests      *fakeDeletionSheetClient
+}
+
+// newDeletionFixture has u1 with three orbit records, one championships record and two
+// identities, and u2 with one orbit record and one identity.
+func newDeletionFixture(mode string) deletionFixture {
+	var orbitRecords []models.Record
+	for _, id := range []string{"a", "b", "c", "other"} {
+		record := testRecord(id)
+		record.Note = "留言"
+		if id == "other" {
+			record.UserID = "u2"
+		}
+		orbitRecords = append(orbitRecords, record)
+	}
+	championship := testRecord("d")
+	championship.LevelType = "A4"
+
+	f := deletionFixture{
+		orbit:         newFakeRecordSheetClient(orbitRecords...),
+		championships: newFakeRecordSheetClient(championship),
+		users:         &fakeUserSheetClient{users: []models.User{{ID: "u1", Nickname: "一号"}, {ID: "u2"}}},
+		identities: &fakeIdentitySheetClient{identities: []models.Identity{
+			{Provider: "wechat", Subject: "u1", UserID: "u1"},
+			{Provider: "email", Subject: "u1@example.com", UserID: "u1"},
+			{Provider: "wechat", Subject: "u2", UserID: "u2"},
+		}},
+		requests: &fakeDeletionSheetClient{},
+	}
+	f.orbitStore = &fakeRecordStore{records: append([]models.Record(nil), f.orbit.rows...)}
+	pseudonymizer := pkg.NewPseudonymizer("secret")
+	f.server = &LyskServer{
+		orbitRecordStore:         f.orbitStore,
+		orbitSheetClient:         f.orbit,
+		championshipsRecordStore: &fakeRecordStore{records: append([]models.Record(nil), f.championships.rows...)},
+		championshipsSheetClient: f.championships,
+		userStore:                newFakeUserStore(f.users.users...),
+		userSheetClient:          f.users,
+		deletionRequestStore:     f.requests,
+		deletionSheetClient:      f.requests,
+		identityStore:            f.identities,
+		identitySheetClient:      f.identities,
+		pseudonymizer:            pseudonymizer,
+		auth:                     pkg.NewAuthenticator(pkg.AuthOptions{JWTSecret: "secret"}),
+	}
+	f.requests.Insert(models.DeletionRequest{
+		ID: "r1", UserID: "u1", PublicID: pseudonymizer.PublicID("u1"), Mode: mode,
+		Status: models.DeletionStatusPending, RequestedAt: "2025-06-01T00:00:00Z",
+	})
+	return f
+}
+
+func (f deletionFixture) checkErased(t *testing.T, wantDeleted bool) {
+	t.Helper()
+
+	for _, record := range append(f.orbit.rows, f.championships.rows...) {
+		if record.Id == "other" {
+			if record.UserID != "u2" || record.Deleted || record.Anonymous {
+				t.Errorf("record of another user changed: %+v", record)
+			}
+			continue
+		}
+		if record.UserID != "" || record.Note != "" || !record.Anonymous || record.Deleted != wantDeleted {
+			t.Errorf("record %s not erased: %+v", record.Id, record)
+		}
+	}
+	for _, record := range f.orbitStore.records {
+		if record.Id != "other" && (record.UserID != "" || record.Deleted != wantDeleted) {
+			t.Errorf("record %s not erased in memory: %+v", record.Id, record)
+		}
+	}
+
+	if len(f.users.users) != 1 || f.users.users[0].ID != "u2" {
+		t.Errorf("unexpected users left: %+v", f.users.users)
+	}
+	if _, ok := f.server.userStore.Get("u1"); ok {
+		t.Error("user still in memory")
+	}
+	if len(f.identities.identities) != 1 || f.identities.identities[0].UserID != "u2" {
+		t.Errorf("unexpected identities left: %+v", f.identities.identities)
+	}
+}
+
+func TestProcessPendingDeletions(t *testing.T) {
+	for _, mode := range []string{models.DeletionModeAnonymise, models.DeletionModeDelete} {
+		t.Run(mode, func(t *testing.T) {
+			f := newDeletionFixture(mode)
+			f.server.processPendingDeletions()
+
+			f.checkErased(t, mode == models.DeletionModeDelete)
+
+			request, _ := f.requests.GetLatestByPublicID(f.server.pseudonymizer.PublicID("u1"))
+			if request.Status != models.DeletionStatusCompleted || request.UserID != "" || request.Error != "" {
+				t.Fatalf("unexpected request %+v", request)
+			}
+			if request.OrbitRecords != 3 || request.ChampionshipsRecords != 1 {
+				t.Errorf("unexpected counts %d/%d", request.OrbitRecords, request.ChampionshipsRecords)
+			}
+			if request.Receipt == "" || request.Receipt != request.GenerateReceipt() {
+				t.Errorf("receipt does not match the request: %q", request.Receipt)
+			}
+			if request.TokensRevokedAt == "" {
+				t.Error("receipt does not record the token revocation")
+			}
+		})
+	}
+}
+
+func TestProcessPendingDeletionsRevokesTokens(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	f := newDeletionFixture(models.DeletionModeAnonymise)
+	pair, err := f.server.auth.IssueTokens("u1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	other, _ := f.server.auth.IssueTokens("u2")
+
+	authenticate := func(token string) int {
+		w := httptest.NewRecorder()
+		c, _ := gin.CreateTestContext(w)
+		c.Request = httptest.NewRequest(http.MethodGet, "/user", nil)
+		c.Request.Header.Set("Authorization", "Bearer "+token)
+		f.server.RequireAuth()(c)
+		return w.Code
+	}
+	if code := authenticate(pair.AccessToken); code != http.StatusOK {
+		t.Fatalf("token rejected before the deletion: %d", code)
+	}
+
+	f.server.processPendingDeletions()
+
+	if code := authenticate(pair.AccessToken); code != http.StatusUnauthorized {
+		t.Errorf("access token issued before the deletion returned %d", code)
+	}
+	if _, _, err := f.server.auth.Refresh(pair.RefreshToken); err == nil {
+		t.Error("refresh token issued before the deletion still works")
+	}
+	if code := authenticate(other.AccessToken); code != http.StatusOK {
+		t.Errorf("token of another user returned %d", code)
+	}
+}
+
+func TestProcessPendingDeletionsResumes(t *testing.T) {
+	f := newDeletionFixture(models.DeletionModeDelete)
+
+	// 写入两条之后表格配额用尽
+	f.orbit.failAfter = 2
+	f.server.processPendingDeletions()
+
+	pending := f.requests.GetPending()
+	if len(pending) != 1 || pending[0].UserID != "u1" || pending[0].Error == "" || pending[0].OrbitRecords != 2 {
+		t.Fatalf("expected the request to stay pending with its progress, got %+v", pending)
+	}
+	if len(f.users.users) != 2 || len(f.identities.identities) != 3 {
+		t.Error("user erased before all the records")
+	}
+
+	f.orbit.failAfter = 0
+	f.server.processPendingDeletions()
+
+	f.checkErased(t, true)
+	request, _ := f.requests.GetLatestByPublicID(f.server.pseudonymizer.PublicID("u1"))
+	if request.Status != models.DeletionStatusCompleted || request.Error != "" {
+		t.Fatalf("request not completed after the retry: %+v", request)
+	}
+	// 回执里的数量包括中断之前处理的记录
+	if request.OrbitRecords != 3 || request.ChampionshipsRecords != 1 || request.Receipt != request.GenerateReceipt() {
+		t.Errorf("unexpected receipt after the retry: %+v", request)
+	}
+	if f.orbit.writes != 3 {
+		t.Errorf("expected each record to be written once, got %d writes", f.orbit.writes)
+	}
+}
@@ -1,62 +1,139 @@
 package usecases
 
 import (
+	"math"
 	"net/http"
+	"strconv"
 
 	"github.com/gin-gonic/gin"
 	"github.com/sirupsen/logrus"
 	"lysk-battle-record/internal/estimator"
 	"lysk-battle-record/internal/models"
-	"lysk-battle-record/internal/pkg"
+)
+
+const (
+	maxSimulationRuns = 10000
+	maxFightDuration  = 600
 )
 
 type AnalyzeResponse struct {
 	CombatPower models.CombatPower `json:"combat_power"`
+	Cards       []CardImpact       `json:"cards,omitempty"` // 填写卡组时每张卡的贡献
+}
+
+// CardImpact is what a single card of the loadout adds to the buffed score.
+type CardImpact struct {
+	Index        int     `json:"index"`
+	Type         string  `json:"type"`
+	BuffedScore  int     `json:"buffed_score"` // 去掉该卡后的战力
+	Contribution float64 `json:"contribution"` // 该卡贡献的战力占比%
+}
+
+// analyzeOptions picks the estimator of an analysis. The default is the versioned estimator at
+// its current version.
+type analyzeOptions struct {
+	version   string
+	estimator string  // montecarlo / timeline
+	runs      int     // 模拟次数，仅 montecarlo
+	duration  float64 // 战斗时长（秒），仅 timeline
 }
 
 func (s *LyskServer) AnalyzeCombatPower(c *gin.Context) {
 	var input map[string]interface{}
 	if err := c.BindJSON(&input); err != nil {
 		logrus.Errorf("[Analysis] Failed to bind JSON: %v", err)
-		c.JSON(http.StatusBadRequest, gin.H{"error": "请求格式错误", "detail": err.Error()})
+		respondErrorDetail(c, http.StatusBadRequest, "malformed_request", err)
+		return
+	}
+
+	parsed, apiErr := readRecordInput(input)
+	if apiErr != nil {
+		apiErr.respondV1(c)
 		return
 	}
 
-	record := models.Record{}
-	record.LevelType = pkg.GetValue(input, "关卡")
-	record.LevelNumber = pkg.GetValue(input, "关数")
-	record.LevelMode = pkg.GetValue(input, "模式")
-	record.Attack = pkg.GetValue(input, "攻击")
-	record.HP = pkg.GetValue(input, "生命")
-	record.Defense = pkg.GetValue(input, "防御")
-	record.Matching = pkg.GetValue(input, "对谱")
-	record.MatchingBuff = pkg.GetValue(input, "对谱加成")
-	record.CritRate = pkg.GetValue(input, "暴击")
-	record.CritDmg = pkg.GetValue(input, "暴伤")
-	record.EnergyRegen = pkg.GetValue(input, "加速回能")
-	record.WeakenBoost = pkg.GetValue(input, "虚弱增伤")
-	record.OathBoost = pkg.GetValue(input, "誓约增伤")
-	record.OathRegen = pkg.GetValue(input, "誓约回能")
-	record.Companion = pkg.GetValue(input, "搭档身份")
-	record.SetCard = pkg.GetValue(input, "日卡")
-	record.Stage = pkg.GetValue(input, "阶数")
-	record.Weapon = pkg.GetValue(input, "武器")
-	record.Buff = pkg.GetValue(input, "加成")
-	record.TotalLevel = pkg.GetValue(input, "卡总等级")
-	record.StarRank = pkg.GetValue(input, "星级")
-
-	cpEstimator := estimator.NewCombatPowerEstimator()
+	runs, _ := strconv.Atoi(c.Query("runs"))
+	duration, _ := strconv.ParseFloat(c.Query("duration"), 64)
+	response, apiErr := s.analyze(parsed.record, analyzeOptions{
+		version:   c.Query("version"),
+		estimator: c.Query("estimator"),
+		runs:      runs,
+		duration:  duration,
+	})
+	if apiErr != nil {
+		apiErr.respondV1(c)
+		return
+	}
+
+	c.JSON(http.StatusOK, response)
+}
+
+// analyze estimates the combat power of a record that is not stored.
+func (s *LyskServer) analyze(record models.Record, options analyzeOptions) (AnalyzeResponse, *apiError) {
+	record.Note, record.Time = "", ""
+	record.ApplyLoadout()
+
+	record.EstimatorVersion = s.cpEstimator.Current()
+	if options.version != "" {
+		if _, ok := s.cpEstimator.Get(options.version); !ok {
+			return AnalyzeResponse{}, newAPIError(http.StatusBadRequest, "unknown_estimator_version", nil)
+		}
+		record.EstimatorVersion = options.version
+	}
+
+	var cpEstimator estimator.CombatPowerEstimator = s.cpEstimator
+	switch options.estimator {
+	case "montecarlo":
+		cpEstimator = estimator.NewMonteCarloEstimator(min(options.runs, maxSimulationRuns))
+	case "timeline":
+		cpEstimator = estimator.NewTimelineEstimator(min(options.duration, maxFightDuration))
+	}
 	combatPower := cpEstimator.EstimateCombatPower(record)
 
 	if record.LevelType != "" && record.LevelNumber != "" {
 		record.CombatPower = combatPower
 		evaluation := s.orbitRecordStore.EvaluateRecord(record)
 		combatPower.Evaluation = evaluation
+
+		// the score stays against the reference enemy so it can be compared with other records
+		combatPower.Level = estimator.EstimateForLevel(cpEstimator, record).Level
 	}
 
-	response := AnalyzeResponse{
+	return AnalyzeResponse{
 		CombatPower: combatPower,
+		Cards:       s.cardImpacts(record),
+	}, nil
+}
+
+// cardImpacts scores the record without each card in turn. It always uses the versioned
+// estimator, since a simulation per card would be too slow.
+func (s *LyskServer) cardImpacts(record models.Record) []CardImpact {
+	if record.Loadout == nil {
+		return nil
 	}
 
-	c.JSON(http.StatusOK, response)
+	full, err := strconv.Atoi(s.cpEstimator.EstimateCombatPower(record).BuffedScore)
+	if err != nil || full <= 0 {
+		return nil
+	}
+
+	impacts := make([]CardImpact, 0, len(record.Loadout.Cards))
+	for i, card := range record.Loadout.Cards {
+		loadout := record.Loadout.Without(i)
+		without := record
+		without.Loadout = &loadout
+		without.Attack, without.HP, without.Defense = "", "", ""
+		without.CritRate, without.CritDmg, without.EnergyRegen = "", "", ""
+		without.WeakenBoost, without.OathBoost, without.OathRegen = "", "", ""
+		without.ApplyLoadout()
+
+		score, _ := strconv.Atoi(s.cpEstimator.EstimateCombatPower(without).BuffedScore)
+		impacts = append(impacts, CardImpact{
+			Index:        i,
+			Type:         card.Type,
+			BuffedScore:  score,
+			Contribution: math.Round(float64(full-score)/float64(full)*10000) / 100,
+		})
+	}
+	return impacts
 }
@@ -0,0 +1,362 @@
+package usecases
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"github.com/sirupsen/logrus"
+
+	"lysk-battle-record/internal/models"
+	"lysk-battle-record/internal/pkg"
+)
+
+type providerLoginRequest struct {
+	Provider    string            `json:"provider"`
+	Credentials map[string]string `json:"credentials"`
+}
+
+type emailRequest struct {
+	Email    string `json:"email"`
+	Password string `json:"password"`
+}
+
+type refreshTokenRequest struct {
+	RefreshToken string `json:"refresh_token"`
+}
+
+// ProviderLogin logs in with any registered provider and returns an access and refresh token.
+func (s *LyskServer) ProviderLogin(c *gin.Context) {
+	var req providerLoginRequest
+	if err := c.BindJSON(&req); err != nil {
+		respondError(c, http.StatusBadRequest, "invalid_request")
+		return
+	}
+
+	external, err := s.authenticateWithProvider(req)
+	if err != nil {
+		logrus.Warnf("[Auth] %s login failed: %v", req.Provider, err)
+		c.JSON(http.StatusUnauthorized, gin.H{"error": err.Error()})
+		return
+	}
+
+	userID, err := s.resolveUserID(c.Request.Context(), external)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	s.respondWithTokens(c, userID)
+}
+
+// RegisterEmail creates an email identity with a password and mails a magic link to the address.
+// The password only works once the identity is confirmed by logging in through that link, so
+// nobody can claim an address they cannot read. No tokens are issued here.
+func (s *LyskServer) RegisterEmail(c *gin.Context) {
+	var req emailRequest
+	if err := c.BindJSON(&req); err != nil {
+		respondError(c, http.StatusBadRequest, "invalid_request")
+		return
+	}
+
+	email := pkg.NormalizeEmail(req.Email)
+	if email == "" {
+		respondError(c, http.StatusBadRequest, "invalid_email")
+		return
+	}
+
+	sender, ok := s.magicLinkSender()
+	if !ok {
+		respondError(c, http.StatusBadRequest, "unsupported_login_method")
+		return
+	}
+
+	passwordHash, err := pkg.HashPassword(req.Password)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	s.identityMutex.Lock()
+	defer s.identityMutex.Unlock()
+
+	if _, exists := s.identityStore.Get(pkg.ProviderEmail, email); exists {
+		respondError(c, http.StatusConflict, "email_registered")
+		return
+	}
+
+	if _, err := s.createIdentity(c.Request.Context(), models.Identity{
+		Provider:     pkg.ProviderEmail,
+		Subject:      email,
+		UserID:       uuid.New().String(),
+		PasswordHash: passwordHash,
+		Unverified:   true,
+	}); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	if err := sender.SendMagicLink(email); err != nil {
+		logrus.Errorf("[Auth] Failed to send the confirmation link for a new email identity: %v", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusAccepted, gin.H{"status": "verification_sent"})
+}
+
+func (s *LyskServer) SendMagicLink(c *gin.Context) {
+	var req emailRequest
+	if err := c.BindJSON(&req); err != nil {
+		respondError(c, http.StatusBadRequest, "invalid_request")
+		return
+	}
+
+	sender, ok := s.magicLinkSender()
+	if !ok {
+		respondError(c, http.StatusBadRequest, "unsupported_login_method")
+		return
+	}
+
+	if err := sender.SendMagicLink(req.Email); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"status": "OK"})
+}
+
+func (s *LyskServer) magicLinkSender() (interface{ SendMagicLink(email string) error }, bool) {
+	provider, ok := s.auth.Provider(pkg.ProviderEmail)
+	sender, canSend := provider.(interface{ SendMagicLink(email string) error })
+	return sender, ok && canSend
+}
+
+func (s *LyskServer) RefreshToken(c *gin.Context) {
+	var req refreshTokenRequest
+	if err := c.BindJSON(&req); err != nil {
+		respondError(c, http.StatusBadRequest, "invalid_request")
+		return
+	}
+
+	_, pair, err := s.auth.Refresh(req.RefreshToken)
+	if err != nil {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, pair)
+}
+
+func (s *LyskServer) Logout(c *gin.Context) {
+	var req refreshTokenRequest
+	if err := c.BindJSON(&req); err != nil {
+		respondError(c, http.StatusBadRequest, "invalid_request")
+		return
+	}
+
+	if err := s.auth.Revoke(req.RefreshToken); err != nil {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": err.Error()})
+		return
+	}
+
+	// the access token stops working right away instead of when it expires
+	if accessToken, err := bearerToken(c.GetHeader("Authorization")); err == nil {
+		if err := s.auth.Revoke(accessToken); err != nil {
+			logrus.Warnf("[Auth] Failed to revoke access token on logout: %v", err)
+		}
+	}
+
+	c.JSON(http.StatusOK, gin.H{"status": "OK"})
+}
+
+type banRequest struct {
+	Reason string `json:"reason"`
+}
+
+// BanUser blocks every token of the user, including the ones already issued.
+func (s *LyskServer) BanUser(c *gin.Context) {
+	userId, _ := c.Get("userID")
+	if !s.auth.IsAdmin(userId.(string)) {
+		respondError(c, http.StatusForbidden, "forbidden")
+		return
+	}
+
+	var req banRequest
+	if err := c.ShouldBindJSON(&req); err != nil && !errors.Is(err, io.EOF) {
+		respondError(c, http.StatusBadRequest, "invalid_request")
+		return
+	}
+
+	if err := s.auth.Ban(c.Param("id"), req.Reason); err != nil {
+		logrus.Errorf("[Auth] Failed to ban user %s: %v", c.Param("id"), err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"status": "OK"})
+}
+
+func (s *LyskServer) UnbanUser(c *gin.Context) {
+	userId, _ := c.Get("userID")
+	if !s.auth.IsAdmin(userId.(string)) {
+		respondError(c, http.StatusForbidden, "forbidden")
+		return
+	}
+
+	if err := s.auth.Unban(c.Param("id")); err != nil {
+		logrus.Errorf("[Auth] Failed to unban user %s: %v", c.Param("id"), err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"status": "OK"})
+}
+
+// LinkIdentity attaches another login method to the current user.
+func (s *LyskServer) LinkIdentity(c *gin.Context) {
+	userId, exists := c.Get("userID")
+	if !exists {
+		respondError(c, http.StatusUnauthorized, "unauthenticated_user")
+		return
+	}
+
+	var req providerLoginRequest
+	if err := c.BindJSON(&req); err != nil {
+		respondError(c, http.StatusBadRequest, "invalid_request")
+		return
+	}
+
+	external, err := s.authenticateWithProvider(req)
+	if err != nil {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": err.Error()})
+		return
+	}
+
+	s.identityMutex.Lock()
+	defer s.identityMutex.Unlock()
+
+	if identity, exists := s.identityStore.Get(external.Provider, external.Subject); exists {
+		if identity.UserID != userId.(string) {
+			respondError(c, http.StatusConflict, "account_already_linked")
+			return
+		}
+		c.JSON(http.StatusOK, identity)
+		return
+	}
+
+	// 微信用户的 openid 即用户ID，已有数据的微信账号不能再关联到其他用户
+	if external.Provider == pkg.ProviderWeChat && external.Subject != userId.(string) {
+		if _, ok := s.userStore.Get(external.Subject); ok {
+			respondError(c, http.StatusConflict, "account_already_linked")
+			return
+		}
+	}
+
+	identity, err := s.createIdentity(c.Request.Context(), models.Identity{
+		Provider: external.Provider,
+		Subject:  external.Subject,
+		UserID:   userId.(string),
+	})
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, identity)
+}
+
+func (s *LyskServer) GetIdentities(c *gin.Context) {
+	userId, exists := c.Get("userID")
+	if !exists {
+		respondError(c, http.StatusUnauthorized, "unauthenticated_user")
+		return
+	}
+
+	c.JSON(http.StatusOK, s.identityStore.GetByUser(userId.(string)))
+}
+
+func (s *LyskServer) authenticateWithProvider(req providerLoginRequest) (pkg.ExternalIdentity, error) {
+	provider, ok := s.auth.Provider(req.Provider)
+	if !ok {
+		return pkg.ExternalIdentity{}, pkg.ErrInvalidCredentials
+	}
+
+	return provider.Authenticate(req.Credentials)
+}
+
+// resolveUserID finds the user behind an external identity, creating one on first login.
+func (s *LyskServer) resolveUserID(ctx context.Context, external pkg.ExternalIdentity) (string, error) {
+	s.identityMutex.Lock()
+	defer s.identityMutex.Unlock()
+
+	if identity, ok := s.identityStore.Get(external.Provider, external.Subject); ok {
+		// the password of an unverified identity is rejected, so this login came through a magic
+		// link mailed to the address and confirms it
+		if identity.Unverified {
+			if err := s.verifyIdentity(ctx, identity); err != nil {
+				return "", err
+			}
+		}
+		return identity.UserID, nil
+	}
+
+	// 微信用户沿用 openid 作为用户ID
+	if external.Provider == pkg.ProviderWeChat {
+		return external.Subject, nil
+	}
+
+	identity, err := s.createIdentity(ctx, models.Identity{
+		Provider: external.Provider,
+		Subject:  external.Subject,
+		UserID:   uuid.New().String(),
+	})
+	if err != nil {
+		return "", err
+	}
+
+	return identity.UserID, nil
+}
+
+func (s *LyskServer) createIdentity(ctx context.Context, identity models.Identity) (*models.Identity, error) {
+	identity.LinkedAt = time.Now().Format(time.RFC3339)
+
+	createdIdentity, err := s.identitySheetClient.ProcessIdentity(ctx, identity)
+	if err != nil {
+		logrus.Errorf("[Auth] Failed to write identity to Google Sheet: %v", err)
+		return nil, err
+	}
+
+	s.identityStore.Insert(*createdIdentity)
+	return createdIdentity, nil
+}
+
+func (s *LyskServer) verifyIdentity(ctx context.Context, identity models.Identity) error {
+	identity.Unverified = false
+
+	if err := s.identitySheetClient.UpdateIdentity(ctx, identity); err != nil {
+		logrus.Errorf("[Auth] Failed to update identity in Google Sheet: %v", err)
+		return err
+	}
+
+	return s.identityStore.Update(identity)
+}
+
+func (s *LyskServer) respondWithTokens(c *gin.Context, userID string) {
+	if err := s.createUserIfNotExist(c.Request.Context(), userID); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	pair, err := s.auth.IssueTokens(userID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, pair)
+}
@@ -0,0 +1,140 @@
+package usecases
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+
+	"lysk-battle-record/internal/models"
+	"lysk-battle-record/internal/pkg"
+)
+
+type recordingMailer struct {
+	links []string
+}
+
+func (m *recordingMailer) SendMagicLink(_, link string) error {
+	m.links = append(m.links, link)
+	return nil
+}
+
+func newAuthServer() (*LyskServer, *fakeIdentitySheetClient, *recordingMailer) {
+	identities := &fakeIdentitySheetClient{}
+	mailer := &recordingMailer{}
+	auth := pkg.NewAuthenticator(pkg.AuthOptions{JWTSecret: "secret"})
+	auth.RegisterProvider(pkg.NewEmailProvider(identities.GetPasswordHash, mailer, "https://example.com/login"))
+
+	users := &fakeUserSheetClient{}
+	return &LyskServer{
+		auth:                auth,
+		identityStore:       identities,
+		identitySheetClient: identities,
+		userStore:           newFakeUserStore(),
+		userSheetClient:     users,
+	}, identities, mailer
+}
+
+func postJSON(handler gin.HandlerFunc, body interface{}) *httptest.ResponseRecorder {
+	payload, _ := json.Marshal(body)
+	w := httptest.NewRecorder()
+	c, _ := gin.CreateTestContext(w)
+	c.Request = httptest.NewRequest(http.MethodPost, "/", bytes.NewReader(payload))
+	handler(c)
+	return w
+}
+
+func emailLogin(s *LyskServer, credentials map[string]string) *httptest.ResponseRecorder {
+	return postJSON(s.ProviderLogin, providerLoginRequest{Provider: pkg.ProviderEmail, Credentials: credentials})
+}
+
+func TestRegisterEmailRequiresConfirmation(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	s, identities, mailer := newAuthServer()
+	password := map[string]string{"email": "a@example.com", "password": "correct horse"}
+
+	if w := postJSON(s.RegisterEmail, emailRequest{Email: "A@example.com", Password: "correct horse"}); w.Code != http.StatusAccepted {
+		t.Fatalf("register returned %d %s", w.Code, w.Body)
+	}
+	identity, ok := identities.Get(pkg.ProviderEmail, "a@example.com")
+	if !ok || !identity.Unverified || len(mailer.links) != 1 {
+		t.Fatalf("expected an unverified identity and one mail, got %+v %v", identity, mailer.links)
+	}
+
+	if w := emailLogin(s, password); w.Code != http.StatusUnauthorized {
+		t.Errorf("password accepted before confirmation: %d", w.Code)
+	}
+	if w := postJSON(s.RegisterEmail, emailRequest{Email: "a@example.com", Password: "another one"}); w.Code != http.StatusConflict {
+		t.Errorf("second registration returned %d", w.Code)
+	}
+
+	link, _ := url.Parse(mailer.links[0])
+	if w := emailLogin(s, map[string]string{"email": "a@example.com", "token": link.Query().Get("token")}); w.Code != http.StatusOK {
+		t.Fatalf("magic link login returned %d %s", w.Code, w.Body)
+	}
+	if identity, _ := identities.Get(pkg.ProviderEmail, "a@example.com"); identity.Unverified {
+		t.Error("magic link login did not confirm the identity")
+	}
+
+	w := emailLogin(s, password)
+	var pair pkg.TokenPair
+	json.Unmarshal(w.Body.Bytes(), &pair)
+	if userID, err := s.auth.ValidateJWT(pair.AccessToken); err != nil || userID != identity.UserID {
+		t.Errorf("password login after confirmation gave %q, %v, want %s", userID, err, identity.UserID)
+	}
+}
+
+func TestRegisterEmailRejectsLinkedAddress(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	s, identities, mailer := newAuthServer()
+	identities.identities = []models.Identity{{Provider: pkg.ProviderEmail, Subject: "a@example.com", UserID: "u1"}}
+
+	if w := postJSON(s.RegisterEmail, emailRequest{Email: "a@example.com", Password: "correct horse"}); w.Code != http.StatusConflict {
+		t.Errorf("register returned %d", w.Code)
+	}
+	if len(identities.identities) != 1 || len(mailer.links) != 0 {
+		t.Errorf("identity created or mail sent for a linked address: %+v %v", identities.identities, mailer.links)
+	}
+}
+
+// fakeWeChatProvider accepts any code and uses it as the openid.
+type fakeWeChatProvider struct{}
+
+func (fakeWeChatProvider) Name() string { return pkg.ProviderWeChat }
+
+func (fakeWeChatProvider) Authenticate(credentials map[string]string) (pkg.ExternalIdentity, error) {
+	return pkg.ExternalIdentity{Provider: pkg.ProviderWeChat, Subject: credentials["code"]}, nil
+}
+
+func TestLoginResolvesLinkedOpenID(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	s, identities, _ := newAuthServer()
+	s.auth.RegisterProvider(fakeWeChatProvider{})
+	identities.identities = []models.Identity{{Provider: pkg.ProviderWeChat, Subject: "openid-1", UserID: "u1"}}
+
+	tests := []struct {
+		code string
+		want string
+	}{
+		{"openid-1", "u1"},
+		// 未关联的 openid 仍然是用户ID
+		{"openid-2", "openid-2"},
+	}
+	for _, tt := range tests {
+		w := postJSON(s.Login, map[string]string{"code": tt.code})
+		var response struct {
+			Token string `json:"token"`
+		}
+		json.Unmarshal(w.Body.Bytes(), &response)
+		if userID, err := s.auth.ValidateJWT(response.Token); err != nil || userID != tt.want {
+			t.Errorf("login with %s gave %q, %v, want %s", tt.code, userID, err, tt.want)
+		}
+		if _, ok := s.userStore.Get(tt.want); !ok {
+			t.Errorf("user %s was not created", tt.want)
+		}
+	}
+}
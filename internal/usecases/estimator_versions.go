@@ -0,0 +1,269 @@
+package usecases
+
+import (
+	"context"
+	"errors"
+	"io"
+	"math"
+	"net/http"
+	"sort"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+	"github.com/sirupsen/logrus"
+	"go.opentelemetry.io/otel/attribute"
+
+	"lysk-battle-record/internal/datastores"
+	"lysk-battle-record/internal/estimator"
+	"lysk-battle-record/internal/pkg"
+	"lysk-battle-record/internal/registry"
+	"lysk-battle-record/internal/sheet_clients"
+)
+
+// VersionShift describes how the buffed score of the records of a level moves from one
+// estimator version to another. Shifts are in percent.
+type VersionShift struct {
+	Level           string  `json:"level,omitempty"`
+	Records         int     `json:"records"`
+	MeanShift       float64 `json:"mean_shift"`
+	MedianShift     float64 `json:"median_shift"`
+	P10Shift        float64 `json:"p10_shift"`
+	P90Shift        float64 `json:"p90_shift"`
+	MaxShift        float64 `json:"max_shift"` // 变化最大的记录
+	SuggestedCPFrom int     `json:"suggested_cp_from"`
+	SuggestedCPTo   int     `json:"suggested_cp_to"`
+	Approximate     int     `json:"approximate"` // 搭档没有技能循环、分数只是近似的记录数
+}
+
+type VersionComparisonResponse struct {
+	From    string         `json:"from"`
+	To      string         `json:"to"`
+	Overall VersionShift   `json:"overall"`
+	Levels  []VersionShift `json:"levels"`
+}
+
+type migrationRequest struct {
+	From   string `json:"from"`
+	To     string `json:"to"`
+	DryRun bool   `json:"dry_run"`
+}
+
+type scorePair struct {
+	from, to    int
+	approximate bool
+}
+
+// CompareEstimatorVersions scores every record with both versions, regardless of the version
+// the record is stored with, and reports the shift per level.
+func (s *LyskServer) CompareEstimatorVersions(c *gin.Context) {
+	userId, _ := c.Get("userID")
+	if !s.auth.IsAdmin(userId.(string)) {
+		respondError(c, http.StatusForbidden, "forbidden")
+		return
+	}
+
+	from, to := c.DefaultQuery("from", estimator.LegacyVersion), c.DefaultQuery("to", s.cpEstimator.Current())
+	fromEstimator, fromOk := s.cpEstimator.Get(from)
+	toEstimator, toOk := s.cpEstimator.Get(to)
+	if !fromOk || !toOk {
+		c.JSON(http.StatusBadRequest, errorResponse(c, "unknown_estimator_version", gin.H{"versions": s.cpEstimator.Versions()}))
+		return
+	}
+
+	levelScores := map[string][]scorePair{}
+	var all []scorePair
+	for _, store := range []datastores.RecordStore{s.orbitRecordStore, s.championshipsRecordStore} {
+		for _, record := range store.GetAll() {
+			if record.Deleted {
+				continue
+			}
+
+			fromScore, err := strconv.Atoi(fromEstimator.EstimateCombatPower(record).BuffedScore)
+			if err != nil || fromScore <= 0 {
+				continue
+			}
+			toPower := toEstimator.EstimateCombatPower(record)
+			toScore, err := strconv.Atoi(toPower.BuffedScore)
+			if err != nil {
+				continue
+			}
+
+			pair := scorePair{from: fromScore, to: toScore, approximate: toPower.Approximate}
+			levelKey := record.GenerateLevelKey()
+			levelScores[levelKey] = append(levelScores[levelKey], pair)
+			all = append(all, pair)
+		}
+	}
+
+	response := VersionComparisonResponse{
+		From:    from,
+		To:      to,
+		Overall: s.versionShift(all),
+		Levels:  make([]VersionShift, 0, len(levelScores)),
+	}
+	for levelKey, pairs := range levelScores {
+		shift := s.versionShift(pairs)
+		shift.Level = formatLevelName(levelKey)
+		response.Levels = append(response.Levels, shift)
+	}
+
+	// 变化最大的关卡排在前面
+	sort.Slice(response.Levels, func(i, j int) bool {
+		return math.Abs(response.Levels[i].MedianShift) > math.Abs(response.Levels[j].MedianShift)
+	})
+
+	c.JSON(http.StatusOK, response)
+}
+
+func (s *LyskServer) versionShift(pairs []scorePair) VersionShift {
+	shift := VersionShift{Records: len(pairs)}
+	if len(pairs) == 0 {
+		return shift
+	}
+
+	shifts := make([]float64, len(pairs))
+	fromCPs := make([]int, len(pairs))
+	toCPs := make([]int, len(pairs))
+	var sum float64
+	for i, pair := range pairs {
+		shifts[i] = float64(pair.to-pair.from) / float64(pair.from) * 100
+		fromCPs[i], toCPs[i] = pair.from, pair.to
+		sum += shifts[i]
+		if pair.approximate {
+			shift.Approximate++
+		}
+		if math.Abs(shifts[i]) > math.Abs(shift.MaxShift) {
+			shift.MaxShift = shifts[i]
+		}
+	}
+	sort.Float64s(shifts)
+
+	shift.MeanShift = roundShift(sum / float64(len(shifts)))
+	shift.MedianShift = roundShift(shifts[len(shifts)/2])
+	shift.P10Shift = roundShift(shifts[int(float64(len(shifts)-1)*0.1)])
+	shift.P90Shift = roundShift(shifts[int(float64(len(shifts)-1)*0.9)])
+	shift.MaxShift = roundShift(shift.MaxShift)
+	shift.SuggestedCPFrom = s.GetSuggestedCP(fromCPs)
+	shift.SuggestedCPTo = s.GetSuggestedCP(toCPs)
+	return shift
+}
+
+func roundShift(v float64) float64 {
+	return math.Round(v*100) / 100
+}
+
+// MigrateEstimatorVersion moves the records stored with one version to another. Apart from
+// the estimator parameter file, this is the only way the score of a stored record changes, so
+// it is an explicit admin action.
+func (s *LyskServer) MigrateEstimatorVersion(c *gin.Context) {
+	userId, _ := c.Get("userID")
+	if !s.auth.IsAdmin(userId.(string)) {
+		respondError(c, http.StatusForbidden, "forbidden")
+		return
+	}
+
+	var req migrationRequest
+	if err := c.ShouldBindJSON(&req); err != nil && !errors.Is(err, io.EOF) {
+		respondError(c, http.StatusBadRequest, "invalid_request")
+		return
+	}
+
+	_, fromOk := s.cpEstimator.Get(req.From)
+	_, toOk := s.cpEstimator.Get(req.To)
+	if !fromOk || !toOk || req.From == req.To {
+		c.JSON(http.StatusBadRequest, errorResponse(c, "unknown_estimator_version", gin.H{"versions": s.cpEstimator.Versions()}))
+		return
+	}
+
+	count := 0
+	for _, store := range []datastores.RecordStore{s.orbitRecordStore, s.championshipsRecordStore} {
+		for _, record := range store.GetAll() {
+			if !record.Deleted && estimator.VersionOf(record) == req.From {
+				count++
+			}
+		}
+	}
+
+	if req.DryRun {
+		c.JSON(http.StatusOK, gin.H{"status": "dry_run", "records": count})
+		return
+	}
+
+	if !s.migrationMutex.TryLock() {
+		respondError(c, http.StatusConflict, "migration_in_progress")
+		return
+	}
+
+	// rewriting every row takes longer than a request may, so the migration runs in the background
+	go func() {
+		defer s.migrationMutex.Unlock()
+
+		ctx, span := pkg.StartSpan(context.Background(), "estimator.migrate",
+			attribute.String("estimator.from", req.From), attribute.String("estimator.to", req.To))
+		defer span.End()
+
+		migrated := 0
+		for _, target := range []struct {
+			sheetClient sheet_clients.RecordSheetClient
+			store       datastores.RecordStore
+		}{
+			{s.orbitSheetClient, s.orbitRecordStore},
+			{s.championshipsSheetClient, s.championshipsRecordStore},
+		} {
+			n, err := migrateRecords(ctx, target.sheetClient, target.store, req.From, req.To)
+			migrated += n
+			if err != nil {
+				logrus.Errorf("[Estimator] Migration of sheet %s from %s to %s stopped after %d records: %v", target.sheetClient.GetType(), req.From, req.To, migrated, err)
+				return
+			}
+		}
+		logrus.Infof("[Estimator] Migrated %d records from %s to %s", migrated, req.From, req.To)
+	}()
+
+	c.JSON(http.StatusAccepted, gin.H{"status": "started", "records": count})
+}
+
+// migrateRecords uses the sheet as the source of truth, so rows that are not in memory yet are
+// migrated as well. Running it again after a failure continues where it stopped. Only the version
+// cell is written, so edits and deletions made while it runs are kept.
+func migrateRecords(ctx context.Context, sheetClient sheet_clients.RecordSheetClient, store datastores.RecordStore, from, to string) (int, error) {
+	records, err := sheetClient.FetchAllSheetData(ctx)
+	if err != nil {
+		return 0, err
+	}
+
+	count := 0
+	for _, record := range records {
+		if record.Deleted || estimator.VersionOf(record) != from {
+			continue
+		}
+
+		if err := sheetClient.UpdateEstimatorVersion(ctx, record, to); err != nil {
+			return count, err
+		}
+		// 用内存中最新的记录，而不是迁移开始时读到的；两者仍不一致时下次刷新会以表格为准
+		if current, ok := store.Get(record.Id); ok && !current.Deleted {
+			current.EstimatorVersion = to
+			if err := store.Update(current); err != nil {
+				logrus.Errorf("[Estimator] Failed to update record %s in memory: %v", record.Id, err)
+			}
+		}
+		count++
+	}
+
+	return count, nil
+}
+
+// LoadEstimatorParams loads the estimator parameter file, if any, and makes its overrides active.
+func LoadEstimatorParams(path string) error {
+	if path == "" {
+		return nil
+	}
+	params, err := registry.LoadParams(path)
+	if err != nil {
+		return err
+	}
+	registry.SetParams(params)
+	logrus.Infof("[Estimator] Loaded parameter overrides from %s", path)
+	return nil
+}
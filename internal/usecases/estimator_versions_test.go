@@ -0,0 +1,149 @@
+package usecases
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+
+	"lysk-battle-record/internal/estimator"
+	"lysk-battle-record/internal/models"
+	"lysk-battle-record/internal/pkg"
+	"lysk-battle-record/internal/registry"
+)
+
+func testRecord(id string) models.Record {
+	return models.Record{
+		Id: id, LevelType: "光", LevelNumber: "120", LevelMode: "稳定", UserID: "u1",
+		Attack: "11200", HP: "50000", Defense: "2000", Matching: "顺", MatchingBuff: "30",
+		CritRate: "60", CritDmg: "200", EnergyRegen: "10.8", WeakenBoost: "50",
+		OathBoost: "40", OathRegen: "17", TotalLevel: "600",
+		Companion: "潮汐之神", SetCard: "神殿", Stage: "IV", Weapon: "专武", Buff: "0",
+	}
+}
+
+func TestMigrateRecords(t *testing.T) {
+	migrated := testRecord("c")
+	migrated.EstimatorVersion = estimator.VersionV2
+	sheet := newFakeRecordSheetClient(testRecord("a"), testRecord("b"), migrated)
+	store := &fakeRecordStore{records: append([]models.Record(nil), sheet.rows...)}
+
+	// 迁移读到表格之后，a 被删除、b 被编辑
+	sheet.afterFetch = func() {
+		sheet.rows[0].Deleted = true
+		sheet.rows[1].Attack = "12000"
+		store.records[0].Deleted = true
+		store.records[1].Attack = "12000"
+	}
+
+	count, err := migrateRecords(context.Background(), sheet, store, estimator.VersionV1, estimator.VersionV2)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if count != 2 || sheet.writes != 2 {
+		t.Errorf("expected 2 records migrated, got %d in %d writes", count, sheet.writes)
+	}
+
+	if !sheet.rows[0].Deleted || !store.records[0].Deleted {
+		t.Error("deletion made during the migration was reverted")
+	}
+	for _, record := range []models.Record{sheet.rows[1], store.records[1]} {
+		if record.Attack != "12000" || record.EstimatorVersion != estimator.VersionV2 {
+			t.Errorf("edit made during the migration was lost: %+v", record)
+		}
+	}
+}
+
+func TestCompareEstimatorVersions(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	deleted := testRecord("c")
+	deleted.Deleted = true
+	// 无尽掠夺者还没有技能循环
+	unported := testRecord("b")
+	unported.Companion, unported.SetCard = "无尽掠夺者", "掠心"
+	s := &LyskServer{
+		orbitRecordStore:         &fakeRecordStore{records: []models.Record{testRecord("a"), unported, deleted}},
+		championshipsRecordStore: &fakeRecordStore{},
+		auth:                     pkg.NewAuthenticator(pkg.AuthOptions{AdminUserIDs: []string{"admin"}}),
+		cpEstimator:              estimator.NewVersionedEstimator(""),
+	}
+
+	compare := func(userID string) *httptest.ResponseRecorder {
+		w := httptest.NewRecorder()
+		c, _ := gin.CreateTestContext(w)
+		c.Request = httptest.NewRequest(http.MethodGet, "/admin/estimator/compare?from=v1&to=v2", nil)
+		c.Set("userID", userID)
+		s.CompareEstimatorVersions(c)
+		return w
+	}
+
+	if w := compare("u1"); w.Code != http.StatusForbidden {
+		t.Errorf("non-admin got %d", w.Code)
+	}
+
+	w := compare("admin")
+	var response VersionComparisonResponse
+	if err := json.Unmarshal(w.Body.Bytes(), &response); err != nil {
+		t.Fatal(err)
+	}
+	if w.Code != http.StatusOK || response.Overall.Records != 2 || len(response.Levels) != 1 {
+		t.Fatalf("unexpected comparison %d %+v", w.Code, response)
+	}
+	if response.Levels[0].SuggestedCPFrom == 0 || response.Levels[0].SuggestedCPTo == 0 {
+		t.Errorf("missing suggested CP: %+v", response.Levels[0])
+	}
+	if response.Overall.Approximate != 1 || response.Levels[0].Approximate != 1 {
+		t.Errorf("expected one approximate record, got %+v", response.Overall)
+	}
+}
+
+func TestLoadEstimatorParams(t *testing.T) {
+	t.Cleanup(func() { registry.SetParams(registry.ParamFile{}) })
+	write := func(t *testing.T, content string) string {
+		path := filepath.Join(t.TempDir(), "params.json")
+		if err := os.WriteFile(path, []byte(content), 0o644); err != nil {
+			t.Fatal(err)
+		}
+		return path
+	}
+
+	if err := LoadEstimatorParams(""); err != nil {
+		t.Fatalf("empty path: %v", err)
+	}
+
+	path := write(t, `{"global":{"weaken_rate_forward":0.3},"companions":{"潮汐之神":{"weaken_rate_forward":0.4}}}`)
+	if err := LoadEstimatorParams(path); err != nil {
+		t.Fatal(err)
+	}
+	if got := registry.Param("遥远少年", registry.ParamWeakenRateForward); got != 0.3 {
+		t.Errorf("global override = %v, want 0.3", got)
+	}
+	if got := registry.Param("潮汐之神", registry.ParamWeakenRateForward); got != 0.4 {
+		t.Errorf("companion override = %v, want 0.4", got)
+	}
+
+	for name, tc := range map[string]struct{ content, want string }{
+		"unknown parameter": {`{"global":{"weaken_rate":0.3}}`, "unknown parameter weaken_rate"},
+		"unknown companion": {`{"companions":{"无名":{"weaken_rate_forward":0.3}}}`, "unknown companion 无名"},
+		"old name":          {`{"companions":{"花坛新锐":{"weaken_rate_forward":0.3}}}`, "use 画坛新锐"},
+		"negative value":    {`{"global":{"weaken_rate_forward":-0.3}}`, "invalid value -0.3"},
+		"unknown field":     {`{"global":{},"version":"v2"}`, "unknown field"},
+	} {
+		t.Run(name, func(t *testing.T) {
+			err := LoadEstimatorParams(write(t, tc.content))
+			if err == nil || !strings.Contains(err.Error(), tc.want) {
+				t.Fatalf("err = %v, want %q", err, tc.want)
+			}
+			if got := registry.Param("潮汐之神", registry.ParamWeakenRateForward); got != 0.4 {
+				t.Errorf("rejected file changed the overrides: %v", got)
+			}
+		})
+	}
+}
@@ -0,0 +1,335 @@
+package usecases
+
+import (
+	"context"
+	"errors"
+
+	"lysk-battle-record/internal/models"
+	"lysk-battle-record/internal/pkg"
+)
+
+var errQuotaExceeded = errors.New("quota exceeded")
+
+// fakeRecordSheetClient keeps the rows of a record sheet in memory.
+type fakeRecordSheetClient struct {
+	rows       []models.Record
+	writes     int
+	failAfter  int    // 大于 0 时，写入这么多次之后的写入都失败
+	afterFetch func() // 读取之后修改表格，模拟同时进行的编辑
+}
+
+func newFakeRecordSheetClient(records ...models.Record) *fakeRecordSheetClient {
+	c := &fakeRecordSheetClient{}
+	for _, record := range records {
+		record.RowNumber = len(c.rows) + 2
+		c.rows = append(c.rows, record)
+	}
+	return c
+}
+
+func (c *fakeRecordSheetClient) write() error {
+	if c.failAfter > 0 && c.writes >= c.failAfter {
+		return errQuotaExceeded
+	}
+	c.writes++
+	return nil
+}
+
+func (c *fakeRecordSheetClient) FetchAllSheetData(context.Context) ([]models.Record, error) {
+	rows := append([]models.Record(nil), c.rows...)
+	if c.afterFetch != nil {
+		c.afterFetch()
+		c.afterFetch = nil
+	}
+	return rows, nil
+}
+
+func (c *fakeRecordSheetClient) ProcessRecord(_ context.Context, record models.Record) (*models.Record, error) {
+	if err := c.write(); err != nil {
+		return nil, err
+	}
+	record.RowNumber = len(c.rows) + 2
+	c.rows = append(c.rows, record)
+	return &record, nil
+}
+
+func (c *fakeRecordSheetClient) UpdateRecord(_ context.Context, record models.Record) error {
+	if err := c.write(); err != nil {
+		return err
+	}
+	c.rows[record.RowNumber-2] = record
+	return nil
+}
+
+func (c *fakeRecordSheetClient) DeleteRecord(_ context.Context, record models.Record) error {
+	if err := c.write(); err != nil {
+		return err
+	}
+	c.rows[record.RowNumber-2].Deleted = true
+	return nil
+}
+
+func (c *fakeRecordSheetClient) UpdateEstimatorVersion(_ context.Context, record models.Record, version string) error {
+	if err := c.write(); err != nil {
+		return err
+	}
+	c.rows[record.RowNumber-2].EstimatorVersion = version
+	return nil
+}
+
+func (c *fakeRecordSheetClient) Ping(context.Context) error { return nil }
+func (c *fakeRecordSheetClient) GetType() string            { return "fake" }
+
+// fakeRecordStore is a record store without the indexes of the in-memory one.
+type fakeRecordStore struct {
+	MockRecordStore
+	records []models.Record
+}
+
+func (s *fakeRecordStore) GetAll() []models.Record {
+	return append([]models.Record(nil), s.records...)
+}
+
+func (s *fakeRecordStore) Get(id string) (models.Record, bool) {
+	for _, record := range s.records {
+		if record.Id == id {
+			return record, true
+		}
+	}
+	return models.Record{}, false
+}
+
+func (s *fakeRecordStore) GetLevelRecords(record models.Record) []models.Record {
+	return models.Records(s.records).Filter(func(r models.Record) bool {
+		return !r.Deleted && r.GenerateLevelKey() == record.GenerateLevelKey()
+	})
+}
+
+func (s *fakeRecordStore) GetAllLevelRecords() map[string][]models.Record {
+	levelRecords := map[string][]models.Record{}
+	for _, record := range s.records {
+		levelRecords[record.GenerateLevelKey()] = append(levelRecords[record.GenerateLevelKey()], record)
+	}
+	return levelRecords
+}
+
+func (s *fakeRecordStore) Update(record models.Record) error {
+	for i, r := range s.records {
+		if r.Id == record.Id {
+			if r.Deleted {
+				return errors.New("cannot update a deleted record")
+			}
+			s.records[i] = record
+			return nil
+		}
+	}
+	return errors.New("record not found")
+}
+
+func (s *fakeRecordStore) Delete(record models.Record) error {
+	for i, r := range s.records {
+		if r.Id == record.Id {
+			s.records[i].Deleted = true
+			return nil
+		}
+	}
+	return errors.New("record not found")
+}
+
+// fakeUserStore keeps users in a map by ID.
+type fakeUserStore struct {
+	users map[string]models.User
+}
+
+func newFakeUserStore(users ...models.User) *fakeUserStore {
+	s := &fakeUserStore{users: map[string]models.User{}}
+	for _, user := range users {
+		s.users[user.ID] = user
+	}
+	return s
+}
+
+func (s *fakeUserStore) Get(id string) (models.User, bool) {
+	user, ok := s.users[id]
+	return user, ok
+}
+
+func (s *fakeUserStore) Insert(user models.User) { s.users[user.ID] = user }
+
+func (s *fakeUserStore) Update(user models.User) error {
+	if _, ok := s.users[user.ID]; !ok {
+		return errors.New("user not found")
+	}
+	s.users[user.ID] = user
+	return nil
+}
+
+func (s *fakeUserStore) Delete(id string) error {
+	delete(s.users, id)
+	return nil
+}
+
+type fakeUserSheetClient struct {
+	users []models.User
+}
+
+func (c *fakeUserSheetClient) FetchAllSheetData(context.Context) ([]models.User, error) {
+	return append([]models.User(nil), c.users...), nil
+}
+
+func (c *fakeUserSheetClient) ProcessUser(_ context.Context, user models.User) (*models.User, error) {
+	c.users = append(c.users, user)
+	return &user, nil
+}
+
+func (c *fakeUserSheetClient) UpdateUser(_ context.Context, user models.User) error {
+	for i, u := range c.users {
+		if u.ID == user.ID {
+			c.users[i] = user
+		}
+	}
+	return nil
+}
+
+func (c *fakeUserSheetClient) DeleteUser(_ context.Context, user models.User) error {
+	for i, u := range c.users {
+		if u.ID == user.ID {
+			c.users = append(c.users[:i], c.users[i+1:]...)
+			break
+		}
+	}
+	return nil
+}
+
+func (c *fakeUserSheetClient) GetType() string { return "fake" }
+
+// fakeIdentitySheetClient doubles as the identity store, both hold the same identities.
+type fakeIdentitySheetClient struct {
+	identities []models.Identity
+}
+
+func (c *fakeIdentitySheetClient) FetchAllSheetData(context.Context) ([]models.Identity, error) {
+	return append([]models.Identity(nil), c.identities...), nil
+}
+
+func (c *fakeIdentitySheetClient) ProcessIdentity(_ context.Context, identity models.Identity) (*models.Identity, error) {
+	c.identities = append(c.identities, identity)
+	return &identity, nil
+}
+
+func (c *fakeIdentitySheetClient) UpdateIdentity(_ context.Context, identity models.Identity) error {
+	return c.Update(identity)
+}
+
+func (c *fakeIdentitySheetClient) DeleteIdentity(_ context.Context, identity models.Identity) error {
+	return c.Delete(identity)
+}
+
+func (c *fakeIdentitySheetClient) GetType() string { return "fake" }
+
+func (c *fakeIdentitySheetClient) Get(provider, subject string) (models.Identity, bool) {
+	for _, identity := range c.identities {
+		if identity.Provider == provider && identity.Subject == subject {
+			return identity, true
+		}
+	}
+	return models.Identity{}, false
+}
+
+func (c *fakeIdentitySheetClient) GetByUser(userId string) []models.Identity {
+	var identities []models.Identity
+	for _, identity := range c.identities {
+		if identity.UserID == userId {
+			identities = append(identities, identity)
+		}
+	}
+	return identities
+}
+
+func (c *fakeIdentitySheetClient) GetPasswordHash(email string) (string, bool) {
+	identity, ok := c.Get(pkg.ProviderEmail, email)
+	if !ok || identity.PasswordHash == "" || identity.Unverified {
+		return "", false
+	}
+	return identity.PasswordHash, true
+}
+
+// Insert does nothing, ProcessIdentity already added the identity.
+func (c *fakeIdentitySheetClient) Insert(models.Identity) {}
+
+func (c *fakeIdentitySheetClient) Update(identity models.Identity) error {
+	for i, id := range c.identities {
+		if id.Provider == identity.Provider && id.Subject == identity.Subject {
+			c.identities[i] = identity
+			return nil
+		}
+	}
+	return errors.New("identity not found")
+}
+
+func (c *fakeIdentitySheetClient) Delete(identity models.Identity) error {
+	for i, id := range c.identities {
+		if id.Provider == identity.Provider && id.Subject == identity.Subject {
+			c.identities = append(c.identities[:i], c.identities[i+1:]...)
+			return nil
+		}
+	}
+	return errors.New("identity not found")
+}
+
+// fakeDeletionSheetClient doubles as the deletion request store.
+type fakeDeletionSheetClient struct {
+	requests []models.DeletionRequest
+}
+
+func (c *fakeDeletionSheetClient) FetchAllSheetData(context.Context) ([]models.DeletionRequest, error) {
+	return c.GetAll(), nil
+}
+
+func (c *fakeDeletionSheetClient) ProcessRequest(_ context.Context, request models.DeletionRequest) (*models.DeletionRequest, error) {
+	c.Insert(request)
+	return &request, nil
+}
+
+func (c *fakeDeletionSheetClient) UpdateRequest(_ context.Context, request models.DeletionRequest) error {
+	return c.Update(request)
+}
+
+func (c *fakeDeletionSheetClient) GetType() string { return "fake" }
+
+func (c *fakeDeletionSheetClient) GetAll() []models.DeletionRequest {
+	return append([]models.DeletionRequest(nil), c.requests...)
+}
+
+func (c *fakeDeletionSheetClient) GetPending() []models.DeletionRequest {
+	var pending []models.DeletionRequest
+	for _, request := range c.requests {
+		if request.Status == models.DeletionStatusPending {
+			pending = append(pending, request)
+		}
+	}
+	return pending
+}
+
+func (c *fakeDeletionSheetClient) GetLatestByPublicID(publicId string) (models.DeletionRequest, bool) {
+	for i := len(c.requests) - 1; i >= 0; i-- {
+		if c.requests[i].PublicID == publicId {
+			return c.requests[i], true
+		}
+	}
+	return models.DeletionRequest{}, false
+}
+
+func (c *fakeDeletionSheetClient) Insert(request models.DeletionRequest) {
+	c.requests = append(c.requests, request)
+}
+
+func (c *fakeDeletionSheetClient) Update(request models.DeletionRequest) error {
+	for i, r := range c.requests {
+		if r.ID == request.ID {
+			c.requests[i] = request
+			return nil
+		}
+	}
+	return errors.New("deletion request not found")
+}
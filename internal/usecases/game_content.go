@@ -0,0 +1,78 @@
+package usecases
+
+import (
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/sirupsen/logrus"
+
+	"lysk-battle-record/internal/registry"
+)
+
+type GameContentResponse struct {
+	Current  string                    `json:"current"`
+	Versions []registry.ContentVersion `json:"versions"`
+}
+
+// GetGameContent serves the level catalogue. With at (RFC3339 or 2006-01-02) only the version
+// in force at that time is returned.
+func (s *LyskServer) GetGameContent(c *gin.Context) {
+	current := registry.ContentAt(time.Now())
+
+	if at := c.Query("at"); at != "" {
+		t, err := time.Parse(time.RFC3339, at)
+		if err != nil {
+			t, err = time.Parse(time.DateOnly, at)
+		}
+		if err != nil {
+			respondError(c, http.StatusBadRequest, "invalid_time")
+			return
+		}
+		c.JSON(http.StatusOK, registry.ContentAt(t))
+		return
+	}
+
+	c.JSON(http.StatusOK, GameContentResponse{
+		Current:  current.Version,
+		Versions: registry.ContentVersions(),
+	})
+}
+
+// LoadGameContent loads the catalogue file, if any.
+func LoadGameContent(path string) error {
+	if path == "" {
+		return nil
+	}
+	if err := registry.LoadContent(path); err != nil {
+		return err
+	}
+	logrus.Infof("[GameContent] Loaded %d versions from %s", len(registry.ContentVersions()), path)
+	return nil
+}
+
+// SetGameContentFile sets the catalogue file ReloadGameContent reads.
+func (s *LyskServer) SetGameContentFile(path string) {
+	s.gameContentFile = path
+}
+
+// ReloadGameContent picks up a new catalogue file after a game update without a deploy.
+func (s *LyskServer) ReloadGameContent(c *gin.Context) {
+	userId, _ := c.Get("userID")
+	if !s.auth.IsAdmin(userId.(string)) {
+		respondError(c, http.StatusForbidden, "forbidden")
+		return
+	}
+
+	if s.gameContentFile == "" {
+		respondError(c, http.StatusBadRequest, "game_content_not_configured")
+		return
+	}
+	if err := LoadGameContent(s.gameContentFile); err != nil {
+		logrus.Errorf("[GameContent] Failed to reload: %v", err)
+		respondErrorDetail(c, http.StatusBadRequest, "invalid_game_content", err)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"status": "OK", "current": registry.ContentAt(time.Now()).Version})
+}
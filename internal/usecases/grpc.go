@@ -0,0 +1,571 @@
+package usecases
+
+import (
+	"context"
+	"errors"
+	"net"
+	"net/http"
+	"strconv"
+	"sync"
+
+	"github.com/sirupsen/logrus"
+	"go.opentelemetry.io/otel/attribute"
+	otelcodes "go.opentelemetry.io/otel/codes"
+	"google.golang.org/genproto/googleapis/rpc/errdetails"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/health"
+	healthpb "google.golang.org/grpc/health/grpc_health_v1"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/peer"
+	"google.golang.org/grpc/reflection"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/protoadapt"
+	"google.golang.org/protobuf/reflect/protoreflect"
+	"google.golang.org/protobuf/reflect/protoregistry"
+	"google.golang.org/protobuf/types/dynamicpb"
+	"google.golang.org/protobuf/types/known/durationpb"
+
+	"lysk-battle-record/internal/datastores"
+	"lysk-battle-record/internal/i18n"
+	"lysk-battle-record/internal/models"
+	"lysk-battle-record/internal/pkg"
+	"lysk-battle-record/internal/utils"
+)
+
+// streamPageSize is how many records StreamRecords reads from the store at a time.
+const streamPageSize = 100
+
+// Requests and responses of the gRPC service. Records use the v2 DTOs.
+
+type CreateRecordRequest struct {
+	Kind   string          `json:"kind" description:"orbit / championships"`
+	Record RecordRequestV2 `json:"record"`
+}
+
+type UpdateRecordRequest struct {
+	Kind   string          `json:"kind" description:"orbit / championships"`
+	ID     string          `json:"id"`
+	Record RecordRequestV2 `json:"record"`
+}
+
+type DeleteRecordRequest struct {
+	Kind string `json:"kind" description:"orbit / championships"`
+	ID   string `json:"id"`
+}
+
+type DeleteRecordResponse struct{}
+
+type ListRecordsRequest struct {
+	Kind        string `json:"kind" description:"orbit / championships"`
+	Mine        bool   `json:"mine" description:"只查自己的记录，需要登录"`
+	LevelType   string `json:"level_type"`
+	LevelNumber *int   `json:"level_number,omitempty"`
+	LevelMode   string `json:"level_mode"`
+	Companion   string `json:"companion"`
+	SetCard     string `json:"set_card"`
+	Stage       string `json:"stage"`
+	Weapon      string `json:"weapon"`
+	Offset      int    `json:"offset" description:"StreamRecords 忽略"`
+	Limit       int    `json:"limit" description:"不填为 10，StreamRecords 忽略"`
+	LevelPart   string `json:"level_part" description:"上 / 下"`
+}
+
+type LatestRecordsRequest struct {
+	Kind string `json:"kind" description:"orbit / championships"`
+}
+
+type AnalyzeRequest struct {
+	Record    RecordRequestV2 `json:"record"`
+	Version   string          `json:"version" description:"估算版本，不填为当前版本"`
+	Estimator string          `json:"estimator" description:"montecarlo / timeline，不填为版本估算"`
+	Runs      int             `json:"runs" description:"模拟次数，仅 montecarlo"`
+	Duration  float64         `json:"duration" description:"战斗时长（秒），仅 timeline"`
+}
+
+type AnalyzeResponseV2 struct {
+	CombatPower CombatPowerV2 `json:"combat_power"`
+	Cards       []CardImpact  `json:"cards"`
+}
+
+type LevelSuggestionRequest struct {
+	LevelType   string `json:"level_type"`
+	LevelNumber string `json:"level_number" description:"锦标赛为 A4 / B4 / C4"`
+	LevelMode   string `json:"level_mode" description:"不填为稳定"`
+	Companion   string `json:"companion"`
+	SetCard     string `json:"set_card"`
+}
+
+type MinCombatPowerRequest struct{}
+
+type MinCombatPowerResponse struct {
+	Levels []LevelMinCP `json:"levels"`
+}
+
+type NewsRequest struct {
+	Mine bool `json:"mine" description:"自己的记录概况，需要登录"`
+}
+
+type RankingRequest struct{}
+
+type RankingResponse struct {
+	Items []models.RankingItem `json:"items"`
+}
+
+// rpcMethod is a method of the gRPC service along with its DTOs. Unary methods return the
+// response; streaming methods send each response.
+type rpcMethod struct {
+	name     string
+	summary  string
+	class    pkg.RouteClass
+	auth     bool
+	stream   bool
+	request  interface{}
+	response interface{}
+	call     func(ctx context.Context, decode func(interface{}) error, send func(interface{}) error) *apiError
+}
+
+func unaryMethod[Request, Response any](name, summary string, class pkg.RouteClass, auth bool, call func(context.Context, Request) (Response, *apiError)) rpcMethod {
+	var request Request
+	var response Response
+	return rpcMethod{name: name, summary: summary, class: class, auth: auth, request: request, response: response,
+		call: func(ctx context.Context, decode func(interface{}) error, send func(interface{}) error) *apiError {
+			var request Request
+			if err := decode(&request); err != nil {
+				return newAPIError(http.StatusBadRequest, "malformed_request", err)
+			}
+			response, apiErr := call(ctx, request)
+			if apiErr != nil {
+				return apiErr
+			}
+			if err := send(response); err != nil {
+				return newAPIError(http.StatusInternalServerError, "internal_error", err)
+			}
+			return nil
+		}}
+}
+
+func streamMethod[Request, Response any](name, summary string, class pkg.RouteClass, auth bool, call func(context.Context, Request, func(Response) error) *apiError) rpcMethod {
+	var request Request
+	var response Response
+	return rpcMethod{name: name, summary: summary, class: class, auth: auth, stream: true, request: request, response: response,
+		call: func(ctx context.Context, decode func(interface{}) error, send func(interface{}) error) *apiError {
+			var request Request
+			if err := decode(&request); err != nil {
+				return newAPIError(http.StatusBadRequest, "malformed_request", err)
+			}
+			return call(ctx, request, func(response Response) error { return send(response) })
+		}}
+}
+
+func (s *LyskServer) rpcMethods() []rpcMethod {
+	return []rpcMethod{
+		unaryMethod("CreateRecord", "Upload a record", pkg.RouteWrite, true, s.rpcCreateRecord),
+		unaryMethod("UpdateRecord", "Update a record of the signed in user", pkg.RouteWrite, true, s.rpcUpdateRecord),
+		unaryMethod("DeleteRecord", "Delete a record of the signed in user", pkg.RouteWrite, true, s.rpcDeleteRecord),
+		unaryMethod("ListRecords", "A page of records", pkg.RouteRead, false, s.rpcListRecords),
+		unaryMethod("LatestRecords", "The latest records", pkg.RouteRead, false, s.rpcLatestRecords),
+		streamMethod("StreamRecords", "Every record matching the filters, one message each", pkg.RouteRead, false, s.rpcStreamRecords),
+		unaryMethod("AnalyzeCombatPower", "Estimate the combat power of a record without storing it", pkg.RouteAnalyze, false, s.rpcAnalyzeCombatPower),
+		unaryMethod("GetLevelSuggestion", "Combat power and teams recorded on a level", pkg.RouteRead, false, s.rpcGetLevelSuggestion),
+		unaryMethod("GetMinCombatPower", "Lowest combat power recorded on every tenth orbit level", pkg.RouteRead, false, s.rpcGetMinCombatPower),
+		unaryMethod("GetNews", "Record counts and top records", pkg.RouteRead, false, s.rpcGetNews),
+		unaryMethod("GetRanking", "Contribution ranking", pkg.RouteRead, false, s.rpcGetRanking),
+	}
+}
+
+func (s *LyskServer) recordKindOf(name string) (recordKind, *apiError) {
+	switch name {
+	case "orbit":
+		return s.orbitKind(), nil
+	case "championships":
+		return s.championshipsKind(), nil
+	}
+	return recordKind{}, newAPIError(http.StatusBadRequest, "invalid_request", errors.New("kind must be orbit or championships"))
+}
+
+func (s *LyskServer) rpcCreateRecord(ctx context.Context, request CreateRecordRequest) (RecordResponseV2, *apiError) {
+	kind, apiErr := s.recordKindOf(request.Kind)
+	if apiErr != nil {
+		return RecordResponseV2{}, apiErr
+	}
+	record, apiErr := s.createRecord(ctx, rpcUserID(ctx), kind, request.Record.input())
+	if apiErr != nil {
+		return RecordResponseV2{}, apiErr
+	}
+	return RecordResponseV2{Record: newRecordV2(*record)}, nil
+}
+
+func (s *LyskServer) rpcUpdateRecord(ctx context.Context, request UpdateRecordRequest) (RecordResponseV2, *apiError) {
+	kind, apiErr := s.recordKindOf(request.Kind)
+	if apiErr != nil {
+		return RecordResponseV2{}, apiErr
+	}
+	record, apiErr := s.updateRecord(ctx, rpcUserID(ctx), kind, request.ID, request.Record.input())
+	if apiErr != nil {
+		return RecordResponseV2{}, apiErr
+	}
+	return RecordResponseV2{Record: newRecordV2(*record)}, nil
+}
+
+func (s *LyskServer) rpcDeleteRecord(ctx context.Context, request DeleteRecordRequest) (DeleteRecordResponse, *apiError) {
+	kind, apiErr := s.recordKindOf(request.Kind)
+	if apiErr != nil {
+		return DeleteRecordResponse{}, apiErr
+	}
+	return DeleteRecordResponse{}, s.deleteRecord(ctx, rpcUserID(ctx), kind, request.ID)
+}
+
+func (r ListRecordsRequest) filters() map[string]string {
+	return recordFilters(map[string]string{
+		"level_type":   r.LevelType,
+		"level_number": formatInt(r.LevelNumber),
+		"level_part":   r.LevelPart,
+		"level_mode":   r.LevelMode,
+		"companion":    r.Companion,
+		"set_card":     r.SetCard,
+		"stage":        r.Stage,
+		"weapon":       r.Weapon,
+	})
+}
+
+// queryRecords is a page of public records, or of the signed in user's when mine is set.
+func (s *LyskServer) queryRecords(ctx context.Context, request ListRecordsRequest, offset, limit int) (datastores.QueryResult, *apiError) {
+	kind, apiErr := s.recordKindOf(request.Kind)
+	if apiErr != nil {
+		return datastores.QueryResult{}, apiErr
+	}
+	if !request.Mine {
+		return s.listRecords(kind, request.filters(), offset, limit), nil
+	}
+
+	userID := rpcUserID(ctx)
+	if userID == "" {
+		return datastores.QueryResult{}, newAPIError(http.StatusUnauthorized, "unauthenticated_user", nil)
+	}
+	return s.myRecords(kind, userID, request.filters(), offset, limit), nil
+}
+
+func (s *LyskServer) rpcListRecords(ctx context.Context, request ListRecordsRequest) (RecordPageV2, *apiError) {
+	result, apiErr := s.queryRecords(ctx, request, request.Offset, request.Limit)
+	if apiErr != nil {
+		return RecordPageV2{}, apiErr
+	}
+	return newRecordPageV2(result), nil
+}
+
+func (s *LyskServer) rpcLatestRecords(ctx context.Context, request LatestRecordsRequest) (RecordPageV2, *apiError) {
+	kind, apiErr := s.recordKindOf(request.Kind)
+	if apiErr != nil {
+		return RecordPageV2{}, apiErr
+	}
+	return newRecordPageV2(s.listRecords(kind, nil, 0, 5)), nil
+}
+
+// rpcStreamRecords sends every matching record, reading the store a page at a time.
+func (s *LyskServer) rpcStreamRecords(ctx context.Context, request ListRecordsRequest, send func(RecordV2) error) *apiError {
+	for offset := 0; ; offset += streamPageSize {
+		result, apiErr := s.queryRecords(ctx, request, offset, streamPageSize)
+		if apiErr != nil {
+			return apiErr
+		}
+		for _, record := range result.Records {
+			if err := send(newRecordV2(record)); err != nil {
+				return newAPIError(http.StatusInternalServerError, "internal_error", err)
+			}
+		}
+		if offset+streamPageSize >= result.Total || ctx.Err() != nil {
+			return nil
+		}
+	}
+}
+
+func (s *LyskServer) rpcAnalyzeCombatPower(ctx context.Context, request AnalyzeRequest) (AnalyzeResponseV2, *apiError) {
+	response, apiErr := s.analyze(request.Record.input().record, analyzeOptions{
+		version:   request.Version,
+		estimator: request.Estimator,
+		runs:      request.Runs,
+		duration:  request.Duration,
+	})
+	if apiErr != nil {
+		return AnalyzeResponseV2{}, apiErr
+	}
+	return AnalyzeResponseV2{CombatPower: newCombatPowerV2(response.CombatPower), Cards: response.Cards}, nil
+}
+
+func (s *LyskServer) rpcGetLevelSuggestion(ctx context.Context, request LevelSuggestionRequest) (LevelSuggestionResponse, *apiError) {
+	companion, setCard := request.Companion, request.SetCard
+	if companion == "" {
+		companion = utils.AllCompanion
+	}
+	if setCard == "" {
+		setCard = utils.AllSetCard
+	}
+	return s.levelSuggestion(request.LevelType, request.LevelNumber, request.LevelMode, companion, setCard)
+}
+
+func (s *LyskServer) rpcGetMinCombatPower(ctx context.Context, request MinCombatPowerRequest) (MinCombatPowerResponse, *apiError) {
+	return MinCombatPowerResponse{Levels: s.minCombatPower()}, nil
+}
+
+func (s *LyskServer) rpcGetNews(ctx context.Context, request NewsRequest) (News, *apiError) {
+	if !request.Mine {
+		return s.news(), nil
+	}
+
+	userID := rpcUserID(ctx)
+	if userID == "" {
+		return News{}, newAPIError(http.StatusUnauthorized, "unauthenticated_user", nil)
+	}
+	return s.userNews(userID), nil
+}
+
+func (s *LyskServer) rpcGetRanking(ctx context.Context, request RankingRequest) (RankingResponse, *apiError) {
+	return RankingResponse{Items: s.ranking(rpcUserID(ctx))}, nil
+}
+
+type rpcUserKey struct{}
+
+// rpcUserID is the signed in user of the call, or empty.
+func rpcUserID(ctx context.Context) string {
+	userID, _ := ctx.Value(rpcUserKey{}).(string)
+	return userID
+}
+
+// metadataCarrier reads the caller's trace context from the gRPC metadata.
+type metadataCarrier metadata.MD
+
+func (m metadataCarrier) Get(key string) string {
+	return firstValue(metadata.MD(m), key)
+}
+
+func (m metadataCarrier) Set(key, value string) {
+	metadata.MD(m).Set(key, value)
+}
+
+func (m metadataCarrier) Keys() []string {
+	keys := make([]string, 0, len(m))
+	for key := range m {
+		keys = append(keys, key)
+	}
+	return keys
+}
+
+// rpcLanguage negotiates the language of error messages from the accept-language metadata.
+func rpcLanguage(ctx context.Context) string {
+	md, _ := metadata.FromIncomingContext(ctx)
+	return i18n.Negotiate(firstValue(md, "accept-language"))
+}
+
+// rpcPeerIP is the IP of the caller, used as its rate limit key when signed out.
+func rpcPeerIP(ctx context.Context) string {
+	p, ok := peer.FromContext(ctx)
+	if !ok {
+		return ""
+	}
+	if host, _, err := net.SplitHostPort(p.Addr.String()); err == nil {
+		return host
+	}
+	return p.Addr.String()
+}
+
+func firstValue(md metadata.MD, key string) string {
+	if values := md.Get(key); len(values) > 0 {
+		return values[0]
+	}
+	return ""
+}
+
+// authenticateRPC reads the bearer token of the authorization metadata. Like AuthMiddleware,
+// methods that do not require a user are served signed out when the token is missing or bad.
+func (s *LyskServer) authenticateRPC(ctx context.Context, method rpcMethod) (context.Context, *apiError) {
+	md, _ := metadata.FromIncomingContext(ctx)
+	userID, err := s.authenticate(firstValue(md, "authorization"))
+	if err != nil {
+		if method.auth {
+			logrus.Warnf("[GRPC] Rejected call to %s: %v", method.name, err)
+			return ctx, authFailure(err)
+		}
+		return ctx, nil
+	}
+	return context.WithValue(ctx, rpcUserKey{}, userID), nil
+}
+
+var rpcCodes = map[int]codes.Code{
+	http.StatusBadRequest:         codes.InvalidArgument,
+	http.StatusUnauthorized:       codes.Unauthenticated,
+	http.StatusForbidden:          codes.PermissionDenied,
+	http.StatusNotFound:           codes.NotFound,
+	http.StatusConflict:           codes.AlreadyExists,
+	http.StatusTooManyRequests:    codes.ResourceExhausted,
+	http.StatusServiceUnavailable: codes.Unavailable,
+	http.StatusGatewayTimeout:     codes.DeadlineExceeded,
+}
+
+// rpcStatus is the error as a gRPC status. The code is in the ErrorInfo reason and validation
+// failures list each field in BadRequest, both in the language of the call.
+func (e *apiError) rpcStatus(lang string) error {
+	code, ok := rpcCodes[e.status]
+	if !ok {
+		code = codes.Internal
+	}
+
+	reason := e.code
+	var details []protoadapt.MessageV1
+	var validationErr *models.ValidationError
+	if e.code == "" && errors.As(e.err, &validationErr) {
+		reason = "validation_failed"
+		badRequest := &errdetails.BadRequest{}
+		for _, violation := range localizeViolations(lang, validationErr.Violations) {
+			badRequest.FieldViolations = append(badRequest.FieldViolations, &errdetails.BadRequest_FieldViolation{
+				Field:       violation.Field,
+				Description: violation.Message,
+			})
+		}
+		details = append(details, badRequest)
+	}
+
+	if e.retryAfter > 0 {
+		details = append(details, &errdetails.RetryInfo{RetryDelay: durationpb.New(e.retryAfter)})
+	}
+
+	info := &errdetails.ErrorInfo{Reason: reason, Domain: "lysk-battle-record"}
+	if e.err != nil && validationErr == nil {
+		info.Metadata = map[string]string{"detail": e.err.Error()}
+	}
+	details = append([]protoadapt.MessageV1{info}, details...)
+
+	message, ok := i18n.Message(lang, reason, nil)
+	if !ok {
+		message = reason
+	}
+	st, err := status.New(code, message).WithDetails(details...)
+	if err != nil {
+		return status.Error(code, message)
+	}
+	return st.Err()
+}
+
+// rpcService is the hand-built service descriptor of the methods. Messages are dynamic and
+// copied to and from the DTOs.
+func (s *LyskServer) rpcService(file protoreflect.FileDescriptor, methods []rpcMethod) *grpc.ServiceDesc {
+	service := file.Services().ByName(rpcService)
+	desc := &grpc.ServiceDesc{
+		ServiceName: string(service.FullName()),
+		HandlerType: (*interface{})(nil),
+		Metadata:    rpcFilePath,
+	}
+
+	for _, method := range methods {
+		method := method
+		descriptor := service.Methods().ByName(protoreflect.Name(method.name))
+		handle := func(ctx context.Context, request *dynamicpb.Message, send func(interface{}) error) error {
+			md, _ := metadata.FromIncomingContext(ctx)
+			ctx, span := pkg.StartServerSpan(ctx, rpcService+"/"+method.name, metadataCarrier(md),
+				attribute.String("rpc.system", "grpc"),
+				attribute.String("rpc.method", method.name))
+			defer span.End()
+
+			lang := rpcLanguage(ctx)
+			ctx, apiErr := s.authenticateRPC(ctx, method)
+			if apiErr == nil {
+				apiErr = s.rateLimit(method.class, rpcUserID(ctx), rpcPeerIP(ctx))
+			}
+			if apiErr == nil {
+				apiErr = method.call(ctx,
+					func(v interface{}) error { return decodeMessage(request, v) },
+					send)
+			}
+			if apiErr != nil {
+				if apiErr.status >= http.StatusInternalServerError {
+					logrus.Errorf("[GRPC] %s failed: %s %v", method.name, apiErr.code, apiErr.err)
+					span.SetStatus(otelcodes.Error, apiErr.code)
+				}
+				return apiErr.rpcStatus(lang)
+			}
+			return nil
+		}
+
+		if method.stream {
+			desc.Streams = append(desc.Streams, grpc.StreamDesc{
+				StreamName:    method.name,
+				ServerStreams: true,
+				Handler: func(srv interface{}, stream grpc.ServerStream) error {
+					request := dynamicpb.NewMessage(descriptor.Input())
+					if err := stream.RecvMsg(request); err != nil {
+						return err
+					}
+					return handle(stream.Context(), request, func(v interface{}) error {
+						message, err := encodeMessage(v, descriptor.Output())
+						if err != nil {
+							return err
+						}
+						return stream.SendMsg(message)
+					})
+				},
+			})
+			continue
+		}
+
+		desc.Methods = append(desc.Methods, grpc.MethodDesc{
+			MethodName: method.name,
+			Handler: func(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+				request := dynamicpb.NewMessage(descriptor.Input())
+				if err := dec(request); err != nil {
+					return nil, err
+				}
+				call := func(ctx context.Context, request interface{}) (interface{}, error) {
+					var response *dynamicpb.Message
+					err := handle(ctx, request.(*dynamicpb.Message), func(v interface{}) (err error) {
+						response, err = encodeMessage(v, descriptor.Output())
+						return err
+					})
+					return response, err
+				}
+				if interceptor == nil {
+					return call(ctx, request)
+				}
+				return interceptor(ctx, request, &grpc.UnaryServerInfo{Server: srv, FullMethod: "/" + desc.ServiceName + "/" + method.name}, call)
+			},
+		})
+	}
+	return desc
+}
+
+var registerRPCFile sync.Once
+
+// NewGRPCServer serves the records, analysis and suggestions over gRPC, with reflection and
+// the standard health service.
+func (s *LyskServer) NewGRPCServer() (*grpc.Server, error) {
+	methods := s.rpcMethods()
+	file, err := newProtoFile(methods).descriptor()
+	if err != nil {
+		return nil, err
+	}
+	// 反射服务从全局注册表查找描述
+	registerRPCFile.Do(func() { err = protoregistry.GlobalFiles.RegisterFile(file) })
+	if err != nil {
+		return nil, err
+	}
+
+	server := grpc.NewServer()
+	server.RegisterService(s.rpcService(file, methods), s)
+	healthpb.RegisterHealthServer(server, health.NewServer())
+	reflection.Register(server)
+	return server, nil
+}
+
+// ServeGRPC listens on the port and serves until the listener fails.
+func (s *LyskServer) ServeGRPC(port int) error {
+	server, err := s.NewGRPCServer()
+	if err != nil {
+		return err
+	}
+
+	listener, err := net.Listen("tcp", ":"+strconv.Itoa(port))
+	if err != nil {
+		return err
+	}
+	logrus.Infof("[GRPC] Serving on %s", listener.Addr())
+	return server.Serve(listener)
+}
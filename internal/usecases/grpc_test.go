@@ -0,0 +1,95 @@
+package usecases
+
+import (
+	"context"
+	"net"
+	"os"
+	"testing"
+
+	"google.golang.org/genproto/googleapis/rpc/errdetails"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+	"google.golang.org/grpc/test/bufconn"
+	"google.golang.org/protobuf/reflect/protoreflect"
+	"google.golang.org/protobuf/reflect/protoregistry"
+	"google.golang.org/protobuf/types/dynamicpb"
+)
+
+const protoSourcePath = "../../proto/lysk/v1/lysk.proto"
+
+// TestProtoSource keeps the checked-in .proto in step with the DTOs. Run with UPDATE_PROTO=1
+// to rewrite it.
+func TestProtoSource(t *testing.T) {
+	file := newProtoFile((&LyskServer{}).rpcMethods())
+	if _, err := file.descriptor(); err != nil {
+		t.Fatalf("invalid descriptor: %v", err)
+	}
+
+	source := file.source()
+	if os.Getenv("UPDATE_PROTO") != "" {
+		if err := os.WriteFile(protoSourcePath, []byte(source), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	checkedIn, err := os.ReadFile(protoSourcePath)
+	if err != nil || string(checkedIn) != source {
+		t.Errorf("%s is out of date, run UPDATE_PROTO=1 go test ./internal/usecases -run TestProtoSource", protoSourcePath)
+	}
+}
+
+func TestGRPCService(t *testing.T) {
+	server, err := (&LyskServer{orbitRecordStore: &MockRecordStore{}}).NewGRPCServer()
+	if err != nil {
+		t.Fatalf("server not built: %v", err)
+	}
+	listener := bufconn.Listen(1 << 20)
+	go server.Serve(listener)
+	defer server.Stop()
+
+	conn, err := grpc.NewClient("passthrough:///bufconn",
+		grpc.WithContextDialer(func(context.Context, string) (net.Conn, error) { return listener.Dial() }),
+		grpc.WithTransportCredentials(insecure.NewCredentials()))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+
+	invoke := func(ctx context.Context, method string, request map[string]interface{}) (*dynamicpb.Message, error) {
+		descriptor, err := protoregistry.GlobalFiles.FindDescriptorByName(protoreflect.FullName(rpcPackage + "." + rpcService + "." + method))
+		if err != nil {
+			t.Fatal(err)
+		}
+		methodDescriptor := descriptor.(protoreflect.MethodDescriptor)
+		in, err := encodeMessage(request, methodDescriptor.Input())
+		if err != nil {
+			t.Fatal(err)
+		}
+		out := dynamicpb.NewMessage(methodDescriptor.Output())
+		return out, conn.Invoke(ctx, "/"+rpcPackage+"."+rpcService+"/"+method, in, out)
+	}
+
+	out, err := invoke(context.Background(), "GetMinCombatPower", map[string]interface{}{})
+	if err != nil {
+		t.Fatalf("GetMinCombatPower failed: %v", err)
+	}
+	var response MinCombatPowerResponse
+	if err := decodeMessage(out, &response); err != nil {
+		t.Fatal(err)
+	}
+	if len(response.Levels) != 2 || response.Levels[0].MinCP != 3000 {
+		t.Errorf("unexpected levels: %+v", response.Levels)
+	}
+
+	ctx := metadata.AppendToOutgoingContext(context.Background(), "accept-language", "en")
+	_, err = invoke(ctx, "ListRecords", map[string]interface{}{"kind": "arena"})
+	st := status.Convert(err)
+	if st.Code() != codes.InvalidArgument || st.Message() != "Invalid request" {
+		t.Errorf("unexpected status: %v", st)
+	}
+	if info, ok := st.Details()[0].(*errdetails.ErrorInfo); !ok || info.Reason != "invalid_request" {
+		t.Errorf("error code missing: %v", st.Details())
+	}
+}
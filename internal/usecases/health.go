@@ -1,10 +1,164 @@
 package usecases
 
 import (
-	"github.com/gin-gonic/gin"
+	"context"
 	"net/http"
+	"sync"
+	"time"
+
+	"github.com/gin-gonic/gin"
+
+	"lysk-battle-record/internal/datastores"
+	"lysk-battle-record/internal/sheet_clients"
+)
+
+const (
+	// sheetProbeTTL keeps readiness probes from spending the Sheets quota.
+	sheetProbeTTL     = 30 * time.Second
+	sheetProbeTimeout = 3 * time.Second
 )
 
+// ComponentStatus is the readiness of one store or sheet.
+type ComponentStatus struct {
+	Status      string     `json:"status"` // ok / not_loaded / stale / unreachable
+	LastRefresh *time.Time `json:"last_refresh,omitempty"`
+	Error       string     `json:"error,omitempty"`
+}
+
+type ReadinessResponse struct {
+	Status     string                     `json:"status"` // ok / unavailable
+	Components map[string]ComponentStatus `json:"components"`
+}
+
+// sheetProbe caches whether the sheets were reachable.
+type sheetProbe struct {
+	mu        sync.Mutex
+	checkedAt time.Time
+	results   map[string]error
+}
+
 func (s *LyskServer) Ping(c *gin.Context) {
 	c.JSON(http.StatusOK, gin.H{"status": "OK"})
 }
+
+// Healthz is the liveness check: the process is serving requests.
+func (s *LyskServer) Healthz(c *gin.Context) {
+	c.JSON(http.StatusOK, gin.H{"status": "ok"})
+}
+
+// Readyz is the readiness check. Every store must have loaded its sheet and refreshed it within
+// the allowed number of missed refreshes, and the record sheets must be reachable.
+func (s *LyskServer) Readyz(c *gin.Context) {
+	response := ReadinessResponse{Status: "ok", Components: map[string]ComponentStatus{}}
+	now := time.Now()
+
+	for name, store := range s.refreshables() {
+		status := store.RefreshStatus()
+		component := refreshComponent(status, now, s.maxRefreshAge(status.Interval))
+		if component.Status != "ok" {
+			response.Status = "unavailable"
+		}
+		response.Components[name] = component
+	}
+
+	for name, err := range s.probeSheets(c.Request.Context()) {
+		component := ComponentStatus{Status: "ok"}
+		if err != nil {
+			component = ComponentStatus{Status: "unreachable", Error: err.Error()}
+			response.Status = "unavailable"
+		}
+		response.Components[name] = component
+	}
+
+	status := http.StatusOK
+	if response.Status != "ok" {
+		status = http.StatusServiceUnavailable
+	}
+	c.JSON(status, response)
+}
+
+// SetMaxMissedRefreshes sets how many refreshes in a row a store may fail before the instance
+// is no longer ready.
+func (s *LyskServer) SetMaxMissedRefreshes(n int) {
+	s.maxMissedRefreshes = n
+}
+
+// SetRevocationStore adds the revocation store, which only the authenticator uses, to the
+// readiness checks.
+func (s *LyskServer) SetRevocationStore(store datastores.Refreshable) {
+	s.revocationStore = store
+}
+
+func (s *LyskServer) refreshables() map[string]datastores.Refreshable {
+	stores := map[string]interface{}{
+		"orbit_records":         s.orbitRecordStore,
+		"championships_records": s.championshipsRecordStore,
+		"users":                 s.userStore,
+		"identities":            s.identityStore,
+		"deletion_requests":     s.deletionRequestStore,
+		"revocations":           s.revocationStore,
+	}
+
+	refreshables := map[string]datastores.Refreshable{}
+	for name, store := range stores {
+		if refreshable, ok := store.(datastores.Refreshable); ok {
+			refreshables[name] = refreshable
+		}
+	}
+	return refreshables
+}
+
+func refreshComponent(status datastores.RefreshStatus, now time.Time, maxAge time.Duration) ComponentStatus {
+	var component ComponentStatus
+	switch {
+	case status.LastSuccess.IsZero():
+		component.Status = "not_loaded"
+	case now.Sub(status.LastSuccess) > maxAge:
+		component.Status = "stale"
+	default:
+		component.Status = "ok"
+	}
+
+	if !status.LastSuccess.IsZero() {
+		lastRefresh := status.LastSuccess
+		component.LastRefresh = &lastRefresh
+	}
+	// 偶尔一次刷新失败仍算就绪，但附上错误
+	if status.LastError != nil {
+		component.Error = status.LastError.Error()
+	}
+	return component
+}
+
+// maxRefreshAge is how long a store refreshed every interval may go without a successful refresh.
+func (s *LyskServer) maxRefreshAge(interval time.Duration) time.Duration {
+	return time.Duration(s.maxMissedRefreshes+1) * interval
+}
+
+// probeSheets pings the record sheets at most once per sheetProbeTTL.
+func (s *LyskServer) probeSheets(ctx context.Context) map[string]error {
+	s.sheetProbe.mu.Lock()
+	defer s.sheetProbe.mu.Unlock()
+
+	if s.sheetProbe.results != nil && time.Since(s.sheetProbe.checkedAt) < sheetProbeTTL {
+		return s.sheetProbe.results
+	}
+
+	// 结果会被缓存，不能因为这次请求断开而记成失败
+	ctx, cancel := context.WithTimeout(context.WithoutCancel(ctx), sheetProbeTimeout)
+	defer cancel()
+
+	results := map[string]error{}
+	for name, client := range map[string]sheet_clients.RecordSheetClient{
+		"orbit_sheet":         s.orbitSheetClient,
+		"championships_sheet": s.championshipsSheetClient,
+	} {
+		if client != nil {
+			results[name] = client.Ping(ctx)
+		}
+	}
+
+	s.sheetProbe.results = results
+	s.sheetProbe.checkedAt = time.Now()
+	return results
+}
@@ -0,0 +1,83 @@
+package usecases
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+
+	"lysk-battle-record/internal/datastores"
+	"lysk-battle-record/internal/sheet_clients"
+)
+
+type fakeRefreshable struct {
+	status datastores.RefreshStatus
+}
+
+func (f fakeRefreshable) RefreshStatus() datastores.RefreshStatus {
+	return f.status
+}
+
+type pingSheetClient struct {
+	sheet_clients.RecordSheetClient
+	err error
+}
+
+func (p pingSheetClient) Ping(context.Context) error {
+	return p.err
+}
+
+func TestReadyz(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	now := time.Now()
+	interval := 5 * time.Minute
+
+	tests := []struct {
+		name      string
+		status    datastores.RefreshStatus
+		sheetErr  error
+		code      int
+		component string
+	}{
+		{"not loaded", datastores.RefreshStatus{Interval: interval}, nil, http.StatusServiceUnavailable, "not_loaded"},
+		{"fresh", datastores.RefreshStatus{Interval: interval, LastSuccess: now.Add(-time.Minute)}, nil, http.StatusOK, "ok"},
+		// 一次失败在允许范围内
+		{"one failed refresh", datastores.RefreshStatus{Interval: interval, LastSuccess: now.Add(-6 * time.Minute), LastError: errors.New("quota")}, nil, http.StatusOK, "ok"},
+		{"stale", datastores.RefreshStatus{Interval: interval, LastSuccess: now.Add(-time.Hour)}, nil, http.StatusServiceUnavailable, "stale"},
+		{"sheet unreachable", datastores.RefreshStatus{Interval: interval, LastSuccess: now}, errors.New("invalid_grant"), http.StatusServiceUnavailable, "ok"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s := &LyskServer{
+				orbitRecordStore: &MockRecordStore{},
+				orbitSheetClient: pingSheetClient{err: tt.sheetErr},
+				revocationStore:  fakeRefreshable{tt.status},
+			}
+			s.maxMissedRefreshes = 2
+
+			w := httptest.NewRecorder()
+			c, _ := gin.CreateTestContext(w)
+			c.Request = httptest.NewRequest(http.MethodGet, "/readyz", nil)
+			s.Readyz(c)
+
+			var response ReadinessResponse
+			if err := json.Unmarshal(w.Body.Bytes(), &response); err != nil {
+				t.Fatal(err)
+			}
+			if w.Code != tt.code || response.Components["revocations"].Status != tt.component {
+				t.Errorf("unexpected response %d %+v", w.Code, response)
+			}
+			if _, ok := response.Components["orbit_records"]; ok {
+				t.Error("store without refresh status reported")
+			}
+			if sheet := response.Components["orbit_sheet"]; (sheet.Status == "ok") != (tt.sheetErr == nil) {
+				t.Errorf("unexpected sheet status %+v", sheet)
+			}
+		})
+	}
+}
@@ -0,0 +1,118 @@
+package usecases
+
+import (
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+
+	"lysk-battle-record/internal/datastores"
+	"lysk-battle-record/internal/i18n"
+	"lysk-battle-record/internal/models"
+	"lysk-battle-record/internal/registry"
+)
+
+// language is the language of the response: the lang query parameter if it is supported,
+// otherwise what Accept-Language prefers.
+func language(c *gin.Context) string {
+	if lang, ok := i18n.Supported(c.Query("lang")); ok {
+		return lang
+	}
+	return i18n.Negotiate(c.GetHeader("Accept-Language"))
+}
+
+// errorResponse is the localised message of the code along with the code itself, plus any
+// extra fields such as detail.
+func errorResponse(c *gin.Context, code string, extra gin.H) gin.H {
+	message, ok := i18n.Message(language(c), code, nil)
+	if !ok {
+		message = code
+	}
+	response := gin.H{"error": message, "code": code}
+	for key, value := range extra {
+		response[key] = value
+	}
+	return response
+}
+
+func respondError(c *gin.Context, status int, code string) {
+	c.JSON(status, errorResponse(c, code, nil))
+}
+
+func respondErrorDetail(c *gin.Context, status int, code string, err error) {
+	c.JSON(status, errorResponse(c, code, gin.H{"detail": err.Error()}))
+}
+
+// localizeViolations translates the messages of the violations, keeping the default message
+// for codes the catalogue does not know.
+func localizeViolations(lang string, violations []models.Violation) []models.Violation {
+	localized := make([]models.Violation, len(violations))
+	for i, violation := range violations {
+		if message, ok := i18n.Message(lang, violation.Code, violation.Params); ok {
+			violation.Message = message
+		}
+		localized[i] = violation
+	}
+	return localized
+}
+
+// englishKeys reports whether the client asked for records with English keys (keys=en).
+func englishKeys(c *gin.Context) bool {
+	return strings.EqualFold(c.Query("keys"), i18n.En)
+}
+
+// respondRecords serves a page of records, with English keys when asked for.
+func respondRecords(c *gin.Context, result datastores.QueryResult) {
+	if englishKeys(c) {
+		c.JSON(http.StatusOK, gin.H{"total": result.Total, "records": models.Records(result.Records).English()})
+		return
+	}
+	c.JSON(http.StatusOK, result)
+}
+
+type EnumName struct {
+	ID   string `json:"id"`
+	Name string `json:"name"`
+}
+
+type CompanionName struct {
+	EnumName
+	Partner string `json:"partner"`
+}
+
+type EnumsResponse struct {
+	Language   string          `json:"language"`
+	Partners   []EnumName      `json:"partners"`
+	Companions []CompanionName `json:"companions"`
+	SetCards   []EnumName      `json:"set_cards"`
+	LevelTypes []EnumName      `json:"level_types"`
+}
+
+// GetEnums lists the canonical IDs of partners, companions, set cards and the current level
+// types with their display names in the negotiated language.
+func (s *LyskServer) GetEnums(c *gin.Context) {
+	lang := language(c)
+	name := func(id string) EnumName { return EnumName{ID: id, Name: i18n.Name(lang, id)} }
+
+	response := EnumsResponse{Language: lang}
+	seen := map[string]bool{}
+	for _, partner := range registry.Partners() {
+		response.Partners = append(response.Partners, name(partner.Name))
+		for _, setCard := range partner.SetCards {
+			if !seen[setCard] {
+				seen[setCard] = true
+				response.SetCards = append(response.SetCards, name(setCard))
+			}
+		}
+	}
+	for _, companion := range registry.Companions() {
+		response.Companions = append(response.Companions, CompanionName{EnumName: name(companion.Name), Partner: companion.Partner})
+	}
+	for _, levelType := range registry.ContentAt(time.Now()).LevelTypes {
+		response.LevelTypes = append(response.LevelTypes, name(levelType.Name))
+	}
+
+	c.Header("Content-Language", lang)
+	c.JSON(http.StatusOK, response)
+}
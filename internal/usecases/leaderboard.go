@@ -0,0 +1,312 @@
+package usecases
+
+import (
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+
+	"lysk-battle-record/internal/models"
+	"lysk-battle-record/internal/utils"
+)
+
+const (
+	LeaderboardContribution      = "contribution"
+	LeaderboardLevelCP           = "level_cp"
+	LeaderboardDeepestLevel      = "deepest_level"
+	LeaderboardCompanion         = "companion"
+	LeaderboardWeekly            = "weekly"
+	LeaderboardChampionshipRound = "championship_round"
+
+	leaderboardLimit = 10
+)
+
+type LeaderboardResponse struct {
+	Board   string               `json:"board"`
+	Ranking []models.RankingItem `json:"ranking"`
+}
+
+// GetLeaderboard serves the leaderboard selected by the board query parameter.
+//
+//	contribution:       ?board=contribution
+//	level_cp:           ?board=level_cp&type=光&level=10&mode=稳定
+//	deepest_level:      ?board=deepest_level&type=光&mode=稳定
+//	companion:          ?board=companion&filteredCompanion=逐光骑士
+//	weekly:             ?board=weekly
+//	championship_round: ?board=championship_round&round=2025-06-02&level=A4
+func (s *LyskServer) GetLeaderboard(c *gin.Context) {
+	board := c.DefaultQuery("board", LeaderboardContribution)
+
+	var ranking models.Ranking
+	var score func(models.RankingItem) int64
+
+	switch board {
+	case LeaderboardContribution:
+		ranking, score = s.orbitRecordStore.GetContributionRanking(), byContribution
+	case LeaderboardLevelCP:
+		levelType, levelNumber, levelMode := c.Query("type"), c.Query("level"), c.DefaultQuery("mode", "稳定")
+		if levelType == "" || levelNumber == "" {
+			respondError(c, http.StatusBadRequest, "level_required")
+			return
+		}
+		ranking, score = s.buildLevelCPBoard(levelType, levelNumber, levelMode), byScore
+	case LeaderboardDeepestLevel:
+		levelType, levelMode := c.Query("type"), c.DefaultQuery("mode", "稳定")
+		if levelType == "" {
+			respondError(c, http.StatusBadRequest, "level_required")
+			return
+		}
+		ranking, score = s.buildDeepestLevelBoard(levelType, levelMode), byLevelDepth
+	case LeaderboardCompanion:
+		companion := utils.GetCompanion(c)
+		if companion == utils.AllCompanion {
+			respondError(c, http.StatusBadRequest, "companion_required")
+			return
+		}
+		ranking, score = s.buildCompanionBoard(companion), byScore
+	case LeaderboardWeekly:
+		start, end := utils.GetCurrentWeek()
+		ranking, score = s.buildContributionBoard(start, end), byContribution
+	case LeaderboardChampionshipRound:
+		roundTime := time.Now()
+		if round := c.Query("round"); round != "" {
+			parsed, err := time.Parse("2006-01-02", round)
+			if err != nil {
+				respondError(c, http.StatusBadRequest, "invalid_round")
+				return
+			}
+			roundTime = parsed
+		}
+		start, end := utils.GetChampionshipsRoundByTime(roundTime)
+		ranking, score = s.buildChampionshipRoundBoard(start, end, c.Query("level")), byScore
+	default:
+		respondError(c, http.StatusBadRequest, "invalid_leaderboard_type")
+		return
+	}
+
+	c.JSON(http.StatusOK, LeaderboardResponse{
+		Board:   board,
+		Ranking: s.finalizeRanking(ranking, score, getViewerID(c)),
+	})
+}
+
+func (s *LyskServer) GetRanking(c *gin.Context) {
+	c.JSON(http.StatusOK, s.ranking(getViewerID(c)))
+}
+
+// ranking is the contribution ranking as seen by the viewer, who may be signed out.
+func (s *LyskServer) ranking(viewerId string) models.Ranking {
+	return s.finalizeRanking(s.orbitRecordStore.GetContributionRanking(), byContribution, viewerId)
+}
+
+// finalizeRanking removes users who opted out of leaderboards, assigns ranks, keeps the
+// top items plus the viewer's own position and attaches public IDs and nicknames.
+func (s *LyskServer) finalizeRanking(ranking models.Ranking, score func(models.RankingItem) int64, viewerId string) models.Ranking {
+	visible := models.Ranking{}
+	for _, item := range ranking {
+		if user, ok := s.userStore.Get(item.OpenID); ok && user.HideFromRanking {
+			continue
+		}
+		visible = append(visible, item)
+	}
+
+	result := visible.Rank(score).Top(leaderboardLimit, viewerId)
+	for i, item := range result {
+		result[i].PublicID = s.pseudonymizer.PublicID(item.OpenID)
+		result[i].IsMe = viewerId != "" && item.OpenID == viewerId
+		if user, ok := s.userStore.Get(item.OpenID); ok && !user.HideNickname {
+			result[i].Nickname = user.Nickname
+		}
+	}
+
+	return result
+}
+
+func (s *LyskServer) buildLevelCPBoard(levelType, levelNumber, levelMode string) models.Ranking {
+	records := s.orbitRecordStore.GetLevelRecords(models.Record{
+		LevelType:   levelType,
+		LevelNumber: levelNumber,
+		LevelMode:   levelMode,
+	})
+
+	return buildTopScoreRanking(records)
+}
+
+func (s *LyskServer) buildCompanionBoard(companion string) models.Ranking {
+	records := models.Records(s.orbitRecordStore.GetAll()).Filter(func(r models.Record) bool {
+		return !r.Deleted && r.Companion == companion
+	})
+
+	return buildTopScoreRanking(records)
+}
+
+func (s *LyskServer) buildChampionshipRoundBoard(start, end time.Time, levelType string) models.Ranking {
+	records := models.Records(s.championshipsRecordStore.GetAll()).Filter(func(r models.Record) bool {
+		if r.Deleted || (levelType != "" && r.LevelType != levelType) {
+			return false
+		}
+		return isWithin(r.Time, start, end)
+	})
+
+	return buildTopScoreRanking(records)
+}
+
+func (s *LyskServer) buildDeepestLevelBoard(levelType, levelMode string) models.Ranking {
+	deepest := map[string]string{}
+	for _, record := range s.orbitRecordStore.GetAll() {
+		if record.Deleted || !isAttributable(record) {
+			continue
+		}
+		if record.LevelType != levelType || record.LevelMode != levelMode {
+			continue
+		}
+
+		current, exists := deepest[record.UserID]
+		if !exists || levelDepth(record.LevelNumber) > levelDepth(current) {
+			deepest[record.UserID] = record.LevelNumber
+		}
+	}
+
+	ranking := models.Ranking{}
+	for userId, levelNumber := range deepest {
+		ranking = append(ranking, models.RankingItem{
+			OpenID: userId,
+			Score:  levelNumberValue(levelNumber),
+			Level:  levelNumber,
+		})
+	}
+
+	sort.SliceStable(ranking, func(i, j int) bool {
+		return byLevelDepth(ranking[i]) > byLevelDepth(ranking[j])
+	})
+
+	return ranking
+}
+
+func (s *LyskServer) buildContributionBoard(start, end time.Time) models.Ranking {
+	contribution := map[string]int32{}
+	records := append(s.orbitRecordStore.GetAll(), s.championshipsRecordStore.GetAll()...)
+	for _, record := range records {
+		if record.Deleted || !isAttributable(record) || !isWithin(record.Time, start, end) {
+			continue
+		}
+		contribution[record.UserID]++
+	}
+
+	ranking := models.Ranking{}
+	for userId, count := range contribution {
+		ranking = append(ranking, models.RankingItem{
+			OpenID:       userId,
+			Contribution: count,
+		})
+	}
+
+	sort.Slice(ranking, func(i, j int) bool {
+		return ranking[i].Contribution > ranking[j].Contribution
+	})
+
+	return ranking
+}
+
+// buildTopScoreRanking keeps the highest BuffedScore of each uploader.
+func buildTopScoreRanking(records []models.Record) models.Ranking {
+	best := map[string]models.RankingItem{}
+	for _, record := range records {
+		if !isAttributable(record) {
+			continue
+		}
+
+		score, ok := parseBuffedScore(record)
+		if !ok {
+			continue
+		}
+
+		if current, exists := best[record.UserID]; !exists || score > current.Score {
+			best[record.UserID] = models.RankingItem{
+				OpenID: record.UserID,
+				Score:  score,
+				Level:  formatLevelName(record.GenerateLevelKey()),
+			}
+		}
+	}
+
+	ranking := models.Ranking{}
+	for _, item := range best {
+		ranking = append(ranking, item)
+	}
+
+	sort.Slice(ranking, func(i, j int) bool {
+		return ranking[i].Score > ranking[j].Score
+	})
+
+	return ranking
+}
+
+func byContribution(item models.RankingItem) int64 {
+	return int64(item.Contribution)
+}
+
+func byScore(item models.RankingItem) int64 {
+	return int64(item.Score)
+}
+
+func byLevelDepth(item models.RankingItem) int64 {
+	return int64(levelDepth(item.Level))
+}
+
+// levelDepth orders level numbers such as "120_上" and "120_下" so that 下 is deeper than 上.
+func levelDepth(levelNumber string) int {
+	depth := levelNumberValue(levelNumber) * 10
+	if strings.HasSuffix(levelNumber, "_上") {
+		depth += 1
+	} else if strings.HasSuffix(levelNumber, "_下") {
+		depth += 2
+	}
+	return depth
+}
+
+func levelNumberValue(levelNumber string) int {
+	parts := strings.Split(levelNumber, "_")
+	value, _ := strconv.Atoi(parts[0])
+	return value
+}
+
+func parseBuffedScore(record models.Record) (int, bool) {
+	if record.CombatPower.BuffedScore == "" || record.CombatPower.BuffedScore == models.NoData {
+		return 0, false
+	}
+
+	score, err := strconv.Atoi(record.CombatPower.BuffedScore)
+	if err != nil || score <= 0 {
+		return 0, false
+	}
+
+	return score, true
+}
+
+func hasUploader(record models.Record) bool {
+	return len(record.UserID) > 0 && record.UserID != "<nil>"
+}
+
+// isAttributable reports whether a record may be credited to its uploader on leaderboards.
+func isAttributable(record models.Record) bool {
+	return hasUploader(record) && !record.Anonymous
+}
+
+func isWithin(recordTime string, start, end time.Time) bool {
+	t, err := time.Parse(time.RFC3339, recordTime)
+	if err != nil {
+		return false
+	}
+	return t.After(start) && t.Before(end)
+}
+
+func getViewerID(c *gin.Context) string {
+	if userId, exists := c.Get("userID"); exists {
+		return userId.(string)
+	}
+	return ""
+}
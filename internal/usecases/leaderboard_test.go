@@ -0,0 +1,151 @@
+package usecases
+
+import (
+	"reflect"
+	"testing"
+	"time"
+
+	"lysk-battle-record/internal/models"
+	"lysk-battle-record/internal/pkg"
+)
+
+func scoredRecord(id, userId, levelNumber, score string, recordTime time.Time) models.Record {
+	record := testRecord(id)
+	record.UserID, record.LevelNumber = userId, levelNumber
+	record.CombatPower.BuffedScore = score
+	record.Time = recordTime.Format(time.RFC3339)
+	return record
+}
+
+func rankedUsers(ranking models.Ranking) []string {
+	users := []string{}
+	for _, item := range ranking {
+		users = append(users, item.OpenID)
+	}
+	return users
+}
+
+func TestLeaderboardBoards(t *testing.T) {
+	now := time.Now().UTC()
+	lastWeek := now.AddDate(0, 0, -7)
+
+	anonymous := scoredRecord("anon", "u5", "120", "9000", now)
+	anonymous.Anonymous = true
+	deleted := scoredRecord("deleted", "u5", "120", "9000", now)
+	deleted.Deleted = true
+	otherCompanion := scoredRecord("other", "u3", "60", "5000", now)
+	otherCompanion.Companion = "远空执舰官"
+
+	s := &LyskServer{
+		orbitRecordStore: &fakeRecordStore{records: []models.Record{
+			scoredRecord("a1", "u1", "120", "1000", now),
+			scoredRecord("a2", "u1", "120", "1500", lastWeek),
+			scoredRecord("b1", "u2", "120", "1200", now),
+			scoredRecord("b2", "u2", "300_下", "800", now),
+			scoredRecord("c1", "u3", "300_上", "900", now),
+			scoredRecord("d1", "u4", "120", "2000", now),
+			scoredRecord("nobody", "", "120", "3000", now),
+			anonymous, deleted, otherCompanion,
+		}},
+		championshipsRecordStore: &fakeRecordStore{},
+		userStore: newFakeUserStore(
+			models.User{ID: "u1", Nickname: "一号"},
+			models.User{ID: "u2", Nickname: "二号", PrivacySettings: models.PrivacySettings{HideNickname: true}},
+			models.User{ID: "u4", Nickname: "四号", PrivacySettings: models.PrivacySettings{HideFromRanking: true}},
+		),
+		pseudonymizer: pkg.NewPseudonymizer("secret"),
+	}
+
+	// 每人只取最高分，匿名、已删除和没有上传者的记录不上榜
+	levelBoard := s.buildLevelCPBoard("光", "120", "稳定")
+	if got := rankedUsers(levelBoard); !reflect.DeepEqual(got, []string{"u4", "u1", "u2"}) {
+		t.Fatalf("unexpected level board %v", got)
+	}
+	if levelBoard[1].Score != 1500 || levelBoard[1].Level != "光 120" {
+		t.Errorf("unexpected best record %+v", levelBoard[1])
+	}
+
+	if got := rankedUsers(s.buildCompanionBoard("远空执舰官")); !reflect.DeepEqual(got, []string{"u3"}) {
+		t.Errorf("unexpected companion board %v", got)
+	}
+
+	// 300_下 比 300_上 更深
+	deepest := s.buildDeepestLevelBoard("光", "稳定")
+	if got := rankedUsers(deepest); len(got) != 4 || !reflect.DeepEqual(got[:2], []string{"u2", "u3"}) {
+		t.Errorf("unexpected deepest level board %v", got)
+	}
+
+	weekly := s.buildContributionBoard(now.Add(-time.Hour), now.Add(time.Hour))
+	contributions := map[string]int32{}
+	for _, item := range weekly {
+		contributions[item.OpenID] = item.Contribution
+	}
+	if want := map[string]int32{"u1": 1, "u2": 2, "u3": 2, "u4": 1}; !reflect.DeepEqual(contributions, want) {
+		t.Errorf("unexpected weekly contributions %v", contributions)
+	}
+
+	// 隐藏排名的用户被移除，其他人换成公开 ID
+	final := s.finalizeRanking(levelBoard, byScore, "u2")
+	if got := rankedUsers(final); !reflect.DeepEqual(got, []string{"u1", "u2"}) {
+		t.Fatalf("unexpected final ranking %v", got)
+	}
+	if final[0].Rank != 1 || final[0].Nickname != "一号" || final[0].IsMe || final[0].PublicID != s.pseudonymizer.PublicID("u1") {
+		t.Errorf("unexpected first item %+v", final[0])
+	}
+	if final[1].Rank != 2 || final[1].Nickname != "" || !final[1].IsMe {
+		t.Errorf("unexpected viewer item %+v", final[1])
+	}
+}
+
+func TestChampionshipRoundBoard(t *testing.T) {
+	start, end := time.Now().Add(-time.Hour), time.Now().Add(time.Hour)
+	inRound := func(id, userId, levelType, score string, recordTime time.Time) models.Record {
+		record := scoredRecord(id, userId, "", score, recordTime)
+		record.LevelType, record.LevelMode = levelType, ""
+		return record
+	}
+
+	s := &LyskServer{championshipsRecordStore: &fakeRecordStore{records: []models.Record{
+		inRound("a", "u1", "A4", "1000", time.Now()),
+		inRound("b", "u2", "B4", "2000", time.Now()),
+		inRound("c", "u3", "A4", "3000", start.Add(-time.Hour)),
+	}}}
+
+	if got := rankedUsers(s.buildChampionshipRoundBoard(start, end, "")); !reflect.DeepEqual(got, []string{"u2", "u1"}) {
+		t.Errorf("unexpected round board %v", got)
+	}
+	if got := rankedUsers(s.buildChampionshipRoundBoard(start, end, "A4")); !reflect.DeepEqual(got, []string{"u1"}) {
+		t.Errorf("unexpected A4 board %v", got)
+	}
+}
+
+func TestPopulatePublicRecords(t *testing.T) {
+	s := &LyskServer{
+		userStore: newFakeUserStore(
+			models.User{ID: "u1", Nickname: "一号"},
+			models.User{ID: "u2", Nickname: "二号", PrivacySettings: models.PrivacySettings{HideNickname: true}},
+		),
+		pseudonymizer: pkg.NewPseudonymizer("secret"),
+	}
+
+	anonymous := models.Record{UserID: "u1", Anonymous: true}
+	records := []models.Record{{UserID: "u1"}, {UserID: "u2"}, anonymous, {UserID: "<nil>"}, {}}
+	s.populatePublicRecords(records)
+
+	for i, record := range records {
+		if record.UserID != "" {
+			t.Errorf("record %d exposes the OpenID %q", i, record.UserID)
+		}
+	}
+	if records[0].PublicID != s.pseudonymizer.PublicID("u1") || records[0].Nickname != "一号" {
+		t.Errorf("unexpected public record %+v", records[0])
+	}
+	if records[1].PublicID == "" || records[1].Nickname != "" {
+		t.Errorf("hidden nickname shown: %+v", records[1])
+	}
+	for _, record := range records[2:] {
+		if record.PublicID != "" || record.Nickname != "" {
+			t.Errorf("anonymous record identified: %+v", record)
+		}
+	}
+}
@@ -19,6 +19,11 @@ type LevelMinCP struct {
 }
 
 func (s *LyskServer) GetMinCombatPower(c *gin.Context) {
+	c.JSON(http.StatusOK, s.minCombatPower())
+}
+
+// minCombatPower is the lowest buffed score recorded on every tenth orbit level.
+func (s *LyskServer) minCombatPower() []LevelMinCP {
 	levelRecords := s.orbitRecordStore.GetAllLevelRecords()
 	var response []LevelMinCP
 
@@ -91,5 +96,5 @@ func (s *LyskServer) GetMinCombatPower(c *gin.Context) {
 		return suffI < suffJ
 	})
 
-	c.JSON(http.StatusOK, response)
+	return response
 }
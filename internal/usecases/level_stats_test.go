@@ -21,11 +21,12 @@ func (m *MockRecordStore) Update(record models.Record) error { return nil }
 func (m *MockRecordStore) Delete(record models.Record) error { return nil }
 func (m *MockRecordStore) PrepareInsert(record models.Record) error { return nil }
 func (m *MockRecordStore) IsDuplicate(record models.Record) bool { return false }
-func (m *MockRecordStore) GetRanking(userId string) []models.RankingItem { return nil }
+func (m *MockRecordStore) GetContributionRanking() models.Ranking { return nil }
 func (m *MockRecordStore) EvaluateRecord(record models.Record) string { return "" }
 func (m *MockRecordStore) GetLevelRecords(record models.Record) []models.Record { return nil }
 func (m *MockRecordStore) GetCompanionCounts() map[string]int { return nil }
 func (m *MockRecordStore) GetPartnerLevelCounts() map[string]int { return nil }
+func (m *MockRecordStore) Subscribe(listener func()) {}
 
 func (m *MockRecordStore) GetAllLevelRecords() map[string][]models.Record {
 	return map[string][]models.Record{
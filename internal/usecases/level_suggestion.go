@@ -92,20 +92,29 @@ func (s *LyskServer) GetSuggestedCP(cps []int) int {
 }
 
 func (s *LyskServer) GetLevelSuggestion(c *gin.Context) {
-	levelType, levelNumber, levelMode := c.Query("type"), c.Query("level"), c.Query("mode")
-	if levelNumber == "" {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "关卡参数不能为空"})
+	response, apiErr := s.levelSuggestion(c.Query("type"), c.Query("level"), c.Query("mode"), utils.GetCompanion(c), utils.GetSetCard(c))
+	if apiErr != nil {
+		apiErr.respondV1(c)
 		return
 	}
 
+	c.JSON(http.StatusOK, response)
+}
+
+// levelSuggestion summarises the records of a level, optionally of a companion and set card.
+func (s *LyskServer) levelSuggestion(levelType, levelNumber, levelMode, companion, setCard string) (LevelSuggestionResponse, *apiError) {
+	if levelNumber == "" {
+		return LevelSuggestionResponse{}, newAPIError(http.StatusBadRequest, "level_required", nil)
+	}
+
 	// Create a temporary record to use with GetLevelRecords
 	tempRecord := models.Record{
 		LevelType:   levelType,
 		LevelNumber: levelNumber,
 		LevelMode:   levelMode,
 		Time:        time.Now().Format(time.RFC3339),
-		Companion:   utils.GetCompanion(c),
-		SetCard:     utils.GetSetCard(c),
+		Companion:   companion,
+		SetCard:     setCard,
 	}
 
 	// Determine which store to use
@@ -154,7 +163,7 @@ func (s *LyskServer) GetLevelSuggestion(c *gin.Context) {
 		}
 	}
 
-	response := LevelSuggestionResponse{
+	return LevelSuggestionResponse{
 		LevelType:             levelType,
 		LevelNumber:           levelNumber,
 		LevelMode:             levelMode,
@@ -163,7 +172,5 @@ func (s *LyskServer) GetLevelSuggestion(c *gin.Context) {
 		CompanionSetCardPairs: companionSetCardPairs,
 		Crit:                  critCount,
 		Weak:                  weakCount,
-	}
-
-	c.JSON(http.StatusOK, response)
+	}, nil
 }
@@ -0,0 +1,46 @@
+package usecases
+
+import (
+	"time"
+
+	"github.com/sirupsen/logrus"
+
+	"lysk-battle-record/internal/datastores"
+	"lysk-battle-record/internal/estimator"
+	"lysk-battle-record/internal/estimator/levels"
+)
+
+// RunThresholdWorker periodically fits the level thresholds against the stored records, so the
+// verdict of a level estimate follows the records as they come in.
+func (s *LyskServer) RunThresholdWorker(interval time.Duration) {
+	for {
+		time.Sleep(interval)
+		s.refreshLevelThresholds()
+	}
+}
+
+func (s *LyskServer) refreshLevelThresholds() {
+	scores := map[string][]float64{}
+	for _, store := range []datastores.RecordStore{s.orbitRecordStore, s.championshipsRecordStore} {
+		for _, records := range store.GetAllLevelRecords() {
+			for _, record := range records {
+				if record.Deleted {
+					continue
+				}
+
+				// stored scores are against the reference enemy, the thresholds are compared
+				// with estimates against the level
+				record.EstimatorVersion = s.cpEstimator.Current()
+				level := estimator.EstimateForLevel(s.cpEstimator, record).Level
+				if level == nil || level.BuffedScore <= 0 {
+					continue
+				}
+				scores[level.Key] = append(scores[level.Key], float64(level.BuffedScore))
+			}
+		}
+	}
+
+	thresholds := levels.FitThresholds(scores)
+	levels.SetThresholds(thresholds)
+	logrus.Infof("[Levels] Fitted thresholds for %d of %d levels", len(thresholds), len(scores))
+}
@@ -0,0 +1,46 @@
+package usecases
+
+import (
+	"strconv"
+	"testing"
+
+	"lysk-battle-record/internal/estimator"
+	"lysk-battle-record/internal/estimator/levels"
+	"lysk-battle-record/internal/models"
+)
+
+func TestRefreshLevelThresholds(t *testing.T) {
+	var records []models.Record
+	for i := 0; i < levels.MinThresholdRecords; i++ {
+		record := testRecord(strconv.Itoa(i))
+		record.Attack = strconv.Itoa(8000 + i*200)
+		records = append(records, record)
+	}
+	sparse := testRecord("sparse")
+	sparse.LevelNumber = "130"
+	deleted := testRecord("deleted")
+	deleted.Attack = "1"
+	deleted.Deleted = true
+	records = append(records, sparse, deleted)
+
+	s := &LyskServer{
+		orbitRecordStore:         &fakeRecordStore{records: records},
+		championshipsRecordStore: &fakeRecordStore{},
+		cpEstimator:              estimator.NewVersionedEstimator(""),
+	}
+	s.refreshLevelThresholds()
+	defer levels.SetThresholds(nil)
+
+	// 第 6 低的攻击力对应 25% 分位
+	quartile := records[levels.MinThresholdRecords/4]
+	quartile.EstimatorVersion = s.cpEstimator.Current()
+	want := estimator.EstimateForLevel(s.cpEstimator, quartile).Level.BuffedScore
+
+	level, _ := levels.Lookup(quartile.GenerateLevelKey())
+	if want <= 0 || int(level.Threshold) != want {
+		t.Errorf("expected threshold %d, got %v", want, level.Threshold)
+	}
+	if level, _ := levels.Lookup(sparse.GenerateLevelKey()); level.Threshold != 0 {
+		t.Errorf("level with one record got threshold %v", level.Threshold)
+	}
+}
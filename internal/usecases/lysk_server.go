@@ -4,13 +4,17 @@ import (
 	"sync"
 
 	"lysk-battle-record/internal/datastores"
+	"lysk-battle-record/internal/estimator"
 	"lysk-battle-record/internal/pkg"
 	"lysk-battle-record/internal/sheet_clients"
 )
 
 func InitLyskServer(orbitRecordStore datastores.RecordStore, orbitSheetClient sheet_clients.RecordSheetClient,
 	championshipsRecordStore datastores.RecordStore, championshipsSheetClient sheet_clients.RecordSheetClient,
-	userStore datastores.UserStore, userSheetClient sheet_clients.UserSheetClient, auth *pkg.Authenticator) *LyskServer {
+	userStore datastores.UserStore, userSheetClient sheet_clients.UserSheetClient,
+	deletionRequestStore datastores.DeletionRequestStore, deletionSheetClient sheet_clients.DeletionSheetClient,
+	identityStore datastores.IdentityStore, identitySheetClient sheet_clients.IdentitySheetClient,
+	auth *pkg.Authenticator, pseudonymizer *pkg.Pseudonymizer, cpEstimator *estimator.VersionedEstimator) *LyskServer {
 
 	return &LyskServer{
 		orbitRecordStore:         orbitRecordStore,
@@ -19,7 +23,14 @@ func InitLyskServer(orbitRecordStore datastores.RecordStore, orbitSheetClient sh
 		championshipsSheetClient: championshipsSheetClient,
 		userStore:                userStore,
 		userSheetClient:          userSheetClient,
+		deletionRequestStore:     deletionRequestStore,
+		deletionSheetClient:      deletionSheetClient,
+		identityStore:            identityStore,
+		identitySheetClient:      identitySheetClient,
 		auth:                     auth,
+		pseudonymizer:            pseudonymizer,
+		cpEstimator:              cpEstimator,
+		maxMissedRefreshes:       2,
 	}
 }
 
@@ -30,6 +41,20 @@ type LyskServer struct {
 	championshipsSheetClient sheet_clients.RecordSheetClient
 	userStore                datastores.UserStore
 	userSheetClient          sheet_clients.UserSheetClient
+	deletionRequestStore     datastores.DeletionRequestStore
+	deletionSheetClient      sheet_clients.DeletionSheetClient
+	identityStore            datastores.IdentityStore
+	identitySheetClient      sheet_clients.IdentitySheetClient
 	auth                     *pkg.Authenticator
+	pseudonymizer            *pkg.Pseudonymizer
+	cpEstimator              *estimator.VersionedEstimator
+	rateLimiter              *pkg.RateLimiter
+	revocationStore          datastores.Refreshable
+	maxMissedRefreshes       int    // 就绪检查允许连续失败的刷新次数
+	gameContentFile          string // 关卡目录文件，为空时使用内置目录
+	sheetProbe               sheetProbe
 	userCreationMutex        sync.Mutex
+	deletionMutex            sync.Mutex
+	identityMutex            sync.Mutex
+	migrationMutex           sync.Mutex
 }
\ No newline at end of file
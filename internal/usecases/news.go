@@ -180,7 +180,11 @@ func (s *LyskServer) getTopCPRecords(records []models.Record) []TopRecord {
 }
 
 func (s *LyskServer) GetNews(c *gin.Context) {
-	news := News{
+	c.JSON(http.StatusOK, s.news())
+}
+
+func (s *LyskServer) news() News {
+	return News{
 		OrbitRecordCompanionCounts:         s.GetOrbitRecordCompanionCounts(),
 		ChampionshipsRecordCompanionCounts: s.GetChampionshipsRecordCompanionCounts(),
 		OrbitRecordPartnerCounts:           s.GetOrbitRecordPartnerCounts(),
@@ -190,20 +194,23 @@ func (s *LyskServer) GetNews(c *gin.Context) {
 		OrbitTopMostRecordsLevels: s.GetOrbitTopMostRecordsLevels(),
 		OrbitLevelCounts:          s.GetOrbitLevelCounts(),
 	}
-
-	c.JSON(http.StatusOK, news)
 }
 
 func (s *LyskServer) GetUserNews(c *gin.Context) {
 	userId, exists := c.Get("userID")
 	if !exists {
-		c.JSON(http.StatusUnauthorized, gin.H{"error": "未登录或无效的用户"})
+		respondError(c, http.StatusUnauthorized, "unauthenticated_user")
 		return
 	}
 
+	c.JSON(http.StatusOK, s.userNews(userId.(string)))
+}
+
+// userNews is the news of the records of a single user.
+func (s *LyskServer) userNews(userId string) News {
 	news := News{}
-	orbitRecords := s.getUserOrbitRecord(userId.(string))
-	championshipsRecords := s.getUserChampionshipsRecord(userId.(string))
+	orbitRecords := s.getUserOrbitRecord(userId)
+	championshipsRecords := s.getUserChampionshipsRecord(userId)
 
 	news.OrbitLevelCounts = len(orbitRecords)
 	news.ChampionshipsLevelCounts = len(championshipsRecords)
@@ -216,7 +223,7 @@ func (s *LyskServer) GetUserNews(c *gin.Context) {
 	news.OrbitTopCPRecords = s.getTopCPRecords(orbitRecords)
 	news.ChampionshipsTopCPRecords = s.getTopCPRecords(championshipsRecords)
 
-	c.JSON(http.StatusOK, news)
+	return news
 }
 
 func formatLevelName(level string) string {
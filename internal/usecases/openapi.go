@@ -0,0 +1,221 @@
+package usecases
+
+import (
+	"net/http"
+	"reflect"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+
+	"lysk-battle-record/internal/pkg"
+)
+
+const v2Prefix = "/v2"
+
+// v2Route is a route of the v2 API along with what the OpenAPI document says about it.
+type v2Route struct {
+	method   string
+	path     string
+	summary  string
+	auth     bool
+	query    []string    // 查询参数
+	request  interface{} // 请求体的类型，nil 为没有请求体
+	response interface{} // 成功时的响应体，nil 为没有响应体
+	status   int         // 成功时的状态码
+	handler  gin.HandlerFunc
+}
+
+var recordFilterParams = []string{"level_type", "level_number", "level_part", "level_mode", "companion", "set_card", "stage", "weapon", "offset"}
+
+func (s *LyskServer) v2Routes() []v2Route {
+	var routes []v2Route
+	for _, kind := range []struct {
+		recordKind
+		path, name string
+	}{
+		{s.orbitKind(), "orbit-records", "orbit"},
+		{s.championshipsKind(), "championships-records", "championships"},
+	} {
+		routes = append(routes,
+			v2Route{method: http.MethodGet, path: "/" + kind.path, summary: "List " + kind.name + " records",
+				query: recordFilterParams, response: RecordPageV2{}, status: http.StatusOK, handler: s.listRecordsV2(kind.recordKind)},
+			v2Route{method: http.MethodGet, path: "/" + kind.path + "/latest", summary: "Latest " + kind.name + " records",
+				response: RecordPageV2{}, status: http.StatusOK, handler: s.latestRecordsV2(kind.recordKind)},
+			v2Route{method: http.MethodGet, path: "/me/" + kind.path, summary: "My " + kind.name + " records", auth: true,
+				query: recordFilterParams, response: RecordPageV2{}, status: http.StatusOK, handler: s.myRecordsV2(kind.recordKind)},
+			v2Route{method: http.MethodPost, path: "/" + kind.path, summary: "Upload " + kind.name + " record", auth: true,
+				request: RecordRequestV2{}, response: RecordResponseV2{}, status: http.StatusCreated, handler: s.createRecordV2(kind.recordKind)},
+			v2Route{method: http.MethodPut, path: "/" + kind.path + "/:id", summary: "Update " + kind.name + " record", auth: true,
+				request: RecordRequestV2{}, response: RecordResponseV2{}, status: http.StatusOK, handler: s.updateRecordV2(kind.recordKind)},
+			v2Route{method: http.MethodDelete, path: "/" + kind.path + "/:id", summary: "Delete " + kind.name + " record", auth: true,
+				status: http.StatusNoContent, handler: s.deleteRecordV2(kind.recordKind)},
+		)
+	}
+	return routes
+}
+
+// RegisterV2 adds the v2 API and its OpenAPI document to the router. v1 stays where it is.
+func (s *LyskServer) RegisterV2(r gin.IRouter) {
+	group := r.Group(v2Prefix)
+	for _, route := range s.v2Routes() {
+		class := pkg.RouteWrite
+		if route.method == http.MethodGet {
+			class = pkg.RouteRead
+		}
+		handlers := []gin.HandlerFunc{s.rateLimitV2(class), route.handler}
+		if route.auth {
+			handlers = append([]gin.HandlerFunc{s.requireAuthV2()}, handlers...)
+		}
+		group.Handle(route.method, route.path, handlers...)
+	}
+	group.GET("/openapi.json", s.GetOpenAPI)
+}
+
+// GetOpenAPI serves the OpenAPI 3 document of the v2 API, generated from the routes and DTOs.
+func (s *LyskServer) GetOpenAPI(c *gin.Context) {
+	c.JSON(http.StatusOK, openAPIDocument(s.v2Routes()))
+}
+
+func openAPIDocument(routes []v2Route) gin.H {
+	schemas := gin.H{}
+	paths := gin.H{}
+	errorSchema := schemaRef(reflect.TypeOf(ErrorV2{}), schemas)
+
+	for _, route := range routes {
+		path := v2Prefix + pathTemplate(route.path)
+		item, ok := paths[path].(gin.H)
+		if !ok {
+			item = gin.H{}
+			paths[path] = item
+		}
+
+		operation := gin.H{
+			"summary": route.summary,
+			"responses": gin.H{
+				"default": gin.H{
+					"description": "Error",
+					"content":     gin.H{"application/json": gin.H{"schema": errorSchema}},
+				},
+			},
+		}
+		success := gin.H{"description": http.StatusText(route.status)}
+		if route.response != nil {
+			success["content"] = gin.H{"application/json": gin.H{"schema": schemaRef(reflect.TypeOf(route.response), schemas)}}
+		}
+		operation["responses"].(gin.H)[strconv.Itoa(route.status)] = success
+
+		var parameters []gin.H
+		for _, segment := range strings.Split(route.path, "/") {
+			if strings.HasPrefix(segment, ":") {
+				parameters = append(parameters, gin.H{"name": segment[1:], "in": "path", "required": true, "schema": gin.H{"type": "string"}})
+			}
+		}
+		for _, name := range route.query {
+			parameters = append(parameters, gin.H{"name": name, "in": "query", "schema": gin.H{"type": "string"}})
+		}
+		if len(parameters) > 0 {
+			operation["parameters"] = parameters
+		}
+		if route.request != nil {
+			operation["requestBody"] = gin.H{
+				"required": true,
+				"content":  gin.H{"application/json": gin.H{"schema": schemaRef(reflect.TypeOf(route.request), schemas)}},
+			}
+		}
+		if route.auth {
+			operation["security"] = []gin.H{{"bearerAuth": []string{}}}
+		}
+		item[strings.ToLower(route.method)] = operation
+	}
+
+	return gin.H{
+		"openapi": "3.0.3",
+		"info":    gin.H{"title": "LYSK Battle Record API", "version": "2"},
+		"paths":   paths,
+		"components": gin.H{
+			"schemas":         schemas,
+			"securitySchemes": gin.H{"bearerAuth": gin.H{"type": "http", "scheme": "bearer", "bearerFormat": "JWT"}},
+		},
+	}
+}
+
+// pathTemplate turns gin parameters (:id) into OpenAPI ones ({id}).
+func pathTemplate(path string) string {
+	segments := strings.Split(path, "/")
+	for i, segment := range segments {
+		if strings.HasPrefix(segment, ":") {
+			segments[i] = "{" + segment[1:] + "}"
+		}
+	}
+	return strings.Join(segments, "/")
+}
+
+var timeType = reflect.TypeOf(time.Time{})
+
+// schemaRef returns the schema of the type. Structs are added to the components once and
+// referenced.
+func schemaRef(t reflect.Type, schemas gin.H) gin.H {
+	for t.Kind() == reflect.Pointer {
+		t = t.Elem()
+	}
+
+	switch {
+	case t == timeType:
+		return gin.H{"type": "string", "format": "date-time"}
+	case t.Kind() == reflect.Struct:
+		if _, ok := schemas[t.Name()]; !ok {
+			schemas[t.Name()] = gin.H{} // 先占位，防止递归
+			schemas[t.Name()] = structSchema(t, schemas)
+		}
+		return gin.H{"$ref": "#/components/schemas/" + t.Name()}
+	case t.Kind() == reflect.Slice:
+		return gin.H{"type": "array", "items": schemaRef(t.Elem(), schemas)}
+	case t.Kind() == reflect.Map:
+		return gin.H{"type": "object", "additionalProperties": schemaRef(t.Elem(), schemas)}
+	case t.Kind() == reflect.String:
+		return gin.H{"type": "string"}
+	case t.Kind() == reflect.Bool:
+		return gin.H{"type": "boolean"}
+	case t.Kind() >= reflect.Int && t.Kind() <= reflect.Uint64:
+		return gin.H{"type": "integer"}
+	case t.Kind() == reflect.Float32 || t.Kind() == reflect.Float64:
+		return gin.H{"type": "number"}
+	}
+	return gin.H{}
+}
+
+func structSchema(t reflect.Type, schemas gin.H) gin.H {
+	properties := gin.H{}
+	var required []string
+	for i := 0; i < t.NumField(); i++ {
+		field := t.Field(i)
+		name, options, _ := strings.Cut(field.Tag.Get("json"), ",")
+		if !field.IsExported() || name == "-" {
+			continue
+		}
+		if name == "" {
+			name = field.Name
+		}
+
+		schema := schemaRef(field.Type, schemas)
+		if description := field.Tag.Get("description"); description != "" {
+			schema["description"] = description
+		}
+		if format := field.Tag.Get("format"); format != "" {
+			schema["format"] = format
+		}
+		properties[name] = schema
+
+		if !strings.Contains(options, "omitempty") && field.Type.Kind() != reflect.Pointer {
+			required = append(required, name)
+		}
+	}
+
+	schema := gin.H{"type": "object", "properties": properties}
+	if len(required) > 0 {
+		schema["required"] = required
+	}
+	return schema
+}
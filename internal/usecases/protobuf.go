@@ -0,0 +1,257 @@
+package usecases
+
+import (
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"sort"
+	"strings"
+
+	"google.golang.org/protobuf/encoding/protojson"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/reflect/protodesc"
+	"google.golang.org/protobuf/reflect/protoreflect"
+	"google.golang.org/protobuf/types/descriptorpb"
+	"google.golang.org/protobuf/types/dynamicpb"
+)
+
+const (
+	rpcPackage  = "lysk.v1"
+	rpcService  = "Lysk"
+	rpcFilePath = "lysk/v1/lysk.proto"
+)
+
+// protoFile builds the protobuf file of the gRPC service from the request and response DTOs,
+// the same way the OpenAPI document is built for the v2 API. Field numbers follow the order of
+// the struct fields, so new fields go at the end of the structs.
+type protoFile struct {
+	file     *descriptorpb.FileDescriptorProto
+	messages map[string]*descriptorpb.DescriptorProto
+	comments map[string]string // 字段说明，键为 消息名.字段名
+}
+
+func newProtoFile(methods []rpcMethod) *protoFile {
+	f := &protoFile{
+		file: &descriptorpb.FileDescriptorProto{
+			Name:    proto.String(rpcFilePath),
+			Package: proto.String(rpcPackage),
+			Syntax:  proto.String("proto3"),
+		},
+		messages: map[string]*descriptorpb.DescriptorProto{},
+		comments: map[string]string{},
+	}
+
+	service := &descriptorpb.ServiceDescriptorProto{Name: proto.String(rpcService)}
+	for _, method := range methods {
+		service.Method = append(service.Method, &descriptorpb.MethodDescriptorProto{
+			Name:            proto.String(method.name),
+			InputType:       proto.String(f.message(reflect.TypeOf(method.request))),
+			OutputType:      proto.String(f.message(reflect.TypeOf(method.response))),
+			ServerStreaming: proto.Bool(method.stream),
+		})
+		f.comments[method.name] = method.summary
+	}
+	f.file.Service = []*descriptorpb.ServiceDescriptorProto{service}
+
+	sort.Slice(f.file.MessageType, func(i, j int) bool {
+		return f.file.MessageType[i].GetName() < f.file.MessageType[j].GetName()
+	})
+	return f
+}
+
+// message adds the struct as a message once and returns its full name.
+func (f *protoFile) message(t reflect.Type) string {
+	name := t.Name()
+	if _, ok := f.messages[name]; !ok {
+		message := &descriptorpb.DescriptorProto{Name: proto.String(name)}
+		f.messages[name] = message // 先占位，防止递归
+		f.file.MessageType = append(f.file.MessageType, message)
+		f.fields(message, t)
+	}
+	return "." + rpcPackage + "." + name
+}
+
+func (f *protoFile) fields(message *descriptorpb.DescriptorProto, t reflect.Type) {
+	var optional []*descriptorpb.FieldDescriptorProto
+	for i := 0; i < t.NumField(); i++ {
+		field := t.Field(i)
+		name, _, _ := strings.Cut(field.Tag.Get("json"), ",")
+		if !field.IsExported() || name == "-" {
+			continue
+		}
+		if name == "" {
+			name = field.Name
+		}
+
+		descriptor := &descriptorpb.FieldDescriptorProto{
+			Name:   proto.String(name),
+			Number: proto.Int32(int32(len(message.Field) + 1)),
+			Label:  descriptorpb.FieldDescriptorProto_LABEL_OPTIONAL.Enum(),
+		}
+		fieldType := field.Type
+		switch {
+		case fieldType.Kind() == reflect.Pointer:
+			fieldType = fieldType.Elem()
+			if fieldType.Kind() != reflect.Struct || fieldType == timeType {
+				optional = append(optional, descriptor)
+			}
+		case fieldType.Kind() == reflect.Slice:
+			fieldType = fieldType.Elem()
+			descriptor.Label = descriptorpb.FieldDescriptorProto_LABEL_REPEATED.Enum()
+		case fieldType.Kind() == reflect.Map:
+			descriptor.Label = descriptorpb.FieldDescriptorProto_LABEL_REPEATED.Enum()
+			descriptor.Type = descriptorpb.FieldDescriptorProto_TYPE_MESSAGE.Enum()
+			descriptor.TypeName = proto.String(f.mapEntry(message, field.Name, fieldType))
+		}
+		if descriptor.Type == nil {
+			descriptor.Type, descriptor.TypeName = f.scalar(fieldType)
+		}
+
+		message.Field = append(message.Field, descriptor)
+		if description := field.Tag.Get("description"); description != "" {
+			f.comments[message.GetName()+"."+name] = description
+		}
+	}
+
+	// proto3 optional 字段各自放在一个合成的 oneof 里
+	for _, descriptor := range optional {
+		descriptor.Proto3Optional = proto.Bool(true)
+		descriptor.OneofIndex = proto.Int32(int32(len(message.OneofDecl)))
+		message.OneofDecl = append(message.OneofDecl, &descriptorpb.OneofDescriptorProto{Name: proto.String("_" + descriptor.GetName())})
+	}
+}
+
+// mapEntry adds the nested entry message of a map field.
+func (f *protoFile) mapEntry(message *descriptorpb.DescriptorProto, fieldName string, t reflect.Type) string {
+	name := fieldName + "Entry"
+	key, keyName := f.scalar(t.Key())
+	value, valueName := f.scalar(t.Elem())
+	message.NestedType = append(message.NestedType, &descriptorpb.DescriptorProto{
+		Name: proto.String(name),
+		Field: []*descriptorpb.FieldDescriptorProto{
+			{Name: proto.String("key"), Number: proto.Int32(1), Label: descriptorpb.FieldDescriptorProto_LABEL_OPTIONAL.Enum(), Type: key, TypeName: keyName},
+			{Name: proto.String("value"), Number: proto.Int32(2), Label: descriptorpb.FieldDescriptorProto_LABEL_OPTIONAL.Enum(), Type: value, TypeName: valueName},
+		},
+		Options: &descriptorpb.MessageOptions{MapEntry: proto.Bool(true)},
+	})
+	return "." + rpcPackage + "." + message.GetName() + "." + name
+}
+
+// scalar maps a Go type to its protobuf type. Go ints become int32, because protojson writes
+// int64 as strings and the DTOs decode numbers.
+func (f *protoFile) scalar(t reflect.Type) (*descriptorpb.FieldDescriptorProto_Type, *string) {
+	switch {
+	case t == timeType || t.Kind() == reflect.String:
+		return descriptorpb.FieldDescriptorProto_TYPE_STRING.Enum(), nil
+	case t.Kind() == reflect.Struct:
+		return descriptorpb.FieldDescriptorProto_TYPE_MESSAGE.Enum(), proto.String(f.message(t))
+	case t.Kind() == reflect.Bool:
+		return descriptorpb.FieldDescriptorProto_TYPE_BOOL.Enum(), nil
+	case t.Kind() >= reflect.Int && t.Kind() <= reflect.Int64:
+		return descriptorpb.FieldDescriptorProto_TYPE_INT32.Enum(), nil
+	case t.Kind() == reflect.Float32 || t.Kind() == reflect.Float64:
+		return descriptorpb.FieldDescriptorProto_TYPE_DOUBLE.Enum(), nil
+	}
+	panic(fmt.Sprintf("no protobuf type for %v", t))
+}
+
+// descriptor checks the built file and links it.
+func (f *protoFile) descriptor() (protoreflect.FileDescriptor, error) {
+	return protodesc.NewFile(f.file, nil)
+}
+
+// source renders the file as a .proto, for clients that generate code instead of using
+// reflection.
+func (f *protoFile) source() string {
+	var b strings.Builder
+	b.WriteString("// Code generated from the gRPC DTOs of lysk-battle-record. DO NOT EDIT.\n\n")
+	fmt.Fprintf(&b, "syntax = \"proto3\";\n\npackage %s;\n", rpcPackage)
+
+	for _, service := range f.file.Service {
+		fmt.Fprintf(&b, "\nservice %s {\n", service.GetName())
+		for _, method := range service.Method {
+			if comment := f.comments[method.GetName()]; comment != "" {
+				fmt.Fprintf(&b, "  // %s\n", comment)
+			}
+			stream := ""
+			if method.GetServerStreaming() {
+				stream = "stream "
+			}
+			fmt.Fprintf(&b, "  rpc %s(%s) returns (%s%s);\n", method.GetName(), shortName(method.GetInputType()), stream, shortName(method.GetOutputType()))
+		}
+		b.WriteString("}\n")
+	}
+
+	for _, message := range f.file.MessageType {
+		fmt.Fprintf(&b, "\nmessage %s {\n", message.GetName())
+		entries := map[string]*descriptorpb.DescriptorProto{}
+		for _, nested := range message.NestedType {
+			entries[nested.GetName()] = nested
+		}
+		for _, field := range message.Field {
+			if comment := f.comments[message.GetName()+"."+field.GetName()]; comment != "" {
+				fmt.Fprintf(&b, "  // %s\n", comment)
+			}
+			fmt.Fprintf(&b, "  %s %s = %d;\n", fieldSource(field, entries), field.GetName(), field.GetNumber())
+		}
+		b.WriteString("}\n")
+	}
+	return b.String()
+}
+
+func fieldSource(field *descriptorpb.FieldDescriptorProto, entries map[string]*descriptorpb.DescriptorProto) string {
+	if entry, ok := entries[shortName(field.GetTypeName())]; ok {
+		return fmt.Sprintf("map<%s, %s>", fieldTypeSource(entry.Field[0]), fieldTypeSource(entry.Field[1]))
+	}
+	switch {
+	case field.GetLabel() == descriptorpb.FieldDescriptorProto_LABEL_REPEATED:
+		return "repeated " + fieldTypeSource(field)
+	case field.GetProto3Optional():
+		return "optional " + fieldTypeSource(field)
+	}
+	return fieldTypeSource(field)
+}
+
+func fieldTypeSource(field *descriptorpb.FieldDescriptorProto) string {
+	if field.GetType() == descriptorpb.FieldDescriptorProto_TYPE_MESSAGE {
+		return shortName(field.GetTypeName())
+	}
+	return strings.ToLower(strings.TrimPrefix(field.GetType().String(), "TYPE_"))
+}
+
+// shortName is the name of a message relative to the package.
+func shortName(fullName string) string {
+	name := strings.TrimPrefix(fullName, "."+rpcPackage+".")
+	if i := strings.LastIndex(name, "."); i >= 0 {
+		return name[i+1:]
+	}
+	return name
+}
+
+var (
+	protoToJSON = protojson.MarshalOptions{UseProtoNames: true}
+	jsonToProto = protojson.UnmarshalOptions{DiscardUnknown: true}
+)
+
+// decodeMessage copies a message into its DTO. Field names are the JSON keys of the DTO, so the
+// protobuf JSON of the message is the DTO's JSON.
+func decodeMessage(message proto.Message, v interface{}) error {
+	data, err := protoToJSON.Marshal(message)
+	if err != nil {
+		return err
+	}
+	return json.Unmarshal(data, v)
+}
+
+// encodeMessage copies a DTO into a message of the descriptor.
+func encodeMessage(v interface{}, descriptor protoreflect.MessageDescriptor) (*dynamicpb.Message, error) {
+	data, err := json.Marshal(v)
+	if err != nil {
+		return nil, err
+	}
+	message := dynamicpb.NewMessage(descriptor)
+	if err := jsonToProto.Unmarshal(data, message); err != nil {
+		return nil, err
+	}
+	return message, nil
+}
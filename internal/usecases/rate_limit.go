@@ -0,0 +1,37 @@
+package usecases
+
+import (
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+
+	"lysk-battle-record/internal/pkg"
+)
+
+// SetRateLimiter limits the v2 and gRPC APIs. The v1 routes use its middleware directly.
+func (s *LyskServer) SetRateLimiter(rateLimiter *pkg.RateLimiter) {
+	s.rateLimiter = rateLimiter
+}
+
+func (s *LyskServer) rateLimit(class pkg.RouteClass, userID, ip string) *apiError {
+	allowed, retryAfter := s.rateLimiter.Allow(class, userID, ip)
+	if allowed {
+		return nil
+	}
+
+	apiErr := newAPIError(http.StatusTooManyRequests, "rate_limited", nil)
+	apiErr.retryAfter = retryAfter
+	return apiErr
+}
+
+// rateLimitV2 is the rate limiter middleware with the v2 error envelope.
+func (s *LyskServer) rateLimitV2(class pkg.RouteClass) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if apiErr := s.rateLimit(class, getViewerID(c), c.ClientIP()); apiErr != nil {
+			apiErr.respondV2(c)
+			c.Abort()
+			return
+		}
+		c.Next()
+	}
+}
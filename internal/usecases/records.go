@@ -0,0 +1,337 @@
+package usecases
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/sirupsen/logrus"
+	"go.opentelemetry.io/otel/attribute"
+
+	"lysk-battle-record/internal/datastores"
+	"lysk-battle-record/internal/i18n"
+	"lysk-battle-record/internal/models"
+	"lysk-battle-record/internal/pkg"
+	"lysk-battle-record/internal/sheet_clients"
+)
+
+// recordKind is what differs between orbit and championships records, so both go through the
+// same create, update and delete path in every API version.
+type recordKind struct {
+	tag          string // 日志前缀
+	store        datastores.RecordStore
+	sheetClient  sheet_clients.RecordSheetClient
+	championship bool
+}
+
+func (s *LyskServer) orbitKind() recordKind {
+	return recordKind{tag: "Orbit", store: s.orbitRecordStore, sheetClient: s.orbitSheetClient}
+}
+
+func (s *LyskServer) championshipsKind() recordKind {
+	return recordKind{tag: "Championships", store: s.championshipsRecordStore, sheetClient: s.championshipsSheetClient, championship: true}
+}
+
+func (k recordKind) validate(record models.Record) error {
+	var err error
+	if k.championship {
+		_, err = record.ValidateChampionships()
+	} else {
+		_, err = record.ValidateOrbit()
+	}
+	return err
+}
+
+// trim drops the fields the kind does not have: championships have no level number, mode or
+// star rank, and orbit records have no buff.
+func (k recordKind) trim(record *models.Record) {
+	if k.championship {
+		record.LevelNumber, record.LevelMode, record.StarRank = "", "", ""
+	} else {
+		record.Buff = ""
+	}
+}
+
+// apiError is a failed record request. Each API version renders it in its own envelope.
+type apiError struct {
+	status     int
+	code       string        // 错误码，校验失败时为空
+	err        error         // 错误详情或校验错误
+	retryAfter time.Duration // 被限流时需等待的时间
+}
+
+func newAPIError(status int, code string, err error) *apiError {
+	return &apiError{status: status, code: code, err: err}
+}
+
+// respondV1 renders the error the way the v1 handlers always have.
+func (e *apiError) respondV1(c *gin.Context) {
+	if e.retryAfter > 0 {
+		c.Header("Retry-After", pkg.RetryAfter(e.retryAfter))
+	}
+	switch {
+	case e.code == "":
+		c.JSON(e.status, validationFailure(c, e.err))
+	case e.err != nil:
+		respondErrorDetail(c, e.status, e.code, e.err)
+	default:
+		respondError(c, e.status, e.code)
+	}
+}
+
+// recordInput is a record as the v1 API sends it: Chinese keys and loosely typed values.
+type recordInput struct {
+	record    models.Record
+	anonymous *bool
+}
+
+// readRecordInput reads every record field of a v1 request. The time is checked by
+// createRecord, since updates keep the stored one.
+func readRecordInput(input map[string]interface{}) (recordInput, *apiError) {
+	record := models.Record{
+		LevelType:        pkg.GetValue(input, "关卡"),
+		LevelNumber:      pkg.GetValue(input, "关数"),
+		LevelMode:        pkg.GetValue(input, "模式"),
+		Attack:           pkg.GetValue(input, "攻击"),
+		HP:               pkg.GetValue(input, "生命"),
+		Defense:          pkg.GetValue(input, "防御"),
+		Matching:         pkg.GetValue(input, "对谱"),
+		MatchingBuff:     pkg.GetValue(input, "对谱加成"),
+		CritRate:         pkg.GetValue(input, "暴击"),
+		CritDmg:          pkg.GetValue(input, "暴伤"),
+		EnergyRegen:      pkg.GetValue(input, "加速回能"),
+		WeakenBoost:      pkg.GetValue(input, "虚弱增伤"),
+		OathBoost:        pkg.GetValue(input, "誓约增伤"),
+		OathRegen:        pkg.GetValue(input, "誓约回能"),
+		Companion:        pkg.GetValue(input, "搭档身份"),
+		SupportCompanion: pkg.GetValue(input, "协助搭档"),
+		SetCard:          pkg.GetValue(input, "日卡"),
+		Stage:            pkg.GetValue(input, "阶数"),
+		Weapon:           pkg.GetValue(input, "武器"),
+		WeaponRefinement: pkg.GetValue(input, "武器精炼"),
+		Buff:             pkg.GetValue(input, "加成"),
+		TotalLevel:       pkg.GetValue(input, "卡总等级"),
+		Note:             pkg.GetValue(input, "备注"),
+		StarRank:         cleanUpStarRankValue(pkg.GetValue(input, "星级")),
+	}
+
+	if t, ok := input["时间"].(string); ok {
+		record.Time = t
+	}
+
+	loadout, err := models.ParseLoadout(input["卡组"])
+	if err != nil {
+		return recordInput{}, newAPIError(http.StatusBadRequest, "invalid_loadout", err)
+	}
+	record.Loadout = loadout
+
+	result := recordInput{record: record}
+	if anonymous, ok := input["匿名"].(bool); ok {
+		result.anonymous = &anonymous
+	}
+	return result, nil
+}
+
+// createRecord validates and stores a new record of the signed in user.
+func (s *LyskServer) createRecord(ctx context.Context, userID string, kind recordKind, input recordInput) (*models.Record, *apiError) {
+	ctx, span := pkg.StartSpan(ctx, "records.create", attribute.String("record.kind", kind.tag))
+	defer span.End()
+
+	record := input.record
+	kind.trim(&record)
+
+	// 时间字段处理为 ISO 格式，校验按该时间生效的游戏版本进行
+	if record.Time == "" {
+		logrus.Errorf("[%s] Time field is missing or in wrong format", kind.tag)
+		return nil, newAPIError(http.StatusBadRequest, "time_missing", nil)
+	}
+	parsedTime, err := time.Parse(time.RFC3339, record.Time)
+	if err != nil {
+		logrus.Errorf("[%s] Failed to parse time: %v", kind.tag, err)
+		return nil, newAPIError(http.StatusBadRequest, "invalid_time", nil)
+	}
+	record.Time = parsedTime.Format(time.RFC3339)
+
+	record.EstimatorVersion = s.cpEstimator.Current()
+	record.UserID = userID
+	record.Anonymous = s.resolveAnonymous(input.anonymous, record.UserID)
+	record.ApplyLoadout()
+
+	if err := kind.validate(record); err != nil {
+		logrus.Errorf("[%s] Record validation failed: %v", kind.tag, err)
+		return nil, newAPIError(http.StatusBadRequest, "", err)
+	}
+
+	if kind.store.IsDuplicate(record) {
+		logrus.Errorf("[%s] Record is duplicated", kind.tag)
+		return nil, newAPIError(http.StatusBadRequest, "record_exists", nil)
+	}
+
+	if err := kind.store.PrepareInsert(record); err != nil {
+		logrus.Errorf("[%s] Failed to prepare record for insertion: %v", kind.tag, err)
+		return nil, newAPIError(http.StatusInternalServerError, "record_upload_failed", err)
+	}
+
+	ingestedRecord, err := kind.sheetClient.ProcessRecord(ctx, record)
+	if err != nil {
+		logrus.Errorf("[%s] Failed to write record to Google Sheet: %v", kind.tag, err)
+		return nil, newAPIError(http.StatusInternalServerError, "write_failed", err)
+	}
+
+	kind.store.Insert(*ingestedRecord)
+	return ingestedRecord, nil
+}
+
+// ownRecord returns the record if it exists, is not deleted and belongs to the signed in user.
+func (s *LyskServer) ownRecord(userID string, kind recordKind, id string, forbidden string) (models.Record, *apiError) {
+	existingRecord, ok := kind.store.Get(id)
+	if !ok {
+		return models.Record{}, newAPIError(http.StatusNotFound, "record_not_found", nil)
+	}
+
+	if existingRecord.Deleted {
+		return models.Record{}, newAPIError(http.StatusNotFound, "record_deleted", nil)
+	}
+
+	if userID == "" || userID != existingRecord.UserID {
+		return models.Record{}, newAPIError(http.StatusUnauthorized, forbidden, nil)
+	}
+	return existingRecord, nil
+}
+
+// updateRecord replaces the stats of a record. The level, time, owner and estimator version
+// stay those of the stored record; only migrations change the version.
+func (s *LyskServer) updateRecord(ctx context.Context, userID string, kind recordKind, id string, input recordInput) (*models.Record, *apiError) {
+	ctx, span := pkg.StartSpan(ctx, "records.update", attribute.String("record.kind", kind.tag))
+	defer span.End()
+
+	existingRecord, apiErr := s.ownRecord(userID, kind, id, "update_forbidden")
+	if apiErr != nil {
+		return nil, apiErr
+	}
+
+	record := input.record
+	record.Id = id
+	record.RowNumber = existingRecord.RowNumber
+	record.UserID = existingRecord.UserID
+	record.Time = existingRecord.Time
+	// 修改不会改变记录的估算版本，只有迁移会
+	record.EstimatorVersion = existingRecord.EstimatorVersion
+	record.Anonymous = existingRecord.Anonymous
+	if input.anonymous != nil {
+		record.Anonymous = *input.anonymous
+	}
+	record.LevelType = existingRecord.LevelType
+	record.LevelNumber = existingRecord.LevelNumber
+	record.LevelMode = existingRecord.LevelMode
+	kind.trim(&record)
+	record.ApplyLoadout()
+
+	if err := kind.validate(record); err != nil {
+		logrus.Errorf("[%s] Record validation failed: %v", kind.tag, err)
+		return nil, newAPIError(http.StatusBadRequest, "", err)
+	}
+
+	if err := kind.sheetClient.UpdateRecord(ctx, record); err != nil {
+		logrus.Errorf("[%s] Failed to update record in Google Sheet: %v", kind.tag, err)
+		return nil, newAPIError(http.StatusInternalServerError, "update_failed", err)
+	}
+
+	if err := kind.store.Update(record); err != nil {
+		logrus.Errorf("[%s] Failed to update record in memory: %v", kind.tag, err)
+		return nil, newAPIError(http.StatusInternalServerError, "update_failed", err)
+	}
+	return &record, nil
+}
+
+func (s *LyskServer) deleteRecord(ctx context.Context, userID string, kind recordKind, id string) *apiError {
+	ctx, span := pkg.StartSpan(ctx, "records.delete", attribute.String("record.kind", kind.tag))
+	defer span.End()
+
+	existingRecord, apiErr := s.ownRecord(userID, kind, id, "delete_forbidden")
+	if apiErr != nil {
+		return apiErr
+	}
+
+	if err := kind.sheetClient.DeleteRecord(ctx, existingRecord); err != nil {
+		logrus.Errorf("[%s] Failed to delete record from Google Sheet: %v", kind.tag, err)
+		return newAPIError(http.StatusInternalServerError, "delete_failed", err)
+	}
+
+	if err := kind.store.Delete(existingRecord); err != nil {
+		logrus.Errorf("[%s] Failed to delete record from memory: %v", kind.tag, err)
+		return newAPIError(http.StatusInternalServerError, "delete_failed", err)
+	}
+	return nil
+}
+
+// v1 handlers
+
+func (s *LyskServer) processRecordV1(c *gin.Context, kind recordKind) {
+	var body map[string]interface{}
+	if err := c.BindJSON(&body); err != nil {
+		logrus.Errorf("[%s] Failed to bind JSON: %v", kind.tag, err)
+		respondErrorDetail(c, http.StatusBadRequest, "malformed_request", err)
+		return
+	}
+
+	input, apiErr := readRecordInput(body)
+	if apiErr == nil {
+		_, apiErr = s.createRecord(c.Request.Context(), getViewerID(c), kind, input)
+	}
+	if apiErr != nil {
+		apiErr.respondV1(c)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"status": "OK"})
+}
+
+func (s *LyskServer) updateRecordV1(c *gin.Context, kind recordKind) {
+	var body map[string]interface{}
+	if err := c.BindJSON(&body); err != nil {
+		logrus.Errorf("[%s] Failed to bind JSON: %v", kind.tag, err)
+		respondErrorDetail(c, http.StatusBadRequest, "malformed_request", err)
+		return
+	}
+
+	input, apiErr := readRecordInput(body)
+	if apiErr == nil {
+		_, apiErr = s.updateRecord(c.Request.Context(), getViewerID(c), kind, c.Param("id"), input)
+	}
+	if apiErr != nil {
+		apiErr.respondV1(c)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"status": "OK"})
+}
+
+func (s *LyskServer) deleteRecordV1(c *gin.Context, kind recordKind) {
+	if apiErr := s.deleteRecord(c.Request.Context(), getViewerID(c), kind, c.Param("id")); apiErr != nil {
+		apiErr.respondV1(c)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"status": "OK"})
+}
+
+// validationFailure keeps the joined message in error and lists the violations so the client
+// can mark each field. Both are in the language of the request.
+func validationFailure(c *gin.Context, err error) gin.H {
+	var validationErr *models.ValidationError
+	if !errors.As(err, &validationErr) {
+		return gin.H{"error": err.Error()}
+	}
+
+	lang := language(c)
+	violations := localizeViolations(lang, validationErr.Violations)
+	messages := make([]string, len(violations))
+	for i, violation := range violations {
+		messages[i] = violation.Message
+	}
+	return gin.H{"error": strings.Join(messages, i18n.Separator(lang)), "violations": violations}
+}
@@ -0,0 +1,441 @@
+package usecases
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/sirupsen/logrus"
+
+	"lysk-battle-record/internal/datastores"
+	"lysk-battle-record/internal/i18n"
+	"lysk-battle-record/internal/models"
+	"lysk-battle-record/internal/pkg"
+	"lysk-battle-record/internal/utils"
+)
+
+const matchingBuffUnknown = "不确定"
+
+// RecordRequestV2 is the body that creates or updates a record in the v2 API. Numbers are
+// JSON numbers; a missing optional number is left out rather than sent as an empty string.
+type RecordRequestV2 struct {
+	LevelType        string            `json:"level_type" description:"关卡类型，如 光 / 开放 / A4"`
+	LevelNumber      *int              `json:"level_number,omitempty" description:"关数，仅星间轨道"`
+	LevelMode        string            `json:"level_mode,omitempty" description:"稳定 / 波动，仅星间轨道"`
+	StarRank         string            `json:"star_rank,omitempty" description:"波动关卡的通关星级"`
+	Buff             *int              `json:"buff,omitempty" description:"锦标赛加成"`
+	Attack           *int              `json:"attack,omitempty"`
+	HP               *int              `json:"hp,omitempty"`
+	Defense          *int              `json:"defense,omitempty"`
+	Matching         string            `json:"matching" description:"顺 / 逆 / 不确定"`
+	MatchingBuff     *int              `json:"matching_buff,omitempty" description:"对谱加成，不填为不确定"`
+	CritRate         *float64          `json:"crit_rate,omitempty"`
+	CritDmg          *float64          `json:"crit_dmg,omitempty"`
+	EnergyRegen      *float64          `json:"energy_regen,omitempty"`
+	WeakenBoost      *float64          `json:"weaken_boost,omitempty"`
+	OathBoost        *float64          `json:"oath_boost,omitempty"`
+	OathRegen        *float64          `json:"oath_regen,omitempty"`
+	TotalLevel       *int              `json:"total_level,omitempty"`
+	Companion        string            `json:"companion"`
+	SupportCompanion string            `json:"support_companion,omitempty"`
+	SetCard          string            `json:"set_card"`
+	Stage            string            `json:"stage" description:"I / II / III / IV / 无套装"`
+	Weapon           string            `json:"weapon"`
+	WeaponRefinement *int              `json:"weapon_refinement,omitempty"`
+	Note             string            `json:"note,omitempty"`
+	Time             *time.Time        `json:"time,omitempty" description:"通关时间，上传时必填，修改时忽略"`
+	Anonymous        *bool             `json:"anonymous,omitempty" description:"不填时使用用户的默认设置"`
+	Loadout          *models.LoadoutEN `json:"loadout,omitempty" description:"卡组，填写后面板中留空的数值由卡组算出"`
+	LevelPart        string            `json:"level_part,omitempty" description:"上 / 下，分上下的关卡必填"`
+}
+
+func (r RecordRequestV2) input() recordInput {
+	record := models.Record{
+		LevelType:        r.LevelType,
+		LevelNumber:      joinLevelNumber(r.LevelNumber, r.LevelPart),
+		LevelMode:        r.LevelMode,
+		StarRank:         r.StarRank,
+		Buff:             formatInt(r.Buff),
+		Attack:           formatInt(r.Attack),
+		HP:               formatInt(r.HP),
+		Defense:          formatInt(r.Defense),
+		Matching:         r.Matching,
+		MatchingBuff:     matchingBuffUnknown,
+		CritRate:         formatFloat(r.CritRate),
+		CritDmg:          formatFloat(r.CritDmg),
+		EnergyRegen:      formatFloat(r.EnergyRegen),
+		WeakenBoost:      formatFloat(r.WeakenBoost),
+		OathBoost:        formatFloat(r.OathBoost),
+		OathRegen:        formatFloat(r.OathRegen),
+		TotalLevel:       formatInt(r.TotalLevel),
+		Companion:        r.Companion,
+		SupportCompanion: r.SupportCompanion,
+		SetCard:          r.SetCard,
+		Stage:            r.Stage,
+		Weapon:           r.Weapon,
+		WeaponRefinement: formatInt(r.WeaponRefinement),
+		Note:             r.Note,
+	}
+	if r.MatchingBuff != nil {
+		record.MatchingBuff = formatInt(r.MatchingBuff)
+	}
+	if r.Time != nil {
+		record.Time = r.Time.Format(time.RFC3339)
+	}
+	if r.Loadout != nil {
+		loadout := r.Loadout.Loadout()
+		record.Loadout = &loadout
+	}
+	return recordInput{record: record, anonymous: r.Anonymous}
+}
+
+// RecordV2 is a record in the v2 API.
+type RecordV2 struct {
+	ID               string            `json:"id"`
+	UserID           string            `json:"user_id,omitempty"`
+	PublicID         string            `json:"public_id,omitempty"`
+	Nickname         string            `json:"nickname,omitempty"`
+	Anonymous        bool              `json:"anonymous"`
+	LevelType        string            `json:"level_type"`
+	LevelNumber      *int              `json:"level_number,omitempty"`
+	LevelMode        string            `json:"level_mode,omitempty"`
+	StarRank         string            `json:"star_rank,omitempty"`
+	Buff             *int              `json:"buff,omitempty"`
+	Attack           *int              `json:"attack,omitempty"`
+	HP               *int              `json:"hp,omitempty"`
+	Defense          *int              `json:"defense,omitempty"`
+	Matching         string            `json:"matching"`
+	MatchingBuff     *int              `json:"matching_buff,omitempty"`
+	CritRate         *float64          `json:"crit_rate,omitempty"`
+	CritDmg          *float64          `json:"crit_dmg,omitempty"`
+	EnergyRegen      *float64          `json:"energy_regen,omitempty"`
+	WeakenBoost      *float64          `json:"weaken_boost,omitempty"`
+	OathBoost        *float64          `json:"oath_boost,omitempty"`
+	OathRegen        *float64          `json:"oath_regen,omitempty"`
+	TotalLevel       *int              `json:"total_level,omitempty"`
+	Companion        string            `json:"companion"`
+	SupportCompanion string            `json:"support_companion,omitempty"`
+	SetCard          string            `json:"set_card"`
+	Stage            string            `json:"stage"`
+	Weapon           string            `json:"weapon"`
+	WeaponRefinement *int              `json:"weapon_refinement,omitempty"`
+	Note             string            `json:"note,omitempty"`
+	Time             string            `json:"time" format:"date-time"`
+	EstimatorVersion string            `json:"estimator_version,omitempty"`
+	CombatPower      CombatPowerV2     `json:"combat_power"`
+	Loadout          *models.LoadoutEN `json:"loadout,omitempty"`
+	LevelPart        string            `json:"level_part,omitempty"`
+}
+
+type CombatPowerV2 struct {
+	Score        *float64                        `json:"score,omitempty"`
+	BuffedScore  *float64                        `json:"buffed_score,omitempty"`
+	WeakenScore  *float64                        `json:"weaken_score,omitempty"`
+	CritScore    *float64                        `json:"crit_score,omitempty"`
+	Evaluation   string                          `json:"evaluation,omitempty"`
+	Version      string                          `json:"version,omitempty"`
+	Distribution *models.CombatPowerDistribution `json:"distribution,omitempty"`
+	Level        *models.LevelEstimate           `json:"level,omitempty"`
+	Approximate  bool                            `json:"approximate,omitempty" description:"搭档还没有技能循环，v2 分数沿用 v1 的技能次数"`
+}
+
+type RecordPageV2 struct {
+	Total   int        `json:"total"`
+	Records []RecordV2 `json:"records"`
+}
+
+type RecordResponseV2 struct {
+	Record RecordV2 `json:"record"`
+}
+
+// ErrorV2 is the envelope of every v2 error.
+type ErrorV2 struct {
+	Error ErrorBodyV2 `json:"error"`
+}
+
+type ErrorBodyV2 struct {
+	Code       string             `json:"code"`
+	Message    string             `json:"message"`
+	Detail     string             `json:"detail,omitempty"`
+	Violations []models.Violation `json:"violations,omitempty"`
+}
+
+func newRecordV2(record models.Record) RecordV2 {
+	levelNumber, levelPart := splitLevelNumber(record.LevelNumber)
+	return RecordV2{
+		ID:               record.Id,
+		UserID:           record.UserID,
+		PublicID:         record.PublicID,
+		Nickname:         record.Nickname,
+		Anonymous:        record.Anonymous,
+		LevelType:        record.LevelType,
+		LevelNumber:      levelNumber,
+		LevelPart:        levelPart,
+		LevelMode:        record.LevelMode,
+		StarRank:         record.StarRank,
+		Buff:             parseInt(record.Buff),
+		Attack:           parseInt(record.Attack),
+		HP:               parseInt(record.HP),
+		Defense:          parseInt(record.Defense),
+		Matching:         record.Matching,
+		MatchingBuff:     parseInt(record.MatchingBuff),
+		CritRate:         parseFloat(record.CritRate),
+		CritDmg:          parseFloat(record.CritDmg),
+		EnergyRegen:      parseFloat(record.EnergyRegen),
+		WeakenBoost:      parseFloat(record.WeakenBoost),
+		OathBoost:        parseFloat(record.OathBoost),
+		OathRegen:        parseFloat(record.OathRegen),
+		TotalLevel:       parseInt(record.TotalLevel),
+		Companion:        record.Companion,
+		SupportCompanion: record.SupportCompanion,
+		SetCard:          record.SetCard,
+		Stage:            record.Stage,
+		Weapon:           record.Weapon,
+		WeaponRefinement: parseInt(record.WeaponRefinement),
+		Note:             record.Note,
+		Time:             record.Time,
+		EstimatorVersion: record.EstimatorVersion,
+		CombatPower:      newCombatPowerV2(record.CombatPower),
+		Loadout:          record.English().Loadout,
+	}
+}
+
+func newCombatPowerV2(combatPower models.CombatPower) CombatPowerV2 {
+	return CombatPowerV2{
+		Score:        parseFloat(combatPower.Score),
+		BuffedScore:  parseFloat(combatPower.BuffedScore),
+		WeakenScore:  parseFloat(combatPower.WeakenScore),
+		CritScore:    parseFloat(combatPower.CritScore),
+		Evaluation:   combatPower.Evaluation,
+		Version:      combatPower.Version,
+		Distribution: combatPower.Distribution,
+		Level:        combatPower.Level,
+		Approximate:  combatPower.Approximate,
+	}
+}
+
+func newRecordPageV2(result datastores.QueryResult) RecordPageV2 {
+	page := RecordPageV2{Total: result.Total, Records: make([]RecordV2, len(result.Records))}
+	for i, record := range result.Records {
+		page.Records[i] = newRecordV2(record)
+	}
+	return page
+}
+
+// joinLevelNumber and splitLevelNumber convert between the stored 关数, such as 300_下, and the
+// number and part of the v2 API.
+func joinLevelNumber(number *int, part string) string {
+	levelNumber := formatInt(number)
+	if levelNumber != "" && part != "" {
+		levelNumber += "_" + part
+	}
+	return levelNumber
+}
+
+func splitLevelNumber(levelNumber string) (*int, string) {
+	number, part, _ := strings.Cut(levelNumber, "_")
+	return parseInt(number), part
+}
+
+func formatInt(value *int) string {
+	if value == nil {
+		return ""
+	}
+	return strconv.Itoa(*value)
+}
+
+func formatFloat(value *float64) string {
+	if value == nil {
+		return ""
+	}
+	return strconv.FormatFloat(*value, 'f', -1, 64)
+}
+
+// parseInt and parseFloat read the stored strings; anything that is not a number, such as
+// 不确定 or 无数据, is left out.
+func parseInt(value string) *int {
+	number, err := strconv.Atoi(value)
+	if err != nil {
+		return nil
+	}
+	return &number
+}
+
+func parseFloat(value string) *float64 {
+	number, err := strconv.ParseFloat(value, 64)
+	if err != nil {
+		return nil
+	}
+	return &number
+}
+
+// respondV2 renders the error in the v2 envelope.
+func (e *apiError) respondV2(c *gin.Context) {
+	if e.retryAfter > 0 {
+		c.Header("Retry-After", pkg.RetryAfter(e.retryAfter))
+	}
+	lang := language(c)
+	body := ErrorBodyV2{Code: e.code}
+
+	var validationErr *models.ValidationError
+	if e.code == "" && errors.As(e.err, &validationErr) {
+		body.Code = "validation_failed"
+		body.Violations = localizeViolations(lang, validationErr.Violations)
+	} else if e.err != nil {
+		body.Detail = e.err.Error()
+	}
+
+	message, ok := i18n.Message(lang, body.Code, nil)
+	if !ok {
+		message = body.Code
+	}
+	body.Message = message
+	c.JSON(e.status, ErrorV2{Error: body})
+}
+
+// bindRecordV2 decodes a v2 record body. Unknown fields and strings in numeric fields are
+// rejected instead of being stringified.
+func bindRecordV2(c *gin.Context) (recordInput, *apiError) {
+	var request RecordRequestV2
+	decoder := json.NewDecoder(c.Request.Body)
+	decoder.DisallowUnknownFields()
+	if err := decoder.Decode(&request); err != nil {
+		return recordInput{}, newAPIError(http.StatusBadRequest, "malformed_request", err)
+	}
+	return request.input(), nil
+}
+
+func (s *LyskServer) createRecordV2(kind recordKind) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		input, apiErr := bindRecordV2(c)
+		if apiErr != nil {
+			logrus.Errorf("[%s] Failed to bind v2 record: %v", kind.tag, apiErr.err)
+			apiErr.respondV2(c)
+			return
+		}
+
+		record, apiErr := s.createRecord(c.Request.Context(), getViewerID(c), kind, input)
+		if apiErr != nil {
+			apiErr.respondV2(c)
+			return
+		}
+		c.JSON(http.StatusCreated, RecordResponseV2{Record: newRecordV2(*record)})
+	}
+}
+
+func (s *LyskServer) updateRecordV2(kind recordKind) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		input, apiErr := bindRecordV2(c)
+		if apiErr != nil {
+			logrus.Errorf("[%s] Failed to bind v2 record: %v", kind.tag, apiErr.err)
+			apiErr.respondV2(c)
+			return
+		}
+
+		record, apiErr := s.updateRecord(c.Request.Context(), getViewerID(c), kind, c.Param("id"), input)
+		if apiErr != nil {
+			apiErr.respondV2(c)
+			return
+		}
+		c.JSON(http.StatusOK, RecordResponseV2{Record: newRecordV2(*record)})
+	}
+}
+
+func (s *LyskServer) deleteRecordV2(kind recordKind) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if apiErr := s.deleteRecord(c.Request.Context(), getViewerID(c), kind, c.Param("id")); apiErr != nil {
+			apiErr.respondV2(c)
+			return
+		}
+		c.Status(http.StatusNoContent)
+	}
+}
+
+// recordFilterFields maps the filter names of the v2 and gRPC APIs to the record fields.
+var recordFilterFields = map[string]string{
+	"level_type":   "关卡",
+	"level_number": "关数",
+	"level_mode":   "模式",
+	"companion":    "搭档身份",
+	"set_card":     "日卡",
+	"stage":        "阶数",
+	"weapon":       "武器",
+}
+
+// recordFilters keeps the filters that are given. Unlike v1, a filter that is not given does
+// not filter.
+func recordFilters(values map[string]string) map[string]string {
+	filters := map[string]string{}
+	for name, field := range recordFilterFields {
+		if value := strings.TrimSpace(values[name]); value != "" {
+			filters[field] = value
+		}
+	}
+	if part := strings.TrimSpace(values["level_part"]); part != "" && filters["关数"] != "" {
+		filters["关数"] += "_" + part
+	}
+	return filters
+}
+
+// recordFiltersV2 reads the optional filters of a v2 record query.
+func recordFiltersV2(c *gin.Context) map[string]string {
+	values := map[string]string{}
+	for name := range recordFilterFields {
+		values[name] = c.Query(name)
+	}
+	values["level_part"] = c.Query("level_part")
+	return recordFilters(values)
+}
+
+// listRecords is a page of public records. Public championships records only show the
+// current round.
+func (s *LyskServer) listRecords(kind recordKind, filters map[string]string, offset, limit int) datastores.QueryResult {
+	options := datastores.QueryOptions{Filters: filters, Offset: offset, Limit: limit}
+	if kind.championship {
+		// 公开的锦标赛记录只显示本轮
+		options.TimeStart, options.TimeEnd = utils.GetCurrentChampionshipsRound()
+	}
+
+	result := kind.store.Query(options)
+	s.populatePublicRecords(result.Records)
+	return result
+}
+
+// myRecords is a page of the records of the user, anonymous ones included.
+func (s *LyskServer) myRecords(kind recordKind, userID string, filters map[string]string, offset, limit int) datastores.QueryResult {
+	filters["用户ID"] = userID
+	result := kind.store.Query(datastores.QueryOptions{Filters: filters, Offset: offset, Limit: limit})
+	s.populateNicknameForRecords(result.Records)
+	return result
+}
+
+func (s *LyskServer) listRecordsV2(kind recordKind) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		result := s.listRecords(kind, recordFiltersV2(c), utils.GetOffset(c), 0)
+		c.JSON(http.StatusOK, newRecordPageV2(result))
+	}
+}
+
+func (s *LyskServer) latestRecordsV2(kind recordKind) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		result := s.listRecords(kind, nil, 0, 5)
+		c.JSON(http.StatusOK, newRecordPageV2(result))
+	}
+}
+
+func (s *LyskServer) myRecordsV2(kind recordKind) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		userId, exists := c.Get("userID")
+		if !exists {
+			newAPIError(http.StatusUnauthorized, "unauthenticated_user", nil).respondV2(c)
+			return
+		}
+
+		result := s.myRecords(kind, userId.(string), recordFiltersV2(c), utils.GetOffset(c), 0)
+		c.JSON(http.StatusOK, newRecordPageV2(result))
+	}
+}
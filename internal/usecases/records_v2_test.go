@@ -0,0 +1,78 @@
+package usecases
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+)
+
+func TestBindRecordV2(t *testing.T) {
+	bind := func(body string) (recordInput, *apiError) {
+		c, _ := gin.CreateTestContext(httptest.NewRecorder())
+		c.Request = httptest.NewRequest(http.MethodPost, "/v2/orbit-records", strings.NewReader(body))
+		return bindRecordV2(c)
+	}
+
+	input, apiErr := bind(`{"level_type": "光", "level_number": 120, "attack": 1000000, "crit_rate": 65.5, "time": "2025-01-02T03:04:05Z"}`)
+	if apiErr != nil {
+		t.Fatalf("valid body rejected: %v", apiErr.err)
+	}
+	if input.record.Attack != "1000000" || input.record.CritRate != "65.5" || input.record.LevelNumber != "120" {
+		t.Errorf("numbers not kept as written: %+v", input.record)
+	}
+	if input.record.MatchingBuff != matchingBuffUnknown || input.record.Time != "2025-01-02T03:04:05Z" {
+		t.Errorf("defaults not applied: %+v", input.record)
+	}
+
+	for _, body := range []string{`{"attack": "1000"}`, `{"攻击": 1000}`} {
+		if _, apiErr := bind(body); apiErr == nil || apiErr.code != "malformed_request" {
+			t.Errorf("%s accepted", body)
+		}
+	}
+}
+
+func TestOpenAPIDocument(t *testing.T) {
+	document := openAPIDocument((&LyskServer{}).v2Routes())
+
+	paths := document["paths"].(gin.H)
+	if _, ok := paths["/v2/orbit-records/{id}"].(gin.H)["put"]; !ok {
+		t.Errorf("update route missing: %v", paths)
+	}
+
+	schemas := document["components"].(gin.H)["schemas"].(gin.H)
+	request := schemas["RecordRequestV2"].(gin.H)["properties"].(gin.H)
+	if request["attack"].(gin.H)["type"] != "integer" || request["crit_rate"].(gin.H)["type"] != "number" {
+		t.Errorf("numeric fields not typed: %v", request)
+	}
+	for _, name := range []string{"ErrorV2", "RecordV2", "LoadoutEN"} {
+		if _, ok := schemas[name]; !ok {
+			t.Errorf("schema %s missing", name)
+		}
+	}
+}
+
+func TestLevelPartV2(t *testing.T) {
+	c, _ := gin.CreateTestContext(httptest.NewRecorder())
+	c.Request = httptest.NewRequest(http.MethodPost, "/v2/orbit-records", strings.NewReader(`{"level_type": "开放", "level_number": 300, "level_part": "下", "level_mode": "稳定"}`))
+	input, apiErr := bindRecordV2(c)
+	if apiErr != nil {
+		t.Fatal(apiErr.err)
+	}
+	if input.record.LevelNumber != "300_下" {
+		t.Fatalf("level part not joined: %q", input.record.LevelNumber)
+	}
+
+	record := newRecordV2(input.record)
+	if record.LevelNumber == nil || *record.LevelNumber != 300 || record.LevelPart != "下" {
+		t.Errorf("level part not split: %v %q", record.LevelNumber, record.LevelPart)
+	}
+
+	number := 300
+	filters := ListRecordsRequest{LevelNumber: &number, LevelPart: "下"}.filters()
+	if filters["关数"] != "300_下" {
+		t.Errorf("level part not filtered: %v", filters)
+	}
+}
@@ -1,6 +1,7 @@
 package usecases
 
 import (
+	"context"
 	"fmt"
 	"net/http"
 	"strings"
@@ -8,79 +9,135 @@ import (
 	"github.com/gin-gonic/gin"
 	"github.com/sirupsen/logrus"
 	"lysk-battle-record/internal/models"
+	"lysk-battle-record/internal/pkg"
 )
 
+// AuthMiddleware sets the userID when a valid token is provided and lets the request through
+// otherwise. It is meant for public routes that show extra data to logged-in users.
 func (s *LyskServer) AuthMiddleware() gin.HandlerFunc {
 	return func(c *gin.Context) {
-		authHeader := c.GetHeader("Authorization")
-		if authHeader == "" {
-			logrus.Info("[Auth] No authorization header provided, proceeding without authentication")
+		userID, err := s.authenticate(c.GetHeader("Authorization"))
+		if err != nil {
+			if err != pkg.ErrMissingToken {
+				logrus.Warnf("[Auth] Proceeding without authentication: %v", err)
+			}
 			c.Next()
 			return
 		}
 
-		parts := strings.Split(authHeader, " ")
-		if len(parts) != 2 || parts[0] != "Bearer" {
-			logrus.Warnf("[Auth] Invalid authorization header format: %s", authHeader)
-			c.Next()
-			return
-		}
+		c.Set("userID", userID)
+		c.Next()
+	}
+}
+
+// RequireAuth rejects the request with 401 unless a valid, unrevoked token is provided.
+func (s *LyskServer) RequireAuth() gin.HandlerFunc {
+	return s.requireAuth(func(c *gin.Context, status int, code string) {
+		c.AbortWithStatusJSON(status, errorResponse(c, code, nil))
+	})
+}
+
+// requireAuthV2 is RequireAuth with the v2 error envelope.
+func (s *LyskServer) requireAuthV2() gin.HandlerFunc {
+	return s.requireAuth(func(c *gin.Context, status int, code string) {
+		newAPIError(status, code, nil).respondV2(c)
+		c.Abort()
+	})
+}
 
-		tokenString := parts[1]
-		userID, err := s.auth.ValidateJWT(tokenString)
+func (s *LyskServer) requireAuth(reject func(c *gin.Context, status int, code string)) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		userID, err := s.authenticate(c.GetHeader("Authorization"))
 		if err != nil {
-			logrus.Warnf("[Auth] JWT validation failed: %v", err)
-			c.Next()
+			logrus.Warnf("[Auth] Rejected request to %s: %v", c.FullPath(), err)
+			apiErr := authFailure(err)
+			reject(c, apiErr.status, apiErr.code)
 			return
 		}
 
-		logrus.Infof("[Auth] Successfully authenticated user: %s", userID)
 		c.Set("userID", userID)
 		c.Next()
 	}
 }
 
+// authFailure is the response to a request whose token was rejected. Banned users get 403,
+// everything else 401.
+func authFailure(err error) *apiError {
+	authErr, ok := err.(*pkg.AuthError)
+	if !ok {
+		authErr = pkg.ErrInvalidToken
+	}
+	status := http.StatusUnauthorized
+	if authErr == pkg.ErrUserBanned {
+		status = http.StatusForbidden
+	}
+	return newAPIError(status, authErr.Code, nil)
+}
+
+// authenticate returns the user of the Authorization header value.
+func (s *LyskServer) authenticate(authHeader string) (string, error) {
+	tokenString, err := bearerToken(authHeader)
+	if err != nil {
+		return "", err
+	}
+
+	return s.auth.ValidateJWT(tokenString)
+}
+
+func bearerToken(authHeader string) (string, error) {
+	if authHeader == "" {
+		return "", pkg.ErrMissingToken
+	}
+
+	parts := strings.Split(authHeader, " ")
+	if len(parts) != 2 || parts[0] != "Bearer" {
+		return "", pkg.ErrMalformedToken
+	}
+
+	return parts[1], nil
+}
+
 func (s *LyskServer) Login(c *gin.Context) {
 	var req struct {
 		Code string `json:"code"`
 	}
 
 	if err := c.BindJSON(&req); err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid request"})
+		respondError(c, http.StatusBadRequest, "invalid_request")
 		return
 	}
 
-	token, err := s.auth.Login(req.Code)
+	external, err := s.authenticateWithProvider(providerLoginRequest{
+		Provider:    pkg.ProviderWeChat,
+		Credentials: map[string]string{"code": req.Code},
+	})
 	if err != nil {
 		c.JSON(http.StatusUnauthorized, gin.H{"error": err.Error()})
 		return
 	}
 
-	userID, err := s.auth.ValidateJWT(token)
+	// 与 ProviderLogin 一样经过身份表，已关联到其他用户的 openid 登录到该用户
+	userID, err := s.resolveUserID(c.Request.Context(), external)
 	if err != nil {
-		c.JSON(http.StatusNonAuthoritativeInfo, gin.H{"error": err.Error()})
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
 		return
 	}
 
-	if err := s.createUserIfNotExist(userID); err != nil {
+	if err := s.createUserIfNotExist(c.Request.Context(), userID); err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
 		return
 	}
 
-	c.JSON(http.StatusOK, gin.H{"token": token})
-}
-
-func (s *LyskServer) GetRanking(c *gin.Context) {
-	userId, exists := c.Get("userID")
-	if !exists {
-		userId = ""
+	token, err := s.auth.IssueLegacyToken(userID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
 	}
-	ranking := s.orbitRecordStore.GetRanking(userId.(string))
 
-	c.JSON(http.StatusOK, ranking)
+	c.JSON(http.StatusOK, gin.H{"token": token})
 }
 
-func (s *LyskServer) createUserIfNotExist(userId string) error {
+func (s *LyskServer) createUserIfNotExist(ctx context.Context, userId string) error {
 	s.userCreationMutex.Lock()
 	defer s.userCreationMutex.Unlock()
 
@@ -92,7 +149,7 @@ func (s *LyskServer) createUserIfNotExist(userId string) error {
 		return nil
 	}
 
-	createdUser, err := s.userSheetClient.ProcessUser(user)
+	createdUser, err := s.userSheetClient.ProcessUser(ctx, user)
 	if err != nil {
 		return fmt.Errorf("创建用户失败: %v", err)
 	}
@@ -107,19 +164,19 @@ func (s *LyskServer) CreateUser(c *gin.Context) {
 
 	var user models.User
 	if err := c.BindJSON(&user); err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid request"})
+		respondError(c, http.StatusBadRequest, "invalid_request")
 		return
 	}
 	userId, exists := c.Get("userID")
 	if !exists {
-		c.JSON(http.StatusUnauthorized, gin.H{"error": "未登录或无效的用户"})
+		respondError(c, http.StatusUnauthorized, "unauthenticated_user")
 		return
 	}
 	user.ID = userId.(string)
 
 	_, ok := s.userStore.Get(userId.(string))
 	if ok {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "用户已存在"})
+		respondError(c, http.StatusBadRequest, "user_exists")
 		return
 	}
 
@@ -128,7 +185,7 @@ func (s *LyskServer) CreateUser(c *gin.Context) {
 		return
 	}
 
-	createdUser, err := s.userSheetClient.ProcessUser(user)
+	createdUser, err := s.userSheetClient.ProcessUser(c.Request.Context(), user)
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
 		return
@@ -141,12 +198,12 @@ func (s *LyskServer) CreateUser(c *gin.Context) {
 func (s *LyskServer) GetUser(c *gin.Context) {
 	userId, exists := c.Get("userID")
 	if !exists {
-		c.JSON(http.StatusUnauthorized, gin.H{"error": "未登录或无效的用户"})
+		respondError(c, http.StatusUnauthorized, "unauthenticated_user")
 		return
 	}
 	user, ok := s.userStore.Get(userId.(string))
 	if !ok {
-		c.JSON(http.StatusNotFound, gin.H{"error": "用户不存在"})
+		respondError(c, http.StatusNotFound, "user_not_found")
 		return
 	}
 	c.JSON(http.StatusOK, user)
@@ -155,12 +212,12 @@ func (s *LyskServer) GetUser(c *gin.Context) {
 func (s *LyskServer) UpdateUser(c *gin.Context) {
 	userId, exists := c.Get("userID")
 	if !exists {
-		c.JSON(http.StatusUnauthorized, gin.H{"error": "未登录或无效的用户"})
+		respondError(c, http.StatusUnauthorized, "unauthenticated_user")
 		return
 	}
 	var user models.User
 	if err := c.BindJSON(&user); err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid request"})
+		respondError(c, http.StatusBadRequest, "invalid_request")
 		return
 	}
 
@@ -168,7 +225,7 @@ func (s *LyskServer) UpdateUser(c *gin.Context) {
 
 	currentUser, ok := s.userStore.Get(userId.(string))
 	if !ok {
-		c.JSON(http.StatusNotFound, gin.H{"error": "用户不存在"})
+		respondError(c, http.StatusNotFound, "user_not_found")
 		return
 	}
 
@@ -178,8 +235,9 @@ func (s *LyskServer) UpdateUser(c *gin.Context) {
 	}
 
 	user.RowNumber = currentUser.RowNumber
+	user.PrivacySettings = currentUser.PrivacySettings
 
-	if err := s.userSheetClient.UpdateUser(user); err != nil {
+	if err := s.userSheetClient.UpdateUser(c.Request.Context(), user); err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
 		return
 	}
@@ -192,6 +250,68 @@ func (s *LyskServer) UpdateUser(c *gin.Context) {
 	c.JSON(http.StatusOK, user)
 }
 
+func (s *LyskServer) UpdatePrivacySettings(c *gin.Context) {
+	userId, exists := c.Get("userID")
+	if !exists {
+		respondError(c, http.StatusUnauthorized, "unauthenticated_user")
+		return
+	}
+	var settings models.PrivacySettings
+	if err := c.BindJSON(&settings); err != nil {
+		respondError(c, http.StatusBadRequest, "invalid_request")
+		return
+	}
+
+	user, ok := s.userStore.Get(userId.(string))
+	if !ok {
+		respondError(c, http.StatusNotFound, "user_not_found")
+		return
+	}
+	user.PrivacySettings = settings
+
+	if err := s.userSheetClient.UpdateUser(c.Request.Context(), user); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	if err := s.userStore.Update(user); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, user)
+}
+
+// resolveAnonymous uses the "匿名" field of the upload when present, otherwise falls back to
+// the uploader's AnonymousByDefault setting.
+func (s *LyskServer) resolveAnonymous(anonymous *bool, userId string) bool {
+	if anonymous != nil {
+		return *anonymous
+	}
+
+	if user, ok := s.userStore.Get(userId); ok {
+		return user.AnonymousByDefault
+	}
+
+	return false
+}
+
+// populatePublicRecords prepares records for public responses: the OpenID is replaced by a
+// pseudonymous ID, and anonymous records or users hiding their nickname show no nickname.
+func (s *LyskServer) populatePublicRecords(records []models.Record) {
+	for i, record := range records {
+		records[i].UserID = ""
+		if record.Anonymous || !hasUploader(record) {
+			continue
+		}
+
+		records[i].PublicID = s.pseudonymizer.PublicID(record.UserID)
+		if user, ok := s.userStore.Get(record.UserID); ok && !user.HideNickname {
+			records[i].Nickname = user.Nickname
+		}
+	}
+}
+
 func (s *LyskServer) populateNicknameForRecords(records []models.Record) {
 	for i, record := range records {
 		if record.UserID != "" {
@@ -0,0 +1,38 @@
+package usecases
+
+import (
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+
+	"lysk-battle-record/internal/registry"
+)
+
+type WeaponResponse struct {
+	ID            string `json:"id"`
+	Name          string `json:"name"`
+	Type          string `json:"type"`
+	Companion     string `json:"companion,omitempty"`
+	MaxRefinement int    `json:"max_refinement"`
+}
+
+// GetWeapons lists the weapon catalogue. With a companion only the weapons it can use are listed.
+func (s *LyskServer) GetWeapons(c *gin.Context) {
+	companion := registry.CanonicalCompanionName(c.Query("companion"))
+
+	weapons := []WeaponResponse{}
+	for _, weapon := range registry.Weapons() {
+		if companion != "" && weapon.Companion != "" && weapon.Companion != companion {
+			continue
+		}
+		weapons = append(weapons, WeaponResponse{
+			ID:            weapon.ID,
+			Name:          weapon.Name,
+			Type:          weapon.Type,
+			Companion:     weapon.Companion,
+			MaxRefinement: weapon.MaxRefinement(),
+		})
+	}
+
+	c.JSON(http.StatusOK, gin.H{"weapons": weapons})
+}
@@ -2,16 +2,28 @@ package utils
 
 import "time"
 
+var (
+	// 第一轮锦标赛开始时间与每轮时长
+	championshipsEpoch       = time.Date(2025, time.June, 2, 0, 0, 0, 0, time.FixedZone("CST", 8*60*60))
+	championshipsRoundLength = 14 * 24 * time.Hour
+)
+
+// SetChampionshipsSchedule sets when the first championships round started and how long each
+// round lasts. It is called once at startup, before any request.
+func SetChampionshipsSchedule(epoch time.Time, roundLength time.Duration) {
+	championshipsEpoch = epoch
+	championshipsRoundLength = roundLength
+}
+
 func GetCurrentChampionshipsRound() (time.Time, time.Time) {
 	return GetChampionshipsRoundByTime(time.Now())
 }
 
 func GetChampionshipsRoundByTime(targetTime time.Time) (time.Time, time.Time) {
-	loc, _ := time.LoadLocation("Asia/Shanghai")
-	firstRoundStartDate := time.Date(2025, time.June, 2, 0, 0, 0, 0, loc)
-	roundDuration := 14 * 24 * time.Hour
+	firstRoundStartDate := championshipsEpoch
+	roundDuration := championshipsRoundLength
 
-	elapsed := targetTime.In(loc).Sub(firstRoundStartDate)
+	elapsed := targetTime.Sub(firstRoundStartDate)
 	roundsPassed := int(elapsed / roundDuration)
 
 	roundStartDate := firstRoundStartDate.Add(time.Duration(roundsPassed) * roundDuration)
@@ -19,3 +31,19 @@ func GetChampionshipsRoundByTime(targetTime time.Time) (time.Time, time.Time) {
 
 	return roundStartDate, roundEndDate
 }
+
+func GetCurrentWeek() (time.Time, time.Time) {
+	return GetWeekByTime(time.Now())
+}
+
+func GetWeekByTime(targetTime time.Time) (time.Time, time.Time) {
+	loc, _ := time.LoadLocation("Asia/Shanghai")
+	t := targetTime.In(loc)
+
+	// weeks start on Monday
+	daysSinceMonday := (int(t.Weekday()) + 6) % 7
+	weekStartDate := time.Date(t.Year(), t.Month(), t.Day()-daysSinceMonday, 0, 0, 0, 0, loc)
+	weekEndDate := weekStartDate.AddDate(0, 0, 7)
+
+	return weekStartDate, weekEndDate
+}
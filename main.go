@@ -1,36 +1,84 @@
 package main
 
 import (
+	"context"
+	"fmt"
 	"os"
 	"time"
 
 	"github.com/gin-contrib/cors"
 	"github.com/gin-gonic/gin"
+	"github.com/sirupsen/logrus"
 
+	"lysk-battle-record/internal/config"
 	"lysk-battle-record/internal/datastores"
 	"lysk-battle-record/internal/estimator"
 	"lysk-battle-record/internal/pkg"
 	"lysk-battle-record/internal/sheet_clients"
 	"lysk-battle-record/internal/usecases"
-)
-
-const (
-	spreadsheetID     = "1-ORnXBnav4JVtP673Oio5sNdVpk0taUSzG3kWZqhIuY"
-	orbitSheetName    = "轨道"
-	championSheetName = "锦标赛"
-	userSheetName     = "用户"
+	"lysk-battle-record/internal/utils"
 )
 
 func main() {
-	cpEstimator := estimator.NewCombatPowerEstimator()
-	orbitGoogleSheetClient := sheet_clients.NewRecordSheetClient(spreadsheetID, orbitSheetName)
-	orbitRecordStore := datastores.NewInMemoryRecordStore(orbitGoogleSheetClient, cpEstimator)
+	cfg, err := config.Load(os.Args[1:], os.Getenv)
+	if err != nil {
+		logrus.Fatalf("[Config] %v", err)
+	}
+	logrus.Infof("[Config] Loaded the %s profile", cfg.Profile)
+	utils.SetChampionshipsSchedule(cfg.Championships.Epoch, cfg.Championships.RoundLength)
 
-	championshipsGoogleSheetClient := sheet_clients.NewRecordSheetClient(spreadsheetID, championSheetName)
-	championshipsRecordStore := datastores.NewInMemoryRecordStore(championshipsGoogleSheetClient, cpEstimator)
+	if err := estimator.CheckRegistry(); err != nil {
+		logrus.Fatalf("[Registry] %v", err)
+	}
+	if err := usecases.LoadGameContent(cfg.Server.GameContentFile); err != nil {
+		logrus.Fatalf("[GameContent] %v", err)
+	}
+	if err := usecases.LoadEstimatorParams(cfg.Server.EstimatorParamsFile); err != nil {
+		logrus.Fatalf("[Estimator] %v", err)
+	}
 
-	userGoogleSheetClient := sheet_clients.NewUserSheetClient(spreadsheetID, userSheetName)
-	userStore := datastores.NewInMemoryUserStore(userGoogleSheetClient)
+	shutdownTracing, err := pkg.InitTracing(context.Background(), cfg.Server.TracesExporter)
+	if err != nil {
+		logrus.Fatalf("[Tracing] %v", err)
+	}
+	defer shutdownTracing(context.Background())
+
+	cpEstimator := estimator.NewVersionedEstimator(cfg.Server.EstimatorVersion)
+	orbitGoogleSheetClient := sheet_clients.NewRecordSheetClient(cfg.Sheets.SpreadsheetID, cfg.Sheets.Orbit)
+	orbitRecordStore := datastores.NewInMemoryRecordStore(orbitGoogleSheetClient, cpEstimator, cfg.Sheets.RefreshInterval)
+
+	championshipsGoogleSheetClient := sheet_clients.NewRecordSheetClient(cfg.Sheets.SpreadsheetID, cfg.Sheets.Championships)
+	championshipsRecordStore := datastores.NewInMemoryRecordStore(championshipsGoogleSheetClient, cpEstimator, cfg.Sheets.RefreshInterval)
+
+	userGoogleSheetClient := sheet_clients.NewUserSheetClient(cfg.Sheets.SpreadsheetID, cfg.Sheets.Users)
+	userStore := datastores.NewInMemoryUserStore(userGoogleSheetClient, cfg.Sheets.RefreshInterval)
+
+	deletionGoogleSheetClient := sheet_clients.NewDeletionSheetClient(cfg.Sheets.SpreadsheetID, cfg.Sheets.Deletions)
+	deletionRequestStore := datastores.NewInMemoryDeletionRequestStore(deletionGoogleSheetClient, cfg.Sheets.RefreshInterval)
+
+	identityGoogleSheetClient := sheet_clients.NewIdentitySheetClient(cfg.Sheets.SpreadsheetID, cfg.Sheets.Identities)
+	identityStore := datastores.NewInMemoryIdentityStore(identityGoogleSheetClient, cfg.Sheets.RefreshInterval)
+
+	revocationGoogleSheetClient := sheet_clients.NewRevocationSheetClient(cfg.Sheets.SpreadsheetID, cfg.Sheets.Revocations)
+	revocationStore := datastores.NewInMemoryRevocationStore(revocationGoogleSheetClient, cfg.Sheets.RefreshInterval)
+
+	auth := pkg.NewAuthenticator(pkg.AuthOptions{
+		JWTSecret:          cfg.Auth.JWTSecret,
+		PreviousJWTSecrets: cfg.Auth.PreviousJWTSecrets,
+		AdminUserIDs:       cfg.Auth.AdminUserIDs,
+		WeChatAppID:        cfg.Auth.WeChatAppID,
+		WeChatAppSecret:    cfg.Auth.WeChatAppSecret,
+		OIDCClientID:       cfg.Auth.OIDCClientID,
+		OIDCClientSecret:   cfg.Auth.OIDCClientSecret,
+		OIDCTokenURL:       cfg.Auth.OIDCTokenURL,
+		OIDCUserinfoURL:    cfg.Auth.OIDCUserinfoURL,
+	})
+	auth.SetRevocationList(revocationStore)
+	if mailer := newMailer(cfg.Auth); mailer != nil {
+		auth.RegisterProvider(pkg.NewEmailProvider(identityStore.GetPasswordHash, mailer, cfg.Auth.MagicLinkURL))
+	} else {
+		logrus.Warn("[Auth] No mailer configured, email login is disabled")
+	}
 
 	server := usecases.InitLyskServer(
 		orbitRecordStore,
@@ -39,17 +87,48 @@ func main() {
 		championshipsGoogleSheetClient,
 		userStore,
 		userGoogleSheetClient,
-		pkg.NewAuthenticator(),
+		deletionRequestStore,
+		deletionGoogleSheetClient,
+		identityStore,
+		identityGoogleSheetClient,
+		auth,
+		pkg.NewPseudonymizer(cfg.Auth.PseudonymSecret),
+		cpEstimator,
 	)
 
-	r := gin.Default()
+	server.SetRevocationStore(revocationStore)
+	server.SetMaxMissedRefreshes(cfg.Server.ReadyMaxMissedRefreshes)
+	server.SetGameContentFile(cfg.Server.GameContentFile)
+	go server.RunDeletionWorker(time.Minute)
+	go server.RunThresholdWorker(5 * time.Minute)
+
+	limiter := pkg.NewRateLimiter(map[pkg.RouteClass]pkg.RateLimit{
+		pkg.RouteRead:    cfg.RateLimits.Read,
+		pkg.RouteAnalyze: cfg.RateLimits.Analyze,
+		pkg.RouteWrite:   cfg.RateLimits.Write,
+	})
+	server.SetRateLimiter(limiter)
+
+	cache := pkg.NewResponseCache(cfg.Server.ResponseCacheMaxAge)
+	orbitRecordStore.Subscribe(cache.Invalidate)
+	championshipsRecordStore.Subscribe(cache.Invalidate)
+
+	go func() {
+		if err := server.ServeGRPC(cfg.Server.GRPCPort); err != nil {
+			logrus.Fatalf("[GRPC] %v", err)
+		}
+	}()
 
-	r.Use(pkg.TimeoutMiddleware(5 * time.Second))
+	r := gin.Default()
+	if err := pkg.TrustProxies(r, cfg.Server.TrustedPlatform, cfg.Server.TrustedProxies); err != nil {
+		logrus.Fatalf("[Config] %v", err)
+	}
 
-	allowOrigins := []string{"*"}
+	r.Use(pkg.TracingMiddleware(), pkg.MetricsMiddleware())
+	r.Use(pkg.TimeoutMiddleware(cfg.Server.RequestTimeout))
 
 	r.Use(cors.New(cors.Config{
-		AllowOrigins:     allowOrigins,
+		AllowOrigins:     cfg.Server.AllowOrigins,
 		AllowMethods:     []string{"GET", "POST", "PUT", "DELETE", "OPTIONS"},
 		AllowHeaders:     []string{"Origin", "Content-Type", "Authorization"},
 		ExposeHeaders:    []string{"Content-Length"},
@@ -58,10 +137,23 @@ func main() {
 	}))
 
 	r.GET("/ping", server.Ping)
-	r.POST("/login", server.Login)
+	r.GET("/healthz", server.Healthz)
+	r.GET("/readyz", server.Readyz)
+	r.GET("/metrics", pkg.MetricsHandler())
+
+	login := r.Group("/", limiter.Middleware(pkg.RouteWrite))
+	{
+		login.POST("/login", server.Login)
+
+		login.POST("/auth/login", server.ProviderLogin)
+		login.POST("/auth/register", server.RegisterEmail)
+		login.POST("/auth/magic-link", server.SendMagicLink)
+		login.POST("/auth/refresh", server.RefreshToken)
+		login.POST("/auth/logout", server.Logout)
+	}
 
 	authRequired := r.Group("/")
-	authRequired.Use(server.AuthMiddleware())
+	authRequired.Use(server.RequireAuth(), limiter.ByMethod())
 	{
 		authRequired.POST("/orbit-record", server.ProcessOrbitRecord)
 		authRequired.PUT("/orbit-record/:id", server.UpdateOrbitRecord)
@@ -80,23 +172,65 @@ func main() {
 		authRequired.POST("/user", server.CreateUser)
 		authRequired.GET("/user", server.GetUser)
 		authRequired.PUT("/user", server.UpdateUser)
+		authRequired.PUT("/user/privacy", server.UpdatePrivacySettings)
 
 		authRequired.GET("/user-news", server.GetUserNews)
+
+		authRequired.POST("/auth/link", server.LinkIdentity)
+		authRequired.GET("/auth/identities", server.GetIdentities)
+
+		authRequired.POST("/account/deletion", server.RequestAccountDeletion)
+		authRequired.GET("/account/deletion", server.GetAccountDeletion)
+
+		authRequired.GET("/admin/deletion-requests", server.GetDeletionRequests)
+		authRequired.POST("/admin/users/:id/ban", server.BanUser)
+		authRequired.DELETE("/admin/users/:id/ban", server.UnbanUser)
+
+		authRequired.GET("/admin/estimator/compare", server.CompareEstimatorVersions)
+		authRequired.POST("/admin/estimator/migrate", server.MigrateEstimatorVersion)
+		authRequired.POST("/admin/game-content/reload", server.ReloadGameContent)
 	}
-	r.POST("/analyze", server.AnalyzeCombatPower)
-	r.GET("/level-suggestion", server.GetLevelSuggestion)
-	r.GET("/min-combat-power", server.GetMinCombatPower)
+	r.POST("/analyze", server.AuthMiddleware(), limiter.Middleware(pkg.RouteAnalyze), server.AnalyzeCombatPower)
 
-	r.GET("/orbit-records", server.GetOrbitRecords)
-	r.GET("/championships-records", server.GetChampionshipsRecords)
+	public := r.Group("/", limiter.Middleware(pkg.RouteRead))
+	{
+		public.GET("/level-suggestion", cache.Middleware(), server.GetLevelSuggestion)
+		public.GET("/min-combat-power", cache.Middleware(), server.GetMinCombatPower)
+		public.GET("/weapons", server.GetWeapons)
+		public.GET("/game-content", server.GetGameContent)
+		public.GET("/enums", server.GetEnums)
 
-	r.GET("/latest-orbit-records", server.GetLatestOrbitRecords)
-	r.GET("/latest-championships-records", server.GetLatestChampionshipsRecords)
+		public.GET("/orbit-records", server.GetOrbitRecords)
+		public.GET("/championships-records", server.GetChampionshipsRecords)
 
-	r.GET("/ranking", server.GetRanking)
-	r.GET("/news", server.GetNews)
+		public.GET("/latest-orbit-records", server.GetLatestOrbitRecords)
+		public.GET("/latest-championships-records", server.GetLatestChampionshipsRecords)
 
-	r.Run(":8080")
+		public.GET("/ranking", server.GetRanking)
+		public.GET("/news", cache.Middleware(), server.GetNews)
+	}
+	r.GET("/leaderboard", server.AuthMiddleware(), limiter.Middleware(pkg.RouteRead), server.GetLeaderboard)
+
+	server.RegisterV2(r)
+
+	r.Run(fmt.Sprintf(":%d", cfg.Server.HTTPPort))
+}
+
+// newMailer is the mailer of the magic links, or nil when none is configured. The configuration
+// only allows the log mailer in development.
+func newMailer(auth config.Auth) pkg.Mailer {
+	switch auth.Mailer {
+	case "smtp":
+		return pkg.SMTPMailer{
+			Addr:     auth.SMTPAddr,
+			Username: auth.SMTPUsername,
+			Password: auth.SMTPPassword,
+			From:     auth.MailFrom,
+		}
+	case "log":
+		return pkg.LogMailer{}
+	}
+	return nil
 }
 
 func isLocal() bool {